@@ -0,0 +1,109 @@
+// Command jpm-ad-settings is a one-off data script for the move of the JPM
+// report to report definitions. The old report read the ad spend of all of
+// its locations from one Google Ads and one Meta account; this points the
+// locations of the JPM definition that have no ad settings yet at those
+// accounts, through the first connection of their profile. A profile
+// without a Meta connection gets one from the legacy meta_token setting; a
+// profile without a Google Ads connection has to connect one first.
+//
+// Run it once per environment after the migration created the definition:
+//
+//	go run ./cmd/jpm-ad-settings
+//
+// It is idempotent: locations that already have settings are left alone.
+package main
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"errors"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// the ad accounts the JPM report used to be hard-coded to
+const (
+	googleAdsCustomerID = "3082004096"
+	metaAdAccountID     = "act_552470297607717"
+)
+
+func main() {
+	var defs []models.ReportDefinition
+	err := db.DB.Where("name = ?", "JPM").Find(&defs).Error
+	if err != nil {
+		log.Fatalf("load JPM report definitions: %v", err)
+	}
+	if len(defs) == 0 {
+		log.Fatal("no JPM report definition found")
+	}
+
+	for _, def := range defs {
+		var locations []models.Location
+		err = db.DB.Where("profile_id = ? AND id IN ?", def.ProfileId, []string(def.LocationIds)).Find(&locations).Error
+		if err != nil {
+			log.Fatalf("load locations of report %d: %v", def.ID, err)
+		}
+		for _, loc := range locations {
+			if err := seedLocation(loc); err != nil {
+				log.Fatalf("seed ad settings of %s: %v", loc.Name, err)
+			}
+			log.Printf("done with %s (%s)", loc.Name, loc.Id)
+		}
+	}
+}
+
+func seedLocation(loc models.Location) error {
+	googleConn := models.GoogleAdsConnection{}
+	err := db.DB.Where("profile_id = ? AND deleted_at IS NULL", loc.ProfileID).Order("id").First(&googleConn).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("profile %d has no Google Ads connection, skipping the Google Ads setting of %s", loc.ProfileID, loc.Name)
+	} else if err != nil {
+		return err
+	} else {
+		setting := models.GoogleAdsLocationSetting{LocationId: loc.Id, ProfileID: loc.ProfileID}
+		err = db.DB.Where(setting).Attrs(models.GoogleAdsLocationSetting{
+			ConnectionID:   googleConn.ID,
+			ConnectionName: googleConn.DisplayName,
+			CustomerID:     googleAdsCustomerID,
+		}).FirstOrCreate(&setting).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	metaConn, err := metaConnection(loc.ProfileID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("profile %d has no Meta connection nor meta_token, skipping the Meta setting of %s", loc.ProfileID, loc.Name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	setting := models.MetaLocationSetting{LocationId: loc.Id, ProfileID: loc.ProfileID}
+	return db.DB.Where(setting).Attrs(models.MetaLocationSetting{
+		ConnectionID:   metaConn.ID,
+		ConnectionName: metaConn.DisplayName,
+		AdAccountID:    metaAdAccountID,
+	}).FirstOrCreate(&setting).Error
+}
+
+// metaConnection returns the first Meta connection of the profile, creating
+// it from the legacy meta_token setting when there is none
+func metaConnection(profileID uint) (models.MetaConnection, error) {
+	conn := models.MetaConnection{}
+	err := db.DB.Where("profile_id = ? AND deleted_at IS NULL", profileID).Order("id").First(&conn).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return conn, err
+	}
+
+	token := models.Setting{}
+	err = db.DB.First(&token, "key = ?", "meta_token").Error
+	if err != nil {
+		return conn, err
+	}
+
+	// a zero expiry is a token that doesn't expire
+	conn = models.MetaConnection{ProfileID: profileID, DisplayName: "JPM", AccessToken: token.Value}
+	return conn, db.DB.Create(&conn).Error
+}
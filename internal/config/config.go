@@ -38,16 +38,24 @@ type (
 		Token        string
 		RefreshToken string
 
-		// Google Ads OAuth (new flow; jpmReport still uses legacy service-account path)
+		// Google Ads OAuth
 		GoogleAdsClientId     string
 		GoogleAdsClientSecret string
 		GoogleAdsRedirectUrl  string
 
+		// Meta (Facebook) Ads OAuth
+		MetaAppId       string
+		MetaAppSecret   string
+		MetaRedirectUrl string
+
 		OpenAIAPIKey         string
 		OpenAIInternalAPIKey string
 		OpenAIBaseURL        string
 		OpenAIOrganization   string
 		OpenAIProject        string
+		// AI provider: openai (default), openai-compatible for a chat
+		// completions API at OpenAIBaseURL, or fake for offline runs
+		AIProvider string
 
 		// Internal Assistant IDs (four agents)
 		InternalOrchestratorID string // Routes requests to appropriate agent
@@ -62,6 +70,29 @@ type (
 		MCPURL            string
 
 		CRAgencyAPI string
+
+		// Deprecated: token of the old /jpm/report route, kept until its
+		// consumer moves to /reports/funnel. The route is off when empty.
+		JpmReportToken string
+
+		// "keyId:base64key" entries for the credential vault, active key first.
+		// VAULT_MASTER_KEYS env var takes precedence.
+		VaultMasterKeys []string
+
+		// REST API auth. JWT_SECRET env var takes precedence over JWTSecret.
+		JWTSecret string
+		// Deprecated: accept HTTP Basic credentials besides bearer tokens
+		AllowBasicAuth bool
+
+		Smtp Smtp
+	}
+
+	Smtp struct {
+		Host     string
+		Port     int
+		Username string
+		Password string
+		From     string
 	}
 
 	RingCentral struct {
@@ -0,0 +1,5 @@
+package db
+
+// the locations the JPM report used to be hard-coded to. Their shared ad
+// accounts are set up by the cmd/jpm-ad-settings script.
+var jpmLocationNames = []string{"Young Medical Spa", "Young Medical Spa - Lansdale", "Young Medical Spa - Wilkes-Barre/Scranton"}
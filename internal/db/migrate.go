@@ -80,26 +80,152 @@ func Migrate() {
 		panic(err)
 	}
 
+	hadNormalized := DB.Migrator().HasColumn(&models.Person{}, "email_normalized")
 	err = DB.AutoMigrate(
 		&models.Person{},
 		&models.AttributionFlow{},
 		&models.StageHit{},
+		&models.Touchpoint{},
 	)
 	if err != nil {
 		panic(err)
 	}
 
+	// people from before identity resolution are matched like new ones
+	if !hadNormalized {
+		err = DB.Exec(`UPDATE people SET
+			email_normalized = LOWER(TRIM(email)),
+			phone_normalized = CASE
+				WHEN LENGTH(REGEXP_REPLACE(phone, '[^0-9]', '', 'g')) = 10 THEN '1' || REGEXP_REPLACE(phone, '[^0-9]', '', 'g')
+				ELSE REGEXP_REPLACE(phone, '[^0-9]', '', 'g')
+			END`).Error
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	err = DB.AutoMigrate(&models.Profile{}, &models.User{})
 	if err != nil {
 		panic(err)
 	}
 
+	// RBAC
+	hadMembers := DB.Migrator().HasTable(&models.ProfileMember{})
+	err = DB.AutoMigrate(&models.ProfileMember{}, &models.ProfileMemberLocation{}, &models.ProfileInvite{})
+	if err != nil {
+		panic(err)
+	}
+
+	// users from before RBAC keep full access to their profile
+	if !hadMembers {
+		err = DB.Exec(`INSERT INTO profile_members (profile_id, user_id, role, all_locations, created_at, updated_at)
+			SELECT u.profile_id, u.id, CASE WHEN p.owner_id = u.id THEN 'owner' ELSE 'admin' END, true, now(), now()
+			FROM users u JOIN profiles p ON p.id = u.profile_id
+			WHERE u.deleted_at IS NULL`).Error
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	err = DB.AutoMigrate(&models.GoogleAdsConnection{}, &models.GoogleAdsLocationSetting{})
 	if err != nil {
 		panic(err)
 	}
 
-	err = DB.AutoMigrate(&models.OpenAIAssistant{}, &models.InternalAssistantThread{}, &models.OpenAIModelPricing{}, &models.OpenAIUsageDaily{})
+	err = DB.AutoMigrate(&models.MetaConnection{}, &models.MetaLocationSetting{})
+	if err != nil {
+		panic(err)
+	}
+
+	// Ad spend
+	err = DB.AutoMigrate(&models.AdSpendDaily{}, &models.AdSpendSync{})
+	if err != nil {
+		panic(err)
+	}
+
+	// Funnel reports
+	hadReports := DB.Migrator().HasTable(&models.ReportDefinition{})
+	err = DB.AutoMigrate(&models.ReportDefinition{})
+	if err != nil {
+		panic(err)
+	}
+
+	// the JPM report used to be hard-coded to these locations
+	if !hadReports {
+		err = DB.Exec(`INSERT INTO report_definitions (profile_id, name, location_ids, group_by, google_ads_source, meta_source, created_at, updated_at)
+			SELECT profile_id, 'JPM', jsonb_agg(id ORDER BY name), 'location', 'Paid Search', 'Facebook paid', now(), now()
+			FROM locations
+			WHERE name IN ?
+			GROUP BY profile_id`, jpmLocationNames).Error
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// Report subscriptions
+	err = DB.AutoMigrate(&models.ReportSubscription{}, &models.ReportDelivery{})
+	if err != nil {
+		panic(err)
+	}
+
+	// No-show report
+	hadInvoiceStatuses := DB.Migrator().HasTable(&models.ZenotiInvoiceStatus{})
+	err = DB.AutoMigrate(&models.ZenotiAppointment{}, &models.ZenotiInvoiceStatus{})
+	if err != nil {
+		panic(err)
+	}
+
+	// status markers of unsynced invoices used to be appointment rows
+	if !hadInvoiceStatuses {
+		err = DB.Exec(`INSERT INTO zenoti_invoice_statuses (invoice_id, status, missed_at, created_at, updated_at)
+			SELECT invoice_id, status, missed_at, created_at, updated_at
+			FROM zenoti_appointments
+			WHERE appointment_id LIKE 'invoice:%' AND deleted_at IS NULL
+			ON CONFLICT DO NOTHING`).Error
+		if err != nil {
+			panic(err)
+		}
+		err = DB.Unscoped().Where("appointment_id LIKE 'invoice:%'").Delete(&models.ZenotiAppointment{}).Error
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// assistants no longer require an OpenAI assistant id, drop its unique index
+	if DB.Migrator().HasIndex(&models.OpenAIAssistant{}, "idx_open_ai_assistants_assistant_id") {
+		err = DB.Migrator().DropIndex(&models.OpenAIAssistant{}, "idx_open_ai_assistants_assistant_id")
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// model pricing is keyed by provider and model
+	if DB.Migrator().HasTable(&models.OpenAIModelPricing{}) && !DB.Migrator().HasColumn(&models.OpenAIModelPricing{}, "provider") {
+		err = DB.Exec(`ALTER TABLE open_ai_model_pricings ADD COLUMN provider text NOT NULL DEFAULT 'openai'`).Error
+		if err != nil {
+			panic(err)
+		}
+		err = DB.Exec(`ALTER TABLE open_ai_model_pricings DROP CONSTRAINT open_ai_model_pricings_pkey, ADD PRIMARY KEY (provider, model)`).Error
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// daily usage is keyed by provider too, so models of the same name don't
+	// add up across providers
+	if DB.Migrator().HasTable(&models.OpenAIUsageDaily{}) && !DB.Migrator().HasColumn(&models.OpenAIUsageDaily{}, "provider") {
+		err = DB.Exec(`ALTER TABLE open_ai_usage_dailies ADD COLUMN provider text NOT NULL DEFAULT 'openai'`).Error
+		if err != nil {
+			panic(err)
+		}
+		err = DB.Exec(`DROP INDEX IF EXISTS idx_openai_usage_profile_model_date`).Error
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	err = DB.AutoMigrate(&models.OpenAIAssistant{}, &models.InternalAssistantThread{}, &models.InternalAssistantMessage{}, &models.OpenAIModelPricing{}, &models.OpenAIUsageDaily{},
+		&models.AIUsageEvent{}, &models.AIBudget{}, &models.AIBudgetAlert{})
 	if err != nil {
 		panic(err)
 	}
@@ -118,11 +244,39 @@ func Migrate() {
 	err = DB.AutoMigrate(
 		&models.MCPApiKey{},
 		&models.MCPApiKeyLocation{},
+		&models.MCPToolUsageDaily{},
+		&models.MCPOAuthClient{},
+		&models.MCPOAuthAuthorization{},
+		&models.MCPOAuthRefreshToken{},
 	)
 	if err != nil {
 		panic(err)
 	}
 
+	// Auth
+	err = DB.AutoMigrate(&models.RefreshToken{}, &models.PasswordResetToken{})
+	if err != nil {
+		panic(err)
+	}
+
+	// Calendar sync
+	err = DB.AutoMigrate(&models.CalendarSyncEntry{}, &models.CalendarSyncCursor{}, &models.CalendarSyncCanceledInvoice{})
+	if err != nil {
+		panic(err)
+	}
+
+	// Audit log
+	err = DB.AutoMigrate(&models.AuditEvent{})
+	if err != nil {
+		panic(err)
+	}
+
+	// Encrypt integration secrets at rest
+	err = encryptSecrets()
+	if err != nil {
+		panic(err)
+	}
+
 	defContact := models.Contact{
 		LocationId:    "0",
 		ContactId:     "0",
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AdNetworkGoogle = "google"
+	AdNetworkMeta   = "meta"
+
+	// ExpenseOther tags manual expenses that aren't ad spend
+	ExpenseOther = "other"
+)
+
+type (
+	// AdSpendDaily is the spend, clicks and impressions of an ad on a day.
+	// Spend not attributed to an ad is kept on the campaign with empty ad
+	// set and ad ids. An account shared by several locations is stored as
+	// an even share under each of them.
+	AdSpendDaily struct {
+		ProfileId  uint      `gorm:"index"`
+		LocationId string    `gorm:"index:idx_ad_spend_daily_row,unique;index:idx_ad_spend_daily_loc_date,priority:1"`
+		Network    string    `gorm:"index:idx_ad_spend_daily_row,unique"` // AdNetworkGoogle or AdNetworkMeta
+		AccountId  string    `gorm:"index:idx_ad_spend_daily_row,unique"` // google customer id or meta ad account id
+		Date       time.Time `gorm:"type:date;index:idx_ad_spend_daily_row,unique;index:idx_ad_spend_daily_loc_date,priority:2"`
+
+		CampaignId   string `gorm:"index:idx_ad_spend_daily_row,unique"`
+		CampaignName string
+		AdSetId      string `gorm:"index:idx_ad_spend_daily_row,unique"` // google ad group or meta ad set
+		AdSetName    string
+		AdId         string `gorm:"index:idx_ad_spend_daily_row,unique"`
+		AdName       string
+
+		Spend       float64
+		Clicks      int64
+		Impressions int64
+		Currency    string
+
+		gorm.Model
+	}
+
+	// AdSpendSync tracks the ingestion of a location's ad account
+	AdSpendSync struct {
+		LocationId    string `gorm:"index:idx_ad_spend_sync,unique"`
+		Network       string `gorm:"index:idx_ad_spend_sync,unique"`
+		ProfileId     uint
+		AccountId     string
+		SyncedFrom    time.Time `gorm:"type:date"` // first day ingested
+		SyncedThrough time.Time `gorm:"type:date"` // last day ingested
+		LastRunAt     time.Time
+		LastError     string
+
+		gorm.Model
+	}
+)
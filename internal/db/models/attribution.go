@@ -8,14 +8,20 @@ import (
 )
 
 type (
+	// Person is one individual across GHL, Zenoti and Cerbo, matched by
+	// normalized email or phone
 	Person struct {
-		ProfileId  uint
+		ProfileId  uint `gorm:"index"`
 		LocationId string
 
 		FirstName string
 		LastName  string
 		Email     string
 		Phone     string
+		Source    string // lead source, e.g. "google", "facebook"
+
+		EmailNormalized string `gorm:"index"`
+		PhoneNormalized string `gorm:"index"`
 
 		ContactId     string
 		OpportunityId string
@@ -35,9 +41,10 @@ type (
 	}
 
 	StageHit struct {
-		ProfileId         uint
-		PersonId          string
-		AttributionFlowId uint
+		ProfileId         uint   `gorm:"index"`
+		LocationId        string `gorm:"index"`
+		PersonId          string `gorm:"index"`
+		AttributionFlowId uint   `gorm:"index"`
 		Stage             string
 		OccurredAt        time.Time
 		Revenue           float64
@@ -48,4 +55,28 @@ type (
 
 		gorm.Model
 	}
+
+	// Touchpoint is a tracked interaction of a person with a lead source,
+	// e.g. the ad click a GHL contact was created from
+	Touchpoint struct {
+		ProfileId  uint   `gorm:"index"`
+		LocationId string `gorm:"index"`
+		PersonId   uint   `gorm:"index"`
+		OccurredAt time.Time
+
+		Source   string // e.g. "google", "facebook", "direct"
+		Medium   string // e.g. "cpc", "organic"
+		Campaign string
+		Content  string
+		Term     string
+		Gclid    string
+		Fbclid   string
+		Url      string
+		Referrer string
+
+		RefSystem string // e.g. "ghl"
+		RefId     string // e.g. ghl contact id
+
+		gorm.Model
+	}
 )
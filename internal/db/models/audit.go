@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+type AuditAction string
+
+const (
+	AuditCreate AuditAction = "create"
+	AuditUpdate AuditAction = "update"
+	AuditDelete AuditAction = "delete"
+)
+
+// AuditEvent records a change made through the API. Before and After hold
+// the entity as it was serialized to clients, so secrets stay redacted;
+// Diff maps each changed top-level field to its before and after values.
+type AuditEvent struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	ProfileID  uint           `gorm:"index" json:"profileId"`
+	ActorID    uint           `gorm:"index" json:"actorId"`
+	ActorEmail string         `json:"actorEmail"`
+	Action     AuditAction    `gorm:"type:text;not null;index" json:"action"`
+	EntityType string         `gorm:"not null;index:idx_audit_entity" json:"entityType"`
+	EntityID   string         `gorm:"index:idx_audit_entity" json:"entityId"`
+	LocationID string         `gorm:"index" json:"locationId,omitempty"`
+	Before     datatypes.JSON `gorm:"type:jsonb" json:"before,omitempty"`
+	After      datatypes.JSON `gorm:"type:jsonb" json:"after,omitempty"`
+	Diff       datatypes.JSON `gorm:"type:jsonb" json:"diff,omitempty"`
+	IP         string         `json:"ip"`
+	UserAgent  string         `json:"userAgent"`
+	CreatedAt  time.Time      `gorm:"index" json:"createdAt"`
+}
@@ -1,6 +1,10 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type (
 	Profile struct {
@@ -13,11 +17,32 @@ type (
 	}
 
 	User struct {
-		Email     string
-		Password  string
-		Profile   Profile
-		ProfileID uint
-		Profiles  []Profile `gorm:"foreignKey:OwnerID"`
+		Email             string
+		Password          string     `json:"-"` // bcrypt hash; legacy rows hold plaintext until the next login
+		PasswordChangedAt *time.Time `json:"-"`
+		Profile           Profile
+		ProfileID         uint
+		Profiles          []Profile `gorm:"foreignKey:OwnerID"`
+		gorm.Model
+	}
+
+	// RefreshToken is a long-lived opaque token exchanged for access tokens.
+	// Only the SHA256 hash is stored; every refresh rotates the token.
+	RefreshToken struct {
+		TokenHash string `gorm:"uniqueIndex"`
+		UserID    uint   `gorm:"index"`
+		ExpiresAt time.Time
+		RevokedAt *time.Time
+		UserAgent string
+		IP        string
+		gorm.Model
+	}
+
+	PasswordResetToken struct {
+		TokenHash string `gorm:"uniqueIndex"`
+		UserID    uint   `gorm:"index"`
+		ExpiresAt time.Time
+		UsedAt    *time.Time
 		gorm.Model
 	}
 )
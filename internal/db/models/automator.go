@@ -62,8 +62,9 @@ type Automation struct {
 	CreatedAt time.Time `json:"createdAt" gorm:"not null"`
 	UpdatedAt time.Time `json:"updatedAt" gorm:"not null"`
 
-	CreatorId uint `json:"-"`
-	UpdaterId uint `json:"-"`
+	// Set by the API from the authenticated user, never from the payload
+	CreatorId uint `json:"creatorId"`
+	UpdaterId uint `json:"updaterId"`
 	Creator   User `json:"-"`
 	Updater   User `json:"-"`
 
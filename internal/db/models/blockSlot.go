@@ -6,7 +6,20 @@ import (
 	"gorm.io/gorm"
 )
 
+// BlockSlotSkipGhlHooks, set on a session with tx.Set, makes the hooks below
+// leave GHL alone. The calendar sync uses it to write GHL in rate-limited
+// batches itself instead of one synchronous call per row.
+const BlockSlotSkipGhlHooks = "block_slot:skip_ghl"
+
+func skipGhlHooks(tx *gorm.DB) bool {
+	_, ok := tx.Get(BlockSlotSkipGhlHooks)
+	return ok
+}
+
 func (b *BlockSlot) BeforeCreate(tx *gorm.DB) (err error) {
+	if skipGhlHooks(tx) {
+		return nil
+	}
 
 	client, err := Svc.NewClientFromId(b.LocationId)
 	if err != nil {
@@ -27,6 +40,9 @@ func (b *BlockSlot) BeforeCreate(tx *gorm.DB) (err error) {
 }
 
 func (b *BlockSlot) BeforeUpdate(tx *gorm.DB) (err error) {
+	if skipGhlHooks(tx) {
+		return nil
+	}
 
 	client, err := Svc.NewClientFromId(b.LocationId)
 	if err != nil {
@@ -46,6 +62,9 @@ func (b *BlockSlot) BeforeUpdate(tx *gorm.DB) (err error) {
 }
 
 func (b *BlockSlot) BeforeDelete(tx *gorm.DB) (err error) {
+	if skipGhlHooks(tx) {
+		return nil
+	}
 
 	client, err := Svc.NewClientFromId(b.LocationId)
 	if err != nil {
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+const (
+	CalendarDefaultWorkingHoursFrom = "09:00"
+	CalendarDefaultWorkingHoursTo   = "18:00"
+	CalendarDefaultSlotMinutes      = 60
+)
+
+// TimeLocation returns the calendar's time zone, UTC if none is set.
+func (c *Calendar) TimeLocation() (*time.Location, error) {
+	if c.TimeZone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(c.TimeZone)
+}
+
+// SlotDuration returns the length of a bookable slot on the calendar.
+func (c *Calendar) SlotDuration() time.Duration {
+	if c.SlotMinutes <= 0 {
+		return CalendarDefaultSlotMinutes * time.Minute
+	}
+	return time.Duration(c.SlotMinutes) * time.Minute
+}
+
+// WorkingHours returns the start and end of the working day containing day,
+// in the calendar's time zone.
+func (c *Calendar) WorkingHours(day time.Time) (time.Time, time.Time, error) {
+	tz, err := c.TimeLocation()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	from, err := ParseWorkingHour(c.WorkingHoursFrom, CalendarDefaultWorkingHoursFrom)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := ParseWorkingHour(c.WorkingHoursTo, CalendarDefaultWorkingHoursTo)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	y, m, d := day.Date()
+	start := time.Date(y, m, d, from.Hour(), from.Minute(), 0, 0, tz)
+	end := time.Date(y, m, d, to.Hour(), to.Minute(), 0, 0, tz)
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("working hours %s-%s are empty", from.Format("15:04"), to.Format("15:04"))
+	}
+	return start, end, nil
+}
+
+// ParseWorkingHour parses a "15:04" clock value, falling back to def when empty.
+func ParseWorkingHour(value, def string) (time.Time, error) {
+	if value == "" {
+		value = def
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return t, fmt.Errorf("invalid working hour %q, expected HH:MM", value)
+	}
+	return t, nil
+}
+
+// GenerateWidgetKey returns a new public key for the chatly booking widget.
+func GenerateWidgetKey() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "sbw_" + hex.EncodeToString(bytes), nil
+}
+
+// CheckWidgetKey reports whether key matches the location's widget key.
+// Locations without a key never match.
+func (l *Location) CheckWidgetKey(key string) bool {
+	if l.WidgetKey == "" || key == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(l.WidgetKey), []byte(key)) == 1
+}
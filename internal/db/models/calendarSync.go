@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	CalendarSyncOriginGhl    = "ghl"
+	CalendarSyncOriginZenoti = "zenoti"
+	CalendarSyncOriginChatly = "chatly"
+
+	CalendarSyncStatusBooked   = "booked"
+	CalendarSyncStatusCanceled = "canceled"
+	CalendarSyncStatusConflict = "conflict"
+	CalendarSyncStatusError    = "error"
+	// the old Zenoti booking is being canceled to book the new time
+	CalendarSyncStatusRescheduling = "rescheduling"
+)
+
+// CalendarSyncEntry is the ledger row linking a GHL calendar appointment to
+// the Zenoti booking created for it. StartTime/EndTime hold the last synced
+// state, so a change on either side can be told apart from a change on both.
+type CalendarSyncEntry struct {
+	LocationId  string `gorm:"index"`
+	CalendarId  string `gorm:"index"`
+	TherapistId string
+
+	GhlEventId   string `gorm:"index"`
+	GhlContactId string
+
+	ZenotiAppointmentId string `gorm:"index"`
+	ZenotiInvoiceId     string `gorm:"index"`
+	ZenotiGuestId       string
+
+	Origin    string
+	Status    string
+	StartTime time.Time
+	EndTime   time.Time
+	LastError string
+	SyncedAt  time.Time
+
+	gorm.Model
+}
+
+// CalendarSyncCanceledInvoice is a Zenoti invoice the calendar sync canceled
+// itself to move a booking. Its cancellation isn't the guest's, so its
+// webhook cancels nothing and it doesn't count as a missed appointment.
+type CalendarSyncCanceledInvoice struct {
+	InvoiceId  string `gorm:"primaryKey"`
+	LocationId string `gorm:"index"`
+	EntryId    uint
+	CreatedAt  time.Time
+}
+
+// CalendarSyncCursor is the per-location state of the incremental calendar
+// sync. Zenoti webhooks widen the dirty window; the sync job consumes it.
+type CalendarSyncCursor struct {
+	LocationId     string `gorm:"primaryKey"`
+	DirtyFrom      *time.Time
+	DirtyTo        *time.Time
+	LastSyncedAt   time.Time
+	LastFullSyncAt time.Time
+	LastError      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (e *CalendarSyncEntry) IsActive() bool {
+	return e.Status == CalendarSyncStatusBooked
+}
+
+func CalendarSyncEntryByGhlEvent(eventId string) (CalendarSyncEntry, error) {
+	entry := CalendarSyncEntry{}
+	err := DB.Where("ghl_event_id = ?", eventId).Order("id desc").First(&entry).Error
+	return entry, err
+}
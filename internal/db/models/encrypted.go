@@ -0,0 +1,96 @@
+package models
+
+import (
+	"client-runaway-zenoti/internal/vault"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+// EncryptedSerializer keeps string columns encrypted at rest through the
+// credential vault. Use it with `gorm:"serializer:encrypted"`. Legacy
+// plaintext values are read as is and get encrypted on the next write or by
+// the startup migration.
+type EncryptedSerializer struct{}
+
+func init() {
+	schema.RegisterSerializer("encrypted", EncryptedSerializer{})
+}
+
+func (EncryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("encrypted serializer: unsupported value %T for %s", dbValue, field.Name)
+	}
+
+	plain, err := vault.Decrypt(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", field.Name, err)
+	}
+
+	field.ReflectValueOf(ctx, dst).SetString(plain)
+	return nil
+}
+
+func (EncryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("encrypted serializer: %s must be a string", field.Name)
+	}
+	return vault.Encrypt(value)
+}
+
+// Secrets are write-only over the API: the models below blank them when
+// marshalled, so no handler can echo them back by accident. The legacy
+// Location.ZenotiApi keeps its "***" mask, which the settings page sends back
+// to mean "unchanged".
+
+const secretMask = "***"
+
+func (z ZenotiApi) MarshalJSON() ([]byte, error) {
+	type alias ZenotiApi
+	a := alias(z)
+	a.ApiKey = ""
+	return json.Marshal(a)
+}
+
+func (c CerboApi) MarshalJSON() ([]byte, error) {
+	type alias CerboApi
+	a := alias(c)
+	a.ApiKey = ""
+	return json.Marshal(a)
+}
+
+func (t GhlTokens) MarshalJSON() ([]byte, error) {
+	type alias GhlTokens
+	a := alias(t)
+	a.AccessToken = ""
+	a.RefreshToken = ""
+	return json.Marshal(a)
+}
+
+func (g GoogleAdsConnection) MarshalJSON() ([]byte, error) {
+	type alias GoogleAdsConnection
+	a := alias(g)
+	a.AccessToken = ""
+	a.RefreshToken = ""
+	return json.Marshal(a)
+}
+
+func (l Location) MarshalJSON() ([]byte, error) {
+	type alias Location
+	a := alias(l)
+	if a.ZenotiApi != "" {
+		a.ZenotiApi = secretMask
+	}
+	return json.Marshal(a)
+}
@@ -5,15 +5,14 @@ import (
 )
 
 // GoogleAdsConnection stores OAuth tokens and metadata for a Google Ads account per profile.
-// jpmReport still uses the legacy service-account path; this model is for the new OAuth flow.
 type GoogleAdsConnection struct {
 	ID          uint `gorm:"primaryKey"`
 	ProfileID   uint
 	DisplayName string // Friendly label for UI selection
 	Email       string // Account email (if available)
 
-	AccessToken  string
-	RefreshToken string
+	AccessToken  string `gorm:"serializer:encrypted"`
+	RefreshToken string `gorm:"serializer:encrypted"`
 	TokenExpiry  time.Time
 
 	CreatedAt time.Time
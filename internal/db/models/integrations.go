@@ -9,7 +9,7 @@ import (
 type ZenotiApi struct {
 	ApiName   string
 	ProfileId uint
-	ApiKey    string
+	ApiKey    string `gorm:"serializer:encrypted"`
 	Url       string
 	gorm.Model
 }
@@ -19,7 +19,7 @@ type CerboApi struct {
 	ProfileId uint
 	Subdomain string
 	Username  string
-	ApiKey    string
+	ApiKey    string `gorm:"serializer:encrypted"`
 	gorm.Model
 }
 
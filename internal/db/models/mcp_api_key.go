@@ -6,13 +6,14 @@ import (
 	"encoding/hex"
 	"time"
 
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 // MCPApiKey represents an API key for MCP server authentication
 type MCPApiKey struct {
 	Name             string `json:"name"`
-	PlainKey         string `json:"-"` // Stored for internal use; external keys are not persisted in plain text.
+	PlainKey         string `gorm:"serializer:encrypted" json:"-"` // Stored (encrypted) for internal keys only; external keys are never persisted.
 	KeyHash          string `gorm:"uniqueIndex" json:"-"`
 	KeyPrefix        string `json:"keyPrefix"`
 	ProfileID        uint   `json:"profileId"`
@@ -22,9 +23,33 @@ type MCPApiKey struct {
 	ExpiresAt        *time.Time          `json:"expiresAt"`
 	IsActive         bool                `gorm:"default:true" json:"isActive"`
 	IsInternal       bool                `gorm:"default:false" json:"isInternal"` // Internal keys see only internal tools
+	// Automator action tools (e.g. ghl_contact_find) the key may call
+	AllowedTools datatypes.JSONSlice[string] `json:"allowedTools"`
+	// Limits enforced per key, 0 means unlimited
+	RateLimitPerMinute int `gorm:"not null;default:0" json:"rateLimitPerMinute"` // token bucket refill rate
+	RateLimitBurst     int `gorm:"not null;default:0" json:"rateLimitBurst"`     // bucket size, the per-minute rate when 0
+	DailyQuota         int `gorm:"not null;default:0" json:"dailyQuota"`         // tool calls per UTC day
+	// Set on keys issued to an MCP client through OAuth
+	OAuthClientID string `gorm:"column:oauth_client_id;index" json:"oauthClientId,omitempty"`
 	gorm.Model
 }
 
+// MCPToolUsageDaily meters the tool calls of an API key per tool and UTC day
+type MCPToolUsageDaily struct {
+	ID             uint      `gorm:"primaryKey"`
+	ProfileID      uint      `gorm:"index"`
+	MCPApiKeyID    uint      `gorm:"index:idx_mcp_usage_key_tool_date,unique"`
+	ToolName       string    `gorm:"index:idx_mcp_usage_key_tool_date,unique"`
+	UsageDate      time.Time `gorm:"index:idx_mcp_usage_key_tool_date,unique"`
+	Calls          int       `gorm:"not null;default:0"`
+	Errors         int       `gorm:"not null;default:0"`
+	Rejected       int       `gorm:"not null;default:0"` // calls refused by the rate limit or quota, not counted in Calls
+	TotalLatencyMs int64     `gorm:"not null;default:0"`
+	MaxLatencyMs   int64     `gorm:"not null;default:0"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
 // MCPApiKeyLocation is the join table for API key to location mapping
 type MCPApiKeyLocation struct {
 	MCPApiKeyID uint     `gorm:"index" json:"mcpApiKeyId"`
@@ -43,6 +68,16 @@ func (k *MCPApiKey) CanAccessLocation(locationID string) bool {
 	return false
 }
 
+// CanUseTool checks if an automator action tool is on the key's allowlist
+func (k *MCPApiKey) CanUseTool(toolName string) bool {
+	for _, t := range k.AllowedTools {
+		if t == toolName {
+			return true
+		}
+	}
+	return false
+}
+
 // GetLocationIDs returns a slice of all allowed location IDs
 func (k *MCPApiKey) GetLocationIDs() []string {
 	ids := make([]string, len(k.AllowedLocations))
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+type (
+	// MCPOAuthClient is an MCP client registered through dynamic client
+	// registration. Clients are public and authenticate with PKCE.
+	MCPOAuthClient struct {
+		ClientID     string                      `gorm:"uniqueIndex" json:"clientId"`
+		ClientName   string                      `json:"clientName"`
+		RedirectURIs datatypes.JSONSlice[string] `json:"redirectUris"`
+		gorm.Model
+	}
+
+	// MCPOAuthAuthorization tracks one run of the authorization code flow.
+	// The consent ticket is issued once the user logs in, the code once the
+	// user approves the locations. Only hashes are stored.
+	MCPOAuthAuthorization struct {
+		ConsentHash   string `gorm:"uniqueIndex"`
+		CodeHash      string `gorm:"index"`
+		ClientID      string `gorm:"index"`
+		UserID        uint   `gorm:"index"`
+		ProfileID     uint   `gorm:"index"`
+		RedirectURI   string
+		State         string
+		Scope         string
+		CodeChallenge string
+		LocationIDs   datatypes.JSONSlice[string]
+		MCPApiKeyID   uint // the key the code was exchanged for
+		ApprovedAt    *time.Time
+		UsedAt        *time.Time
+		ExpiresAt     time.Time
+		gorm.Model
+	}
+
+	// MCPOAuthRefreshToken renews the MCPApiKey an authorization issued.
+	// Only the hash is stored, tokens are rotated on every use.
+	MCPOAuthRefreshToken struct {
+		TokenHash   string `gorm:"uniqueIndex"`
+		MCPApiKeyID uint   `gorm:"index"`
+		ClientID    string `gorm:"index"`
+		Scope       string
+		ExpiresAt   time.Time
+		RevokedAt   *time.Time
+		gorm.Model
+	}
+)
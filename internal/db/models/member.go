@@ -0,0 +1,103 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+type Role string
+
+const (
+	RoleOwner   Role = "owner"
+	RoleAdmin   Role = "admin"
+	RoleBuilder Role = "builder"
+	RoleViewer  Role = "viewer"
+)
+
+var roleRank = map[Role]int{
+	RoleViewer:  1,
+	RoleBuilder: 2,
+	RoleAdmin:   3,
+	RoleOwner:   4,
+}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	return roleRank[r] > 0
+}
+
+// AtLeast reports whether r grants everything min does
+func (r Role) AtLeast(min Role) bool {
+	return roleRank[r] >= roleRank[min]
+}
+
+type (
+	// ProfileMember gives a user a role in a profile. Members with
+	// AllLocations unset only see the locations listed in Locations.
+	ProfileMember struct {
+		ProfileID    uint                    `gorm:"uniqueIndex:idx_profile_member" json:"profileId"`
+		Profile      Profile                 `json:"-"`
+		UserID       uint                    `gorm:"uniqueIndex:idx_profile_member" json:"userId"`
+		User         User                    `json:"user"`
+		Role         Role                    `gorm:"type:text;not null" json:"role"`
+		AllLocations bool                    `json:"allLocations"`
+		Locations    []ProfileMemberLocation `gorm:"foreignKey:ProfileMemberID" json:"locations"`
+		gorm.Model
+	}
+
+	ProfileMemberLocation struct {
+		ProfileMemberID uint   `gorm:"index" json:"-"`
+		LocationID      string `gorm:"index" json:"locationId"`
+		gorm.Model
+	}
+
+	// ProfileInvite lets a user join a profile. Only the token hash is stored.
+	ProfileInvite struct {
+		ProfileID    uint                        `gorm:"index" json:"profileId"`
+		Email        string                      `gorm:"index" json:"email"`
+		Role         Role                        `gorm:"type:text;not null" json:"role"`
+		AllLocations bool                        `json:"allLocations"`
+		LocationIds  datatypes.JSONSlice[string] `json:"locationIds"`
+		TokenHash    string                      `gorm:"uniqueIndex" json:"-"`
+		InvitedByID  uint                        `json:"invitedById"`
+		ExpiresAt    time.Time                   `json:"expiresAt"`
+		AcceptedAt   *time.Time                  `json:"acceptedAt"`
+		gorm.Model
+	}
+)
+
+// CanAccessLocation checks the member's location restriction
+func (m *ProfileMember) CanAccessLocation(locationID string) bool {
+	if m.AllLocations {
+		return true
+	}
+	for _, l := range m.Locations {
+		if l.LocationID == locationID {
+			return true
+		}
+	}
+	return false
+}
+
+// LocationIds returns the allowed location ids, nil meaning all locations
+func (m *ProfileMember) LocationIds() []string {
+	if m.AllLocations {
+		return nil
+	}
+	res := make([]string, 0, len(m.Locations))
+	for _, l := range m.Locations {
+		res = append(res, l.LocationID)
+	}
+	return res
+}
+
+// GetProfileMember loads the membership of a user in a profile
+func GetProfileMember(profileID, userID uint) (ProfileMember, error) {
+	m := ProfileMember{}
+	err := DB.Where("profile_id = ? AND user_id = ?", profileID, userID).
+		Preload("Locations").
+		First(&m).Error
+	return m, err
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// MetaConnection stores a long-lived Meta (Facebook) user token per profile.
+type MetaConnection struct {
+	ID          uint   `gorm:"primaryKey"`
+	ProfileID   uint   `gorm:"index"`
+	DisplayName string // Friendly label for UI selection
+	MetaUserID  string // Meta user the token belongs to
+
+	AccessToken string `gorm:"serializer:encrypted"`
+	TokenExpiry time.Time
+	// set once the profile was warned that the token is about to expire,
+	// cleared when the token is renewed
+	ExpiryAlertedAt *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time `gorm:"index"`
+}
+
+// MetaLocationSetting stores the ad account a location's Meta spend is read from
+// and the pixel its offline conversions are sent to.
+type MetaLocationSetting struct {
+	ID             uint   `gorm:"primaryKey"`
+	LocationId     string `gorm:"index:idx_meta_loc_profile,unique"`
+	ProfileID      uint   `gorm:"index:idx_meta_loc_profile,unique"`
+	ConnectionID   uint   `gorm:"index"`
+	ConnectionName string
+	AdAccountID    string // e.g. "act_1234567890"
+	AdAccountName  string
+	PixelID        string // dataset the Conversions API events go to
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      *time.Time `gorm:"index"`
+}
@@ -62,11 +62,16 @@ type (
 		Status        types.ZenotiStatus //NoShow = -2, Cancelled = -1, New = 0, Closed = 1, Checkin = 2, Confirm = 4, Break = 10, NotSpecified = 11, Available = 20, and Voided = 21
 	}
 
+	// LocationExpense is a manually set daily expense. Network is the ad
+	// network the spend went to (AdNetworkGoogle, AdNetworkMeta or
+	// ExpenseOther for spend outside them); legacy expenses without one are
+	// lump totals of all the location's ad spend.
 	LocationExpense struct {
 		ExpenseId  string `gorm:"primaryKey"`
 		LocationId string
 		Date       time.Time
 		Total      float64
+		Network    string
 	}
 
 	Location struct {
@@ -80,7 +85,7 @@ type (
 		ShowNoSaleId       string    // legacy
 		MemberId           string    // legacy
 		TrackNewLeads      bool      // legacy
-		ZenotiApi          string    // legacy
+		ZenotiApi          string    `gorm:"serializer:encrypted"` // legacy
 		ZenotiUrl          string    // legacy
 		ZenotiServiceId    string    // legacy
 		ZenotiServiceName  string    // legacy
@@ -103,6 +108,9 @@ type (
 		//Cerbo Integration
 		CerboApiObjId *uint `gorm:"default:null"`
 		CerboApiObj   CerboApi
+
+		// Public key for the chatly booking widget
+		WidgetKey string `gorm:"index"`
 	}
 
 	GhlTrigger struct {
@@ -118,8 +126,8 @@ type (
 
 	GhlTokens struct {
 		LocationId   string `gorm:"primaryKey"`
-		AccessToken  string
-		RefreshToken string
+		AccessToken  string `gorm:"serializer:encrypted"`
+		RefreshToken string `gorm:"serializer:encrypted"`
 		CreatedAt    time.Time
 		UpdatedAt    time.Time
 	}
@@ -141,6 +149,12 @@ type (
 		TherapistName string
 		LocationId    string
 		CalendarId    string
+
+		// chatly widget settings
+		WorkingHoursFrom string // "15:04" in TimeZone
+		WorkingHoursTo   string
+		TimeZone         string // IANA name, e.g. "America/Phoenix"
+		SlotMinutes      int
 	}
 
 	BlockSlot struct {
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"client-runaway-zenoti/internal/types"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ZenotiAppointment is a Zenoti appointment kept for the no-show report
+type ZenotiAppointment struct {
+	ProfileId     uint   `gorm:"index"`
+	LocationId    string `gorm:"index:idx_zenoti_appointment_loc_start,priority:1"`
+	AppointmentId string `gorm:"uniqueIndex"`
+	InvoiceId     string `gorm:"index"` // status webhooks only carry the invoice
+	GuestId       string `gorm:"index"` // lower case, as in JpmReportNewLead
+	GuestName     string
+	TherapistId   string
+	TherapistName string
+	ServiceId     string
+	ServiceName   string
+
+	StartTime    time.Time `gorm:"index:idx_zenoti_appointment_loc_start,priority:2"` // center time
+	StartTimeUtc time.Time
+	BookedAt     time.Time
+	Status       types.ZenotiStatus
+	// MissedAt is when the appointment was seen turning into a no-show or
+	// cancellation, nil when it already was on the first sync
+	MissedAt *time.Time
+
+	gorm.Model
+}
+
+// Missed reports whether the guest didn't come, cancelled or no-showed
+func (a *ZenotiAppointment) Missed() bool {
+	return a.Status == types.NoShowed || a.Status == types.Canceled
+}
+
+// ZenotiInvoiceStatus is the last status webhook of an invoice whose
+// appointments weren't synced yet, so the next webhooks of the group are
+// compared with it. It's dropped once the appointments are synced.
+type ZenotiInvoiceStatus struct {
+	InvoiceId string `gorm:"primaryKey"`
+	Status    types.ZenotiStatus
+	MissedAt  *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Missed reports whether the invoice was last seen no-showed or cancelled
+func (s *ZenotiInvoiceStatus) Missed() bool {
+	return s.Status == types.NoShowed || s.Status == types.Canceled
+}
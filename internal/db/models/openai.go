@@ -3,25 +3,51 @@ package models
 import (
 	"time"
 
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
+// OpenAIAssistant is a prompt config run through the Responses API: model,
+// instructions and tools are stored locally. AssistantID is only set for
+// assistants created with the Assistants API, their config is imported from
+// OpenAI on first use.
 type OpenAIAssistant struct {
-	AssistantID string `gorm:"not null;uniqueIndex"`
-	ProfileID   uint   `gorm:"index"`
-	Name        string `gorm:"not null"`
-	GptModel    string `gorm:"not null"`
+	AssistantID  string         `gorm:"index:idx_openai_assistant_legacy_id"`
+	ProfileID    uint           `gorm:"index"`
+	Name         string         `gorm:"not null"`
+	GptModel     string         `gorm:"not null"`
+	Instructions string         `gorm:"type:text"`
+	Tools        datatypes.JSON // Responses API tool definitions
+	Temperature  *float64
+	ImportedAt   *time.Time // when the legacy assistant config was imported
 	gorm.Model
 }
 
+// InternalAssistantThread is a conversation with an internal assistant.
+// ThreadID is a local conversation id, or an OpenAI thread id for threads
+// from before the Responses API, whose messages are imported on first use.
 type InternalAssistantThread struct {
-	ProfileID   uint   `gorm:"index:idx_internal_assistant_thread_list"`
-	AssistantID string `gorm:"index:idx_internal_assistant_thread_list"`
-	ThreadID    string `gorm:"not null;uniqueIndex"`
+	ProfileID      uint   `gorm:"index:idx_internal_assistant_thread_list"`
+	AssistantID    string `gorm:"index:idx_internal_assistant_thread_list"`
+	ThreadID       string `gorm:"not null;uniqueIndex"`
+	LastResponseID string // continues the conversation via previous_response_id
+	ImportedAt     *time.Time
 	gorm.Model
 }
 
+// InternalAssistantMessage is a message of an internal assistant thread,
+// kept for the thread history.
+type InternalAssistantMessage struct {
+	ThreadID   string `gorm:"not null;index"`
+	Role       string `gorm:"not null"`
+	Content    string `gorm:"type:text"`
+	ResponseID string
+	gorm.Model
+}
+
+// OpenAIModelPricing is the price of a model of an AI provider
 type OpenAIModelPricing struct {
+	Provider         string  `gorm:"primaryKey;default:openai"`
 	Model            string  `gorm:"primaryKey"`
 	InputCentsPer1K  float64 `gorm:"not null"`
 	OutputCentsPer1K float64 `gorm:"not null"`
@@ -31,12 +57,59 @@ type OpenAIModelPricing struct {
 
 type OpenAIUsageDaily struct {
 	ID           uint      `gorm:"primaryKey"`
-	ProfileID    uint      `gorm:"index:idx_openai_usage_profile_model_date,unique"`
-	Model        string    `gorm:"index:idx_openai_usage_profile_model_date,unique"`
-	UsageDate    time.Time `gorm:"index:idx_openai_usage_profile_model_date,unique"`
+	ProfileID    uint      `gorm:"index:idx_openai_usage_profile_provider_model_date,unique"`
+	Provider     string    `gorm:"not null;default:openai;index:idx_openai_usage_profile_provider_model_date,unique"`
+	Model        string    `gorm:"index:idx_openai_usage_profile_provider_model_date,unique"`
+	UsageDate    time.Time `gorm:"index:idx_openai_usage_profile_provider_model_date,unique"`
 	InputTokens  int       `gorm:"not null;default:0"`
 	OutputTokens int       `gorm:"not null;default:0"`
 	Credits      float64   `gorm:"not null;default:0"`
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 }
+
+// AIUsageEvent is one AI call with the profile, location, assistant and
+// automation run it was made for. Budgets are checked against these.
+type AIUsageEvent struct {
+	ID              uint      `gorm:"primaryKey"`
+	ProfileID       uint      `gorm:"index:idx_ai_usage_event_profile_time"`
+	LocationID      string    `gorm:"index"`
+	AssistantID     uint      `gorm:"index"`
+	AutomationRunID string    `gorm:"index"`
+	Provider        string    `gorm:"not null;default:openai"`
+	Model           string    `gorm:"not null"`
+	InputTokens     int       `gorm:"not null;default:0"`
+	OutputTokens    int       `gorm:"not null;default:0"`
+	Credits         float64   `gorm:"not null;default:0"`
+	CreatedAt       time.Time `gorm:"index:idx_ai_usage_event_profile_time"`
+}
+
+const (
+	AIBudgetDaily   = "daily"
+	AIBudgetMonthly = "monthly"
+)
+
+// AIBudget caps the AI credits a profile spends per UTC day or month,
+// optionally only for one location or assistant. Calls are refused once the
+// limit is reached, alerts go out at the thresholds before.
+type AIBudget struct {
+	ProfileID       uint                        `gorm:"not null;index"`
+	LocationID      string                      `gorm:"index"` // empty for all locations
+	AssistantID     uint                        `gorm:"index"` // 0 for all assistants
+	Period          string                      `gorm:"not null"`
+	LimitCredits    float64                     `gorm:"not null"`
+	AlertThresholds datatypes.JSONSlice[int]    // percent of the limit
+	AlertEmails     datatypes.JSONSlice[string] // recipients of the alerts
+	IsActive        bool                        `gorm:"not null;default:true"`
+	gorm.Model
+}
+
+// AIBudgetAlert is an alert sent for a budget, once per period and threshold
+type AIBudgetAlert struct {
+	ID          uint      `gorm:"primaryKey"`
+	BudgetID    uint      `gorm:"uniqueIndex:idx_ai_budget_alert_period"`
+	PeriodStart time.Time `gorm:"uniqueIndex:idx_ai_budget_alert_period"`
+	Threshold   int       `gorm:"uniqueIndex:idx_ai_budget_alert_period"`
+	Credits     float64
+	CreatedAt   time.Time
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	ReportGroupByLocation = "location" // a column per location plus the total
+	ReportGroupByTotal    = "total"    // only the total of all locations
+
+	ReportTypeFunnel  = "funnel"  // PDF summary of a ReportDefinition
+	ReportTypeDetails = "details" // XLSX of the leads by stage
+
+	ReportCadenceDaily   = "daily"
+	ReportCadenceWeekly  = "weekly"  // on Mondays, for the week before
+	ReportCadenceMonthly = "monthly" // on the 1st, for the month before
+
+	ReportChannelEmail    = "email"
+	ReportChannelTelegram = "telegram"
+
+	ReportDeliverySent   = "sent"
+	ReportDeliveryFailed = "failed"
+)
+
+// ReportDefinition configures a funnel report over some of a profile's
+// locations. Ad spend is read from each location's AdSpendDaily and
+// credited to the lead sources named here.
+type ReportDefinition struct {
+	ProfileId   uint `gorm:"index"`
+	Name        string
+	LocationIds datatypes.JSONSlice[string] `gorm:"type:jsonb"`
+	GroupBy     string                      // ReportGroupByLocation or ReportGroupByTotal
+
+	GoogleAdsSource string // lead source of Google Ads leads, e.g. "Paid Search"
+	MetaSource      string // lead source of Meta leads, e.g. "Facebook paid"
+
+	gorm.Model
+}
+
+type (
+	// ReportSubscription sends a report for the period just ended to its
+	// recipients on every cadence
+	ReportSubscription struct {
+		ProfileId          uint `gorm:"index"`
+		Name               string
+		ReportType         string                      // ReportTypeFunnel or ReportTypeDetails
+		ReportDefinitionId uint                        // funnel reports
+		LocationIds        datatypes.JSONSlice[string] `gorm:"type:jsonb"` // details reports
+		Cadence            string                      // ReportCadenceDaily, ReportCadenceWeekly or ReportCadenceMonthly
+		SendHour           int                         // hour of the day in TimeZone
+		TimeZone           string                      // IANA name, UTC when empty
+		Channel            string                      // ReportChannelEmail or ReportChannelTelegram
+		Recipients         datatypes.JSONSlice[string] `gorm:"type:jsonb"` // emails or telegram chat ids
+		Enabled            bool
+		NextRunAt          time.Time `gorm:"index"`
+		LastSentAt         *time.Time
+
+		gorm.Model
+	}
+
+	// ReportDelivery logs a send of a subscription
+	ReportDelivery struct {
+		SubscriptionId uint      `gorm:"index"`
+		ProfileId      uint      `gorm:"index"`
+		PeriodStart    time.Time `gorm:"type:date"`
+		PeriodEnd      time.Time `gorm:"type:date"`
+		Channel        string
+		Recipients     datatypes.JSONSlice[string] `gorm:"type:jsonb"`
+		FileName       string
+		Status         string // ReportDeliverySent or ReportDeliveryFailed
+		Error          string
+
+		gorm.Model
+	}
+)
+
+// TimeLocation returns the subscription's time zone, UTC if none is set.
+func (s *ReportSubscription) TimeLocation() (*time.Location, error) {
+	if s.TimeZone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(s.TimeZone)
+}
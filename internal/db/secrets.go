@@ -0,0 +1,74 @@
+package db
+
+import (
+	"client-runaway-zenoti/internal/vault"
+	"fmt"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+)
+
+// secretColumns are the columns stored through the encrypted serializer
+var secretColumns = []struct {
+	table  string
+	pk     string
+	column string
+}{
+	{"zenoti_apis", "id", "api_key"},
+	{"cerbo_apis", "id", "api_key"},
+	{"ghl_tokens", "location_id", "access_token"},
+	{"ghl_tokens", "location_id", "refresh_token"},
+	{"google_ads_connections", "id", "access_token"},
+	{"google_ads_connections", "id", "refresh_token"},
+	{"mcp_api_keys", "id", "plain_key"},
+	{"locations", "id", "zenoti_api"},
+}
+
+// encryptSecrets encrypts plaintext secrets left from before the vault and
+// rewraps the ones encrypted with a rotated-out master key.
+func encryptSecrets() error {
+	// a malformed key must not pass for no key: every write of a secret
+	// would fail later on
+	if err := vault.Err(); err != nil {
+		return err
+	}
+	if !vault.Enabled() {
+		lvn.Logger.Warning("vault: no master key configured, integration secrets are stored in plain text")
+		return nil
+	}
+
+	for _, sc := range secretColumns {
+		rows := []struct {
+			Pk    string
+			Value string
+		}{}
+		err := DB.Table(sc.table).
+			Select(fmt.Sprintf("%s AS pk, %s AS value", sc.pk, sc.column)).
+			Where(sc.column + " <> ''").
+			Scan(&rows).Error
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", sc.table, sc.column, err)
+		}
+
+		updated := 0
+		for _, r := range rows {
+			if !vault.NeedsRewrap(r.Value) {
+				continue
+			}
+			enc, err := vault.Rewrap(r.Value)
+			if err != nil {
+				return fmt.Errorf("%s.%s %s: %w", sc.table, sc.column, r.Pk, err)
+			}
+			err = DB.Table(sc.table).Where(sc.pk+" = ?", r.Pk).UpdateColumn(sc.column, enc).Error
+			if err != nil {
+				return fmt.Errorf("%s.%s %s: %w", sc.table, sc.column, r.Pk, err)
+			}
+			updated++
+		}
+
+		if updated > 0 {
+			lvn.Logger.Noticef("vault: encrypted %d %s.%s values with key %s", updated, sc.table, sc.column, vault.ActiveKeyId())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package integrations_zenoti
+
+import (
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/runway"
+	zenotiv1 "client-runaway-zenoti/packages/zenotiV1"
+	"time"
+)
+
+type AvailableSlot struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// CalendarAvailableSlots returns the bookable slots of a calendar on day:
+// GHL free slots clipped to the calendar working hours, minus Zenoti
+// block-outs of the therapist. Slots that already started are dropped.
+func CalendarAvailableSlots(l models.Location, calendar models.Calendar, day time.Time) ([]AvailableSlot, error) {
+	workFrom, workTo, err := calendar.WorkingHours(day)
+	if err != nil {
+		return nil, err
+	}
+
+	rwsvc := runway.GetSvc()
+	cli, err := rwsvc.NewClientFromId(l.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	freeSlots, err := cli.CalendarGetFreeSlots(calendar.CalendarId, workFrom, workTo, calendar.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	starts, err := freeSlots.Times()
+	if err != nil {
+		return nil, err
+	}
+
+	zcli, err := zenotiv1.NewClient(l.Id, l.ZenotiCenterId, l.ZenotiApi)
+	if err != nil {
+		return nil, err
+	}
+	blockOuts, err := zcli.EmployeesListBlockOutTimes(calendar.TherapistId, workFrom, workTo)
+	if err != nil {
+		return nil, err
+	}
+
+	tz := workFrom.Location()
+	now := time.Now()
+	duration := calendar.SlotDuration()
+	res := []AvailableSlot{}
+	for _, start := range starts {
+		end := start.Add(duration)
+		if start.Before(workFrom) || end.After(workTo) || start.Before(now) {
+			continue
+		}
+		if blockedOut(blockOuts, start, end) {
+			continue
+		}
+		res = append(res, AvailableSlot{From: start.In(tz), To: end.In(tz)})
+	}
+
+	return res, nil
+}
+
+// IsSlotAvailable reports whether from-to is one of the calendar's available slots
+func IsSlotAvailable(l models.Location, calendar models.Calendar, from, to time.Time) (bool, error) {
+	tz, err := calendar.TimeLocation()
+	if err != nil {
+		return false, err
+	}
+
+	slots, err := CalendarAvailableSlots(l, calendar, from.In(tz))
+	if err != nil {
+		return false, err
+	}
+
+	for _, s := range slots {
+		if s.From.Equal(from) && s.To.Equal(to) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func blockedOut(blockOuts []zenotiv1.BlockOut, start, end time.Time) bool {
+	for _, bo := range blockOuts {
+		if overlaps(start, end, bo.Start_time.Time, bo.End_time.Time) {
+			return true
+		}
+	}
+	return false
+}
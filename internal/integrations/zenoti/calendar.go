@@ -4,13 +4,45 @@ import (
 	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
 	"client-runaway-zenoti/internal/runway"
+	"client-runaway-zenoti/internal/services/svc_zenoti"
+	runwayv2 "client-runaway-zenoti/packages/runwayV2"
 	zenotiv1 "client-runaway-zenoti/packages/zenotiV1"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+const (
+	// GHL allows 100 requests per 10 seconds per location, keep well below it
+	ghlWritesPerSecond   = 5
+	blockSlotDbBatchSize = 50
+
+	// full syncs are only a safety net for missed webhooks
+	calendarFullSyncInterval = 24 * time.Hour
+)
+
+type CalendarSyncPlan struct {
+	CalendarId    string             `json:"calendarId"`
+	TherapistId   string             `json:"therapistId"`
+	TherapistName string             `json:"therapistName"`
+	Create        []models.BlockSlot `json:"create"`
+	Update        []models.BlockSlot `json:"update"`
+	Delete        []models.BlockSlot `json:"delete"`
+	Error         string             `json:"error,omitempty"`
+}
+
+func (p *CalendarSyncPlan) IsEmpty() bool {
+	return len(p.Create) == 0 && len(p.Update) == 0 && len(p.Delete) == 0
+}
+
+// SyncCalendars runs a full sync for locations that did not have one within
+// calendarFullSyncInterval. Day-to-day changes come through SyncDirtyCalendars.
 func SyncCalendars() {
 	fmt.Println("Starting calendar sync...")
 
@@ -23,113 +55,407 @@ func SyncCalendars() {
 	}
 
 	for _, l := range locs {
+		cursor := models.CalendarSyncCursor{}
+		db.DB.Where("location_id = ?", l.Id).First(&cursor)
+		if time.Since(cursor.LastFullSyncAt) < calendarFullSyncInterval {
+			continue
+		}
 		SyncCalendarsForLocation(l)
 	}
 }
 
 func SyncCalendarsForLocation(l models.Location) {
-	slotsToBe, err := getBlockSlotsToBe(l)
+	from, to := calendarFullSyncWindow()
+	_, err := SyncCalendarsForWindow(l, from, to, false)
+
+	now := time.Now()
+	cursor := models.CalendarSyncCursor{LocationId: l.Id}
+	db.DB.Where(&cursor).FirstOrCreate(&cursor)
+	cursor.LastError = ""
+	if err != nil {
+		fmt.Println(err)
+		cursor.LastError = err.Error()
+	} else {
+		cursor.LastFullSyncAt = now
+		cursor.LastSyncedAt = now
+	}
+	db.DB.Save(&cursor)
+}
+
+// SyncDirtyCalendars syncs only the windows Zenoti webhooks marked as changed.
+func SyncDirtyCalendars() {
+	cursors := []models.CalendarSyncCursor{}
+	err := db.DB.Where("dirty_from IS NOT NULL AND dirty_to IS NOT NULL").Find(&cursors).Error
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
 
+	for _, cursor := range cursors {
+		var err error
+		l := models.Location{}
+		db.DB.Where("id = ? AND sync_calendars = ?", cursor.LocationId, true).First(&l)
+		if l.Id != "" {
+			_, err = SyncCalendarsForWindow(l, *cursor.DirtyFrom, *cursor.DirtyTo, false)
+		}
+
+		updates := map[string]interface{}{"last_error": ""}
+		if err != nil {
+			fmt.Println(err)
+			updates["last_error"] = err.Error()
+		} else {
+			updates["dirty_from"] = nil
+			updates["dirty_to"] = nil
+			updates["last_synced_at"] = time.Now()
+		}
+
+		// a webhook that widened the window meanwhile keeps it dirty for the next run
+		db.DB.Model(&models.CalendarSyncCursor{}).
+			Where("location_id = ? AND dirty_from = ? AND dirty_to = ?", cursor.LocationId, *cursor.DirtyFrom, *cursor.DirtyTo).
+			Updates(updates)
+	}
+}
+
+// SyncCalendarsForWindow diffs the location's calendars against Zenoti for
+// [from, to). With dryRun nothing is written and the plans are only reported.
+func SyncCalendarsForWindow(l models.Location, from, to time.Time, dryRun bool) ([]CalendarSyncPlan, error) {
+	appts, err := getAppointmentsForWindow(l, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	slotsToBe := getBlockSlotsToBe(l, appts, from, to, dryRun)
+
+	plans := []CalendarSyncPlan{}
+	failed := 0
 	for c, slots := range slotsToBe {
-		SyncCalendar(c, slots)
+		asIs := []models.BlockSlot{}
+		if c.CalendarId != "" {
+			db.DB.Where("calendar_id = ? AND start_time < ? AND end_time > ?", c.CalendarId, to, from).Find(&asIs)
+		}
+
+		plan := planCalendarSync(c, slots, asIs)
+		if !dryRun && !plan.IsEmpty() {
+			err = applyCalendarSyncPlan(l, plan)
+			if err != nil {
+				plan.Error = err.Error()
+				failed++
+			}
+		}
+		plans = append(plans, plan)
 	}
 
+	sort.Slice(plans, func(i, j int) bool { return plans[i].TherapistName < plans[j].TherapistName })
+
+	if dryRun {
+		return plans, nil
+	}
+
+	ReconcileCalendarSyncLedger(l, appts)
+
+	if failed > 0 {
+		return plans, fmt.Errorf("calendar sync failed for %d of %d calendars of %s", failed, len(plans), l.Name)
+	}
+	return plans, nil
 }
 
-func SyncCalendar(calendar models.Calendar, slots []models.BlockSlot) {
-	toCreate := []models.BlockSlot{}
-	toUpdate := []models.BlockSlot{}
-	toDelete := []models.BlockSlot{}
+// MarkCalendarSyncDirty widens the location's dirty window to cover [from, to).
+func MarkCalendarSyncDirty(locationId string, from, to time.Time) error {
+	cursor := models.CalendarSyncCursor{
+		LocationId: locationId,
+		DirtyFrom:  &from,
+		DirtyTo:    &to,
+	}
 
-	asIs := []models.BlockSlot{}
-	db.DB.Where("calendar_id = ?", calendar.CalendarId).Find(&asIs)
+	tbl := "calendar_sync_cursors"
 
-	for _, s := range slots {
-		found := false
-		for _, ais := range asIs {
-			if ais.ZenotiId == s.ZenotiId {
-				found = true
-				if !ais.StartTime.Equal(s.StartTime) || !ais.EndTime.Equal(s.EndTime) {
-					s.Id = ais.Id
-					toUpdate = append(toUpdate, s)
-				}
+	return db.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "location_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"dirty_from": gorm.Expr("LEAST(COALESCE(" + tbl + ".dirty_from, EXCLUDED.dirty_from), EXCLUDED.dirty_from)"),
+			"dirty_to":   gorm.Expr("GREATEST(COALESCE(" + tbl + ".dirty_to, EXCLUDED.dirty_to), EXCLUDED.dirty_to)"),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&cursor).Error
+}
+
+// ZenotiAppointmentWebhookHandler marks the calendars of the appointment
+// group's center dirty, so the next SyncDirtyCalendars run picks it up.
+func ZenotiAppointmentWebhookHandler(eventType string, bodyBytes []byte) error {
+	var centerId string
+	var from, to time.Time
+
+	switch eventType {
+	case "AppointmentGroup.Created":
+		body := struct {
+			Data zenotiv1.AppointmentGroupWebhookData `json:"data"`
+		}{}
+		err := json.Unmarshal(bodyBytes, &body)
+		if err != nil {
+			return err
+		}
+		centerId = body.Data.Center_Id
+
+		for _, a := range body.Data.Appointments {
+			if from.IsZero() || a.Start_time.Time.Before(from) {
+				from = a.Start_time.Time
+			}
+			if a.End_time.Time.After(to) {
+				to = a.End_time.Time
 			}
 		}
-		if !found {
-			toCreate = append(toCreate, s)
+	case "AppointmentGroup.Status":
+		body := struct {
+			Data zenotiv1.AppointmentGroupStatusWebhookData `json:"data"`
+		}{}
+		err := json.Unmarshal(bodyBytes, &body)
+		if err != nil {
+			return err
+		}
+		centerId, err = svc_zenoti.GetCenterIdByAppointmentGroupId(body.Data.Appointment_Group_Id)
+		if err != nil {
+			return err
 		}
+	default:
+		return nil
 	}
 
-	for _, ais := range asIs {
-		found := false
-		for _, s := range slots {
-			if ais.ZenotiId == s.ZenotiId {
-				found = true
-				break
-			}
+	// status webhooks carry no times, so the whole upcoming window is marked
+	if from.IsZero() || to.IsZero() {
+		from = time.Now()
+		to = time.Now().Add(appointmentsFetchToDays * 24 * time.Hour)
+	}
+
+	locs := []models.Location{}
+	err := db.DB.Where("zenoti_center_id = ? AND sync_calendars = ?", centerId, true).Find(&locs).Error
+	if err != nil {
+		return err
+	}
+
+	// padded by a day as webhook times are not guaranteed to be in UTC
+	for _, l := range locs {
+		err = MarkCalendarSyncDirty(l.Id, from.Add(-24*time.Hour), to.Add(24*time.Hour))
+		if err != nil {
+			return err
 		}
-		if !found {
-			toDelete = append(toDelete, ais)
+	}
+
+	return nil
+}
+
+// Helper functions _____________________________________________
+
+// planCalendarSync diffs desired against existing slots by ZenotiId.
+// Existing slots without a ZenotiId, or duplicates of one, are deleted.
+func planCalendarSync(calendar models.Calendar, slots []models.BlockSlot, asIs []models.BlockSlot) CalendarSyncPlan {
+	plan := CalendarSyncPlan{
+		CalendarId:    calendar.CalendarId,
+		TherapistId:   calendar.TherapistId,
+		TherapistName: calendar.TherapistName,
+	}
+
+	existing := make(map[string]models.BlockSlot, len(asIs))
+	for _, s := range asIs {
+		if _, dup := existing[s.ZenotiId]; dup || s.ZenotiId == "" {
+			plan.Delete = append(plan.Delete, s)
+			continue
 		}
+		existing[s.ZenotiId] = s
 	}
 
-	// saving changes to db
-	for _, s := range toCreate {
-		err := db.DB.Create(&s).Error
-		if err != nil {
-			fmt.Println(err)
+	desired := make(map[string]bool, len(slots))
+	for _, s := range slots {
+		if desired[s.ZenotiId] {
+			continue
+		}
+		desired[s.ZenotiId] = true
+
+		cur, ok := existing[s.ZenotiId]
+		if !ok {
+			plan.Create = append(plan.Create, s)
+			continue
+		}
+		if !cur.StartTime.Equal(s.StartTime) || !cur.EndTime.Equal(s.EndTime) || cur.Title != s.Title {
+			s.Id = cur.Id
+			plan.Update = append(plan.Update, s)
+		}
+	}
+
+	for zenotiId, s := range existing {
+		if !desired[zenotiId] {
+			plan.Delete = append(plan.Delete, s)
 		}
 	}
 
-	for _, s := range toUpdate {
-		err := db.DB.Save(&s).Error
+	for _, list := range [][]models.BlockSlot{plan.Create, plan.Update, plan.Delete} {
+		sort.Slice(list, func(i, j int) bool { return list[i].StartTime.Before(list[j].StartTime) })
+	}
+
+	return plan
+}
+
+// applyCalendarSyncPlan writes the plan to GHL at ghlWritesPerSecond and
+// stores the result in batches. The BlockSlot hooks are bypassed so every
+// row costs exactly one GHL call.
+func applyCalendarSyncPlan(l models.Location, plan CalendarSyncPlan) error {
+	rwsvc := runway.GetSvc()
+	cli, err := rwsvc.NewClientFromId(l.Id)
+	if err != nil {
+		return err
+	}
+
+	limiter := time.NewTicker(time.Second / ghlWritesPerSecond)
+	defer limiter.Stop()
+
+	tx := db.DB.Set(models.BlockSlotSkipGhlHooks, true).Session(&gorm.Session{})
+	total := len(plan.Create) + len(plan.Update) + len(plan.Delete)
+	failed := 0
+	var lastErr error
+
+	batch := make([]models.BlockSlot, 0, blockSlotDbBatchSize)
+	flush := func(write func([]models.BlockSlot) error) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := write(batch); err != nil {
+			failed += len(batch)
+			lastErr = err
+		}
+		batch = batch[:0]
+	}
+	save := func(slots []models.BlockSlot) error { return tx.Save(&slots).Error }
+	remove := func(slots []models.BlockSlot) error {
+		ids := make([]string, 0, len(slots))
+		for _, s := range slots {
+			ids = append(ids, s.Id)
+		}
+		return tx.Where("id IN ?", ids).Delete(&models.BlockSlot{}).Error
+	}
+
+	for _, s := range plan.Create {
+		<-limiter.C
+		created, err := cli.CalendarCreateBlockSlot(toGhlBlockSlot(s))
 		if err != nil {
-			fmt.Println(err)
+			failed++
+			lastErr = err
+			continue
+		}
+		s.Id = created.Id
+		batch = append(batch, s)
+		if len(batch) == blockSlotDbBatchSize {
+			flush(save)
 		}
 	}
+	flush(save)
 
-	for _, s := range toDelete {
-		err := db.DB.Delete(&s).Error
+	for _, s := range plan.Update {
+		<-limiter.C
+		_, err := cli.CalendarEditBlockSlot(s.Id, toGhlBlockSlot(s))
 		if err != nil {
-			fmt.Println(err)
+			failed++
+			lastErr = err
+			continue
+		}
+		batch = append(batch, s)
+		if len(batch) == blockSlotDbBatchSize {
+			flush(save)
 		}
 	}
+	flush(save)
+
+	for _, s := range plan.Delete {
+		<-limiter.C
+		// an event already gone in GHL is removed as if deleted; on other
+		// errors the row stays so the next run retries it
+		err := cli.CalendarDeleteEvent(s.Id)
+		if err != nil && !strings.Contains(err.Error(), "HTTP error: 404") {
+			failed++
+			lastErr = err
+			continue
+		}
+		batch = append(batch, s)
+		if len(batch) == blockSlotDbBatchSize {
+			flush(remove)
+		}
+	}
+	flush(remove)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d block slot writes failed for calendar %s: %v", failed, total, plan.CalendarId, lastErr)
+	}
+	return nil
 }
 
-// Helper functions _____________________________________________
+func toGhlBlockSlot(s models.BlockSlot) runwayv2.BlockSlot {
+	return runwayv2.BlockSlot{
+		LocationId:    s.LocationId,
+		CalendarId:    s.CalendarId,
+		StartTime:     s.StartTime,
+		EndTime:       s.EndTime,
+		Title:         s.Title,
+		CalendarNotes: s.Notes,
+	}
+}
 
-func getBlockSlotsToBe(l models.Location) (map[models.Calendar][]models.BlockSlot, error) {
-	res := map[models.Calendar][]models.BlockSlot{}
-	appts, err := getAppointments(l)
+func calendarFullSyncWindow() (time.Time, time.Time) {
+	now := time.Now()
+	return now.Add(appointmentsFetchFromDays * 24 * time.Hour), now.Add(appointmentsFetchToDays * 24 * time.Hour)
+}
+
+func getAppointmentsForWindow(l models.Location, from, to time.Time) ([]zenotiv1.Appointment, error) {
+	client, err := zenotiv1.NewClient(l.Id, l.ZenotiCenterId, l.ZenotiApi)
 	if err != nil {
-		return res, err
+		return nil, err
 	}
 
-	blocksFromAppts := mapApptsToBlockSlots(appts, l)
-	res = blocksFromAppts
+	// Zenoti filters by date, so the window is padded and trimmed afterwards
+	apts, err := client.AppointmentsListAllAppointments(zenotiv1.AppointmentFilter{
+		StartDate:           from.Add(-24 * time.Hour),
+		EndDate:             to.Add(24*time.Hour + 1*time.Second),
+		IncludeNoShowCancel: false,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]zenotiv1.Appointment, 0, len(apts))
+	for _, a := range apts {
+		if overlaps(from, to, a.Start_time_utc.Time, a.End_time_utc.Time) {
+			res = append(res, a)
+		}
+	}
+	return res, nil
+}
 
-	for c, _ := range res {
+func getBlockSlotsToBe(l models.Location, appts []zenotiv1.Appointment, from, to time.Time, dryRun bool) map[models.Calendar][]models.BlockSlot {
+	res := mapApptsToBlockSlots(appts, l, dryRun)
 
-		client, err := zenotiv1.NewClient(l.Id, l.ZenotiCenterId, l.ZenotiApi)
-		if err != nil {
-			fmt.Println(err)
-			continue
+	// calendars without appointments in the window may still have block-outs or stale slots
+	calendars := []models.Calendar{}
+	db.DB.Where("location_id = ?", l.Id).Find(&calendars)
+	for _, c := range calendars {
+		if _, ok := res[c]; !ok {
+			res[c] = []models.BlockSlot{}
 		}
-		blockOuts, err := client.EmployeesListBlockOutTimesAll(
-			c.TherapistId,
-			time.Now().Add(appointmentsFetchFromDays*24*time.Hour),
-			time.Now().Add(appointmentsFetchToDays*24*time.Hour),
-		)
+	}
+
+	client, err := zenotiv1.NewClient(l.Id, l.ZenotiCenterId, l.ZenotiApi)
+	if err != nil {
+		fmt.Println(err)
+		return res
+	}
+
+	for c := range res {
+		blockOuts, err := client.EmployeesListBlockOutTimesAll(c.TherapistId, from.Add(-24*time.Hour), to.Add(24*time.Hour))
 		if err != nil {
 			fmt.Println(err)
 			continue
 		}
 
 		for _, bo := range blockOuts {
+			if !overlaps(from, to, bo.Start_time.Time, bo.End_time.Time) {
+				continue
+			}
 			slot := models.BlockSlot{
 				LocationId: l.Id,
 				CalendarId: c.CalendarId,
@@ -142,13 +468,14 @@ func getBlockSlotsToBe(l models.Location) (map[models.Calendar][]models.BlockSlo
 		}
 	}
 
-	return res, nil
+	return res
 }
 
-func mapApptsToBlockSlots(appts []zenotiv1.Appointment, l models.Location) map[models.Calendar][]models.BlockSlot {
+func mapApptsToBlockSlots(appts []zenotiv1.Appointment, l models.Location, dryRun bool) map[models.Calendar][]models.BlockSlot {
 	res := map[models.Calendar][]models.BlockSlot{}
 	calendars := []models.Calendar{}
 	db.DB.Where("location_id = ?", l.Id).Find(&calendars)
+	synced := calendarSyncedAppointmentIds(l.Id)
 
 	for _, a := range appts {
 		if a.Start_time_utc == a.End_time_utc { // skip merged appointments
@@ -159,7 +486,17 @@ func mapApptsToBlockSlots(appts []zenotiv1.Appointment, l models.Location) map[m
 			continue
 		}
 
-		c, err := calendarFirsOrCreate(&calendars, l.Id, a.Therapist)
+		if synced[a.Id] { // already a real appointment in GHL
+			continue
+		}
+
+		var c models.Calendar
+		var err error
+		if dryRun {
+			c = calendarFindOrPlaceholder(calendars, a.Therapist)
+		} else {
+			c, err = calendarFirsOrCreate(&calendars, l.Id, a.Therapist)
+		}
 		if err != nil {
 			fmt.Println(err)
 			continue
@@ -193,3 +530,18 @@ func calendarFirsOrCreate(calendars *([]models.Calendar), locationId string, the
 	*calendars = append(*calendars, calendar)
 	return calendar, nil
 }
+
+// calendarFindOrPlaceholder is calendarFirsOrCreate for dry runs: a missing
+// calendar is reported with an empty CalendarId instead of being created.
+func calendarFindOrPlaceholder(calendars []models.Calendar, therapist zenotiv1.Therapist) models.Calendar {
+	for _, c := range calendars {
+		if c.TherapistId == therapist.Id {
+			return c
+		}
+	}
+
+	return models.Calendar{
+		TherapistId:   therapist.Id,
+		TherapistName: fmt.Sprintf("%s %s", therapist.First_name, therapist.Last_name),
+	}
+}
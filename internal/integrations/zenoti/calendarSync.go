@@ -0,0 +1,665 @@
+package integrations_zenoti
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/runway"
+	"client-runaway-zenoti/internal/tgbot"
+	runwayv2 "client-runaway-zenoti/packages/runwayV2"
+	zenotiv1 "client-runaway-zenoti/packages/zenotiV1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Two-way sync between GHL appointments on therapist calendars and Zenoti
+// bookings. Every synced pair is tracked in models.CalendarSyncEntry; the
+// ledger is also what stops webhook echoes from bouncing between the systems.
+
+const ghlAppointmentCancelled = "cancelled"
+
+type ghlAppointmentWebhook struct {
+	LocationId  string               `json:"locationId"`
+	Appointment runwayv2.Appointment `json:"appointment"`
+}
+
+// GhlAppointmentWebhookHandler pushes GHL appointment changes to Zenoti.
+// Appointments on calendars that are not mapped to a therapist are ignored.
+func GhlAppointmentWebhookHandler(eventType string, bodyBytes []byte) error {
+	body := ghlAppointmentWebhook{}
+	err := json.Unmarshal(bodyBytes, &body)
+	if err != nil {
+		return err
+	}
+
+	l := models.Location{}
+	err = db.DB.Where("id = ?", body.LocationId).First(&l).Error
+	if err != nil {
+		return err
+	}
+
+	switch eventType {
+	case "AppointmentCreate":
+		return ghlAppointmentCreated(l, body.Appointment)
+	case "AppointmentUpdate":
+		return ghlAppointmentUpdated(l, body.Appointment)
+	case "AppointmentDelete":
+		body.Appointment.AppointmentStatus = ghlAppointmentCancelled
+		return ghlAppointmentUpdated(l, body.Appointment)
+	}
+
+	return nil
+}
+
+// ZenotiAppointmentGroupStatusWebhookHandler cancels the GHL appointments
+// linked to a Zenoti invoice once its appointment group gets canceled.
+func ZenotiAppointmentGroupStatusWebhookHandler(bodyBytes []byte) error {
+	body := struct {
+		Data zenotiv1.AppointmentGroupStatusWebhookData `json:"data"`
+	}{}
+	err := json.Unmarshal(bodyBytes, &body)
+	if err != nil {
+		return err
+	}
+
+	if body.Data.Appointment_Group_Status != zenotiv1.Canceled || body.Data.Invoice_id == "" {
+		return nil
+	}
+
+	entries := []models.CalendarSyncEntry{}
+	err = db.DB.Where("zenoti_invoice_id = ? AND status = ?", body.Data.Invoice_id, models.CalendarSyncStatusBooked).Find(&entries).Error
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		err = cancelGhlAppointment(&e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BookCalendarSlot books a slot on a therapist calendar straight into Zenoti
+// and mirrors it as a block slot, so GHL shows it without waiting for the
+// next SyncCalendars run.
+func BookCalendarSlot(calendarId string, from, to time.Time, customerName, customerPhone string) (models.CalendarSyncEntry, error) {
+	calendar := models.Calendar{}
+	err := db.DB.Where("calendar_id = ?", calendarId).First(&calendar).Error
+	if err != nil {
+		return models.CalendarSyncEntry{}, fmt.Errorf("calendar %s is not mapped to a therapist", calendarId)
+	}
+
+	l := models.Location{}
+	err = db.DB.Where("id = ?", calendar.LocationId).First(&l).Error
+	if err != nil {
+		return models.CalendarSyncEntry{}, err
+	}
+
+	entry := models.CalendarSyncEntry{
+		LocationId:  l.Id,
+		CalendarId:  calendar.CalendarId,
+		TherapistId: calendar.TherapistId,
+		Origin:      models.CalendarSyncOriginChatly,
+		StartTime:   from,
+		EndTime:     to,
+	}
+
+	zcli, err := zenotiv1.NewClient(l.Id, l.ZenotiCenterId, l.ZenotiApi)
+	if err != nil {
+		return entry, err
+	}
+
+	conflict, err := findTherapistConflict(zcli, calendar, from, to, "")
+	if err != nil {
+		return entry, err
+	}
+	if conflict != "" {
+		return entry, errors.New(conflict)
+	}
+
+	guest, err := zenotiGuestFirstOrCreate(zcli, l, runwayv2.Contact{FirstName: customerName, Phone: customerPhone})
+	if err != nil {
+		return entry, err
+	}
+	entry.ZenotiGuestId = guest.Id
+
+	err = bookZenotiAppointment(zcli, l, calendar, &entry)
+	if err != nil {
+		return entry, err
+	}
+
+	err = db.DB.Create(&entry).Error
+	if err != nil {
+		return entry, err
+	}
+
+	slot := models.BlockSlot{
+		LocationId: l.Id,
+		CalendarId: calendar.CalendarId,
+		StartTime:  from,
+		EndTime:    to,
+		Title:      customerName,
+		Notes:      customerPhone,
+		ZenotiId:   entry.ZenotiAppointmentId,
+	}
+	return entry, db.DB.Create(&slot).Error
+}
+
+// ReconcileCalendarSyncLedger pulls Zenoti-side reschedules and cancellations
+// of GHL-originated bookings back into GHL. If both sides moved since the last
+// sync the entry is flagged as a conflict instead of picking a winner.
+func ReconcileCalendarSyncLedger(l models.Location, appts []zenotiv1.Appointment) {
+	entries := []models.CalendarSyncEntry{}
+	db.DB.Where("location_id = ? AND status = ? AND ghl_event_id <> '' AND start_time > ?",
+		l.Id, models.CalendarSyncStatusBooked, time.Now().Add(appointmentsFetchFromDays*24*time.Hour)).
+		Find(&entries)
+	if len(entries) == 0 {
+		return
+	}
+
+	byId := map[string]zenotiv1.Appointment{}
+	for _, a := range appts {
+		byId[a.Id] = a
+	}
+
+	zcli, err := zenotiv1.NewClient(l.Id, l.ZenotiCenterId, l.ZenotiApi)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for _, e := range entries {
+		a, ok := byId[e.ZenotiAppointmentId]
+		if !ok {
+			details, err := zcli.AppointmentsGetDetails(e.ZenotiAppointmentId)
+			if err != nil || len(details) == 0 {
+				continue
+			}
+			a = details[0]
+		}
+
+		if a.Status == zenotiv1.Canceled {
+			err = cancelGhlAppointment(&e)
+			if err != nil {
+				fmt.Println(err)
+			}
+			continue
+		}
+
+		if a.Start_time_utc.Time.Equal(e.StartTime) && a.End_time_utc.Time.Equal(e.EndTime) {
+			continue
+		}
+
+		err = pushZenotiRescheduleToGhl(&e, a)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// calendarSyncedAppointmentIds returns Zenoti appointments that already exist
+// in GHL as real appointments and must not be mirrored as block slots.
+func calendarSyncedAppointmentIds(locationId string) map[string]bool {
+	ids := []string{}
+	db.DB.Model(&models.CalendarSyncEntry{}).
+		Where("location_id = ? AND status = ? AND ghl_event_id <> ''", locationId, models.CalendarSyncStatusBooked).
+		Pluck("zenoti_appointment_id", &ids)
+
+	res := map[string]bool{}
+	for _, id := range ids {
+		res[id] = true
+	}
+	return res
+}
+
+// Helper functions _____________________________________________
+
+func ghlAppointmentCreated(l models.Location, a runwayv2.Appointment) error {
+	entry, err := models.CalendarSyncEntryByGhlEvent(a.Id)
+	if err == nil {
+		return ghlAppointmentChanged(l, &entry, a)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if a.AppointmentStatus == ghlAppointmentCancelled {
+		return nil
+	}
+
+	calendar := models.Calendar{}
+	db.DB.Where("location_id = ? AND calendar_id = ?", l.Id, a.CalendarId).First(&calendar)
+	if calendar.TherapistId == "" {
+		return nil
+	}
+
+	entry = models.CalendarSyncEntry{
+		LocationId:   l.Id,
+		CalendarId:   calendar.CalendarId,
+		TherapistId:  calendar.TherapistId,
+		GhlEventId:   a.Id,
+		GhlContactId: a.ContactId,
+		Origin:       models.CalendarSyncOriginGhl,
+	}
+
+	return bookGhlAppointment(l, calendar, &entry, a)
+}
+
+func ghlAppointmentUpdated(l models.Location, a runwayv2.Appointment) error {
+	entry, err := models.CalendarSyncEntryByGhlEvent(a.Id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ghlAppointmentCreated(l, a)
+	}
+	if err != nil {
+		return err
+	}
+
+	return ghlAppointmentChanged(l, &entry, a)
+}
+
+func ghlAppointmentChanged(l models.Location, entry *models.CalendarSyncEntry, a runwayv2.Appointment) error {
+	if entry.Status == models.CalendarSyncStatusCanceled {
+		return nil
+	}
+
+	if a.AppointmentStatus == ghlAppointmentCancelled {
+		// A conflicted entry still holds a Zenoti booking; only entries that
+		// never got one can be closed locally.
+		if entry.ZenotiInvoiceId == "" {
+			entry.Status = models.CalendarSyncStatusCanceled
+			return db.DB.Save(entry).Error
+		}
+		return cancelZenotiAppointment(l, entry)
+	}
+
+	calendar := models.Calendar{}
+	db.DB.Where("location_id = ? AND calendar_id = ?", l.Id, entry.CalendarId).First(&calendar)
+	if calendar.TherapistId == "" {
+		return fmt.Errorf("calendar %s is no longer mapped to a therapist", entry.CalendarId)
+	}
+
+	// a previous attempt never reached Zenoti, so this is a fresh booking
+	if entry.ZenotiInvoiceId == "" {
+		return bookGhlAppointment(l, calendar, entry, a)
+	}
+	// editing the appointment in GHL again resolves a conflict in favour of GHL
+	entry.Status = models.CalendarSyncStatusBooked
+
+	start, end, err := parseGhlAppointmentTimes(a)
+	if err != nil {
+		return err
+	}
+	if start.Equal(entry.StartTime) && end.Equal(entry.EndTime) {
+		return nil
+	}
+
+	return rescheduleZenotiAppointment(l, calendar, entry, start, end)
+}
+
+func bookGhlAppointment(l models.Location, calendar models.Calendar, entry *models.CalendarSyncEntry, a runwayv2.Appointment) error {
+	start, end, err := parseGhlAppointmentTimes(a)
+	if err != nil {
+		return err
+	}
+	entry.StartTime = start
+	entry.EndTime = end
+
+	zcli, err := zenotiv1.NewClient(l.Id, l.ZenotiCenterId, l.ZenotiApi)
+	if err != nil {
+		return err
+	}
+
+	conflict, err := findTherapistConflict(zcli, calendar, start, end, "")
+	if err != nil {
+		return err
+	}
+	if conflict != "" {
+		return markCalendarSyncFailed(l, entry, models.CalendarSyncStatusConflict, conflict)
+	}
+
+	rwsvc := runway.GetSvc()
+	cli, err := rwsvc.NewClientFromId(l.Id)
+	if err != nil {
+		return err
+	}
+	contact, err := cli.ContactsGet(a.ContactId)
+	if err != nil {
+		return markCalendarSyncFailed(l, entry, models.CalendarSyncStatusError, err.Error())
+	}
+
+	guest, err := zenotiGuestFirstOrCreate(zcli, l, contact)
+	if err != nil {
+		return markCalendarSyncFailed(l, entry, models.CalendarSyncStatusError, err.Error())
+	}
+	entry.ZenotiGuestId = guest.Id
+
+	err = bookZenotiAppointment(zcli, l, calendar, entry)
+	if err != nil {
+		return markCalendarSyncFailed(l, entry, models.CalendarSyncStatusError, err.Error())
+	}
+
+	return db.DB.Save(entry).Error
+}
+
+func rescheduleZenotiAppointment(l models.Location, calendar models.Calendar, entry *models.CalendarSyncEntry, start, end time.Time) error {
+	zcli, err := zenotiv1.NewClient(l.Id, l.ZenotiCenterId, l.ZenotiApi)
+	if err != nil {
+		return err
+	}
+
+	// the booking stays where it is, the conflict is only reported
+	conflict, err := findTherapistConflict(zcli, calendar, start, end, entry.ZenotiAppointmentId)
+	if err != nil {
+		return err
+	}
+	if conflict != "" {
+		entry.LastError = conflict
+		notifyCalendarSync(l, "Reschedule conflict: "+conflict)
+		return db.DB.Save(entry).Error
+	}
+
+	// Zenoti has no reschedule endpoint: a new booking is made and the old
+	// invoice canceled. The cancellation is recorded first, so its webhook
+	// isn't taken for the guest's.
+	old := *entry
+	err = db.DB.Save(&models.CalendarSyncCanceledInvoice{InvoiceId: old.ZenotiInvoiceId, LocationId: l.Id, EntryId: entry.ID}).Error
+	if err != nil {
+		return err
+	}
+
+	// booking the new time first keeps the guest booked if it fails
+	entry.StartTime = start
+	entry.EndTime = end
+	if err = bookZenotiAppointment(zcli, l, calendar, entry); err == nil {
+		if err = db.DB.Save(entry).Error; err != nil {
+			return err
+		}
+		if err = zcli.InvoicesCancel(old.ZenotiInvoiceId, "Rescheduled in GHL"); err != nil {
+			return markCalendarSyncFailed(l, entry, models.CalendarSyncStatusBooked,
+				fmt.Sprintf("moved, but the old Zenoti invoice %s is still booked: %s", old.ZenotiInvoiceId, err.Error()))
+		}
+		return nil
+	}
+
+	// Zenoti refuses a new time overlapping the therapist's old booking, that
+	// one has to go first. The entry drops the old invoice before, so its
+	// webhook doesn't cancel the GHL appointment.
+	bookErr := err
+	entry.ZenotiInvoiceId = ""
+	entry.ZenotiAppointmentId = ""
+	entry.Status = models.CalendarSyncStatusRescheduling
+	if err = db.DB.Save(entry).Error; err != nil {
+		return err
+	}
+
+	err = zcli.InvoicesCancel(old.ZenotiInvoiceId, "Rescheduled in GHL")
+	if err != nil {
+		// nothing changed in Zenoti
+		db.DB.Delete(&models.CalendarSyncCanceledInvoice{InvoiceId: old.ZenotiInvoiceId})
+		entry.ZenotiInvoiceId, entry.ZenotiAppointmentId = old.ZenotiInvoiceId, old.ZenotiAppointmentId
+		entry.StartTime, entry.EndTime = old.StartTime, old.EndTime
+		return markCalendarSyncFailed(l, entry, models.CalendarSyncStatusConflict,
+			fmt.Sprintf("unable to move the Zenoti booking: %s; %s", bookErr.Error(), err.Error()))
+	}
+
+	err = bookZenotiAppointment(zcli, l, calendar, entry)
+	if err == nil {
+		return db.DB.Save(entry).Error
+	}
+
+	// the new time can't be booked, the guest goes back to the old one and
+	// the entry is left in conflict with GHL
+	rebookErr := err
+	entry.StartTime, entry.EndTime = old.StartTime, old.EndTime
+	if err = bookZenotiAppointment(zcli, l, calendar, entry); err == nil {
+		return markCalendarSyncFailed(l, entry, models.CalendarSyncStatusConflict,
+			"unable to book the new time in Zenoti, kept the original one: "+rebookErr.Error())
+	}
+
+	entry.Status = models.CalendarSyncStatusError
+	entry.LastError = fmt.Sprintf("the guest has no Zenoti booking: the old one was canceled and rebooking failed: %s; %s", rebookErr.Error(), err.Error())
+	tgbot.Notify("Calendar sync", fmt.Sprintf("%s (%s)\nGHL event %s: %s", l.Name, l.Id, entry.GhlEventId, entry.LastError), true)
+	return db.DB.Save(entry).Error
+}
+
+func cancelZenotiAppointment(l models.Location, entry *models.CalendarSyncEntry) error {
+	zcli, err := zenotiv1.NewClient(l.Id, l.ZenotiCenterId, l.ZenotiApi)
+	if err != nil {
+		return err
+	}
+
+	err = zcli.InvoicesCancel(entry.ZenotiInvoiceId, "Canceled in GHL")
+	if err != nil {
+		entry.LastError = err.Error()
+		db.DB.Save(entry)
+		return err
+	}
+
+	entry.Status = models.CalendarSyncStatusCanceled
+	entry.LastError = ""
+	entry.SyncedAt = time.Now()
+	return db.DB.Save(entry).Error
+}
+
+func cancelGhlAppointment(entry *models.CalendarSyncEntry) error {
+	if entry.GhlEventId != "" {
+		rwsvc := runway.GetSvc()
+		cli, err := rwsvc.NewClientFromId(entry.LocationId)
+		if err != nil {
+			return err
+		}
+
+		_, err = cli.CalendarEditAppointment(entry.GhlEventId, runwayv2.CalendarEditAppointmentReq{
+			AppointmentStatus: ghlAppointmentCancelled,
+		})
+		if err != nil {
+			entry.LastError = err.Error()
+			db.DB.Save(entry)
+			return err
+		}
+	}
+
+	entry.Status = models.CalendarSyncStatusCanceled
+	entry.LastError = ""
+	entry.SyncedAt = time.Now()
+	return db.DB.Save(entry).Error
+}
+
+func pushZenotiRescheduleToGhl(entry *models.CalendarSyncEntry, a zenotiv1.Appointment) error {
+	rwsvc := runway.GetSvc()
+	cli, err := rwsvc.NewClientFromId(entry.LocationId)
+	if err != nil {
+		return err
+	}
+
+	ghlAppt, err := cli.CalendarGetAppointment(entry.GhlEventId)
+	if err != nil {
+		return err
+	}
+
+	ghlStart, ghlEnd, err := parseGhlAppointmentTimes(ghlAppt)
+	if err != nil {
+		return err
+	}
+
+	ghlMoved := !ghlStart.Equal(entry.StartTime) || !ghlEnd.Equal(entry.EndTime)
+	sameAsZenoti := ghlStart.Equal(a.Start_time_utc.Time) && ghlEnd.Equal(a.End_time_utc.Time)
+	if ghlMoved && !sameAsZenoti {
+		entry.Status = models.CalendarSyncStatusConflict
+		entry.LastError = "appointment was moved both in GHL and in Zenoti"
+		notifyCalendarSync(models.Location{Id: entry.LocationId}, fmt.Sprintf("%s (GHL event %s)", entry.LastError, entry.GhlEventId))
+		return db.DB.Save(entry).Error
+	}
+
+	prevStart, prevEnd := entry.StartTime, entry.EndTime
+
+	// Save the new times before editing GHL: the edit fires a GHL webhook
+	// which must see the entry already in sync, not as a move made in GHL.
+	entry.StartTime = a.Start_time_utc.Time
+	entry.EndTime = a.End_time_utc.Time
+	entry.SyncedAt = time.Now()
+	if err = db.DB.Save(entry).Error; err != nil {
+		return err
+	}
+
+	if !sameAsZenoti {
+		start := a.Start_time_utc.Time
+		end := a.End_time_utc.Time
+		_, err = cli.CalendarEditAppointment(entry.GhlEventId, runwayv2.CalendarEditAppointmentReq{
+			StartTime:       &start,
+			EndTime:         &end,
+			IgnoreDateRange: true,
+		})
+		if err != nil {
+			entry.StartTime, entry.EndTime = prevStart, prevEnd
+			entry.LastError = err.Error()
+			db.DB.Save(entry)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// bookZenotiAppointment books entry's slot for entry's guest and fills in the
+// Zenoti invoice and appointment ids.
+func bookZenotiAppointment(zcli zenotiv1.Client, l models.Location, calendar models.Calendar, entry *models.CalendarSyncEntry) error {
+	_, reservation, err := zcli.BookWithConfirmDetails(zenotiv1.BookingReq{
+		Date:     zenotiv1.ZenotiDate{Time: entry.StartTime},
+		CenterId: l.ZenotiCenterId,
+		Guests: []zenotiv1.BookingReqGuest{
+			{
+				Id: entry.ZenotiGuestId,
+				Items: []zenotiv1.BookingReqGuestsItems{
+					{
+						Item:      zenotiv1.BookingReqItem{Id: l.ZenotiServiceId},
+						Therapist: zenotiv1.BookingReqTherapist{Id: calendar.TherapistId},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	entry.ZenotiInvoiceId = reservation.Invoice.Invoice_id
+	if entry.ZenotiInvoiceId == "" && len(reservation.Invoices) > 0 {
+		entry.ZenotiInvoiceId = reservation.Invoices[0].Invoice_id
+	}
+
+	// the confirmation only carries the invoice, the appointment id is looked up
+	appts, err := zcli.AppointmentsListAppointments(zenotiv1.AppointmentFilter{
+		StartDate:   entry.StartTime,
+		EndDate:     entry.StartTime.Add(24 * time.Hour),
+		TherapistId: calendar.TherapistId,
+	})
+	if err == nil {
+		for _, a := range appts {
+			if a.Invoice_id == entry.ZenotiInvoiceId {
+				entry.ZenotiAppointmentId = a.Id
+				entry.StartTime = a.Start_time_utc.Time
+				entry.EndTime = a.End_time_utc.Time
+				break
+			}
+		}
+	}
+
+	entry.Status = models.CalendarSyncStatusBooked
+	entry.LastError = ""
+	entry.SyncedAt = time.Now()
+	return nil
+}
+
+// findTherapistConflict checks the therapist's Zenoti appointments and
+// block-outs for an overlap with [start, end). ignoreId is the appointment
+// being moved, if any.
+func findTherapistConflict(zcli zenotiv1.Client, calendar models.Calendar, start, end time.Time, ignoreId string) (string, error) {
+	appts, err := zcli.AppointmentsListAppointments(zenotiv1.AppointmentFilter{
+		StartDate:   start,
+		EndDate:     end.Add(24 * time.Hour),
+		TherapistId: calendar.TherapistId,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, a := range appts {
+		if a.Id == ignoreId || a.Status == zenotiv1.Canceled || a.Start_time_utc == a.End_time_utc {
+			continue
+		}
+		if overlaps(start, end, a.Start_time_utc.Time, a.End_time_utc.Time) {
+			return fmt.Sprintf("%s is already booked from %s to %s", calendar.TherapistName,
+				a.Start_time_utc.Time.Format(time.RFC3339), a.End_time_utc.Time.Format(time.RFC3339)), nil
+		}
+	}
+
+	blockOuts, err := zcli.EmployeesListBlockOutTimes(calendar.TherapistId, start, end.Add(24*time.Hour))
+	if err != nil {
+		return "", err
+	}
+
+	for _, bo := range blockOuts {
+		if overlaps(start, end, bo.Start_time.Time, bo.End_time.Time) {
+			return fmt.Sprintf("%s is blocked out from %s to %s", calendar.TherapistName,
+				bo.Start_time.Time.Format(time.RFC3339), bo.End_time.Time.Format(time.RFC3339)), nil
+		}
+	}
+
+	return "", nil
+}
+
+func zenotiGuestFirstOrCreate(zcli zenotiv1.Client, l models.Location, contact runwayv2.Contact) (zenotiv1.Guest, error) {
+	guests, err := zcli.GuestsGetByPhoneEmail(contact.Phone, contact.Email)
+	if err != nil && err.Error() != "guest not found" {
+		return zenotiv1.Guest{}, err
+	}
+	if len(guests) > 0 {
+		return guests[0], nil
+	}
+
+	return zcli.GuestsCreate(zenotiv1.Guest{
+		Center_id: l.ZenotiCenterId,
+		Personal_info: zenotiv1.Personal_info{
+			First_name: contact.FirstName,
+			Last_name:  contact.LastName,
+			Email:      contact.Email,
+			Mobile_phone: zenotiv1.Phone_info{
+				Number: contact.Phone,
+			},
+		},
+	})
+}
+
+func markCalendarSyncFailed(l models.Location, entry *models.CalendarSyncEntry, status, reason string) error {
+	entry.Status = status
+	entry.LastError = reason
+	notifyCalendarSync(l, fmt.Sprintf("GHL event %s: %s", entry.GhlEventId, reason))
+	return db.DB.Save(entry).Error
+}
+
+func notifyCalendarSync(l models.Location, msg string) {
+	tgbot.Notify("Calendar sync", fmt.Sprintf("%s (%s)\n%s", l.Name, l.Id, msg), false)
+}
+
+func parseGhlAppointmentTimes(a runwayv2.Appointment) (time.Time, time.Time, error) {
+	start, err := time.Parse(time.RFC3339, a.StartTime)
+	if err != nil {
+		return start, start, fmt.Errorf("invalid appointment start time %q", a.StartTime)
+	}
+	end, err := time.Parse(time.RFC3339, a.EndTime)
+	if err != nil {
+		return start, end, fmt.Errorf("invalid appointment end time %q", a.EndTime)
+	}
+	return start, end, nil
+}
+
+func overlaps(start1, end1, start2, end2 time.Time) bool {
+	return start1.Before(end2) && start2.Before(end1)
+}
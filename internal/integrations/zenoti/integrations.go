@@ -2,7 +2,11 @@ package integrations_zenoti
 
 import (
 	"client-runaway-zenoti/internal/runway"
+	"client-runaway-zenoti/internal/services/svc_adspend"
 	svc_jpmreport "client-runaway-zenoti/internal/services/svc_jpmReport"
+	"client-runaway-zenoti/internal/services/svc_meta"
+	"client-runaway-zenoti/internal/services/svc_noshows"
+	"client-runaway-zenoti/internal/services/svc_subscriptions"
 	"client-runaway-zenoti/internal/tgbot"
 	"time"
 
@@ -18,6 +22,11 @@ func StartScheduledJobs() {
 	tgbot.Notify("Scheduled jobs", "Starting scheduled jobs", false)
 	s := gocron.NewScheduler(time.UTC)
 	s.Every(2).Hours().Do(runFrequentJobs)
+	s.Every(1).Minute().SingletonMode().Do(SyncDirtyCalendars)
+	s.Every(12).Hours().SingletonMode().Do(svc_meta.RefreshConnections)
+	s.Every(6).Hours().SingletonMode().Do(svc_adspend.IngestAll)
+	s.Every(15).Minutes().SingletonMode().Do(svc_subscriptions.DeliverDue)
+	s.Every(6).Hours().SingletonMode().Do(svc_noshows.SyncAppointments)
 	s.StartBlocking()
 }
 
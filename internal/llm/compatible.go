@@ -0,0 +1,101 @@
+package llm
+
+import (
+	openaiv1 "client-runaway-zenoti/packages/openaiV1"
+	"context"
+	"fmt"
+)
+
+// compatible runs on an OpenAI-compatible chat completions API at BaseURL,
+// e.g. a self-hosted model server. It keeps no state between requests.
+type compatible struct {
+	client openaiv1.Client
+}
+
+func newCompatible(s Settings) (Provider, error) {
+	if s.BaseURL == "" {
+		return nil, fmt.Errorf("a base url is required for an openai-compatible provider")
+	}
+	// local servers often run without a key
+	if s.APIKey == "" {
+		s.APIKey = "none"
+	}
+	client, err := newOpenAIClient(s)
+	if err != nil {
+		return nil, err
+	}
+	return compatible{client: client}, nil
+}
+
+func (p compatible) Name() string   { return ProviderOpenAICompatible }
+func (p compatible) Stateful() bool { return false }
+
+func (p compatible) Respond(ctx context.Context, req Request, onDelta func(string)) (Response, error) {
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+
+	messages := []openaiv1.ChatMessage{}
+	if req.Instructions != "" {
+		messages = append(messages, openaiv1.ChatMessage{Role: "system", Content: req.Instructions})
+	}
+	for _, m := range req.Input {
+		msg := openaiv1.ChatMessage{Role: m.Role, Content: m.Content, ToolCallID: m.ToolCallID}
+		for _, call := range m.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, openaiv1.ChatToolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: openaiv1.ChatToolCallFunction{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+		messages = append(messages, msg)
+	}
+
+	request := openaiv1.ChatCompletionRequest{
+		Model:       req.Model,
+		Messages:    messages,
+		Temperature: req.Temperature,
+	}
+	for _, t := range req.Tools {
+		request.Tools = append(request.Tools, openaiv1.Tool{
+			Type:     "function",
+			Function: &openaiv1.ToolFunction{Name: t.Name, Description: t.Description, Parameters: t.Parameters},
+		})
+	}
+	if req.Schema != nil {
+		strict := true
+		request.ResponseFormat = &openaiv1.ChatResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &openaiv1.ChatJSONSchema{Name: req.Schema.Name, Schema: req.Schema.Schema, Strict: &strict},
+		}
+	}
+
+	var completion openaiv1.ChatCompletion
+	var err error
+	if onDelta != nil {
+		completion, err = p.client.ChatCompletionsCreateStream(request, onDelta)
+	} else {
+		completion, err = p.client.ChatCompletionsCreate(request)
+	}
+	if err != nil {
+		return Response{}, err
+	}
+	if len(completion.Choices) == 0 {
+		return Response{}, fmt.Errorf("completion has no choices")
+	}
+
+	message := completion.Choices[0].Message
+	res := Response{
+		ID:    completion.ID,
+		Model: completion.Model,
+		Text:  message.Content,
+		Usage: Usage{
+			InputTokens:  completion.Usage.PromptTokens + completion.Usage.InputTokens,
+			OutputTokens: completion.Usage.CompletionTokens + completion.Usage.OutputTokens,
+		},
+	}
+	for _, call := range message.ToolCalls {
+		res.ToolCalls = append(res.ToolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+	}
+	return res, nil
+}
@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Fake is a deterministic provider without network, for tests and
+// environments without model access. The same request always gets the same
+// response:
+//   - with a schema it answers with a sample object of the schema
+//   - a user message "call <tool> {json}" calls that tool when it is offered
+//   - a tool message is answered with its output
+//   - anything else is echoed
+type Fake struct{}
+
+func (Fake) Name() string   { return ProviderFake }
+func (Fake) Stateful() bool { return false }
+
+func (Fake) Respond(ctx context.Context, req Request, onDelta func(string)) (Response, error) {
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+
+	id := fakeID(req)
+	res := Response{ID: "fake_" + id, Model: req.Model}
+
+	last := Message{}
+	if len(req.Input) > 0 {
+		last = req.Input[len(req.Input)-1]
+	}
+
+	switch {
+	case req.Schema != nil:
+		raw, err := json.Marshal(fakeValue(req.Schema.Schema, req.Schema.Name))
+		if err != nil {
+			return Response{}, err
+		}
+		res.Text = string(raw)
+	case last.Role == "tool":
+		res.Text = "Tool result: " + last.Content
+	case last.Role == "user" && strings.HasPrefix(last.Content, "call "):
+		name, args, _ := strings.Cut(strings.TrimPrefix(last.Content, "call "), " ")
+		if fakeHasTool(req.Tools, name) {
+			args = strings.TrimSpace(args)
+			if args == "" {
+				args = "{}"
+			}
+			res.ToolCalls = []ToolCall{{ID: "call_" + id[:8], Name: name, Arguments: args}}
+			break
+		}
+		res.Text = fmt.Sprintf("Tool %s is not available", name)
+	default:
+		res.Text = "Fake response to: " + last.Content
+	}
+
+	if onDelta != nil && res.Text != "" {
+		words := strings.SplitAfter(res.Text, " ")
+		for _, word := range words {
+			onDelta(word)
+		}
+	}
+
+	input := []string{req.Instructions}
+	for _, m := range req.Input {
+		input = append(input, m.Content)
+	}
+	res.Usage = Usage{
+		InputTokens:  len(strings.Fields(strings.Join(input, " "))),
+		OutputTokens: len(strings.Fields(res.Text)) + len(res.ToolCalls),
+	}
+	return res, nil
+}
+
+func fakeID(req Request) string {
+	raw, _ := json.Marshal(req)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])[:24]
+}
+
+func fakeHasTool(tools []Tool, name string) bool {
+	for _, t := range tools {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeValue builds a sample value of a JSON schema: the first enum value,
+// the property name for strings, zero for numbers and false for booleans
+func fakeValue(schema map[string]interface{}, name string) interface{} {
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	if enum, ok := schema["enum"].([]string); ok && len(enum) > 0 {
+		return enum[0]
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj := map[string]interface{}{}
+		props, _ := schema["properties"].(map[string]interface{})
+		for key, prop := range props {
+			propSchema, _ := prop.(map[string]interface{})
+			obj[key] = fakeValue(propSchema, key)
+		}
+		return obj
+	case "array":
+		items, _ := schema["items"].(map[string]interface{})
+		return []interface{}{fakeValue(items, name)}
+	case "number", "integer":
+		return 0
+	case "boolean":
+		return false
+	case "null":
+		return nil
+	}
+	return name
+}
@@ -0,0 +1,64 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestFakeStructuredOutput(t *testing.T) {
+	resp, err := Fake{}.Respond(context.Background(), Request{
+		Model: "test",
+		Input: []Message{{Role: "user", Content: "I'd like to book a facial next week"}},
+		Schema: &Schema{Name: "extraction", Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"intent":  map[string]interface{}{"type": "string", "enum": []string{"booking", "question"}},
+				"urgency": map[string]interface{}{"type": "integer"},
+				"vip":     map[string]interface{}{"type": "boolean"},
+			},
+		}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(resp.Text), &result); err != nil {
+		t.Fatalf("invalid json %q: %v", resp.Text, err)
+	}
+	if result["intent"] != "booking" || result["urgency"] != float64(0) || result["vip"] != false {
+		t.Errorf("unexpected result %v", result)
+	}
+}
+
+func TestFakeToolLoop(t *testing.T) {
+	provider := Fake{}
+	conv := Conversation{Provider: provider}
+	conv.Add(Message{Role: "user", Content: `call find_contact {"email":"a@b.c"}`})
+
+	tools := []Tool{{Name: "find_contact"}}
+	resp, err := provider.Respond(context.Background(), conv.Request(Request{Model: "test", Tools: tools}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Arguments != `{"email":"a@b.c"}` {
+		t.Fatalf("expected a find_contact call, got %+v", resp.ToolCalls)
+	}
+	conv.Done(resp)
+	conv.Add(Message{Role: "tool", ToolCallID: resp.ToolCalls[0].ID, Content: "found"})
+
+	deltas := ""
+	resp, err = provider.Respond(context.Background(), conv.Request(Request{Model: "test", Tools: tools}), func(d string) { deltas += d })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "Tool result: found" || deltas != resp.Text {
+		t.Errorf("unexpected answer %q, streamed %q", resp.Text, deltas)
+	}
+
+	again, _ := provider.Respond(context.Background(), conv.Request(Request{Model: "test", Tools: tools}), nil)
+	if again.ID != resp.ID {
+		t.Error("expected the same response for the same request")
+	}
+}
@@ -0,0 +1,139 @@
+// Package llm is the provider interface the AI features run on. Providers
+// are the OpenAI Responses API, any OpenAI-compatible chat completions API
+// and a deterministic fake for tests and offline environments.
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	ProviderOpenAI           = "openai"
+	ProviderOpenAICompatible = "openai-compatible"
+	ProviderFake             = "fake"
+)
+
+type (
+	Provider interface {
+		// Name keys the model pricing of the provider
+		Name() string
+		// Stateful providers keep the conversation: a request continues from
+		// PreviousResponseID and only carries the new input. Stateless ones
+		// need the whole transcript every time.
+		Stateful() bool
+		// Respond creates one model response. Text deltas are passed to
+		// onDelta while the response is generated, onDelta may be nil.
+		Respond(ctx context.Context, req Request, onDelta func(string)) (Response, error)
+	}
+
+	Request struct {
+		Model        string
+		Instructions string
+		Input        []Message
+		Tools        []Tool
+		// HostedTools are the provider's built-in tools, e.g. web_search,
+		// passed as they are. Providers without them ignore these.
+		HostedTools        []map[string]interface{}
+		Schema             *Schema // answer with a JSON object of this schema
+		Temperature        *float64
+		PreviousResponseID string
+		Store              bool // keep the response so it can be continued
+	}
+
+	Message struct {
+		Role       string // user, assistant or tool
+		Content    string
+		ToolCalls  []ToolCall // tools called by an assistant message
+		ToolCallID string     // the call a tool message answers
+	}
+
+	Tool struct {
+		Name        string
+		Description string
+		Parameters  map[string]interface{} // JSON schema of the arguments
+	}
+
+	ToolCall struct {
+		ID        string
+		Name      string
+		Arguments string // JSON object
+	}
+
+	Schema struct {
+		Name   string
+		Schema map[string]interface{}
+	}
+
+	Response struct {
+		ID        string
+		Model     string
+		Text      string
+		ToolCalls []ToolCall
+		Usage     Usage
+	}
+
+	Usage struct {
+		InputTokens  int
+		OutputTokens int
+	}
+
+	Settings struct {
+		Provider     string // openai (default), openai-compatible or fake
+		APIKey       string
+		BaseURL      string
+		Organization string
+		Project      string
+	}
+)
+
+// New returns the provider the settings select
+func New(s Settings) (Provider, error) {
+	switch s.Provider {
+	case "", ProviderOpenAI:
+		return newOpenAI(s)
+	case ProviderOpenAICompatible:
+		return newCompatible(s)
+	case ProviderFake:
+		return Fake{}, nil
+	}
+	return nil, fmt.Errorf("unknown ai provider %q", s.Provider)
+}
+
+// Conversation builds the requests of a multi-turn exchange, e.g. a tool
+// calling loop. Stateful providers get the new messages and the previous
+// response id, stateless ones the whole transcript.
+type Conversation struct {
+	Provider           Provider
+	PreviousResponseID string
+
+	transcript []Message
+	pending    []Message
+}
+
+// Add appends messages to send with the next request
+func (c *Conversation) Add(messages ...Message) {
+	c.transcript = append(c.transcript, messages...)
+	c.pending = append(c.pending, messages...)
+}
+
+// Request fills the input of the request
+func (c *Conversation) Request(req Request) Request {
+	if c.Provider.Stateful() {
+		req.Input = append([]Message{}, c.pending...)
+		req.PreviousResponseID = c.PreviousResponseID
+		req.Store = true
+	} else {
+		req.Input = append([]Message{}, c.transcript...)
+	}
+	return req
+}
+
+// Done records the response as the next assistant message
+func (c *Conversation) Done(resp Response) {
+	c.pending = nil
+	c.transcript = append(c.transcript, Message{Role: "assistant", Content: resp.Text, ToolCalls: resp.ToolCalls})
+	if c.Provider.Stateful() {
+		c.PreviousResponseID = resp.ID
+	}
+}
@@ -0,0 +1,125 @@
+package llm
+
+import (
+	openaiv1 "client-runaway-zenoti/packages/openaiV1"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// openAI runs on the OpenAI Responses API, which keeps conversations
+type openAI struct {
+	client openaiv1.Client
+}
+
+func newOpenAI(s Settings) (Provider, error) {
+	client, err := newOpenAIClient(s)
+	if err != nil {
+		return nil, err
+	}
+	return openAI{client: client}, nil
+}
+
+func newOpenAIClient(s Settings) (openaiv1.Client, error) {
+	if s.APIKey == "" {
+		return openaiv1.Client{}, fmt.Errorf("ai api key is required")
+	}
+	service := openaiv1.Service{
+		APIKey:       s.APIKey,
+		BaseURL:      s.BaseURL,
+		Organization: s.Organization,
+		Project:      s.Project,
+	}
+	return service.NewClient("")
+}
+
+func (p openAI) Name() string   { return ProviderOpenAI }
+func (p openAI) Stateful() bool { return true }
+
+func (p openAI) Respond(ctx context.Context, req Request, onDelta func(string)) (Response, error) {
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+
+	input := []any{}
+	for _, m := range req.Input {
+		switch {
+		case m.Role == "tool":
+			input = append(input, openaiv1.ResponseFunctionCallOutput{Type: "function_call_output", CallID: m.ToolCallID, Output: m.Content})
+		case m.Role == "assistant" && len(m.ToolCalls) > 0:
+			if m.Content != "" {
+				input = append(input, openaiv1.ResponseInputMessage{Role: m.Role, Content: m.Content})
+			}
+			for _, call := range m.ToolCalls {
+				input = append(input, openaiv1.ResponseFunctionCall{Type: "function_call", CallID: call.ID, Name: call.Name, Arguments: call.Arguments})
+			}
+		default:
+			input = append(input, openaiv1.ResponseInputMessage{Role: m.Role, Content: m.Content})
+		}
+	}
+
+	tools := make([]openaiv1.ResponseTool, 0, len(req.Tools)+len(req.HostedTools))
+	for _, t := range req.Tools {
+		tools = append(tools, openaiv1.ResponseTool{Type: "function", Name: t.Name, Description: t.Description, Parameters: t.Parameters})
+	}
+	for _, hosted := range req.HostedTools {
+		raw, err := json.Marshal(hosted)
+		if err != nil {
+			return Response{}, err
+		}
+		tool := openaiv1.ResponseTool{}
+		if err := json.Unmarshal(raw, &tool); err != nil {
+			return Response{}, fmt.Errorf("invalid hosted tool: %w", err)
+		}
+		tools = append(tools, tool)
+	}
+
+	store := req.Store
+	request := openaiv1.ResponseRequest{
+		Model:              req.Model,
+		Instructions:       req.Instructions,
+		Input:              input,
+		Tools:              tools,
+		Temperature:        req.Temperature,
+		PreviousResponseID: req.PreviousResponseID,
+		Store:              &store,
+	}
+	if req.Schema != nil {
+		strict := true
+		request.Text = &openaiv1.ResponseText{Format: openaiv1.ResponseTextFormat{
+			Type:   "json_schema",
+			Name:   req.Schema.Name,
+			Schema: req.Schema.Schema,
+			Strict: &strict,
+		}}
+	}
+
+	var resp openaiv1.Response
+	var err error
+	if onDelta != nil {
+		resp, err = p.client.ResponsesCreateStream(request, func(event openaiv1.ResponseStreamEvent) {
+			if event.Type == "response.output_text.delta" {
+				onDelta(event.Delta)
+			}
+		})
+	} else {
+		resp, err = p.client.ResponsesCreate(request)
+	}
+	if err != nil {
+		return Response{}, err
+	}
+
+	res := Response{
+		ID:    resp.ID,
+		Model: resp.Model,
+		Text:  resp.OutputText(),
+		Usage: Usage{
+			InputTokens:  resp.Usage.InputTokens + resp.Usage.PromptTokens,
+			OutputTokens: resp.Usage.OutputTokens + resp.Usage.CompletionTokens,
+		},
+	}
+	for _, call := range resp.FunctionCalls() {
+		res.ToolCalls = append(res.ToolCalls, ToolCall{ID: call.CallID, Name: call.Name, Arguments: call.Arguments})
+	}
+	return res, nil
+}
@@ -0,0 +1,103 @@
+package mailer
+
+import (
+	"client-runaway-zenoti/internal/config"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Configured reports whether SMTP settings are present
+func Configured() bool {
+	s := config.Confs.Settings.Smtp
+	return s.Host != "" && s.From != ""
+}
+
+// Send sends a plain text email through the configured SMTP server
+func Send(to []string, subject, body string) error {
+	return send(to, strings.Join([]string{
+		"From: " + config.Confs.Settings.Smtp.From,
+		"To: " + strings.Join(to, ", "),
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n"))
+}
+
+// SendWithAttachments sends a plain text email with files attached
+func SendWithAttachments(to []string, subject, body string, attachments []Attachment) error {
+	boundary := uuid.NewString()
+
+	parts := []string{
+		"From: " + config.Confs.Settings.Smtp.From,
+		"To: " + strings.Join(to, ", "),
+		"Subject: " + mime.QEncoding.Encode("UTF-8", subject),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/mixed; boundary=" + boundary,
+		"",
+		"--" + boundary,
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}
+	for _, a := range attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		parts = append(parts,
+			"--"+boundary,
+			fmt.Sprintf("Content-Type: %s; name=%q", contentType, a.Name),
+			"Content-Transfer-Encoding: base64",
+			fmt.Sprintf("Content-Disposition: attachment; filename=%q", a.Name),
+			"",
+			wrap(base64.StdEncoding.EncodeToString(a.Data), 76),
+		)
+	}
+	parts = append(parts, "--"+boundary+"--", "")
+
+	return send(to, strings.Join(parts, "\r\n"))
+}
+
+func send(to []string, msg string) error {
+	s := config.Confs.Settings.Smtp
+	if !Configured() {
+		return errors.New("smtp is not configured")
+	}
+
+	port := s.Port
+	if port == 0 {
+		port = 587
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	return smtp.SendMail(fmt.Sprintf("%s:%d", s.Host, port), auth, s.From, to, []byte(msg))
+}
+
+// wrap splits s into lines of n characters, as base64 bodies must be
+func wrap(s string, n int) string {
+	lines := make([]string, 0, len(s)/n+1)
+	for len(s) > n {
+		lines = append(lines, s[:n])
+		s = s[n:]
+	}
+	return strings.Join(append(lines, s), "\r\n")
+}
@@ -0,0 +1,134 @@
+package mcp
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/automator"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// actionToolNames contains the automator actions registered as customer tools.
+// A customer key only sees and calls the ones on its allowlist.
+var actionToolNames = map[string]bool{}
+
+// IsActionTool checks if a tool name is a bridged automator action
+func IsActionTool(toolName string) bool {
+	return actionToolNames[toolName]
+}
+
+// registerActionTools publishes the automator catalog actions as tools. The
+// input schema comes from the node fields, the result from the port payload.
+func (m *MCPServer) registerActionTools() {
+	for _, t := range automator.ActionTools() {
+		actionToolNames[t.Name] = true
+		m.server.AddTool(newActionTool(t), m.actionToolHandler(t))
+	}
+}
+
+func newActionTool(t automator.ActionTool) mcp.Tool {
+	ports := []string{}
+	for _, p := range t.Node.Ports {
+		if p.Name != "error" {
+			ports = append(ports, p.Name)
+		}
+	}
+
+	opts := []mcp.ToolOption{
+		mcp.WithDescription(fmt.Sprintf("%s Returns the port the action exited through (%s) with its payload.", t.Node.Description, strings.Join(ports, ", "))),
+		mcp.WithTitleAnnotation(t.Node.Title),
+		mcp.WithString("location_id",
+			mcp.Required(),
+			mcp.Description("The location ID to run the action for"),
+		),
+	}
+
+	for _, f := range t.Node.Fields {
+		// the location comes from location_id
+		if f.Key == "locationId" {
+			continue
+		}
+
+		propOpts := []mcp.PropertyOption{}
+		desc := f.Label
+		if desc == "" {
+			desc = f.Key
+		}
+		if f.Type == "datetime" {
+			desc += " (YYYY-MM-DD HH:MM or RFC3339)"
+		}
+		propOpts = append(propOpts, mcp.Description(desc))
+		if f.Required {
+			propOpts = append(propOpts, mcp.Required())
+		}
+
+		switch f.Type {
+		case "number":
+			opts = append(opts, mcp.WithNumber(f.Key, propOpts...))
+		case "bool", "boolean":
+			opts = append(opts, mcp.WithBoolean(f.Key, propOpts...))
+		default:
+			if len(f.SelectOptions) > 0 {
+				propOpts = append(propOpts, mcp.Enum(f.SelectOptions...))
+			}
+			opts = append(opts, mcp.WithString(f.Key, propOpts...))
+		}
+	}
+
+	return mcp.NewTool(t.Name, opts...)
+}
+
+func (m *MCPServer) actionToolHandler(t automator.ActionTool) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		apiKey, locationID, err := m.authenticateAndCheckRLS(ctx, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if !apiKey.CanUseTool(t.Name) {
+			return mcp.NewToolResultError("access denied: this tool is not enabled for the API key"), nil
+		}
+
+		var location models.Location
+		err = db.DB.
+			Where("id = ? AND profile_id = ?", locationID, apiKey.ProfileID).
+			Preload("ZenotiApiObj").
+			Preload("CerboApiObj").
+			First(&location).Error
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Location not found: %v", err)), nil
+		}
+
+		args := request.GetArguments()
+		delete(args, "location_id")
+
+		result, err := t.Run(ctx, args, location)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		jsonBytes, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError("Failed to serialize response"), nil
+		}
+
+		return mcp.NewToolResultText(string(jsonBytes)), nil
+	}
+}
+
+// filterActionTools drops the action tools the key isn't allowed to use
+func filterActionTools(apiKey *models.MCPApiKey, tools []mcp.Tool) []mcp.Tool {
+	filtered := make([]mcp.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if IsActionTool(tool.Name) && (apiKey == nil || !apiKey.CanUseTool(tool.Name)) {
+			continue
+		}
+		filtered = append(filtered, tool)
+	}
+	return filtered
+}
@@ -0,0 +1,227 @@
+package mcp
+
+import (
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/automator"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// automationToolSyncInterval is how stale the registered automation tools may
+// get before a listing reloads them
+const automationToolSyncInterval = 30 * time.Second
+
+// automationTool is an automation registered as a tool, bound to its location
+type automationTool struct {
+	automationID string
+	locationID   string
+	tool         mcp.Tool
+	signature    string
+}
+
+// automationTools holds the automations published through an mcp.tool trigger,
+// keyed by tool name. A customer key sees the ones in its allowed locations.
+var automationTools = struct {
+	sync.RWMutex
+	byName   map[string]automationTool
+	syncedAt time.Time
+}{byName: map[string]automationTool{}}
+
+var toolNameSanitizer = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// IsAutomationTool checks if a tool name is a published automation
+func IsAutomationTool(toolName string) bool {
+	automationTools.RLock()
+	defer automationTools.RUnlock()
+	_, ok := automationTools.byName[toolName]
+	return ok
+}
+
+// automationToolName makes the declared name a valid, unique tool name,
+// e.g. "Book a consult" -> book_a_consult_1a2b3c4d
+func automationToolName(t automator.McpToolAutomation) string {
+	name := strings.Trim(toolNameSanitizer.ReplaceAllString(strings.ToLower(t.Name), "_"), "_")
+	if len(name) > 50 {
+		name = name[:50]
+	}
+	suffix := strings.ReplaceAll(t.Automation.ID, "-", "")
+	if len(suffix) > 8 {
+		suffix = suffix[:8]
+	}
+	if name == "" {
+		return "automation_" + suffix
+	}
+	return name + "_" + suffix
+}
+
+func newAutomationTool(name string, t automator.McpToolAutomation) mcp.Tool {
+	opts := []mcp.ToolOption{
+		mcp.WithDescription(t.Description),
+		mcp.WithTitleAnnotation(t.Name),
+	}
+
+	for _, f := range t.Inputs {
+		propOpts := []mcp.PropertyOption{mcp.Description(f.Key)}
+		if f.Required {
+			propOpts = append(propOpts, mcp.Required())
+		}
+
+		switch f.Type {
+		case "number":
+			opts = append(opts, mcp.WithNumber(f.Key, propOpts...))
+		case "bool", "boolean":
+			opts = append(opts, mcp.WithBoolean(f.Key, propOpts...))
+		default:
+			opts = append(opts, mcp.WithString(f.Key, propOpts...))
+		}
+	}
+
+	return mcp.NewTool(name, opts...)
+}
+
+// syncAutomationTools registers the active mcp.tool automations, re-registers
+// the changed ones and removes the ones that were deactivated or deleted
+func (m *MCPServer) syncAutomationTools() {
+	tools, err := automator.ListMcpToolAutomations(nil)
+	if err != nil {
+		log.Printf("mcp: sync automation tools: %s", err.Error())
+		return
+	}
+
+	automationTools.Lock()
+	defer automationTools.Unlock()
+
+	current := make(map[string]automationTool, len(tools))
+	for _, t := range tools {
+		name := automationToolName(t)
+		tool := newAutomationTool(name, t)
+		raw, _ := json.Marshal(tool)
+		entry := automationTool{
+			automationID: t.Automation.ID,
+			locationID:   t.Automation.LocationId,
+			tool:         tool,
+			signature:    t.Automation.LocationId + string(raw),
+		}
+		current[name] = entry
+
+		if prev, ok := automationTools.byName[name]; !ok || prev.signature != entry.signature {
+			m.server.AddTool(tool, m.handleAutomationTool)
+		}
+	}
+
+	stale := []string{}
+	for name := range automationTools.byName {
+		if _, ok := current[name]; !ok {
+			stale = append(stale, name)
+		}
+	}
+	if len(stale) > 0 {
+		m.server.DeleteTools(stale...)
+	}
+
+	automationTools.byName = current
+	automationTools.syncedAt = time.Now()
+}
+
+// registerAutomationTools publishes the mcp.tool automations and keeps them in
+// sync with the automator
+func (m *MCPServer) registerAutomationTools() {
+	m.syncAutomationTools()
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			m.syncAutomationTools()
+		}
+	}()
+}
+
+// listAutomationTools returns the automation tools in the key's allowed
+// locations, reloading them first if they are stale
+func (m *MCPServer) listAutomationTools(apiKey *models.MCPApiKey) []mcp.Tool {
+	automationTools.RLock()
+	stale := time.Since(automationTools.syncedAt) > automationToolSyncInterval
+	automationTools.RUnlock()
+	if stale {
+		m.syncAutomationTools()
+	}
+
+	automationTools.RLock()
+	defer automationTools.RUnlock()
+
+	tools := []mcp.Tool{}
+	for _, t := range automationTools.byName {
+		if apiKey.CanAccessLocation(t.locationID) {
+			tools = append(tools, t.tool)
+		}
+	}
+	return tools
+}
+
+// filterAutomationTools replaces the registered automation tools with the
+// ones the key may use
+func (m *MCPServer) filterAutomationTools(apiKey *models.MCPApiKey, tools []mcp.Tool) []mcp.Tool {
+	filtered := make([]mcp.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if !IsAutomationTool(tool.Name) {
+			filtered = append(filtered, tool)
+		}
+	}
+	if apiKey == nil {
+		return filtered
+	}
+	return append(filtered, m.listAutomationTools(apiKey)...)
+}
+
+// handleAutomationTool runs the automation behind the called tool and returns
+// the payload of its automation.return node
+func (m *MCPServer) handleAutomationTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	apiKey, err := m.auth.ValidateAndGetKey(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("authentication failed: %v", err)), nil
+	}
+
+	automationTools.RLock()
+	entry, ok := automationTools.byName[request.Params.Name]
+	automationTools.RUnlock()
+	if !ok {
+		return mcp.NewToolResultError("tool not found"), nil
+	}
+
+	if !m.auth.CanAccessLocation(apiKey, entry.locationID) {
+		return mcp.NewToolResultError("access denied: API key does not have permission to access this location"), nil
+	}
+
+	tool, err := automator.GetMcpToolAutomation(entry.automationID)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	// the automation may have moved since the tool was registered
+	if !m.auth.CanAccessLocation(apiKey, tool.Automation.LocationId) {
+		return mcp.NewToolResultError("access denied: API key does not have permission to access this location"), nil
+	}
+
+	result, err := tool.Run(ctx, request.GetArguments())
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to serialize response"), nil
+	}
+
+	if result.Status != models.RunSuccess {
+		return mcp.NewToolResultError(string(jsonBytes)), nil
+	}
+	return mcp.NewToolResultText(string(jsonBytes)), nil
+}
@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -53,7 +54,7 @@ func (m *MCPServer) createToolFilter() server.ToolFilterFunc {
 		apiKey, err := m.auth.ValidateAndGetKey(ctx)
 		if err != nil {
 			// No valid key - return only customer tools (they'll still fail auth)
-			return filterOutInternalTools(tools)
+			return m.filterAutomationTools(nil, filterActionTools(nil, filterOutInternalTools(tools)))
 		}
 
 		if apiKey.IsInternal {
@@ -61,8 +62,9 @@ func (m *MCPServer) createToolFilter() server.ToolFilterFunc {
 			return filterToInternalTools(tools)
 		}
 
-		// Customer key: return ONLY customer tools
-		return filterOutInternalTools(tools)
+		// Customer key: return ONLY customer tools, with actions from its allowlist
+		// and automations from its locations
+		return m.filterAutomationTools(apiKey, filterActionTools(apiKey, filterOutInternalTools(tools)))
 	}
 }
 
@@ -95,6 +97,7 @@ func filterOutInternalTools(tools []mcp.Tool) []mcp.Tool {
 // createToolMiddleware returns middleware that blocks cross-access:
 // - Internal tools can only be called by internal keys
 // - Customer tools can only be called by customer keys
+// It also enforces the key's rate limit and daily quota and meters the calls.
 func (m *MCPServer) createToolMiddleware() server.ToolHandlerMiddleware {
 	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
 		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -114,7 +117,21 @@ func (m *MCPServer) createToolMiddleware() server.ToolHandlerMiddleware {
 				return mcp.NewToolResultError("access denied: this tool is not available for internal keys"), nil
 			}
 
-			return next(ctx, request)
+			// Per-key limits
+			if !allowRate(apiKey) {
+				recordToolRejected(apiKey, toolName)
+				return mcp.NewToolResultError(fmt.Sprintf("rate limit exceeded: %d calls per minute", apiKey.RateLimitPerMinute)), nil
+			}
+			if quotaExceeded(apiKey) {
+				recordToolRejected(apiKey, toolName)
+				return mcp.NewToolResultError(fmt.Sprintf("daily quota exceeded: %d calls per day", apiKey.DailyQuota)), nil
+			}
+
+			start := time.Now()
+			result, err := next(ctx, request)
+			recordToolCall(apiKey, toolName, time.Since(start), err != nil || (result != nil && result.IsError))
+
+			return result, err
 		}
 	}
 }
@@ -480,8 +497,8 @@ func (m *MCPServer) handleGetCatalogList(ctx context.Context, request mcp.CallTo
 
 // runSubAssistant runs a sub-assistant with MCP tools and returns the response.
 // It uses the internal MCP API key from the authenticated request.
-func (m *MCPServer) runSubAssistant(ctx context.Context, mcpAPIKey string, assistantID string, message string) (string, error) {
-	return svc_internal_assistant.RunSubAssistant(ctx, mcpAPIKey, assistantID, message)
+func (m *MCPServer) runSubAssistant(ctx context.Context, profileID uint, mcpAPIKey string, assistantID string, message string) (string, error) {
+	return svc_internal_assistant.RunSubAssistant(ctx, profileID, mcpAPIKey, assistantID, message)
 }
 
 func (m *MCPServer) handleRunBuilderAssistant(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -511,7 +528,7 @@ func (m *MCPServer) handleRunBuilderAssistant(ctx context.Context, request mcp.C
 	}
 
 	inputJSON, _ := json.Marshal(input)
-	response, err := m.runSubAssistant(ctx, apiKey.PlainKey, assistantID, string(inputJSON))
+	response, err := m.runSubAssistant(ctx, apiKey.ProfileID, apiKey.PlainKey, assistantID, string(inputJSON))
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Builder assistant error: %v", err)), nil
 	}
@@ -549,7 +566,7 @@ func (m *MCPServer) handleRunValidatorAssistant(ctx context.Context, request mcp
 	}
 
 	inputJSON, _ := json.Marshal(input)
-	response, err := m.runSubAssistant(ctx, apiKey.PlainKey, assistantID, string(inputJSON))
+	response, err := m.runSubAssistant(ctx, apiKey.ProfileID, apiKey.PlainKey, assistantID, string(inputJSON))
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Validator assistant error: %v", err)), nil
 	}
@@ -590,7 +607,7 @@ func (m *MCPServer) handleRunHelperAssistant(ctx context.Context, request mcp.Ca
 	}
 
 	inputJSON, _ := json.Marshal(input)
-	response, err := m.runSubAssistant(ctx, apiKey.PlainKey, assistantID, string(inputJSON))
+	response, err := m.runSubAssistant(ctx, apiKey.ProfileID, apiKey.PlainKey, assistantID, string(inputJSON))
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Helper assistant error: %v", err)), nil
 	}
@@ -0,0 +1,130 @@
+package mcp
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// tokenBucket refills at the key's per-minute rate up to its burst size
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateBuckets holds the token buckets per API key. They live in memory, so
+// each MCP server instance limits on its own.
+var rateBuckets = struct {
+	sync.Mutex
+	byKey map[uint]*tokenBucket
+}{byKey: map[uint]*tokenBucket{}}
+
+// allowRate takes a token from the key's bucket, false if it is empty
+func allowRate(apiKey *models.MCPApiKey) bool {
+	if apiKey.RateLimitPerMinute <= 0 {
+		return true
+	}
+
+	burst := float64(apiKey.RateLimitBurst)
+	if burst <= 0 {
+		burst = float64(apiKey.RateLimitPerMinute)
+	}
+	perSecond := float64(apiKey.RateLimitPerMinute) / 60
+
+	rateBuckets.Lock()
+	defer rateBuckets.Unlock()
+
+	now := time.Now()
+	bucket, ok := rateBuckets.byKey[apiKey.ID]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		rateBuckets.byKey[apiKey.ID] = bucket
+	}
+
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*perSecond)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// quotaExceeded checks the key's tool calls today against its daily quota
+func quotaExceeded(apiKey *models.MCPApiKey) bool {
+	if apiKey.DailyQuota <= 0 {
+		return false
+	}
+
+	var calls int64
+	err := db.DB.Model(&models.MCPToolUsageDaily{}).
+		Where("mcp_api_key_id = ? AND usage_date = ?", apiKey.ID, usageDate(time.Now())).
+		Select("COALESCE(SUM(calls), 0)").
+		Scan(&calls).Error
+	if err != nil {
+		// don't lock customers out because metering is unavailable
+		log.Printf("mcp: check daily quota for key %d: %s", apiKey.ID, err.Error())
+		return false
+	}
+	return calls >= int64(apiKey.DailyQuota)
+}
+
+// recordToolCall adds a finished call to the key's daily usage of the tool
+func recordToolCall(apiKey *models.MCPApiKey, toolName string, latency time.Duration, failed bool) {
+	record := models.MCPToolUsageDaily{
+		ProfileID:      apiKey.ProfileID,
+		MCPApiKeyID:    apiKey.ID,
+		ToolName:       toolName,
+		UsageDate:      usageDate(time.Now()),
+		Calls:          1,
+		TotalLatencyMs: latency.Milliseconds(),
+		MaxLatencyMs:   latency.Milliseconds(),
+	}
+	if failed {
+		record.Errors = 1
+	}
+	saveToolUsage(record)
+}
+
+// recordToolRejected counts a call refused by the rate limit or quota
+func recordToolRejected(apiKey *models.MCPApiKey, toolName string) {
+	saveToolUsage(models.MCPToolUsageDaily{
+		ProfileID:   apiKey.ProfileID,
+		MCPApiKeyID: apiKey.ID,
+		ToolName:    toolName,
+		UsageDate:   usageDate(time.Now()),
+		Rejected:    1,
+	})
+}
+
+func saveToolUsage(record models.MCPToolUsageDaily) {
+	tbl := "mcp_tool_usage_dailies"
+
+	err := db.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "mcp_api_key_id"}, {Name: "tool_name"}, {Name: "usage_date"},
+		},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"calls":            gorm.Expr(tbl + ".calls + EXCLUDED.calls"),
+			"errors":           gorm.Expr(tbl + ".errors + EXCLUDED.errors"),
+			"rejected":         gorm.Expr(tbl + ".rejected + EXCLUDED.rejected"),
+			"total_latency_ms": gorm.Expr(tbl + ".total_latency_ms + EXCLUDED.total_latency_ms"),
+			"max_latency_ms":   gorm.Expr("GREATEST(" + tbl + ".max_latency_ms, EXCLUDED.max_latency_ms)"),
+			"updated_at":       time.Now().UTC(),
+		}),
+	}).Create(&record).Error
+	if err != nil {
+		log.Printf("mcp: record usage for key %d: %s", record.MCPApiKeyID, err.Error())
+	}
+}
+
+func usageDate(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
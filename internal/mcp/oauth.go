@@ -0,0 +1,723 @@
+package mcp
+
+import (
+	"client-runaway-zenoti/internal/config"
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/auth"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// OAuth 2.1 authorization server for MCP clients (authorization code + PKCE,
+// dynamic client registration). The tokens it issues are MCPApiKey rows with
+// an expiry, so the tools see the same location RLS, limits and metering as
+// for static keys.
+
+const (
+	oauthScopeTools = "mcp:tools"
+
+	oauthCodeTTL         = 10 * time.Minute // from login to code exchange
+	oauthAccessTokenTTL  = time.Hour
+	oauthRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var oauthScopes = []string{oauthScopeTools}
+
+// oauthError is an RFC 6749 error response
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e oauthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// authorizeParams are the validated parameters of an authorization request
+type authorizeParams struct {
+	Client        models.MCPOAuthClient
+	RedirectURI   string
+	State         string
+	Scope         string
+	CodeChallenge string
+}
+
+// registerOAuthRoutes adds the discovery, registration, authorization and
+// token endpoints next to the MCP endpoint
+func (m *MCPServer) registerOAuthRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /.well-known/oauth-protected-resource", handleProtectedResourceMetadata)
+	mux.HandleFunc("GET /.well-known/oauth-protected-resource/mcp", handleProtectedResourceMetadata)
+	mux.HandleFunc("GET /.well-known/oauth-authorization-server", handleAuthorizationServerMetadata)
+	mux.HandleFunc("POST /oauth/register", handleRegisterClient)
+	mux.HandleFunc("GET /oauth/authorize", handleAuthorize)
+	mux.HandleFunc("POST /oauth/authorize", handleAuthorizeSubmit)
+	mux.HandleFunc("POST /oauth/token", handleToken)
+	mux.HandleFunc("POST /oauth/revoke", handleRevoke)
+}
+
+// requireBearer answers requests without a valid key with a 401 pointing
+// MCP clients at the authorization server
+func (m *MCPServer) requireBearer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := extractAuthContext(r.Context(), r)
+		if _, err := m.auth.ValidateAndGetKey(ctx); err != nil {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", resource_metadata="%s/.well-known/oauth-protected-resource"`, issuerURL(r)))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// issuerURL is the public base URL of the MCP server: the configured MCPURL
+// without the endpoint path, or the URL the request came in on
+func issuerURL(r *http.Request) string {
+	if raw := strings.TrimSpace(config.Confs.Settings.MCPURL); raw != "" {
+		return strings.TrimSuffix(strings.TrimSuffix(raw, "/"), "/mcp")
+	}
+
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// ============================================================================
+// Discovery
+// ============================================================================
+
+func handleProtectedResourceMetadata(w http.ResponseWriter, r *http.Request) {
+	issuer := issuerURL(r)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"resource":                 issuer + "/mcp",
+		"authorization_servers":    []string{issuer},
+		"scopes_supported":         oauthScopes,
+		"bearer_methods_supported": []string{"header"},
+	})
+}
+
+func handleAuthorizationServerMetadata(w http.ResponseWriter, r *http.Request) {
+	issuer := issuerURL(r)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/oauth/authorize",
+		"token_endpoint":                        issuer + "/oauth/token",
+		"registration_endpoint":                 issuer + "/oauth/register",
+		"revocation_endpoint":                   issuer + "/oauth/revoke",
+		"scopes_supported":                      oauthScopes,
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "refresh_token"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"none"},
+	})
+}
+
+// ============================================================================
+// Dynamic client registration (RFC 7591)
+// ============================================================================
+
+type registerClientRequest struct {
+	ClientName              string   `json:"client_name"`
+	RedirectURIs            []string `json:"redirect_uris"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+}
+
+func handleRegisterClient(w http.ResponseWriter, r *http.Request) {
+	var req registerClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthError{"invalid_client_metadata", "invalid JSON body"})
+		return
+	}
+
+	if req.TokenEndpointAuthMethod != "" && req.TokenEndpointAuthMethod != "none" {
+		writeOAuthError(w, http.StatusBadRequest, oauthError{"invalid_client_metadata", "only public clients (token_endpoint_auth_method none) are supported"})
+		return
+	}
+	if len(req.RedirectURIs) == 0 {
+		writeOAuthError(w, http.StatusBadRequest, oauthError{"invalid_redirect_uri", "redirect_uris is required"})
+		return
+	}
+	for _, uri := range req.RedirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			writeOAuthError(w, http.StatusBadRequest, oauthError{"invalid_redirect_uri", err.Error()})
+			return
+		}
+	}
+
+	clientID, err := randomSecret("sbmcpc_", 16)
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, oauthError{"server_error", "unable to generate client id"})
+		return
+	}
+
+	name := strings.TrimSpace(req.ClientName)
+	if name == "" {
+		name = "MCP client"
+	}
+
+	client := models.MCPOAuthClient{
+		ClientID:     clientID,
+		ClientName:   name,
+		RedirectURIs: req.RedirectURIs,
+	}
+	if err := db.DB.Create(&client).Error; err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, oauthError{"server_error", "unable to register client"})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"client_id":                  client.ClientID,
+		"client_id_issued_at":        client.CreatedAt.Unix(),
+		"client_name":                client.ClientName,
+		"redirect_uris":              client.RedirectURIs,
+		"token_endpoint_auth_method": "none",
+		"grant_types":                []string{"authorization_code", "refresh_token"},
+		"response_types":             []string{"code"},
+	})
+}
+
+// validateRedirectURI allows https, plain http on loopback only, and the
+// private-use schemes native apps register
+func validateRedirectURI(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Fragment != "" {
+		return fmt.Errorf("invalid redirect uri %q", raw)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		return nil
+	case "http":
+		host := u.Hostname()
+		if host == "localhost" || host == "127.0.0.1" || host == "::1" {
+			return nil
+		}
+		return fmt.Errorf("redirect uri %q must use https", raw)
+	case "javascript", "data", "file", "vbscript":
+		return fmt.Errorf("redirect uri scheme %q is not allowed", u.Scheme)
+	}
+	return nil
+}
+
+// ============================================================================
+// Authorization endpoint
+// ============================================================================
+
+// parseAuthorizeParams validates an authorization request. Errors about the
+// client or redirect uri must be shown to the user, the others are sent to
+// the redirect uri.
+func parseAuthorizeParams(values url.Values) (params authorizeParams, redirect bool, err error) {
+	params = authorizeParams{State: values.Get("state")}
+
+	clientID := values.Get("client_id")
+	if clientID == "" || db.DB.Where("client_id = ?", clientID).First(&params.Client).Error != nil {
+		return params, false, errors.New("unknown client")
+	}
+
+	params.RedirectURI = values.Get("redirect_uri")
+	if params.RedirectURI == "" && len(params.Client.RedirectURIs) == 1 {
+		params.RedirectURI = params.Client.RedirectURIs[0]
+	}
+	if !slices.Contains(params.Client.RedirectURIs, params.RedirectURI) {
+		return params, false, errors.New("redirect uri is not registered for this client")
+	}
+
+	if values.Get("response_type") != "code" {
+		return params, true, oauthError{"unsupported_response_type", "response_type must be code"}
+	}
+
+	params.CodeChallenge = values.Get("code_challenge")
+	if params.CodeChallenge == "" || values.Get("code_challenge_method") != "S256" {
+		return params, true, oauthError{"invalid_request", "PKCE with code_challenge_method S256 is required"}
+	}
+
+	params.Scope = oauthScopeTools
+	if scope := strings.TrimSpace(values.Get("scope")); scope != "" {
+		for _, s := range strings.Fields(scope) {
+			if !slices.Contains(oauthScopes, s) {
+				return params, true, oauthError{"invalid_scope", fmt.Sprintf("unsupported scope %q", s)}
+			}
+		}
+		params.Scope = scope
+	}
+
+	return params, true, nil
+}
+
+func handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	params, redirect, err := parseAuthorizeParams(r.URL.Query())
+	if err != nil {
+		failAuthorize(w, r, params, err, redirect)
+		return
+	}
+
+	renderLoginPage(w, params, "")
+}
+
+// handleAuthorizeSubmit handles the two forms of the consent screen: the
+// login, which starts an authorization, and the location consent, which
+// completes it
+func handleAuthorizeSubmit(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+
+	if r.PostForm.Get("step") == "consent" {
+		handleConsent(w, r)
+		return
+	}
+
+	params, redirect, err := parseAuthorizeParams(r.PostForm)
+	if err != nil {
+		failAuthorize(w, r, params, err, redirect)
+		return
+	}
+
+	user, err := auth.VerifyCredentials(strings.TrimSpace(r.PostForm.Get("email")), r.PostForm.Get("password"))
+	if err != nil {
+		renderLoginPage(w, params, "Incorrect email or password")
+		return
+	}
+
+	member, err := models.GetProfileMember(user.ProfileID, user.ID)
+	if err != nil || !member.Role.AtLeast(models.RoleAdmin) {
+		renderLoginPage(w, params, "Connecting MCP clients requires the admin role")
+		return
+	}
+
+	var locations []models.Location
+	db.DB.Where("profile_id = ?", user.ProfileID).Order("name asc").Find(&locations)
+	allowed := make([]models.Location, 0, len(locations))
+	for _, l := range locations {
+		if member.CanAccessLocation(l.Id) {
+			allowed = append(allowed, l)
+		}
+	}
+
+	ticket, err := randomSecret("sbmcpt_", 32)
+	if err != nil {
+		http.Error(w, "unable to start authorization", http.StatusInternalServerError)
+		return
+	}
+
+	authorization := models.MCPOAuthAuthorization{
+		ConsentHash:   hashSecret(ticket),
+		ClientID:      params.Client.ClientID,
+		UserID:        user.ID,
+		ProfileID:     user.ProfileID,
+		RedirectURI:   params.RedirectURI,
+		State:         params.State,
+		Scope:         params.Scope,
+		CodeChallenge: params.CodeChallenge,
+		ExpiresAt:     time.Now().Add(oauthCodeTTL),
+	}
+	if err := db.DB.Create(&authorization).Error; err != nil {
+		http.Error(w, "unable to start authorization", http.StatusInternalServerError)
+		return
+	}
+
+	renderConsentPage(w, params.Client, user, allowed, ticket)
+}
+
+func handleConsent(w http.ResponseWriter, r *http.Request) {
+	ticket := r.PostForm.Get("ticket")
+
+	var authorization models.MCPOAuthAuthorization
+	err := db.DB.Where("consent_hash = ?", hashSecret(ticket)).First(&authorization).Error
+	if ticket == "" || err != nil || authorization.ApprovedAt != nil || time.Now().After(authorization.ExpiresAt) {
+		renderErrorPage(w, http.StatusBadRequest, "This authorization request has expired. Start again from your MCP client.")
+		return
+	}
+
+	if r.PostForm.Get("decision") != "allow" {
+		redirectWithParams(w, r, authorization.RedirectURI, url.Values{
+			"error": {"access_denied"},
+			"state": {authorization.State},
+		})
+		return
+	}
+
+	member, err := models.GetProfileMember(authorization.ProfileID, authorization.UserID)
+	if err != nil || !member.Role.AtLeast(models.RoleAdmin) {
+		renderErrorPage(w, http.StatusForbidden, "Connecting MCP clients requires the admin role")
+		return
+	}
+
+	locationIDs := r.PostForm["location_id"]
+	if len(locationIDs) == 0 {
+		renderErrorPage(w, http.StatusBadRequest, "Select at least one location. Start again from your MCP client.")
+		return
+	}
+	for _, id := range locationIDs {
+		var count int64
+		db.DB.Model(&models.Location{}).Where("id = ? AND profile_id = ?", id, authorization.ProfileID).Count(&count)
+		if count == 0 || !member.CanAccessLocation(id) {
+			renderErrorPage(w, http.StatusForbidden, "You don't have access to one of the selected locations")
+			return
+		}
+	}
+
+	code, err := randomSecret("sbmcpa_", 32)
+	if err != nil {
+		http.Error(w, "unable to issue code", http.StatusInternalServerError)
+		return
+	}
+
+	// conditional update so a ticket can only be approved once
+	now := time.Now()
+	res := db.DB.Model(&authorization).
+		Where("approved_at IS NULL").
+		Updates(map[string]any{
+			"code_hash":    hashSecret(code),
+			"location_ids": datatypes.JSONSlice[string](locationIDs),
+			"approved_at":  now,
+		})
+	if res.Error != nil || res.RowsAffected == 0 {
+		renderErrorPage(w, http.StatusBadRequest, "This authorization request has expired. Start again from your MCP client.")
+		return
+	}
+
+	redirectWithParams(w, r, authorization.RedirectURI, url.Values{
+		"code":  {code},
+		"state": {authorization.State},
+		"iss":   {issuerURL(r)},
+	})
+}
+
+// failAuthorize shows client errors on the page and sends the rest back to
+// the client's redirect uri
+func failAuthorize(w http.ResponseWriter, r *http.Request, params authorizeParams, err error, redirect bool) {
+	var oerr oauthError
+	if !redirect || !errors.As(err, &oerr) {
+		renderErrorPage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	redirectWithParams(w, r, params.RedirectURI, url.Values{
+		"error":             {oerr.Code},
+		"error_description": {oerr.Description},
+		"state":             {params.State},
+	})
+}
+
+func redirectWithParams(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		renderErrorPage(w, http.StatusBadRequest, "invalid redirect uri")
+		return
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// ============================================================================
+// Token endpoint
+// ============================================================================
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+func handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthError{"invalid_request", "invalid form body"})
+		return
+	}
+
+	var (
+		resp tokenResponse
+		err  error
+	)
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		resp, err = exchangeAuthorizationCode(r.PostForm)
+	case "refresh_token":
+		resp, err = exchangeRefreshToken(r.PostForm)
+	default:
+		err = oauthError{"unsupported_grant_type", "grant_type must be authorization_code or refresh_token"}
+	}
+
+	if err != nil {
+		var oerr oauthError
+		if !errors.As(err, &oerr) {
+			writeOAuthError(w, http.StatusInternalServerError, oauthError{"server_error", err.Error()})
+			return
+		}
+		writeOAuthError(w, http.StatusBadRequest, oerr)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func exchangeAuthorizationCode(form url.Values) (tokenResponse, error) {
+	code := form.Get("code")
+	invalidGrant := oauthError{"invalid_grant", "invalid or expired authorization code"}
+
+	var authorization models.MCPOAuthAuthorization
+	err := db.DB.Where("code_hash = ?", hashSecret(code)).First(&authorization).Error
+	if code == "" || err != nil || authorization.ApprovedAt == nil {
+		return tokenResponse{}, invalidGrant
+	}
+
+	if authorization.UsedAt != nil {
+		// a replayed code revokes what it was exchanged for
+		revokeOAuthKey(authorization.MCPApiKeyID)
+		return tokenResponse{}, invalidGrant
+	}
+	if time.Now().After(authorization.ExpiresAt) ||
+		authorization.ClientID != form.Get("client_id") ||
+		authorization.RedirectURI != form.Get("redirect_uri") ||
+		!verifyCodeChallenge(form.Get("code_verifier"), authorization.CodeChallenge) {
+		return tokenResponse{}, invalidGrant
+	}
+
+	res := db.DB.Model(&authorization).Where("used_at IS NULL").Update("used_at", time.Now())
+	if res.Error != nil {
+		return tokenResponse{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return tokenResponse{}, invalidGrant
+	}
+
+	var client models.MCPOAuthClient
+	db.DB.Where("client_id = ?", authorization.ClientID).First(&client)
+
+	var resp tokenResponse
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		plainKey, keyHash, keyPrefix, err := models.GenerateMCPApiKey()
+		if err != nil {
+			return err
+		}
+
+		expiresAt := time.Now().Add(oauthAccessTokenTTL)
+		apiKey := models.MCPApiKey{
+			Name:          client.ClientName + " (OAuth)",
+			KeyHash:       keyHash,
+			KeyPrefix:     keyPrefix,
+			ProfileID:     authorization.ProfileID,
+			IsActive:      true,
+			ExpiresAt:     &expiresAt,
+			OAuthClientID: authorization.ClientID,
+		}
+		if err := tx.Create(&apiKey).Error; err != nil {
+			return err
+		}
+
+		for _, locID := range authorization.LocationIDs {
+			keyLoc := models.MCPApiKeyLocation{MCPApiKeyID: apiKey.ID, LocationID: locID}
+			if err := tx.Create(&keyLoc).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&authorization).Update("mcp_api_key_id", apiKey.ID).Error; err != nil {
+			return err
+		}
+
+		refresh, err := issueOAuthRefreshToken(tx, apiKey.ID, authorization.ClientID, authorization.Scope)
+		if err != nil {
+			return err
+		}
+
+		resp = tokenResponse{
+			AccessToken:  plainKey,
+			TokenType:    "Bearer",
+			ExpiresIn:    int(oauthAccessTokenTTL.Seconds()),
+			RefreshToken: refresh,
+			Scope:        authorization.Scope,
+		}
+		return nil
+	})
+	return resp, err
+}
+
+// exchangeRefreshToken rotates the refresh token and the key's access token.
+// Presenting an already rotated refresh token revokes the key, since it
+// means the token was stolen or replayed.
+func exchangeRefreshToken(form url.Values) (tokenResponse, error) {
+	token := form.Get("refresh_token")
+	invalidGrant := oauthError{"invalid_grant", "invalid or expired refresh token"}
+
+	var rt models.MCPOAuthRefreshToken
+	err := db.DB.Where("token_hash = ?", hashSecret(token)).First(&rt).Error
+	if token == "" || err != nil || rt.ClientID != form.Get("client_id") {
+		return tokenResponse{}, invalidGrant
+	}
+
+	if rt.RevokedAt != nil {
+		revokeOAuthKey(rt.MCPApiKeyID)
+		return tokenResponse{}, invalidGrant
+	}
+	if time.Now().After(rt.ExpiresAt) {
+		return tokenResponse{}, invalidGrant
+	}
+
+	// a key revoked or deleted from the settings can't be renewed
+	var apiKey models.MCPApiKey
+	if err := db.DB.First(&apiKey, rt.MCPApiKeyID).Error; err != nil || !apiKey.IsActive {
+		return tokenResponse{}, invalidGrant
+	}
+
+	res := db.DB.Model(&models.MCPOAuthRefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", rt.ID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return tokenResponse{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return tokenResponse{}, invalidGrant
+	}
+
+	var resp tokenResponse
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		plainKey, keyHash, keyPrefix, err := models.GenerateMCPApiKey()
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&apiKey).Updates(map[string]any{
+			"key_hash":   keyHash,
+			"key_prefix": keyPrefix,
+			"expires_at": time.Now().Add(oauthAccessTokenTTL),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		refresh, err := issueOAuthRefreshToken(tx, apiKey.ID, rt.ClientID, rt.Scope)
+		if err != nil {
+			return err
+		}
+
+		resp = tokenResponse{
+			AccessToken:  plainKey,
+			TokenType:    "Bearer",
+			ExpiresIn:    int(oauthAccessTokenTTL.Seconds()),
+			RefreshToken: refresh,
+			Scope:        rt.Scope,
+		}
+		return nil
+	})
+	return resp, err
+}
+
+func issueOAuthRefreshToken(tx *gorm.DB, apiKeyID uint, clientID, scope string) (string, error) {
+	plain, err := randomSecret("sbmcpr_", 32)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Create(&models.MCPOAuthRefreshToken{
+		TokenHash:   hashSecret(plain),
+		MCPApiKeyID: apiKeyID,
+		ClientID:    clientID,
+		Scope:       scope,
+		ExpiresAt:   time.Now().Add(oauthRefreshTokenTTL),
+	}).Error
+	return plain, err
+}
+
+// handleRevoke implements RFC 7009 for refresh and access tokens. It always
+// answers 200 so it can't be used to probe tokens.
+func handleRevoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthError{"invalid_request", "invalid form body"})
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token != "" {
+		var rt models.MCPOAuthRefreshToken
+		if db.DB.Where("token_hash = ?", hashSecret(token)).First(&rt).Error == nil {
+			revokeOAuthKey(rt.MCPApiKeyID)
+		} else {
+			var apiKey models.MCPApiKey
+			err := db.DB.Where("key_hash = ? AND oauth_client_id <> ''", models.HashMCPApiKey(token)).First(&apiKey).Error
+			if err == nil {
+				revokeOAuthKey(apiKey.ID)
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// revokeOAuthKey deactivates a key issued through OAuth and its refresh tokens
+func revokeOAuthKey(apiKeyID uint) {
+	if apiKeyID == 0 {
+		return
+	}
+	db.DB.Model(&models.MCPApiKey{}).
+		Where("id = ? AND oauth_client_id <> ''", apiKeyID).
+		Update("is_active", false)
+	db.DB.Model(&models.MCPOAuthRefreshToken{}).
+		Where("mcp_api_key_id = ? AND revoked_at IS NULL", apiKeyID).
+		Update("revoked_at", time.Now())
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func randomSecret(prefix string, size int) (string, error) {
+	bytes := make([]byte, size)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(bytes), nil
+}
+
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, err oauthError) {
+	writeJSON(w, status, err)
+}
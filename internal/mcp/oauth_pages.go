@@ -0,0 +1,122 @@
+package mcp
+
+import (
+	"client-runaway-zenoti/internal/db/models"
+	"html/template"
+	"net/http"
+)
+
+// Pages of the OAuth consent screen. They are plain server-rendered forms so
+// the MCP server doesn't depend on the web app being deployed next to it.
+
+var oauthPage = template.Must(template.New("page").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>SalesBridge MCP</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; background: #f5f6fa; margin: 0; }
+main { max-width: 420px; margin: 64px auto; background: #fff; border-radius: 12px; padding: 32px; box-shadow: 0 2px 12px rgba(0,0,0,.08); }
+h1 { font-size: 20px; margin: 0 0 8px; }
+p { color: #555; font-size: 14px; }
+label { display: block; font-size: 14px; margin: 12px 0 4px; }
+input[type=email], input[type=password] { width: 100%; box-sizing: border-box; padding: 10px; border: 1px solid #d0d4dc; border-radius: 8px; }
+.location { display: flex; gap: 8px; align-items: center; margin: 6px 0; }
+.error { color: #c62828; }
+.actions { display: flex; gap: 8px; margin-top: 24px; }
+button { flex: 1; padding: 10px; border-radius: 8px; border: 0; font-size: 14px; cursor: pointer; background: #4C6FFF; color: #fff; }
+button.secondary { background: #e8eaf0; color: #333; }
+</style>
+</head>
+<body>
+<main>
+{{if eq .Page "login"}}
+	<h1>Connect {{.ClientName}}</h1>
+	<p>Sign in to SalesBridge to give {{.ClientName}} access to your locations over MCP.</p>
+	{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+	<form method="post" action="/oauth/authorize">
+		<input type="hidden" name="step" value="login">
+		{{range $key, $value := .Params}}<input type="hidden" name="{{$key}}" value="{{$value}}">{{end}}
+		<label for="email">Email</label>
+		<input type="email" id="email" name="email" required autofocus>
+		<label for="password">Password</label>
+		<input type="password" id="password" name="password" required>
+		<div class="actions"><button type="submit">Sign in</button></div>
+	</form>
+{{else if eq .Page "consent"}}
+	<h1>Allow {{.ClientName}}?</h1>
+	<p>Signed in as {{.Email}}. {{.ClientName}} will be able to use the MCP tools for the locations you select.</p>
+	{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+	<form method="post" action="/oauth/authorize">
+		<input type="hidden" name="step" value="consent">
+		<input type="hidden" name="ticket" value="{{.Ticket}}">
+		{{range .Locations}}
+		<label class="location"><input type="checkbox" name="location_id" value="{{.Id}}"> {{.Name}}</label>
+		{{else}}
+		<p class="error">You don't have access to any location.</p>
+		{{end}}
+		<div class="actions">
+			<button type="submit" name="decision" value="deny" class="secondary">Deny</button>
+			<button type="submit" name="decision" value="allow">Allow</button>
+		</div>
+	</form>
+{{else}}
+	<h1>Unable to connect</h1>
+	<p class="error">{{.Error}}</p>
+{{end}}
+</main>
+</body>
+</html>
+`))
+
+type oauthPageData struct {
+	Page       string
+	ClientName string
+	Email      string
+	Error      string
+	Params     map[string]string
+	Ticket     string
+	Locations  []models.Location
+}
+
+func renderLoginPage(w http.ResponseWriter, params authorizeParams, errMsg string) {
+	renderOAuthPage(w, http.StatusOK, oauthPageData{
+		Page:       "login",
+		ClientName: params.Client.ClientName,
+		Error:      errMsg,
+		Params: map[string]string{
+			"client_id":             params.Client.ClientID,
+			"redirect_uri":          params.RedirectURI,
+			"state":                 params.State,
+			"scope":                 params.Scope,
+			"response_type":         "code",
+			"code_challenge":        params.CodeChallenge,
+			"code_challenge_method": "S256",
+		},
+	})
+}
+
+func renderConsentPage(w http.ResponseWriter, client models.MCPOAuthClient, user models.User, locations []models.Location, ticket string) {
+	renderOAuthPage(w, http.StatusOK, oauthPageData{
+		Page:       "consent",
+		ClientName: client.ClientName,
+		Email:      user.Email,
+		Ticket:     ticket,
+		Locations:  locations,
+	})
+}
+
+func renderErrorPage(w http.ResponseWriter, status int, errMsg string) {
+	renderOAuthPage(w, status, oauthPageData{Page: "error", Error: errMsg})
+}
+
+func renderOAuthPage(w http.ResponseWriter, status int, data oauthPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	// the consent screen must not be framed by another site
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.WriteHeader(status)
+	oauthPage.Execute(w, data)
+}
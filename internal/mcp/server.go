@@ -35,17 +35,30 @@ func NewMCPServer(addr string) *MCPServer {
 	// Register customer-facing tools
 	mcpServer.registerTools()
 
+	// Register automator actions (visible per key allowlist)
+	mcpServer.registerActionTools()
+
+	// Register automations published through an mcp.tool trigger (visible per key locations)
+	mcpServer.registerAutomationTools()
+
 	// Register internal tools (only visible to internal API keys)
 	mcpServer.registerInternalTools()
 
+	// The MCP endpoint shares its HTTP server with the OAuth endpoints
+	mux := http.NewServeMux()
+
 	// Create streamable HTTP server with auth context extraction
 	mcpServer.httpServer = server.NewStreamableHTTPServer(s,
 		server.WithEndpointPath("/mcp"),
 		server.WithStateLess(true),
 		server.WithHeartbeatInterval(30*time.Second),
 		server.WithHTTPContextFunc(extractAuthContext),
+		server.WithStreamableHTTPServer(&http.Server{Addr: addr, Handler: mux}),
 	)
 
+	mux.Handle("/mcp", mcpServer.requireBearer(mcpServer.httpServer))
+	mcpServer.registerOAuthRoutes(mux)
+
 	return mcpServer
 }
 
@@ -10,18 +10,97 @@ import (
 	"gorm.io/gorm/clause"
 )
 
-func GetExpenses(from, to time.Time, l []string) (float64, error) {
-	expense := models.LocationExpense{}
-	res := []struct {
-		Total float64
-		Num   int
-	}{}
+// DailyExpense is the spend of a location on a day, on an ad network when
+// Network is set
+type DailyExpense struct {
+	LocationId string
+	Network    string
+	Date       time.Time
+	Total      float64
+}
 
+// GetExpenses sums the spend of the locations. A network with ingested ad
+// spend is read from it from the day it was synced from, and from the
+// manually set expenses of the network before that.
+func GetExpenses(from, to time.Time, l []string) (float64, error) {
 	from = lvn.Time(from).StartOfTheDay()
 	to = lvn.Time(to).StartOfTheDay().Add(time.Second)
 
-	err := db.DB.Model(&expense).Select("sum(total) as total, count(expense_id) as num").Where("date between ? and ? and location_id in ?", from, to, l).Find(&res).Error
-	return res[0].Total, err
+	var syncs []models.AdSpendSync
+	err := db.DB.Where("location_id in ?", l).Find(&syncs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	// the ingested spend of a network is complete once its account went
+	// through a run
+	syncedFrom := map[string]map[string]time.Time{}
+	for _, s := range syncs {
+		if s.SyncedThrough.IsZero() || s.SyncedFrom.IsZero() {
+			continue
+		}
+		if syncedFrom[s.LocationId] == nil {
+			syncedFrom[s.LocationId] = map[string]time.Time{}
+		}
+		syncedFrom[s.LocationId][s.Network] = s.SyncedFrom
+	}
+
+	manual := []DailyExpense{}
+	err = db.DB.Model(&models.LocationExpense{}).Select("location_id, network, date, total").Where("date between ? and ? and location_id in ?", from, to, l).Scan(&manual).Error
+	if err != nil {
+		return 0, err
+	}
+
+	ingested := []DailyExpense{}
+	if len(syncedFrom) > 0 {
+		err = db.DB.Model(&models.AdSpendDaily{}).Select("location_id, network, date, sum(spend) as total").Where("date between ? and ? and location_id in ?", from.Format("2006-01-02"), to.Format("2006-01-02"), l).Group("location_id, network, date").Scan(&ingested).Error
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return MergeExpenses(manual, ingested, syncedFrom), nil
+}
+
+// MergeExpenses sums the daily expenses of locations. syncedFrom holds per
+// location the day each network's ingested spend starts: the network counts
+// its ingested spend from that day on and its manual expenses before it.
+// Manual expenses of other networks or outside ad networks always count.
+// Legacy manual expenses without a network cover all the ad spend, so they
+// give way to the ingested spend from the latest day a network starts.
+func MergeExpenses(manual, ingested []DailyExpense, syncedFrom map[string]map[string]time.Time) float64 {
+	total := 0.0
+	for _, e := range manual {
+		from, ok := syncedFrom[e.LocationId][e.Network]
+		if e.Network == "" {
+			from, ok = latest(syncedFrom[e.LocationId])
+		}
+		if !ok || dayOf(e.Date).Before(dayOf(from)) {
+			total += e.Total
+		}
+	}
+	for _, e := range ingested {
+		from, ok := syncedFrom[e.LocationId][e.Network]
+		if ok && !dayOf(e.Date).Before(dayOf(from)) {
+			total += e.Total
+		}
+	}
+	return total
+}
+
+func latest(days map[string]time.Time) (time.Time, bool) {
+	var res time.Time
+	for _, d := range days {
+		if d.After(res) {
+			res = d
+		}
+	}
+	return res, len(days) > 0
+}
+
+// dayOf is the calendar day of t, whatever its time zone
+func dayOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
 }
 
 func GetSales(from, to time.Time, l []string) (float64, error) {
@@ -66,7 +145,9 @@ func GetNoShows(from, to time.Time, l []string) (float64, error) {
 	return res[0].Num, err
 }
 
-func SetExpenses(from, to time.Time, total float64, locations []string) {
+// SetExpenses spreads the total evenly over the days and locations. The
+// network is the ad network the spend went to, or models.ExpenseOther.
+func SetExpenses(from, to time.Time, total float64, locations []string, network string) {
 	from = lvn.Time(from).StartOfTheDay()
 	to = lvn.Time(to).StartOfTheDay()
 	days := to.Sub(from).Hours() / 24
@@ -75,10 +156,11 @@ func SetExpenses(from, to time.Time, total float64, locations []string) {
 		for i := 0; i < int(days); i++ {
 			date := from.Add(time.Duration(i) * 24 * time.Hour)
 			expense := models.LocationExpense{
-				ExpenseId:  fmt.Sprintf("%s%s", l, date.Format("2006-01-02")),
+				ExpenseId:  fmt.Sprintf("%s%s%s", l, date.Format("2006-01-02"), network),
 				Date:       date,
 				Total:      daily,
 				LocationId: l,
+				Network:    network,
 			}
 			db.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&expense)
 		}
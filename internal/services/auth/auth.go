@@ -1,17 +1,34 @@
 package auth
 
 import (
+	"client-runaway-zenoti/internal/config"
 	"client-runaway-zenoti/internal/db/models"
 	"fmt"
+	"strings"
 
 	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
+// Auth authenticates REST requests with a bearer access token. HTTP Basic
+// credentials are still accepted when Settings.AllowBasicAuth is set.
 func Auth(c *gin.Context) {
+	header := c.GetHeader("Authorization")
+
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		user, err := userFromAccessToken(token)
+		if err != nil {
+			c.Data(lvn.Res(401, "", "Invalid or expired token"))
+			c.Abort()
+			return
+		}
+
+		c.Set("user", user)
+		c.Next()
+		return
+	}
 
-	// Assuming the header is in the format "Basic base64encodedstring"
 	email, password, ok := c.Request.BasicAuth()
 	if !ok {
 		c.Data(lvn.Res(400, "", "No login info"))
@@ -19,18 +36,44 @@ func Auth(c *gin.Context) {
 		return
 	}
 
-	user := models.User{}
-	result := models.DB.Where("email = ? AND password = ?", email, password).Preload("Profile").First(&user)
-	if result.Error != nil {
+	if !config.Confs.Settings.AllowBasicAuth {
+		c.Data(lvn.Res(401, "", "Basic auth is disabled, use a bearer token"))
+		c.Abort()
+		return
+	}
+
+	user, err := authenticate(email, password)
+	if err != nil {
 		c.Data(lvn.Res(400, "", "Incorrect email or password"))
 		c.Abort()
 		return
 	}
 
+	c.Header("Deprecation", "true")
 	c.Set("user", user)
 	c.Next()
 }
 
+func userFromAccessToken(token string) (models.User, error) {
+	claims, err := parseAccessToken(token)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user := models.User{}
+	err = models.DB.Preload("Profile").First(&user, claims.Sub).Error
+	if err != nil {
+		return user, errInvalidToken
+	}
+
+	// tokens issued before a password change are void
+	if user.PasswordChangedAt != nil && claims.Iat < user.PasswordChangedAt.Unix() {
+		return user, errInvalidToken
+	}
+
+	return user, nil
+}
+
 func Login(c *gin.Context) {
 	user := c.MustGet("user").(models.User)
 
@@ -43,6 +86,17 @@ func Register(c *gin.Context) {
 	password := c.PostForm("password")
 	profileName := c.PostForm("profile_name")
 
+	if err := validatePassword(password); err != nil {
+		c.Data(lvn.Res(400, "", err.Error()))
+		return
+	}
+
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		lvn.GinErr(c, 500, err, "Unable to hash password")
+		return
+	}
+
 	// check if user already exists
 	var existingUser models.User
 	result := models.DB.Where("email = ?", email).First(&existingUser)
@@ -51,6 +105,12 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	// invited users join the inviting profile instead of creating their own
+	if inviteToken := c.PostForm("invite_token"); inviteToken != "" {
+		registerWithInvite(c, email, passwordHash, inviteToken)
+		return
+	}
+
 	tx := models.DB.Begin()
 	if tx.Error != nil {
 		lvn.GinErr(c, 500, tx.Error, "Unable to start registration")
@@ -68,7 +128,7 @@ func Register(c *gin.Context) {
 
 	user := models.User{
 		Email:     email,
-		Password:  password,
+		Password:  passwordHash,
 		ProfileID: profile.ID,
 	}
 
@@ -84,6 +144,18 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	owner := models.ProfileMember{
+		ProfileID:    profile.ID,
+		UserID:       user.ID,
+		Role:         models.RoleOwner,
+		AllLocations: true,
+	}
+	if err := tx.Create(&owner).Error; err != nil {
+		tx.Rollback()
+		lvn.GinErr(c, 500, err, "Unable to create profile owner")
+		return
+	}
+
 	if err := createInternalMCPKey(tx, profile.ID); err != nil {
 		tx.Rollback()
 		lvn.GinErr(c, 500, err, "Unable to create internal MCP key")
@@ -98,6 +170,35 @@ func Register(c *gin.Context) {
 	c.Data(lvn.Res(200, user, "User registered successfully"))
 }
 
+func registerWithInvite(c *gin.Context, email, passwordHash, inviteToken string) {
+	invite := models.ProfileInvite{}
+	err := models.DB.Where("token_hash = ?", hashToken(inviteToken)).First(&invite).Error
+	if err != nil {
+		c.Data(lvn.Res(400, "", "Invalid or expired invite"))
+		return
+	}
+
+	user := models.User{
+		Email:     email,
+		Password:  passwordHash,
+		ProfileID: invite.ProfileID,
+	}
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		_, err := acceptInvite(tx, inviteToken, user)
+		return err
+	})
+	if err != nil {
+		lvn.GinErr(c, 400, err, err.Error())
+		return
+	}
+
+	c.Data(lvn.Res(200, user, "User registered successfully"))
+}
+
 func createInternalMCPKey(tx *gorm.DB, profileID uint) error {
 	if profileID == 0 {
 		return nil
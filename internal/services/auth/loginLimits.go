@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// failed logins are counted over this window, a locked out email or IP
+	// is let in again once its first failure is older than it
+	loginFailureWindow  = 15 * time.Minute
+	maxFailuresPerEmail = 5
+	maxFailuresPerIP    = 20
+)
+
+type failureWindow struct {
+	count int
+	since time.Time
+}
+
+// loginFailures counts the recent failed logins per email and per client
+// IP. They live in memory, so each instance limits on its own.
+var loginFailures = struct {
+	sync.Mutex
+	byKey map[string]*failureWindow
+}{byKey: map[string]*failureWindow{}}
+
+// loginLocked reports whether the email or the IP failed too often lately
+func loginLocked(email, ip string) bool {
+	loginFailures.Lock()
+	defer loginFailures.Unlock()
+
+	now := time.Now()
+	return failures(emailKey(email), now) >= maxFailuresPerEmail || failures(ipKey(ip), now) >= maxFailuresPerIP
+}
+
+func recordLoginFailure(email, ip string) {
+	loginFailures.Lock()
+	defer loginFailures.Unlock()
+
+	now := time.Now()
+	for _, key := range []string{emailKey(email), ipKey(ip)} {
+		w, ok := loginFailures.byKey[key]
+		if !ok || now.Sub(w.since) > loginFailureWindow {
+			w = &failureWindow{since: now}
+			loginFailures.byKey[key] = w
+		}
+		w.count++
+	}
+
+	// drop expired windows now and then so the map doesn't grow forever
+	if len(loginFailures.byKey) > 10000 {
+		for key, w := range loginFailures.byKey {
+			if now.Sub(w.since) > loginFailureWindow {
+				delete(loginFailures.byKey, key)
+			}
+		}
+	}
+}
+
+// clearLoginFailures forgets the failures of an email after it logged in
+func clearLoginFailures(email string) {
+	loginFailures.Lock()
+	defer loginFailures.Unlock()
+	delete(loginFailures.byKey, emailKey(email))
+}
+
+// failures returns the count of the key's current window; the caller
+// holds the lock
+func failures(key string, now time.Time) int {
+	w, ok := loginFailures.byKey[key]
+	if !ok || now.Sub(w.since) > loginFailureWindow {
+		return 0
+	}
+	return w.count
+}
+
+func emailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,419 @@
+package auth
+
+import (
+	"client-runaway-zenoti/internal/config"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/mailer"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const inviteTTL = 7 * 24 * time.Hour
+
+type (
+	memberAccessReq struct {
+		Role         *models.Role `json:"role"`
+		AllLocations *bool        `json:"allLocations"`
+		LocationIds  *[]string    `json:"locationIds"`
+	}
+
+	inviteReq struct {
+		Email        string      `json:"email"`
+		Role         models.Role `json:"role"`
+		AllLocations bool        `json:"allLocations"`
+		LocationIds  []string    `json:"locationIds"`
+	}
+
+	acceptInviteReq struct {
+		Token string `json:"token"`
+	}
+
+	profileMembership struct {
+		ProfileID    uint        `json:"profileId"`
+		ProfileName  string      `json:"profileName"`
+		Role         models.Role `json:"role"`
+		AllLocations bool        `json:"allLocations"`
+		LocationIds  []string    `json:"locationIds"`
+		IsDefault    bool        `json:"isDefault"`
+	}
+)
+
+// ListMyProfiles returns the profiles the user belongs to. Any of them can
+// be made active per request with the X-Profile-Id header.
+func ListMyProfiles(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	members := []models.ProfileMember{}
+	err := models.DB.Where("user_id = ?", user.ID).Preload("Profile").Preload("Locations").Find(&members).Error
+	lvn.GinErr(c, 500, err, "Unable to list profiles")
+
+	res := make([]profileMembership, 0, len(members))
+	for _, m := range members {
+		res = append(res, profileMembership{
+			ProfileID:    m.ProfileID,
+			ProfileName:  m.Profile.Name,
+			Role:         m.Role,
+			AllLocations: m.AllLocations,
+			LocationIds:  m.LocationIds(),
+			IsDefault:    m.ProfileID == user.ProfileID,
+		})
+	}
+
+	c.Data(lvn.Res(200, res, "OK"))
+}
+
+// ListMembers returns the members of the active profile
+func ListMembers(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	members := []models.ProfileMember{}
+	err := models.DB.Where("profile_id = ?", user.ProfileID).
+		Preload("User").Preload("Locations").
+		Order("id").Find(&members).Error
+	lvn.GinErr(c, 500, err, "Unable to list members")
+
+	c.Data(lvn.Res(200, members, "OK"))
+}
+
+// UpdateMember changes the role or location restriction of a member
+func UpdateMember(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	actor := c.MustGet("member").(models.ProfileMember)
+
+	body := memberAccessReq{}
+	err := c.BindJSON(&body)
+	lvn.GinErr(c, 400, err, "Invalid body")
+
+	target := models.ProfileMember{}
+	err = models.DB.Where("id = ? AND profile_id = ?", c.Param("memberId"), user.ProfileID).Preload("Locations").First(&target).Error
+	lvn.GinErr(c, 404, err, "Member not found")
+
+	err = checkLocationScope(actor, target.AllLocations, target.LocationIds())
+	if err != nil {
+		lvn.GinErr(c, 403, err, err.Error())
+	}
+
+	if body.Role != nil {
+		if !body.Role.Valid() {
+			lvn.GinErr(c, 400, fmt.Errorf("unknown role %q", *body.Role), "Invalid role")
+		}
+		err = checkOwnerChange(actor, target, *body.Role)
+		if err != nil {
+			lvn.GinErr(c, 403, err, err.Error())
+		}
+		target.Role = *body.Role
+	}
+	if body.AllLocations != nil {
+		target.AllLocations = *body.AllLocations
+	}
+	granted := target.LocationIds()
+	if body.LocationIds != nil {
+		granted = *body.LocationIds
+	}
+	err = checkLocationScope(actor, target.AllLocations, granted)
+	if err != nil {
+		lvn.GinErr(c, 403, err, err.Error())
+	}
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&target).Select("role", "all_locations").Updates(&target).Error
+		if err != nil {
+			return err
+		}
+		if body.LocationIds == nil {
+			return nil
+		}
+		return setMemberLocations(tx, &target, *body.LocationIds)
+	})
+	if err != nil {
+		lvn.GinErr(c, 400, err, err.Error())
+	}
+
+	c.Data(lvn.Res(200, target, "OK"))
+}
+
+// RemoveMember removes a user from the active profile
+func RemoveMember(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	actor := c.MustGet("member").(models.ProfileMember)
+
+	target := models.ProfileMember{}
+	err := models.DB.Where("id = ? AND profile_id = ?", c.Param("memberId"), user.ProfileID).Preload("Locations").First(&target).Error
+	lvn.GinErr(c, 404, err, "Member not found")
+
+	err = checkLocationScope(actor, target.AllLocations, target.LocationIds())
+	if err != nil {
+		lvn.GinErr(c, 403, err, err.Error())
+	}
+
+	if target.Role == models.RoleOwner {
+		err = checkOwnerChange(actor, target, models.RoleViewer)
+		if err != nil {
+			lvn.GinErr(c, 403, err, err.Error())
+		}
+	}
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("profile_member_id = ?", target.ID).Delete(&models.ProfileMemberLocation{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Unscoped().Delete(&target).Error
+		if err != nil {
+			return err
+		}
+
+		// move the user's default profile to one they still belong to; with
+		// none left the user keeps it but Access denies every request
+		next := models.ProfileMember{}
+		if tx.Where("user_id = ?", target.UserID).Order("id").First(&next).Error != nil {
+			return nil
+		}
+		return tx.Model(&models.User{}).
+			Where("id = ? AND profile_id = ?", target.UserID, target.ProfileID).
+			Update("profile_id", next.ProfileID).Error
+	})
+	lvn.GinErr(c, 500, err, "Unable to remove member")
+
+	c.Data(lvn.Res(200, "", "OK"))
+}
+
+// CreateInvite invites an email to the active profile. The accept link is
+// emailed when SMTP is configured and returned either way.
+func CreateInvite(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	actor := c.MustGet("member").(models.ProfileMember)
+
+	body := inviteReq{}
+	err := c.BindJSON(&body)
+	lvn.GinErr(c, 400, err, "Invalid body")
+
+	body.Email = strings.TrimSpace(body.Email)
+	if body.Email == "" {
+		lvn.GinErr(c, 400, errors.New("email is required"), "Invalid body")
+	}
+	if !body.Role.Valid() {
+		lvn.GinErr(c, 400, fmt.Errorf("unknown role %q", body.Role), "Invalid role")
+	}
+	if body.Role == models.RoleOwner && actor.Role != models.RoleOwner {
+		lvn.GinErr(c, 403, errors.New("only owners can invite owners"), "Forbidden")
+	}
+	if !body.AllLocations && len(body.LocationIds) == 0 {
+		lvn.GinErr(c, 400, errors.New("pick locations or all locations"), "Invalid locations")
+	}
+	err = checkLocationScope(actor, body.AllLocations, body.LocationIds)
+	if err != nil {
+		lvn.GinErr(c, 403, err, err.Error())
+	}
+	err = checkProfileLocations(models.DB, user.ProfileID, body.LocationIds)
+	lvn.GinErr(c, 400, err, "Invalid locations")
+
+	plain, hash, err := randomToken("sbi_")
+	lvn.GinErr(c, 500, err, "Unable to create invite")
+
+	invite := models.ProfileInvite{
+		ProfileID:    user.ProfileID,
+		Email:        body.Email,
+		Role:         body.Role,
+		AllLocations: body.AllLocations,
+		LocationIds:  body.LocationIds,
+		TokenHash:    hash,
+		InvitedByID:  user.ID,
+		ExpiresAt:    time.Now().Add(inviteTTL),
+	}
+	err = models.DB.Create(&invite).Error
+	lvn.GinErr(c, 500, err, "Unable to create invite")
+
+	link := config.Confs.Settings.AppDomain + "/invite?token=" + url.QueryEscape(plain)
+	emailed := false
+	if mailer.Configured() {
+		msg := fmt.Sprintf("%s invited you to join %s as %s.\n\nAccept the invite within %d days:\n%s",
+			user.Email, user.Profile.Name, invite.Role, int(inviteTTL.Hours()/24), link)
+		err = mailer.Send([]string{invite.Email}, "You are invited to "+user.Profile.Name, msg)
+		if err != nil {
+			lvn.Logger.Errorf("auth: sending invite %d: %s", invite.ID, err.Error())
+		}
+		emailed = err == nil
+	}
+
+	c.Data(lvn.Res(200, gin.H{"invite": invite, "acceptUrl": link, "emailed": emailed}, "OK"))
+}
+
+// ListInvites returns the pending invites of the active profile
+func ListInvites(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	invites := []models.ProfileInvite{}
+	err := models.DB.Where("profile_id = ? AND accepted_at IS NULL AND expires_at > ?", user.ProfileID, time.Now()).
+		Order("id desc").Find(&invites).Error
+	lvn.GinErr(c, 500, err, "Unable to list invites")
+
+	c.Data(lvn.Res(200, invites, "OK"))
+}
+
+// RevokeInvite deletes a pending invite
+func RevokeInvite(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	res := models.DB.Where("id = ? AND profile_id = ? AND accepted_at IS NULL", c.Param("inviteId"), user.ProfileID).
+		Delete(&models.ProfileInvite{})
+	lvn.GinErr(c, 500, res.Error, "Unable to revoke invite")
+	if res.RowsAffected == 0 {
+		lvn.GinErr(c, 404, errors.New("invite not found"), "Invite not found")
+	}
+
+	c.Data(lvn.Res(200, "", "OK"))
+}
+
+// AcceptInvite adds the authenticated user to the inviting profile
+func AcceptInvite(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	body := acceptInviteReq{}
+	err := c.BindJSON(&body)
+	lvn.GinErr(c, 400, err, "Invalid body")
+
+	member := models.ProfileMember{}
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		member, err = acceptInvite(tx, body.Token, user)
+		return err
+	})
+	if err != nil {
+		lvn.GinErr(c, 400, err, err.Error())
+	}
+
+	c.Data(lvn.Res(200, member, "Invite accepted"))
+}
+
+// acceptInvite validates an invite token for user and creates the membership.
+// A user who isn't a member of their default profile gets the invite's
+// profile as default.
+func acceptInvite(tx *gorm.DB, token string, user models.User) (models.ProfileMember, error) {
+	invite := models.ProfileInvite{}
+	err := tx.Where("token_hash = ?", hashToken(token)).First(&invite).Error
+	if err != nil || invite.AcceptedAt != nil || time.Now().After(invite.ExpiresAt) {
+		return models.ProfileMember{}, errors.New("invalid or expired invite")
+	}
+	if !strings.EqualFold(invite.Email, user.Email) {
+		return models.ProfileMember{}, errors.New("the invite was sent to a different email")
+	}
+
+	if _, err := models.GetProfileMember(invite.ProfileID, user.ID); err == nil {
+		return models.ProfileMember{}, errors.New("you are already a member of this profile")
+	}
+
+	res := tx.Model(&invite).Where("accepted_at IS NULL").Update("accepted_at", time.Now())
+	if res.Error != nil {
+		return models.ProfileMember{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return models.ProfileMember{}, errors.New("invalid or expired invite")
+	}
+
+	member := models.ProfileMember{
+		ProfileID:    invite.ProfileID,
+		UserID:       user.ID,
+		Role:         invite.Role,
+		AllLocations: invite.AllLocations,
+	}
+	if err := tx.Create(&member).Error; err != nil {
+		return member, err
+	}
+	if !invite.AllLocations {
+		if err := setMemberLocations(tx, &member, invite.LocationIds); err != nil {
+			return member, err
+		}
+	}
+
+	var current int64
+	tx.Model(&models.ProfileMember{}).Where("profile_id = ? AND user_id = ?", user.ProfileID, user.ID).Count(&current)
+	if current == 0 {
+		err = tx.Model(&models.User{}).Where("id = ?", user.ID).Update("profile_id", invite.ProfileID).Error
+	}
+	return member, err
+}
+
+// checkOwnerChange guards owner roles: only owners grant or take them, and
+// a profile can't lose its last owner
+func checkOwnerChange(actor, target models.ProfileMember, newRole models.Role) error {
+	if target.Role != models.RoleOwner && newRole != models.RoleOwner {
+		return nil
+	}
+	if actor.Role != models.RoleOwner {
+		return errors.New("only owners can change owner roles")
+	}
+	if target.Role == models.RoleOwner && newRole != models.RoleOwner {
+		var owners int64
+		models.DB.Model(&models.ProfileMember{}).
+			Where("profile_id = ? AND role = ?", target.ProfileID, models.RoleOwner).
+			Count(&owners)
+		if owners <= 1 {
+			return errors.New("a profile needs at least one owner")
+		}
+	}
+	return nil
+}
+
+// checkLocationScope keeps location-restricted actors within their own
+// locations: they can't grant all locations or ones they don't have, nor
+// manage members who see more than they do
+func checkLocationScope(actor models.ProfileMember, allLocations bool, locationIds []string) error {
+	if actor.AllLocations {
+		return nil
+	}
+	if allLocations {
+		return errors.New("this needs access to all locations")
+	}
+	for _, id := range locationIds {
+		if !actor.CanAccessLocation(id) {
+			return fmt.Errorf("you don't have access to location %s", id)
+		}
+	}
+	return nil
+}
+
+func setMemberLocations(tx *gorm.DB, member *models.ProfileMember, locationIds []string) error {
+	if err := checkProfileLocations(tx, member.ProfileID, locationIds); err != nil {
+		return err
+	}
+
+	err := tx.Unscoped().Where("profile_member_id = ?", member.ID).Delete(&models.ProfileMemberLocation{}).Error
+	if err != nil {
+		return err
+	}
+
+	member.Locations = make([]models.ProfileMemberLocation, 0, len(locationIds))
+	for _, id := range locationIds {
+		member.Locations = append(member.Locations, models.ProfileMemberLocation{
+			ProfileMemberID: member.ID,
+			LocationID:      id,
+		})
+	}
+	if len(member.Locations) == 0 {
+		return nil
+	}
+	return tx.Create(&member.Locations).Error
+}
+
+func checkProfileLocations(tx *gorm.DB, profileID uint, locationIds []string) error {
+	if len(locationIds) == 0 {
+		return nil
+	}
+	var count int64
+	err := tx.Model(&models.Location{}).Where("profile_id = ? AND id IN ?", profileID, locationIds).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if int(count) != len(locationIds) {
+		return errors.New("some locations don't belong to this profile")
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"client-runaway-zenoti/internal/db/models"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLength = 8
+
+var errInvalidCredentials = errors.New("incorrect email or password")
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+func isPasswordHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return errors.New("password must be at least 8 characters long")
+	}
+	return nil
+}
+
+// authenticate checks email/password. Users still holding a plaintext
+// password get it hashed on the first successful login.
+func authenticate(email, password string) (models.User, error) {
+	user := models.User{}
+	err := models.DB.Where("email = ?", email).Preload("Profile").First(&user).Error
+	if err != nil || password == "" {
+		return user, errInvalidCredentials
+	}
+
+	if isPasswordHash(user.Password) {
+		if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+			return user, errInvalidCredentials
+		}
+		return user, nil
+	}
+
+	// legacy plaintext password
+	if subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) != 1 {
+		return user, errInvalidCredentials
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return user, err
+	}
+	err = models.DB.Model(&user).Update("password", hash).Error
+	return user, err
+}
+
+// VerifyCredentials checks email/password for logins outside the REST API,
+// such as the MCP OAuth consent screen
+func VerifyCredentials(email, password string) (models.User, error) {
+	return authenticate(email, password)
+}
+
+// setPassword stores a new password hash and revokes every session of the user
+func setPassword(user *models.User, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	// access tokens issued before this second stop working
+	now := time.Now().Truncate(time.Second)
+	err = models.DB.Model(user).Updates(map[string]any{
+		"password":            hash,
+		"password_changed_at": now,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	return revokeAllRefreshTokens(user.ID)
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"bytes"
+	"client-runaway-zenoti/internal/db/models"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// Access authorizes the authenticated user in the active profile: the
+// X-Profile-Id header, or the user's default profile. Reads need readRole,
+// everything else writeRole. The member must be allowed to every location
+// the request targets, directly or through an automation, run or batch
+// run. Must run after Auth.
+func Access(readRole, writeRole models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(models.User)
+
+		profileID := user.ProfileID
+		if h := c.GetHeader("X-Profile-Id"); h != "" {
+			id, err := strconv.ParseUint(h, 10, 64)
+			if err != nil {
+				denyAccess(c, 400, "Invalid X-Profile-Id header")
+				return
+			}
+			profileID = uint(id)
+		}
+
+		member, err := models.GetProfileMember(profileID, user.ID)
+		if err != nil {
+			denyAccess(c, 403, "You are not a member of this profile")
+			return
+		}
+
+		required := writeRole
+		if isReadRequest(c) {
+			required = readRole
+		}
+		if !member.Role.AtLeast(required) {
+			denyAccess(c, 403, fmt.Sprintf("This action requires the %s role", required))
+			return
+		}
+
+		for _, locationId := range requestLocationIds(c) {
+			if !member.CanAccessLocation(locationId) {
+				denyAccess(c, 403, "You don't have access to this location")
+				return
+			}
+		}
+
+		// handlers scope by user.ProfileID, so point it at the active profile
+		if user.ProfileID != profileID {
+			user.ProfileID = profileID
+			user.Profile = models.Profile{}
+			models.DB.First(&user.Profile, profileID)
+		}
+
+		c.Set("user", user)
+		c.Set("member", member)
+		c.Next()
+	}
+}
+
+// AllowedLocationIds returns the locations the member may see in the active
+// profile; nil means all of them
+func AllowedLocationIds(c *gin.Context) []string {
+	m, ok := c.Get("member")
+	if !ok {
+		return nil
+	}
+	member := m.(models.ProfileMember)
+	return member.LocationIds()
+}
+
+// CanSeeLocations reports whether the member may see all of the locations
+func CanSeeLocations(c *gin.Context, locationIds []string) bool {
+	allowed := AllowedLocationIds(c)
+	if allowed == nil {
+		return true
+	}
+	for _, id := range locationIds {
+		if !slices.Contains(allowed, id) {
+			return false
+		}
+	}
+	return true
+}
+
+func denyAccess(c *gin.Context, code int, msg string) {
+	c.Data(lvn.Res(code, "", msg))
+	c.Abort()
+}
+
+func isReadRequest(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// requestLocationIds finds the locations a request targets. An automation,
+// node, run or batch run contributes the location stored on its row (a
+// node through its automation, as do the runIds of a restart), and every
+// locationId the client sends counts too, so a body can't move a record to
+// a location the member can't access. Ids that don't resolve are left for
+// the handler to reject.
+func requestLocationIds(c *gin.Context) []string {
+	var ids []string
+	add := func(id string) {
+		if id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+
+	lookups := []struct {
+		id    string
+		table string
+	}{
+		{firstNonEmpty(c.Param("automationId"), c.Query("automationId"), bodyString(c, "automationId")), "automations"},
+		{c.Param("runId"), "automation_runs"},
+		{firstNonEmpty(c.Param("batchRunId"), c.Query("batchRunId")), "automation_batch_runs"},
+	}
+	for _, l := range lookups {
+		if l.id == "" {
+			continue
+		}
+		var locationIds []string
+		models.DB.Table(l.table).Where("id = ?", l.id).Limit(1).Pluck("location_id", &locationIds)
+		if len(locationIds) > 0 {
+			add(locationIds[0])
+		}
+	}
+
+	if nodeId := bodyString(c, "nodeId"); nodeId != "" {
+		var locationIds []string
+		models.DB.Table("nodes").
+			Joins("JOIN automations ON automations.id = nodes.automation_id").
+			Where("nodes.id = ?", nodeId).Limit(1).
+			Pluck("automations.location_id", &locationIds)
+		if len(locationIds) > 0 {
+			add(locationIds[0])
+		}
+	}
+	if runIds := bodyStrings(c, "runIds"); len(runIds) > 0 {
+		var locationIds []string
+		models.DB.Table("automation_runs").Where("id IN ?", runIds).Distinct().Pluck("location_id", &locationIds)
+		for _, id := range locationIds {
+			add(id)
+		}
+	}
+
+	add(c.Param("locationId"))
+	add(c.Query("locationId"))
+	add(bodyString(c, "locationId"))
+
+	return ids
+}
+
+// bodyString reads a top-level string field of a JSON body, leaving the body
+// intact for the handler
+func bodyString(c *gin.Context, key string) string {
+	if c.Request.Body == nil || !strings.HasPrefix(c.ContentType(), "application/json") {
+		return ""
+	}
+
+	raw, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+	if err != nil {
+		return ""
+	}
+	return gjson.GetBytes(raw, key).String()
+}
+
+// bodyStrings reads a top-level string array of a JSON body, leaving the body
+// intact for the handler
+func bodyStrings(c *gin.Context, key string) []string {
+	if c.Request.Body == nil || !strings.HasPrefix(c.ContentType(), "application/json") {
+		return nil
+	}
+
+	raw, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+	var res []string
+	for _, v := range gjson.GetBytes(raw, key).Array() {
+		if v.String() != "" {
+			res = append(res, v.String())
+		}
+	}
+	return res
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
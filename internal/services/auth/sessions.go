@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"client-runaway-zenoti/internal/config"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/mailer"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	loginReq struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	refreshReq struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+
+	changePasswordReq struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+
+	forgotPasswordReq struct {
+		Email string `json:"email"`
+	}
+
+	resetPasswordReq struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"newPassword"`
+	}
+)
+
+// TokenLogin exchanges email/password for an access and refresh token pair
+func TokenLogin(c *gin.Context) {
+	body := loginReq{}
+	err := c.BindJSON(&body)
+	lvn.GinErr(c, 400, err, "Invalid body")
+
+	if loginLocked(body.Email, c.ClientIP()) {
+		c.Data(lvn.Res(429, "", "Too many failed logins, try again later"))
+		return
+	}
+
+	user, err := authenticate(body.Email, body.Password)
+	if err != nil {
+		recordLoginFailure(body.Email, c.ClientIP())
+		c.Data(lvn.Res(401, "", "Incorrect email or password"))
+		return
+	}
+	clearLoginFailures(body.Email)
+
+	pair, err := issueTokenPair(user, c.Request.UserAgent(), c.ClientIP())
+	lvn.GinErr(c, 500, err, "Unable to issue tokens")
+
+	c.Data(lvn.Res(200, pair, "OK"))
+}
+
+// RefreshTokens rotates a refresh token and returns a new token pair
+func RefreshTokens(c *gin.Context) {
+	body := refreshReq{}
+	err := c.BindJSON(&body)
+	lvn.GinErr(c, 400, err, "Invalid body")
+
+	pair, err := rotateRefreshToken(body.RefreshToken, c.Request.UserAgent(), c.ClientIP())
+	if errors.Is(err, errInvalidToken) {
+		c.Data(lvn.Res(401, "", "Invalid or expired refresh token"))
+		return
+	}
+	lvn.GinErr(c, 500, err, "Unable to refresh tokens")
+
+	c.Data(lvn.Res(200, pair, "OK"))
+}
+
+// Logout revokes the given refresh token. Access tokens expire on their own.
+func Logout(c *gin.Context) {
+	body := refreshReq{}
+	err := c.BindJSON(&body)
+	lvn.GinErr(c, 400, err, "Invalid body")
+
+	err = revokeRefreshToken(body.RefreshToken)
+	lvn.GinErr(c, 500, err, "Unable to log out")
+
+	c.Data(lvn.Res(200, "", "OK"))
+}
+
+// ChangePassword sets a new password for the authenticated user and ends
+// all their other sessions
+func ChangePassword(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	body := changePasswordReq{}
+	err := c.BindJSON(&body)
+	lvn.GinErr(c, 400, err, "Invalid body")
+
+	_, err = authenticate(user.Email, body.CurrentPassword)
+	if err != nil {
+		c.Data(lvn.Res(400, "", "Current password is incorrect"))
+		return
+	}
+
+	err = validatePassword(body.NewPassword)
+	if err != nil {
+		c.Data(lvn.Res(400, "", err.Error()))
+		return
+	}
+
+	err = setPassword(&user, body.NewPassword)
+	lvn.GinErr(c, 500, err, "Unable to change password")
+
+	pair, err := issueTokenPair(user, c.Request.UserAgent(), c.ClientIP())
+	lvn.GinErr(c, 500, err, "Unable to issue tokens")
+
+	c.Data(lvn.Res(200, pair, "Password changed"))
+}
+
+// ForgotPassword emails a password reset link. The response is the same
+// whether or not the email is registered.
+func ForgotPassword(c *gin.Context) {
+	body := forgotPasswordReq{}
+	err := c.BindJSON(&body)
+	lvn.GinErr(c, 400, err, "Invalid body")
+
+	user := models.User{}
+	if models.DB.Where("email = ?", body.Email).First(&user).Error == nil {
+		err = sendPasswordReset(user)
+		if err != nil {
+			lvn.Logger.Errorf("auth: password reset for user %d: %s", user.ID, err.Error())
+		}
+	}
+
+	c.Data(lvn.Res(200, "", "If the email is registered, a reset link has been sent"))
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword
+func ResetPassword(c *gin.Context) {
+	body := resetPasswordReq{}
+	err := c.BindJSON(&body)
+	lvn.GinErr(c, 400, err, "Invalid body")
+
+	err = validatePassword(body.NewPassword)
+	if err != nil {
+		c.Data(lvn.Res(400, "", err.Error()))
+		return
+	}
+
+	rt := models.PasswordResetToken{}
+	err = models.DB.Where("token_hash = ?", hashToken(body.Token)).First(&rt).Error
+	if err != nil || rt.UsedAt != nil || time.Now().After(rt.ExpiresAt) {
+		c.Data(lvn.Res(400, "", "Invalid or expired reset token"))
+		return
+	}
+
+	// single use, even under concurrent requests
+	res := models.DB.Model(&rt).Where("used_at IS NULL").Update("used_at", time.Now())
+	lvn.GinErr(c, 500, res.Error, "Unable to reset password")
+	if res.RowsAffected == 0 {
+		c.Data(lvn.Res(400, "", "Invalid or expired reset token"))
+		return
+	}
+
+	user := models.User{}
+	err = models.DB.First(&user, rt.UserID).Error
+	lvn.GinErr(c, 400, err, "User not found")
+
+	err = setPassword(&user, body.NewPassword)
+	lvn.GinErr(c, 500, err, "Unable to reset password")
+
+	c.Data(lvn.Res(200, "", "Password has been reset"))
+}
+
+func sendPasswordReset(user models.User) error {
+	plain, hash, err := randomToken("sbp_")
+	if err != nil {
+		return err
+	}
+
+	err = models.DB.Create(&models.PasswordResetToken{
+		TokenHash: hash,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(resetTokenTTL),
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	link := config.Confs.Settings.AppDomain + "/reset-password?token=" + url.QueryEscape(plain)
+	body := fmt.Sprintf("A password reset was requested for your account.\n\n"+
+		"Open the link below within %d minutes to choose a new password:\n%s\n\n"+
+		"If you didn't request it, you can ignore this email.", int(resetTokenTTL.Minutes()), link)
+
+	return mailer.Send([]string{user.Email}, "Reset your password", body)
+}
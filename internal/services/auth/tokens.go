@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"client-runaway-zenoti/internal/config"
+	"client-runaway-zenoti/internal/db/models"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+)
+
+const (
+	accessTokenTTL   = 15 * time.Minute
+	refreshTokenTTL  = 30 * 24 * time.Hour
+	resetTokenTTL    = time.Hour
+	accessTokenType  = "access"
+	jwtHeaderEncoded = "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9" // {"alg":"HS256","typ":"JWT"}
+)
+
+type (
+	accessClaims struct {
+		Sub uint   `json:"sub"`
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+		Typ string `json:"typ"`
+	}
+
+	tokenPair struct {
+		AccessToken  string      `json:"accessToken"`
+		RefreshToken string      `json:"refreshToken"`
+		ExpiresIn    int         `json:"expiresIn"`
+		User         models.User `json:"user"`
+	}
+)
+
+var (
+	jwtSecret     []byte
+	jwtSecretOnce sync.Once
+
+	errInvalidToken = errors.New("invalid or expired token")
+)
+
+func getJwtSecret() []byte {
+	jwtSecretOnce.Do(func() {
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			secret = config.Confs.Settings.JWTSecret
+		}
+		if secret != "" {
+			jwtSecret = []byte(secret)
+			return
+		}
+
+		// a generated secret would void every session on restart and differ
+		// between instances, only tests may run on one
+		if !testing.Testing() {
+			lvn.Logger.Panicf("auth: JWT_SECRET or Settings.JWTSecret must be set")
+		}
+		jwtSecret = make([]byte, 32)
+		rand.Read(jwtSecret)
+	})
+	return jwtSecret
+}
+
+// RequireJwtSecret stops the server at startup when no JWT secret is
+// configured, rather than on the first login
+func RequireJwtSecret() {
+	getJwtSecret()
+}
+
+func issueAccessToken(user models.User) (string, error) {
+	now := time.Now()
+	claims, err := json.Marshal(accessClaims{
+		Sub: user.ID,
+		Iat: now.Unix(),
+		Exp: now.Add(accessTokenTTL).Unix(),
+		Typ: accessTokenType,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := jwtHeaderEncoded + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return unsigned + "." + signJwt(unsigned), nil
+}
+
+func parseAccessToken(token string) (accessClaims, error) {
+	claims := accessClaims{}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeaderEncoded {
+		return claims, errInvalidToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(signJwt(parts[0]+"."+parts[1]))) {
+		return claims, errInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, errInvalidToken
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, errInvalidToken
+	}
+	if claims.Typ != accessTokenType || claims.Sub == 0 || time.Now().Unix() >= claims.Exp {
+		return claims, errInvalidToken
+	}
+
+	return claims, nil
+}
+
+func signJwt(unsigned string) string {
+	mac := hmac.New(sha256.New, getJwtSecret())
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// randomToken returns an opaque token and the hash it is stored under
+func randomToken(prefix string) (plain string, hash string, err error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", "", err
+	}
+	plain = prefix + hex.EncodeToString(bytes)
+	return plain, hashToken(plain), nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func issueTokenPair(user models.User, userAgent, ip string) (tokenPair, error) {
+	access, err := issueAccessToken(user)
+	if err != nil {
+		return tokenPair{}, err
+	}
+
+	plain, hash, err := randomToken("sbr_")
+	if err != nil {
+		return tokenPair{}, err
+	}
+
+	err = models.DB.Create(&models.RefreshToken{
+		TokenHash: hash,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+		UserAgent: userAgent,
+		IP:        ip,
+	}).Error
+	if err != nil {
+		return tokenPair{}, err
+	}
+
+	return tokenPair{
+		AccessToken:  access,
+		RefreshToken: plain,
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+		User:         user,
+	}, nil
+}
+
+// rotateRefreshToken revokes the presented refresh token and issues a new
+// pair. Presenting an already revoked token revokes every session of the
+// user, since it means the token was stolen or replayed.
+func rotateRefreshToken(token, userAgent, ip string) (tokenPair, error) {
+	rt := models.RefreshToken{}
+	err := models.DB.Where("token_hash = ?", hashToken(token)).First(&rt).Error
+	if err != nil {
+		return tokenPair{}, errInvalidToken
+	}
+
+	if rt.RevokedAt != nil {
+		revokeAllRefreshTokens(rt.UserID)
+		return tokenPair{}, errInvalidToken
+	}
+	if time.Now().After(rt.ExpiresAt) {
+		return tokenPair{}, errInvalidToken
+	}
+
+	// conditional update so two concurrent refreshes can't both succeed
+	res := models.DB.Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", rt.ID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return tokenPair{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return tokenPair{}, errInvalidToken
+	}
+
+	user := models.User{}
+	err = models.DB.Preload("Profile").First(&user, rt.UserID).Error
+	if err != nil {
+		return tokenPair{}, errInvalidToken
+	}
+
+	return issueTokenPair(user, userAgent, ip)
+}
+
+func revokeRefreshToken(token string) error {
+	return models.DB.Model(&models.RefreshToken{}).
+		Where("token_hash = ? AND revoked_at IS NULL", hashToken(token)).
+		Update("revoked_at", time.Now()).Error
+}
+
+func revokeAllRefreshTokens(userID uint) error {
+	return models.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
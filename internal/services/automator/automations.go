@@ -3,6 +3,8 @@ package automator
 import (
 	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/auth"
+	"client-runaway-zenoti/internal/services/svc_audit"
 	"errors"
 	"fmt"
 	"regexp"
@@ -35,9 +37,10 @@ func CreateAutomation(c *gin.Context) {
 	err := c.BindJSON(&payload)
 	lvn.GinErr(c, 400, err, "error while binding json")
 
+	user := c.MustGet("user").(models.User)
 	payload.LocationId = locationId
-	payload.CreatorId = c.MustGet("user").(models.User).ID
-	payload.UpdaterId = c.MustGet("user").(models.User).ID
+	payload.CreatorId = user.ID
+	payload.UpdaterId = user.ID
 
 	if validationErrors := validateAutomationGraph(payload); len(validationErrors) > 0 {
 		c.Data(lvn.Res(400, gin.H{
@@ -49,6 +52,8 @@ func CreateAutomation(c *gin.Context) {
 	err = db.DB.Create(&payload).Error
 	lvn.GinErr(c, 400, err, "error while creating automation")
 
+	svc_audit.Record(c, models.AuditCreate, "automation", payload.ID, payload.LocationId, nil, payload)
+
 	c.Data(lvn.Res(200, payload, ""))
 }
 
@@ -62,6 +67,7 @@ func UpdateAutomation(c *gin.Context) {
 	var automation models.Automation
 	err = db.DB.First(&automation, "id = ?", automationId).Error
 	lvn.GinErr(c, 400, err, "error while getting automation")
+	before := automation
 
 	payload.UpdaterId = c.MustGet("user").(models.User).ID
 
@@ -71,6 +77,10 @@ func UpdateAutomation(c *gin.Context) {
 		}, "automation validation failed"))
 		return
 	}
+	if payload.LocationId != automation.LocationId && !auth.CanSeeLocations(c, []string{payload.LocationId}) {
+		c.Data(lvn.Res(403, nil, "You don't have access to this location"))
+		return
+	}
 	automation.Name = payload.Name
 	automation.Description = payload.Description
 	automation.LocationId = payload.LocationId
@@ -82,6 +92,8 @@ func UpdateAutomation(c *gin.Context) {
 
 	lvn.GinErr(c, 400, err, "error while updating automation")
 
+	svc_audit.Record(c, models.AuditUpdate, "automation", automation.ID, automation.LocationId, before, automation)
+
 	c.Data(lvn.Res(200, automation, ""))
 }
 
@@ -89,9 +101,15 @@ func DeleteAutomation(c *gin.Context) {
 
 	automationId := c.Param("automationId")
 
-	err := db.DB.Delete(&models.Automation{}, "id = ?", automationId).Error
+	var automation models.Automation
+	err := db.DB.First(&automation, "id = ?", automationId).Error
+	lvn.GinErr(c, 400, err, "error while getting automation")
+
+	err = db.DB.Delete(&automation).Error
 	lvn.GinErr(c, 400, err, "error while deleting automation")
 
+	svc_audit.Record(c, models.AuditDelete, "automation", automation.ID, automation.LocationId, automation, nil)
+
 	c.Data(lvn.Res(200, "", ""))
 }
 
@@ -177,6 +195,8 @@ func DuplicateAutomation(c *gin.Context) {
 		return
 	}
 
+	svc_audit.Record(c, models.AuditCreate, "automation", newAutomation.ID, newAutomation.LocationId, nil, newAutomation)
+
 	c.Data(lvn.Res(200, newAutomation, ""))
 
 }
@@ -218,6 +238,10 @@ func validateAutomationGraph(auto models.Automation) []error {
 			errs = append(errs, fmt.Errorf("node %s references unknown catalog node %s", nodeLbl, node.Type))
 			continue
 		}
+		catalogNode, err := withConfigPorts(catalogNode, node)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node %s has invalid configuration: %w", nodeLbl, err))
+		}
 		catalogByNodeID[node.ID] = catalogNode
 
 		if catalogNode.Type != "" && string(node.Kind) != string(catalogNode.Type) {
@@ -317,6 +341,7 @@ func validateNodeConfig(node models.APINode, catalogNode Node, edgeByID map[stri
 		}
 
 		errs = append(errs, validateConfigValueReferences(node.ID, cfgMap, nodeByID, edgesFrom, catalogByNodeID)...)
+		errs = append(errs, validateConfigValueTypes(node.ID, catalogNode, cfgMap, nodeByID, edgesFrom, catalogByNodeID)...)
 	}
 
 	return errs
@@ -538,20 +563,79 @@ func findPortsForReference(fromNodeID, toNodeID string, edgesFrom map[string][]m
 }
 
 func nodePortHasField(node Node, portName, fieldKey string) bool {
+	_, ok := nodePortField(node, portName, fieldKey)
+	return ok
+}
+
+func nodePortField(node Node, portName, fieldKey string) (NodeField, bool) {
 	if portName == "" || fieldKey == "" {
-		return false
+		return NodeField{}, false
 	}
 	for _, port := range node.Ports {
 		if port.Name != portName {
 			continue
 		}
-		for _, field := range port.Payload {
-			if field.Key == fieldKey {
-				return true
+		if field, ok := portField(port, fieldKey); ok {
+			return field, true
+		}
+	}
+	return NodeField{}, false
+}
+
+// validateConfigValueTypes checks number and boolean fields set to a single
+// placeholder against the type of the referenced field. Only fields of nodes
+// that build their ports from config are checked, their types are declared
+// by the builder.
+func validateConfigValueTypes(nodeID string, catalogNode Node, cfg map[string]interface{}, nodeByID map[string]models.APINode, edgesFrom map[string][]models.APIEdge, catalogByNodeID map[string]Node) []error {
+	var errs []error
+	nodeLbl := nodeLabelByID(nodeID, nodeByID)
+
+	for _, field := range catalogNode.Fields {
+		expected := normalizeFieldType(field.Type)
+		if expected != "number" && expected != "boolean" {
+			continue
+		}
+		raw, ok := cfg[field.Key].(string)
+		if !ok {
+			continue
+		}
+		match := nodeReferenceRegex.FindStringSubmatch(strings.TrimSpace(raw))
+		if match == nil || match[0] != strings.TrimSpace(raw) {
+			continue
+		}
+
+		refID := match[1]
+		refCatalog, ok := catalogByNodeID[refID]
+		if !ok || refCatalog.PortsFunc == nil {
+			continue
+		}
+		fieldKey := strings.Split(strings.TrimPrefix(strings.TrimSpace(match[2]), "."), ".")[0]
+
+		for _, port := range findPortsForReference(refID, nodeID, edgesFrom, nodeByID) {
+			refField, ok := nodePortField(refCatalog, port, fieldKey)
+			if !ok {
+				continue
 			}
+			if actual := normalizeFieldType(refField.Type); actual != "" && actual != expected {
+				errs = append(errs, fmt.Errorf("node %s field %s expects a %s, but %s of %s is a %s", nodeLbl, field.Key, expected, fieldKey, nodeLabelByID(refID, nodeByID), actual))
+			}
+			break
 		}
 	}
-	return false
+
+	return errs
+}
+
+func normalizeFieldType(t string) string {
+	switch t {
+	case "bool", "boolean":
+		return "boolean"
+	case "number", "integer":
+		return "number"
+	case "string":
+		return "string"
+	}
+	return ""
 }
 
 func nodeLabel(node models.APINode) string {
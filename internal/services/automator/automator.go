@@ -4,4 +4,5 @@ import "client-runaway-zenoti/internal/runway"
 
 func init() {
 	svc = runway.GetSvc()
+	triggerStageHit = AttributionTriggerStageHit
 }
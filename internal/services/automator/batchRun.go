@@ -351,9 +351,13 @@ func RestartMultipleAutomationRuns(c *gin.Context) {
 		return
 	}
 
-	// Find all automation runs
+	// Find the automation runs of the active profile
 	var runs []models.AutomationRun
-	err = db.DB.Where("id IN ?", request.RunIDs).Find(&runs).Error
+	err = db.DB.
+		Joins("JOIN automations ON automations.id = automation_runs.automation_id").
+		Joins("JOIN locations ON locations.id = automations.location_id").
+		Where("automation_runs.id IN ? AND locations.profile_id = ?", request.RunIDs, c.MustGet("user").(models.User).ProfileID).
+		Find(&runs).Error
 	lvn.GinErr(c, 500, err, "Error getting automation runs")
 
 	if len(runs) == 0 {
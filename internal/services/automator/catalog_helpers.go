@@ -25,6 +25,61 @@ func getCatalogNode(nodeType string) (Node, bool) {
 	return node, ok
 }
 
+// withConfigPorts resolves the ports of a node that builds them from its
+// config. The node's configs are merged, a field declared in any of them is
+// provided.
+func withConfigPorts(catalogNode Node, node models.APINode) (Node, error) {
+	if catalogNode.PortsFunc == nil {
+		return catalogNode, nil
+	}
+
+	configs := []map[string]interface{}{}
+	for _, cfg := range node.Config {
+		configs = append(configs, cfg)
+	}
+	if len(configs) == 0 {
+		configs = append(configs, map[string]interface{}{})
+	}
+
+	ports := []NodePort{}
+	for _, cfg := range configs {
+		cfgPorts, err := catalogNode.PortsFunc(cfg)
+		if err != nil {
+			return catalogNode, err
+		}
+		for _, port := range cfgPorts {
+			ports = mergePort(ports, port)
+		}
+	}
+
+	catalogNode.Ports = ports
+	return catalogNode, nil
+}
+
+func mergePort(ports []NodePort, port NodePort) []NodePort {
+	for i := range ports {
+		if ports[i].Name != port.Name {
+			continue
+		}
+		for _, field := range port.Payload {
+			if _, ok := portField(ports[i], field.Key); !ok {
+				ports[i].Payload = append(ports[i].Payload, field)
+			}
+		}
+		return ports
+	}
+	return append(ports, NodePort{Name: port.Name, Payload: append([]NodeField{}, port.Payload...)})
+}
+
+func portField(port NodePort, key string) (NodeField, bool) {
+	for _, field := range port.Payload {
+		if field.Key == key {
+			return field, true
+		}
+	}
+	return NodeField{}, false
+}
+
 func errorPayload(err error, message string) map[string]map[string]interface{} {
 	errorMsg := ""
 	if err != nil {
@@ -4,7 +4,12 @@ import (
 	"client-runaway-zenoti/internal/db/models"
 	"client-runaway-zenoti/internal/services/svc_openai"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -16,6 +21,7 @@ var (
 		Color: "#10B981",
 		Nodes: []Node{
 			aiAssistantAction,
+			aiExtractAction,
 		},
 	}
 
@@ -40,8 +46,39 @@ var (
 	aiAssistantOutputFields = []NodeField{
 		{Key: "message", Type: "string"},
 	}
+
+	aiExtractAction = Node{
+		Id:          "ai.extract",
+		Title:       "AI Extract",
+		Description: "Extracts structured fields from text with a selected AI assistant. Each output field is available on the success port.",
+		ExecFunc:    aiExtractExecute,
+		Type:        NodeTypeAction,
+		Icon:        "ri:braces-line",
+		Color:       ColorAction,
+		Ports: []NodePort{
+			successPort([]NodeField{}),
+			errorPort,
+		},
+		PortsFunc: aiExtractPorts,
+		Fields: []NodeField{
+			{Key: "assistant_id", Type: "string", Required: true, ListFromApi: "aiAssistants"},
+			{Key: "text", Type: "string", Required: true},
+			// [{"name": "intent", "type": "string", "enum": ["booking", "question"], "description": "..."}]
+			{Key: "outputs", Label: "Output Fields", Type: "json", Required: true},
+		},
+	}
 )
 
+// aiExtractField is an output field of ai.extract as defined by the builder
+type aiExtractField struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"` // string, number, integer or boolean
+	Enum        []string `json:"enum,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+var aiExtractFieldName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 func aiAssistantExecute(ctx context.Context, fields map[string]interface{}, l models.Location) map[string]map[string]interface{} {
 	text := strings.TrimSpace(fmt.Sprint(fields["text"]))
 	if text == "" {
@@ -53,10 +90,169 @@ func aiAssistantExecute(ctx context.Context, fields map[string]interface{}, l mo
 		return errorPayload(nil, "assistant_id is required")
 	}
 
-	responseText, err := svc_openai.RunThroughAssistant(rawID, text)
+	responseText, err := svc_openai.RunThroughAssistant(ctx, rawID, text)
+	if errors.Is(err, svc_openai.ErrBudgetExceeded) {
+		return errorPayload(err, "AI budget exceeded")
+	}
 	if err != nil {
 		return errorPayload(err, "assistant run failed")
 	}
 
 	return successPayload(map[string]interface{}{"message": responseText})
 }
+
+func aiExtractExecute(ctx context.Context, fields map[string]interface{}, l models.Location) map[string]map[string]interface{} {
+	text := strings.TrimSpace(fmt.Sprint(fields["text"]))
+	if text == "" {
+		return errorPayload(nil, "text is required")
+	}
+
+	rawID := strings.TrimSpace(fmt.Sprint(fields["assistant_id"]))
+	if rawID == "" || rawID == "<nil>" {
+		return errorPayload(nil, "assistant_id is required")
+	}
+
+	outputs, err := parseAIExtractFields(fields["outputs"])
+	if err != nil {
+		return errorPayload(err, "invalid output fields")
+	}
+
+	result, err := svc_openai.ExtractThroughAssistant(ctx, rawID, text, aiExtractSchema(outputs))
+	if errors.Is(err, svc_openai.ErrBudgetExceeded) {
+		return errorPayload(err, "AI budget exceeded")
+	}
+	if err != nil {
+		return errorPayload(err, "assistant run failed")
+	}
+
+	values, err := validateAIExtractResult(outputs, result)
+	if err != nil {
+		return errorPayload(err, "assistant returned an invalid result")
+	}
+
+	return successPayload(values)
+}
+
+func aiExtractPorts(config map[string]interface{}) ([]NodePort, error) {
+	payload := []NodeField{}
+	if raw, ok := config["outputs"]; ok && !isEmptyConfigValue(raw) {
+		outputs, err := parseAIExtractFields(raw)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range outputs {
+			typ := f.Type
+			if typ == "integer" {
+				typ = "number"
+			}
+			payload = append(payload, NodeField{Key: f.Name, Label: f.Description, Type: typ, SelectOptions: f.Enum})
+		}
+	}
+
+	return []NodePort{successPort(payload), errorPort}, nil
+}
+
+// parseAIExtractFields reads the output fields, given as a list or as JSON text
+func parseAIExtractFields(raw interface{}) ([]aiExtractField, error) {
+	data, ok := raw.(string)
+	if !ok {
+		encoded, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		data = string(encoded)
+	}
+
+	outputs := []aiExtractField{}
+	if err := json.Unmarshal([]byte(data), &outputs); err != nil {
+		return nil, fmt.Errorf("outputs must be a list of fields: %w", err)
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("at least one output field is required")
+	}
+
+	seen := map[string]bool{}
+	for i, f := range outputs {
+		if !aiExtractFieldName.MatchString(f.Name) {
+			return nil, fmt.Errorf("output field %q must be a letter or underscore followed by letters, digits or underscores", f.Name)
+		}
+		if seen[f.Name] {
+			return nil, fmt.Errorf("duplicate output field %s", f.Name)
+		}
+		seen[f.Name] = true
+
+		if f.Type == "" {
+			outputs[i].Type = "string"
+		}
+		switch outputs[i].Type {
+		case "string", "number", "integer", "boolean":
+		default:
+			return nil, fmt.Errorf("output field %s has unsupported type %s", f.Name, f.Type)
+		}
+		if len(f.Enum) > 0 && outputs[i].Type != "string" {
+			return nil, fmt.Errorf("output field %s: enum is only supported for strings", f.Name)
+		}
+	}
+	return outputs, nil
+}
+
+// aiExtractSchema builds the strict JSON schema the model must answer with
+func aiExtractSchema(outputs []aiExtractField) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, f := range outputs {
+		prop := map[string]interface{}{"type": f.Type}
+		if f.Description != "" {
+			prop["description"] = f.Description
+		}
+		if len(f.Enum) > 0 {
+			prop["enum"] = f.Enum
+		}
+		properties[f.Name] = prop
+		required = append(required, f.Name)
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// validateAIExtractResult checks the model answer against the output fields
+// and returns only the declared ones
+func validateAIExtractResult(outputs []aiExtractField, result map[string]interface{}) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(outputs))
+	for _, f := range outputs {
+		value, ok := result[f.Name]
+		if !ok || value == nil {
+			return nil, fmt.Errorf("field %s is missing", f.Name)
+		}
+
+		switch f.Type {
+		case "string":
+			str, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("field %s must be a string", f.Name)
+			}
+			if len(f.Enum) > 0 && !slices.Contains(f.Enum, str) {
+				return nil, fmt.Errorf("field %s must be one of %s", f.Name, strings.Join(f.Enum, ", "))
+			}
+		case "number", "integer":
+			num, ok := value.(float64)
+			if !ok {
+				return nil, fmt.Errorf("field %s must be a number", f.Name)
+			}
+			if f.Type == "integer" && num != math.Trunc(num) {
+				return nil, fmt.Errorf("field %s must be an integer", f.Name)
+			}
+		case "boolean":
+			if _, ok := value.(bool); !ok {
+				return nil, fmt.Errorf("field %s must be a boolean", f.Name)
+			}
+		}
+		values[f.Name] = value
+	}
+	return values, nil
+}
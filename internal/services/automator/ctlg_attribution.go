@@ -0,0 +1,387 @@
+package automator
+
+import (
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/svc_attribution"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// Attribution Category
+	attributionCategory = Category{
+		Id:   "attribution",
+		Name: "Attribution",
+		Nodes: []Node{
+			attributionActionFindPerson,
+			attributionActionCreatePerson,
+			attributionActionUpdatePerson,
+			attributionActionRecordStageHit,
+			attributionStageHit,
+		},
+	}
+
+	// Triggers
+	attributionStageHit = Node{
+		Id:          "attribution.stage.hit",
+		Title:       "Stage Hit",
+		Description: "Triggers when a person hits a specific stage in Attribution.",
+		Type:        NodeTypeTrigger,
+		Icon:        "ri:form",
+		Color:       ColorTrigger,
+		Ports: []NodePort{
+			{
+				Name:    "out",
+				Payload: attributionStageHitNodeFields,
+			},
+		},
+		// filters, empty matches any flow or stage
+		Fields: []NodeField{
+			{Key: "flowId", Label: "Flow", Type: "string", ListFromApi: "attributionFlows"},
+			{Key: "stage", Label: "Stage", Type: "string"},
+		},
+	}
+
+	// Actions
+	attributionActionFindPerson = Node{
+		Id:          "attribution.person.find",
+		Title:       "Find Person",
+		Description: "Finds a person in Attribution. with email or phone.",
+		ExecFunc:    attributionFindPersonFunc,
+		Type:        NodeTypeAction,
+		Icon:        "ri:form",
+		Color:       ColorAction,
+		Ports: []NodePort{
+			successPort(attributionPersonNodeFields),
+			customPort("notFound", []NodeField{}),
+			errorPort,
+		},
+		Fields: []NodeField{
+			{Key: "email", Type: "string"},
+			{Key: "phone", Type: "string"},
+		},
+	}
+
+	attributionActionCreatePerson = Node{
+		Id:          "attribution.person.create",
+		Title:       "Create Person",
+		Description: "Creates a new person in Attribution. A person with the same contact id, email or phone is updated instead.",
+		ExecFunc:    attributionCreatePersonFunc,
+		Type:        NodeTypeAction,
+		Icon:        "ri:form",
+		Color:       ColorAction,
+		Ports: []NodePort{
+			successPort(attributionPersonNodeFields),
+			errorPort,
+		},
+		Fields: attributionPersonInputFields,
+	}
+
+	attributionActionUpdatePerson = Node{
+		Id:          "attribution.person.update",
+		Title:       "Update Person",
+		Description: "Updates an existing person in Attribution.",
+		ExecFunc:    attributionUpdatePersonFunc,
+		Type:        NodeTypeAction,
+		Icon:        "ri:form",
+		Color:       ColorAction,
+		Ports: []NodePort{
+			successPort(attributionPersonNodeFields),
+			errorPort,
+		},
+		Fields: append([]NodeField{
+			{Key: "personId", Label: "Person ID", Type: "string", Required: true},
+		}, attributionPersonInputFields...),
+	}
+
+	attributionActionRecordStageHit = Node{
+		Id:          "attribution.stage.record",
+		Title:       "Record Stage Hit",
+		Description: "Records that a person reached a stage of an attribution flow.",
+		ExecFunc:    attributionRecordStageHitFunc,
+		Type:        NodeTypeAction,
+		Icon:        "ri:flag-line",
+		Color:       ColorAction,
+		Ports: []NodePort{
+			successPort(attributionStageHitNodeFields),
+			errorPort,
+		},
+		Fields: []NodeField{
+			{Key: "personId", Label: "Person ID", Type: "string", Required: true},
+			{Key: "flowId", Label: "Flow", Type: "string", Required: true, ListFromApi: "attributionFlows"},
+			{Key: "stage", Label: "Stage", Type: "string", Required: true},
+			{Key: "revenue", Label: "Revenue", Type: "number"},
+			{Key: "occurredAt", Label: "Occurred At", Type: "string"},
+			{Key: "refSystem", Label: "Ref System", Type: "string", SelectOptions: []string{"ghl", "zenoti", "cerbo", "sales-bridge"}},
+			{Key: "refId", Label: "Ref ID", Type: "string"},
+			{Key: "refLink", Label: "Ref Link", Type: "string"},
+		},
+	}
+
+	//////////////////////////////////////////////////
+	//                  Node Fields
+	///////////////////////////////////////////////////
+
+	attributionPersonNodeFields = []NodeField{
+		{Key: "personId", Type: "string"},
+		{Key: "first_name", Type: "string"},
+		{Key: "last_name", Type: "string"},
+		{Key: "email", Type: "string"},
+		{Key: "phone", Type: "string"},
+		{Key: "source", Label: "Source", Type: "string"},
+		{Key: "contactId", Label: "GHL Contact ID", Type: "string"},
+		{Key: "opportunityId", Label: "GHL Opportunity ID", Type: "string"},
+		{Key: "emrSystem", Label: "EMR System", Type: "string"},
+		{Key: "emrContactId", Label: "EMR Contact ID", Type: "string"},
+		{Key: "emrContactLink", Label: "EMR Contact Link", Type: "string"},
+	}
+
+	attributionPersonInputFields = []NodeField{
+		{Key: "first_name", Type: "string"},
+		{Key: "last_name", Type: "string"},
+		{Key: "email", Type: "string"},
+		{Key: "phone", Type: "string"},
+		{Key: "source", Label: "Source", Type: "string"},
+		{Key: "contactId", Label: "GHL Contact ID", Type: "string"},
+		{Key: "opportunityId", Label: "GHL Opportunity ID", Type: "string"},
+		{Key: "emrSystem", Label: "EMR System", Type: "string", SelectOptions: []string{"zenoti", "cerbo"}},
+		{Key: "emrContactId", Label: "EMR Contact ID", Type: "string"},
+		{Key: "emrContactLink", Label: "EMR Contact Link", Type: "string"},
+	}
+
+	attributionStageHitNodeFields = []NodeField{
+		{Key: "personId", Type: "string"},
+		{Key: "first_name", Type: "string"},
+		{Key: "last_name", Type: "string"},
+		{Key: "email", Type: "string"},
+		{Key: "phone", Type: "string"},
+		{Key: "source", Label: "Source", Type: "string"},
+		{Key: "stageHitId", Label: "Stage Hit ID", Type: "string"},
+		{Key: "flowId", Label: "Flow ID", Type: "string"},
+		{Key: "stage", Label: "Stage", Type: "string"},
+		{Key: "revenue", Label: "Revenue", Type: "number"},
+		{Key: "occurredAt", Label: "Occurred At", Type: "string"},
+		{Key: "refSystem", Label: "Ref System", Type: "string"},
+		{Key: "refId", Label: "Ref ID", Type: "string"},
+		{Key: "refLink", Label: "Ref Link", Type: "string"},
+	}
+)
+
+//////////////////////////////////////////////////
+//
+//                  Functions
+//
+///////////////////////////////////////////////////
+
+// triggerStageHit is AttributionTriggerStageHit, set in init: the node
+// running the automations it triggers would be an initialization cycle
+var triggerStageHit func(ctx context.Context, hit models.StageHit, person models.Person) error
+
+// stageHitChainKey carries the flow stages whose hits started the runs of
+// the context
+type stageHitChainKey struct{}
+
+// AttributionTriggerStageHit starts the automations of the hit's location
+// triggered by the flow and stage. A hit recorded by a run that the same
+// flow and stage started doesn't trigger again, so an automation can't loop
+// on its own trigger.
+func AttributionTriggerStageHit(ctx context.Context, hit models.StageHit, person models.Person) error {
+	if hit.LocationId == "" {
+		return nil
+	}
+
+	chain, _ := ctx.Value(stageHitChainKey{}).([]string)
+	key := fmt.Sprintf("%v:%s", hit.AttributionFlowId, hit.Stage)
+	if slices.Contains(chain, key) {
+		log.Printf("automator: stage hit %d of %s re-enters its own trigger, not starting automations", hit.ID, key)
+		return nil
+	}
+	ctx = context.WithValue(ctx, stageHitChainKey{}, append(slices.Clip(chain), key))
+
+	return StartAutomationsForTrigger(ctx, TriggerInput{
+		LocationID:  hit.LocationId,
+		TriggerType: "attribution.stage.hit",
+		Port:        "out",
+		Payload:     mapStageHitToNodePayload(hit, person),
+		Filters: map[string]interface{}{
+			"flowId": hit.AttributionFlowId,
+			"stage":  hit.Stage,
+		},
+	})
+}
+
+func attributionFindPersonFunc(ctx context.Context, fields map[string]interface{}, l models.Location) map[string]map[string]interface{} {
+	email, _ := fields["email"].(string)
+	phone, _ := fields["phone"].(string)
+	if strings.TrimSpace(email) == "" && strings.TrimSpace(phone) == "" {
+		return errorPayload(nil, "email or phone is required")
+	}
+
+	person, err := svc_attribution.FindPerson(l.ProfileID, svc_attribution.Identity{Email: email, Phone: phone})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return customPayload("notFound", map[string]interface{}{})
+	}
+	if err != nil {
+		return errorPayload(err, "failed to find person")
+	}
+
+	return successPayload(mapPersonToNodePayload(person))
+}
+
+func attributionCreatePersonFunc(ctx context.Context, fields map[string]interface{}, l models.Location) map[string]map[string]interface{} {
+	person, _, err := svc_attribution.ResolvePerson(l.ProfileID, l.Id, identityFromFields(fields))
+	if err != nil {
+		return errorPayload(err, "failed to create person")
+	}
+
+	return successPayload(mapPersonToNodePayload(person))
+}
+
+func attributionUpdatePersonFunc(ctx context.Context, fields map[string]interface{}, l models.Location) map[string]map[string]interface{} {
+	personID, ok := attributionID(fields["personId"])
+	if !ok {
+		return errorPayload(nil, "personId is required")
+	}
+
+	person, err := svc_attribution.UpdatePerson(l.ProfileID, personID, identityFromFields(fields))
+	if err != nil {
+		return errorPayload(err, "failed to update person")
+	}
+
+	return successPayload(mapPersonToNodePayload(person))
+}
+
+func attributionRecordStageHitFunc(ctx context.Context, fields map[string]interface{}, l models.Location) map[string]map[string]interface{} {
+	personID, ok := attributionID(fields["personId"])
+	if !ok {
+		return errorPayload(nil, "personId is required")
+	}
+	flowID, ok := attributionID(fields["flowId"])
+	if !ok {
+		return errorPayload(nil, "flowId is required")
+	}
+
+	input := svc_attribution.HitInput{
+		PersonId:   personID,
+		FlowId:     flowID,
+		Stage:      fieldString(fields, "stage"),
+		LocationId: l.Id,
+		RefSystem:  fieldString(fields, "refSystem"),
+		RefId:      fieldString(fields, "refId"),
+		RefLink:    fieldString(fields, "refLink"),
+	}
+	if raw := fields["revenue"]; raw != nil && fmt.Sprint(raw) != "" {
+		revenue, ok := toFloat(raw)
+		if !ok {
+			return errorPayload(nil, "revenue must be a number")
+		}
+		input.Revenue = revenue
+	}
+	if raw := fieldString(fields, "occurredAt"); raw != "" {
+		occurredAt, err := parseOccurredAt(raw)
+		if err != nil {
+			return errorPayload(err, "occurredAt is invalid")
+		}
+		input.OccurredAt = occurredAt
+	}
+
+	hit, person, recorded, err := svc_attribution.RecordStageHit(l.ProfileID, input)
+	if err != nil {
+		return errorPayload(err, "failed to record stage hit")
+	}
+
+	if recorded {
+		// the triggered runs outlive this one
+		err = triggerStageHit(context.WithoutCancel(ctx), hit, person)
+		if err != nil {
+			log.Printf("automator: trigger stage hit %d: %s", hit.ID, err.Error())
+		}
+	}
+
+	return successPayload(mapStageHitToNodePayload(hit, person))
+}
+
+func identityFromFields(fields map[string]interface{}) svc_attribution.Identity {
+	return svc_attribution.Identity{
+		FirstName:      fieldString(fields, "first_name"),
+		LastName:       fieldString(fields, "last_name"),
+		Email:          fieldString(fields, "email"),
+		Phone:          fieldString(fields, "phone"),
+		Source:         fieldString(fields, "source"),
+		ContactId:      fieldString(fields, "contactId"),
+		OpportunityId:  fieldString(fields, "opportunityId"),
+		EmrSystem:      fieldString(fields, "emrSystem"),
+		EmrContactId:   fieldString(fields, "emrContactId"),
+		EmrContactLink: fieldString(fields, "emrContactLink"),
+	}
+}
+
+// fieldString returns a field as a trimmed string, empty when it isn't set
+func fieldString(fields map[string]interface{}, key string) string {
+	value, ok := fields[key]
+	if !ok || value == nil {
+		return ""
+	}
+	return strings.TrimSpace(toString(value))
+}
+
+// attributionID reads a person or flow id, given as a number or a string
+func attributionID(value interface{}) (uint, bool) {
+	id, ok := toFloat(value)
+	if !ok || id < 1 || id != float64(uint(id)) {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func parseOccurredAt(raw string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported date %q", raw)
+}
+
+func mapPersonToNodePayload(person models.Person) map[string]interface{} {
+	return map[string]interface{}{
+		"personId":       strconv.FormatUint(uint64(person.ID), 10),
+		"first_name":     person.FirstName,
+		"last_name":      person.LastName,
+		"email":          person.Email,
+		"phone":          person.Phone,
+		"source":         person.Source,
+		"contactId":      person.ContactId,
+		"opportunityId":  person.OpportunityId,
+		"emrSystem":      person.EmrSystem,
+		"emrContactId":   person.EmrContactId,
+		"emrContactLink": person.EMRContactLink,
+	}
+}
+
+func mapStageHitToNodePayload(hit models.StageHit, person models.Person) map[string]interface{} {
+	return map[string]interface{}{
+		"personId":   hit.PersonId,
+		"first_name": person.FirstName,
+		"last_name":  person.LastName,
+		"email":      person.Email,
+		"phone":      person.Phone,
+		"source":     person.Source,
+		"stageHitId": strconv.FormatUint(uint64(hit.ID), 10),
+		"flowId":     strconv.FormatUint(uint64(hit.AttributionFlowId), 10),
+		"stage":      hit.Stage,
+		"revenue":    hit.Revenue,
+		"occurredAt": hit.OccurredAt.Format(time.RFC3339),
+		"refSystem":  hit.RefSystem,
+		"refId":      hit.RefId,
+		"refLink":    hit.RefLink,
+	}
+}
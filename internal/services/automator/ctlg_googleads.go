@@ -5,6 +5,11 @@ import (
 	"client-runaway-zenoti/internal/services/svc_googleads"
 	"client-runaway-zenoti/packages/googleads"
 	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shenzhencenter/google-ads-pb/enums"
 )
 
 var (
@@ -16,6 +21,8 @@ var (
 		Color: "#4C6FFF",
 		Nodes: []Node{
 			gaActionUploadConversionData,
+			gaActionEnhancedConversion,
+			gaActionConversionAdjustment,
 		},
 	}
 )
@@ -31,7 +38,7 @@ var gaActionUploadConversionData = Node{
 	Color:       ColorAction,
 	Ports: []NodePort{
 		successPort([]NodeField{}),
-		errorPort,
+		gaUploadErrorPort,
 	},
 	Fields: []NodeField{
 		{Key: "googleAdsActionId", Type: "string", ListFromApi: "googleAdsActions"},
@@ -42,14 +49,73 @@ var gaActionUploadConversionData = Node{
 	},
 }
 
-func gaUploadConversionData(ctx context.Context, fields map[string]interface{}, l models.Location) (payload map[string]map[string]interface{}) {
-	client, err := svc_googleads.CliForLocation(l.Id, l.ProfileID)
-	if err != nil {
-		return errorPayload(err, "unable to create google ads client")
-	}
-	defer client.Close()
+var gaActionEnhancedConversion = Node{
+	Id:          "ga.enhancedConversion",
+	Title:       "Upload Enhanced Conversion",
+	Description: "Uploads a lead conversion to Google Ads matched by the lead's hashed email and phone, for leads without a gclid.",
+	ExecFunc:    gaUploadEnhancedConversion,
+	Type:        NodeTypeAction,
+	Icon:        "ri:user-search-line",
+	Kind:        "Conversions",
+	Color:       ColorAction,
+	Ports: []NodePort{
+		successPort([]NodeField{}),
+		gaUploadErrorPort,
+	},
+	Fields: []NodeField{
+		{Key: "googleAdsActionId", Type: "string", Required: true, ListFromApi: "googleAdsActions"},
+		{Key: "email", Type: "string"},
+		{Key: "phone", Type: "string"},
+		{Key: "gclid", Type: "string"},
+		{Key: "eventTime", Type: "string"},
+		{Key: "value", Type: "string"},
+		{Key: "currency", Type: "string"},
+		{Key: "orderId", Type: "string"},
+	},
+}
 
-	eventTime, err := parseTime(fields["eventTime"].(string))
+var gaActionConversionAdjustment = Node{
+	Id:          "ga.conversionAdjustment",
+	Title:       "Adjust Conversion",
+	Description: "Restates the value of an uploaded conversion or retracts it, e.g. when a sale is refunded in Zenoti.",
+	ExecFunc:    gaAdjustConversion,
+	Type:        NodeTypeAction,
+	Icon:        "ri:arrow-go-back-line",
+	Kind:        "Conversions",
+	Color:       ColorAction,
+	Ports: []NodePort{
+		successPort([]NodeField{}),
+		gaUploadErrorPort,
+	},
+	Fields: []NodeField{
+		{Key: "googleAdsActionId", Type: "string", Required: true, ListFromApi: "googleAdsActions"},
+		{Key: "adjustmentType", Type: "string", Required: true, SelectOptions: []string{gaAdjustmentRestatement, gaAdjustmentRetraction}},
+		{Key: "orderId", Type: "string"},
+		{Key: "gclid", Type: "string"},
+		{Key: "conversionTime", Type: "string"},
+		{Key: "adjustmentTime", Type: "string"},
+		{Key: "value", Type: "string"},
+		{Key: "currency", Type: "string"},
+	},
+}
+
+const (
+	gaAdjustmentRestatement = "restatement"
+	gaAdjustmentRetraction  = "retraction"
+)
+
+// gaUploadErrorPort also lists the rows Google Ads rejected in the upload
+var gaUploadErrorPort = NodePort{
+	Name: "error",
+	Payload: []NodeField{
+		{Key: "message", Type: "string"},
+		{Key: "error", Type: "string"},
+		{Key: "rows", Type: "json"},
+	},
+}
+
+func gaUploadConversionData(ctx context.Context, fields map[string]interface{}, l models.Location) (payload map[string]map[string]interface{}) {
+	eventTime, err := parseTime(fieldString(fields, "eventTime"))
 	if err != nil {
 		return errorPayload(err, "invalid event time format")
 	}
@@ -58,27 +124,150 @@ func gaUploadConversionData(ctx context.Context, fields map[string]interface{},
 		return errorPayload(err, "invalid value format")
 	}
 
-	actionId, ok := fields["googleAdsActionId"].(string)
-	if !ok || actionId == "" {
+	actionId := fieldString(fields, "googleAdsActionId")
+	if actionId == "" {
 		return errorPayload(err, "googleAdsActionId is required")
 	}
 
-	gclid, ok := fields["gclid"].(string)
-	if !ok || gclid == "" {
+	gclid := fieldString(fields, "gclid")
+	if gclid == "" {
 		return errorPayload(err, "gclid is required")
 	}
 
-	req := googleads.ConversionRequest{
+	return gaSendConversion(l, googleads.ConversionRequest{
 		ConversionActionID: actionId,
 		Gclid:              gclid,
 		EventTime:          eventTime,
 		ConversionValue:    value,
-		OrderId:            fields["orderId"].(string),
+		OrderId:            fieldString(fields, "orderId"),
+	})
+}
+
+func gaUploadEnhancedConversion(ctx context.Context, fields map[string]interface{}, l models.Location) (payload map[string]map[string]interface{}) {
+	req := googleads.ConversionRequest{
+		ConversionActionID: fieldString(fields, "googleAdsActionId"),
+		Email:              fieldString(fields, "email"),
+		Phone:              fieldString(fields, "phone"),
+		Gclid:              fieldString(fields, "gclid"),
+		CurrencyCode:       fieldString(fields, "currency"),
+		OrderId:            fieldString(fields, "orderId"),
+		EventTime:          time.Now(),
 	}
+	if req.ConversionActionID == "" {
+		return errorPayload(errors.New("googleAdsActionId is empty"), "googleAdsActionId is required")
+	}
+	if req.Email == "" && req.Phone == "" {
+		return errorPayload(errors.New("email and phone are empty"), "email or phone is required")
+	}
+
+	if raw := fieldString(fields, "eventTime"); raw != "" {
+		eventTime, err := parseTime(raw)
+		if err != nil {
+			return errorPayload(err, "invalid event time format")
+		}
+		req.EventTime = eventTime
+	}
+	if raw := fieldString(fields, "value"); raw != "" {
+		value, ok := toFloat(fields["value"])
+		if !ok {
+			return errorPayload(fmt.Errorf("value %v is not a number", raw), "invalid value format")
+		}
+		req.ConversionValue = value
+	}
+
+	return gaSendConversion(l, req)
+}
+
+func gaAdjustConversion(ctx context.Context, fields map[string]interface{}, l models.Location) (payload map[string]map[string]interface{}) {
+	req := googleads.ConversionAdjustmentRequest{
+		ConversionActionID:  fieldString(fields, "googleAdsActionId"),
+		OrderID:             fieldString(fields, "orderId"),
+		Gclid:               fieldString(fields, "gclid"),
+		RestatementCurrency: fieldString(fields, "currency"),
+		AdjustmentTime:      time.Now(),
+	}
+	if req.ConversionActionID == "" {
+		return errorPayload(errors.New("googleAdsActionId is empty"), "googleAdsActionId is required")
+	}
+	if req.OrderID == "" && req.Gclid == "" {
+		return errorPayload(errors.New("orderId and gclid are empty"), "orderId or gclid is required")
+	}
+
+	switch fieldString(fields, "adjustmentType") {
+	case gaAdjustmentRestatement:
+		req.AdjustmentType = enums.ConversionAdjustmentTypeEnum_RESTATEMENT
+		value, ok := toFloat(fields["value"])
+		if !ok {
+			return errorPayload(fmt.Errorf("value %v is not a number", fields["value"]), "value is required for a restatement")
+		}
+		req.RestatementValue = value
+	case gaAdjustmentRetraction:
+		req.AdjustmentType = enums.ConversionAdjustmentTypeEnum_RETRACTION
+	default:
+		return errorPayload(fmt.Errorf("unknown adjustment type %q", fieldString(fields, "adjustmentType")), "adjustmentType must be restatement or retraction")
+	}
+
+	// a conversion is found by its gclid and time, or by its order id alone
+	conversionTime, err := parseTime(fieldString(fields, "conversionTime"))
+	if err != nil && req.Gclid != "" {
+		return errorPayload(err, "invalid conversion time format")
+	}
+	if err != nil {
+		conversionTime = req.AdjustmentTime
+	}
+	req.OriginalEventTime = conversionTime
+
+	if raw := fieldString(fields, "adjustmentTime"); raw != "" {
+		req.AdjustmentTime, err = parseTime(raw)
+		if err != nil {
+			return errorPayload(err, "invalid adjustment time format")
+		}
+	}
+
+	client, err := svc_googleads.CliForLocation(l.Id, l.ProfileID)
+	if err != nil {
+		return errorPayload(err, "unable to create google ads client")
+	}
+	defer client.Close()
+
+	err = client.SendConversionAdjustment(req)
+	if err != nil {
+		return gaUploadErrorPayload(err, "unable to upload conversion adjustment")
+	}
+
+	return successPayload(map[string]interface{}{})
+}
+
+func gaSendConversion(l models.Location, req googleads.ConversionRequest) map[string]map[string]interface{} {
+	client, err := svc_googleads.CliForLocation(l.Id, l.ProfileID)
+	if err != nil {
+		return errorPayload(err, "unable to create google ads client")
+	}
+	defer client.Close()
+
 	err = client.SendConversion(req)
 	if err != nil {
-		return errorPayload(err, "unable to upload conversion data")
+		return gaUploadErrorPayload(err, "unable to upload conversion data")
 	}
 
 	return successPayload(map[string]interface{}{})
 }
+
+// gaUploadErrorPayload is errorPayload with the rows Google Ads rejected
+func gaUploadErrorPayload(err error, message string) map[string]map[string]interface{} {
+	payload := errorPayload(err, message)
+
+	var partial *googleads.PartialFailureError
+	if errors.As(err, &partial) {
+		rows := make([]map[string]interface{}, 0, len(partial.Rows))
+		for _, row := range partial.Rows {
+			rows = append(rows, map[string]interface{}{
+				"index":   row.Index,
+				"code":    row.Code,
+				"message": row.Message,
+			})
+		}
+		payload["error"]["rows"] = rows
+	}
+	return payload
+}
@@ -0,0 +1,61 @@
+package automator
+
+var (
+	// MCP Category
+	mcpCategory = Category{
+		Id:    "mcp",
+		Name:  "MCP",
+		Icon:  "ri:robot-2-line",
+		Color: "#7A5AF8",
+		Nodes: []Node{
+			mcpTriggerTool,
+			mcpActionReturn,
+		},
+	}
+
+	// Triggers
+	mcpTriggerTool = Node{
+		Id:          McpToolTriggerType,
+		Title:       "MCP Tool",
+		Description: "Publishes the automation as a tool to AI agents connected over MCP. The tool arguments are the trigger payload.",
+		Type:        NodeTypeTrigger,
+		Icon:        "ri:robot-2-line",
+		Color:       ColorTrigger,
+		Ports: []NodePort{
+			{
+				Name:    defaultPortOut,
+				Payload: []NodeField{},
+			},
+		},
+		PortsFunc: mcpToolTriggerPorts,
+		Fields: []NodeField{
+			{Key: "name", Label: "Tool Name", Type: "string", Required: true},
+			{Key: "description", Label: "Description", Type: "string", Required: true},
+			{Key: "inputs", Label: "Inputs (e.g. email, amount:number, vip:boolean)", Type: "string"},
+			{Key: "requiredInputs", Label: "Required Inputs (e.g. email)", Type: "string"},
+		},
+	}
+
+	// Actions
+	mcpActionReturn = Node{
+		Id:          automationReturnType,
+		Title:       "Return",
+		Description: "Ends an MCP tool run and returns the configured values to the agent.",
+		ExecFunc:    mergeActionFunc,
+		Type:        NodeTypeAction,
+		Icon:        "ri:arrow-go-back-line",
+		Color:       ColorDefault,
+		Ports: []NodePort{
+			successPort([]NodeField{}),
+		},
+		Fields: []NodeField{
+			{Key: "result", Label: "Result", Type: "string"},
+		},
+	}
+)
+
+// mcpToolTriggerPorts exposes the declared tool inputs on the trigger port
+func mcpToolTriggerPorts(config map[string]interface{}) ([]NodePort, error) {
+	inputs := parseMcpToolInputs(configString(config["inputs"]), configString(config["requiredInputs"]))
+	return []NodePort{{Name: defaultPortOut, Payload: inputs}}, nil
+}
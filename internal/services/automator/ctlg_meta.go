@@ -0,0 +1,124 @@
+package automator
+
+import (
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/svc_meta"
+	"client-runaway-zenoti/packages/meta"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// Meta Ads Category
+	metaCategory = Category{
+		Id:    "meta",
+		Name:  "Meta Ads",
+		Icon:  "ri:meta-line",
+		Color: "#0866FF",
+		Nodes: []Node{
+			metaActionUploadConversion,
+		},
+	}
+)
+
+var metaActionUploadConversion = Node{
+	Id:          "meta.conversionUpload",
+	Title:       "Upload Conversion",
+	Description: "Sends an offline conversion to the location's Meta pixel through the Conversions API. Set a test event code to check events in Events Manager without counting them.",
+	ExecFunc:    metaUploadConversion,
+	Type:        NodeTypeAction,
+	Icon:        "ri:meta-line",
+	Kind:        "Conversions",
+	Color:       ColorAction,
+	Ports: []NodePort{
+		successPort([]NodeField{
+			{Key: "eventId", Label: "Event ID", Type: "string"},
+			{Key: "eventsReceived", Label: "Events Received", Type: "number"},
+			{Key: "fbtraceId", Label: "Trace ID", Type: "string"},
+		}),
+		errorPort,
+	},
+	Fields: []NodeField{
+		{Key: "eventName", Label: "Event", Type: "string", Required: true, SelectOptions: []string{meta.EventLead, meta.EventSchedule, meta.EventPurchase}},
+		{Key: "eventTime", Label: "Event Time", Type: "string"},
+		{Key: "eventId", Label: "Event ID", Type: "string"},
+		{Key: "email", Label: "Email", Type: "string"},
+		{Key: "phone", Label: "Phone", Type: "string"},
+		{Key: "fbc", Label: "Click ID (fbc)", Type: "string"},
+		{Key: "fbp", Label: "Browser ID (fbp)", Type: "string"},
+		{Key: "value", Label: "Value", Type: "number"},
+		{Key: "currency", Label: "Currency", Type: "string"},
+		{Key: "testEventCode", Label: "Test Event Code", Type: "string"},
+	},
+}
+
+func metaUploadConversion(ctx context.Context, fields map[string]interface{}, l models.Location) (payload map[string]map[string]interface{}) {
+	cli, setting, err := svc_meta.CliForLocation(l.Id, l.ProfileID)
+	if err != nil {
+		return errorPayload(err, "unable to create meta client")
+	}
+	if setting.PixelID == "" {
+		return errorPayload(errors.New("no pixel set for the location"), "pixel is required")
+	}
+
+	event := meta.ConversionEvent{
+		EventName: fieldString(fields, "eventName"),
+		EventID:   fieldString(fields, "eventId"),
+		Email:     fieldString(fields, "email"),
+		Phone:     fieldString(fields, "phone"),
+		Fbc:       fieldString(fields, "fbc"),
+		Fbp:       fieldString(fields, "fbp"),
+		Currency:  fieldString(fields, "currency"),
+		EventTime: time.Now(),
+	}
+	if event.EventName == "" {
+		return errorPayload(errors.New("eventName is empty"), "eventName is required")
+	}
+
+	rawEventTime := fieldString(fields, "eventTime")
+	if rawEventTime != "" {
+		event.EventTime, err = parseOccurredAt(rawEventTime)
+		if err != nil {
+			event.EventTime, err = parseTime(rawEventTime)
+		}
+		if err != nil {
+			return errorPayload(err, "invalid event time format")
+		}
+	}
+
+	if raw := fields["value"]; raw != nil && fmt.Sprint(raw) != "" {
+		value, ok := toFloat(raw)
+		if !ok {
+			return errorPayload(fmt.Errorf("value %v is not a number", raw), "invalid value format")
+		}
+		event.Value = value
+	}
+
+	// the same conversion sent twice gets the same id, so Meta counts it
+	// once. Without an event time there is nothing stable to derive it from,
+	// the event then goes without one.
+	if event.EventID == "" && rawEventTime != "" {
+		event.EventID = conversionEventID(event)
+	}
+
+	res, err := cli.SendConversions(setting.PixelID, []meta.ConversionEvent{event}, fieldString(fields, "testEventCode"))
+	if err != nil {
+		return errorPayload(err, "unable to upload conversion")
+	}
+
+	return successPayload(map[string]interface{}{
+		"eventId":        event.EventID,
+		"eventsReceived": res.EventsReceived,
+		"fbtraceId":      res.FbtraceID,
+	})
+}
+
+func conversionEventID(event meta.ConversionEvent) string {
+	key := fmt.Sprintf("%s|%s|%s|%d", event.EventName, meta.HashEmail(event.Email), meta.HashPhone(event.Phone), event.EventTime.Unix())
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
@@ -3,11 +3,14 @@ package automator
 import (
 	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/svc_noshows"
 	"client-runaway-zenoti/internal/services/svc_zenoti"
+	"client-runaway-zenoti/internal/types"
 	zenotiv1 "client-runaway-zenoti/packages/zenotiV1"
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 )
@@ -23,6 +26,7 @@ var (
 			zenotiTriggerGuestCreated,
 			zenotiTriggerAppointmentCreated,
 			zenotiTriggerAppointmentGroupStatus,
+			zenotiTriggerAppointmentNoShow,
 			zenotiTriggerInvoiceClosed,
 			zenotiCollectionAppointments,
 			zenotiCollectionCollectionsNew,
@@ -31,6 +35,7 @@ var (
 			zenotiActionMergeAppointment,
 			zenotiActionMergeSales,
 			zenotiActionGetInvoice,
+			zenotiActionBookAppointment,
 			zenotiActionGetGuest,
 			zenotiActionFindGuest,
 			zenotiActionCreateGuest,
@@ -191,6 +196,20 @@ var (
 		{Key: "email", Label: "Email", Type: "string"},
 	}
 
+	zenotiAppointmentNoShowNodeFields = []NodeField{
+		{Key: "invoiceId", Label: "Invoice ID", Type: "string"},
+		{Key: "appointmentGroupId", Label: "Appointment Group ID", Type: "string"},
+		{Key: "statusId", Label: "Status ID", Type: "number"},
+		{Key: "status", Label: "Status", Type: "string"},
+		{Key: "guestId", Label: "Guest ID", Type: "string"},
+		{Key: "firstName", Label: "First Name", Type: "string"},
+		{Key: "lastName", Label: "Last Name", Type: "string"},
+		{Key: "email", Label: "Email", Type: "string"},
+		{Key: "serviceNames", Label: "Service Names", Type: "string"},
+		{Key: "therapistNames", Label: "Therapist Names", Type: "string"},
+		{Key: "date", Label: "Appointment Date", Type: "string"},
+	}
+
 	zenotiGuestNodeFields = []NodeField{
 		{Key: "id", Label: "Guest ID", Type: "string"},
 		{Key: "centerId", Label: "Center ID", Type: "string"},
@@ -325,6 +344,74 @@ func ZenotiTriggerAppointmentGroupStatus(ctx context.Context, WebhookBodyBytes [
 	return nil
 }
 
+var zenotiTriggerAppointmentNoShow = Node{
+	Id:          "zenoti.appointment.noshow",
+	Title:       "Appointment No-Show",
+	Description: "Triggers when an appointment group turns into a no-show or is cancelled in Zenoti.",
+	Type:        NodeTypeTrigger,
+	Icon:        "ri:form",
+	Color:       ColorTrigger,
+	Ports: []NodePort{
+		{
+			Name:    "out",
+			Payload: zenotiAppointmentNoShowNodeFields,
+		},
+	},
+}
+
+// ZenotiTriggerAppointmentNoShow records the status of every appointment
+// group status webhook and starts the automations only when the group turns
+// into a no-show or cancellation. The recorded status, on the synced
+// appointments or on a ZenotiInvoiceStatus for an invoice not synced yet,
+// keeps repeated webhooks from firing it twice. Cancellations made by the
+// calendar sync to move a booking don't fire it.
+func ZenotiTriggerAppointmentNoShow(ctx context.Context, WebhookBodyBytes []byte) error {
+	type WebhookBody struct {
+		Data zenotiv1.AppointmentGroupStatusWebhookData `json:"data"`
+	}
+
+	var webhookBody WebhookBody
+	if err := json.Unmarshal(WebhookBodyBytes, &webhookBody); err != nil {
+		return err
+	}
+
+	appts, turned, err := svc_noshows.RecordStatus(webhookBody.Data.Invoice_id, types.ZenotiStatus(webhookBody.Data.Appointment_Group_Status))
+	if err != nil || !turned {
+		return err
+	}
+
+	locs := []models.Location{}
+	if len(appts) > 0 {
+		err = db.DB.Where("id = ?", appts[0].LocationId).Find(&locs).Error
+	} else {
+		var centerId string
+		centerId, err = svc_zenoti.GetCenterIdByAppointmentGroupId(webhookBody.Data.Appointment_Group_Id)
+		if err != nil {
+			return err
+		}
+		err = db.DB.Where("zenoti_center_id = ?", centerId).Find(&locs).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, loc := range locs {
+		res := mapZenotiAppointmentNoShowToNodePayload(webhookBody.Data, appts)
+		triggerInput := TriggerInput{
+			LocationID:  loc.Id,
+			TriggerType: "zenoti.appointment.noshow",
+			Port:        "out",
+			Payload:     res,
+		}
+		err = StartAutomationsForTrigger(ctx, triggerInput)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 var zenotiTriggerInvoiceClosed = Node{
 	Id:          "zenoti.invoice.closed",
 	Title:       "Invoice Closed",
@@ -793,6 +880,91 @@ func zenotiActionGetInvoiceById(ctx context.Context, fields map[string]interface
 	return successPayload(mapZenotiInvoiceToNodePayload(invoice))
 }
 
+// Appointments
+
+var zenotiActionBookAppointment = Node{
+	Id:          "zenoti.appointment.book",
+	Title:       "Book Appointment",
+	Description: "Books and confirms an appointment for a guest in Zenoti. The start time is in the center's local time; the service defaults to the location's Zenoti service.",
+	ExecFunc:    zenotiActionBookAppointmentFunc,
+	Type:        NodeTypeAction,
+	Icon:        "ri:calendar-check-line",
+	Color:       ColorAction,
+	Ports: []NodePort{
+		successPort([]NodeField{
+			{Key: "bookingId", Type: "string"},
+			{Key: "invoiceId", Type: "string"},
+			{Key: "guestId", Type: "string"},
+			{Key: "startTime", Type: "datetime"},
+		}),
+		errorPort,
+	},
+	Fields: []NodeField{
+		{Key: "guestId", Label: "Guest ID", Type: "string", Required: true},
+		{Key: "startTime", Label: "Start Time", Type: "datetime", Required: true},
+		{Key: "serviceId", Label: "Service ID", Type: "string"},
+		{Key: "therapistId", Label: "Therapist ID", Type: "string"},
+	},
+}
+
+func zenotiActionBookAppointmentFunc(ctx context.Context, fields map[string]interface{}, l models.Location) map[string]map[string]interface{} {
+	guestId, _ := fields["guestId"].(string)
+	if guestId == "" {
+		return errorPayload(nil, "guestId is required")
+	}
+
+	startStr, _ := fields["startTime"].(string)
+	start, err := parseTime(startStr)
+	if err != nil {
+		return errorPayload(err, "startTime is required and must be a valid date time")
+	}
+
+	serviceId, _ := fields["serviceId"].(string)
+	if serviceId == "" {
+		serviceId = l.ZenotiServiceId
+	}
+	if serviceId == "" {
+		return errorPayload(nil, "serviceId is required when the location has no default Zenoti service")
+	}
+	therapistId, _ := fields["therapistId"].(string)
+
+	zenotiCli, err := zenotiv1.NewClient(l.Id, l.ZenotiCenterId, l.ZenotiApiObj.ApiKey)
+	if err != nil {
+		return errorPayload(err, "failed to create zenoti client")
+	}
+
+	booking, reservation, err := zenotiCli.BookWithConfirmDetails(zenotiv1.BookingReq{
+		Date:     zenotiv1.ZenotiDate{Time: start},
+		CenterId: l.ZenotiCenterId,
+		Guests: []zenotiv1.BookingReqGuest{
+			{
+				Id: guestId,
+				Items: []zenotiv1.BookingReqGuestsItems{
+					{
+						Item:      zenotiv1.BookingReqItem{Id: serviceId},
+						Therapist: zenotiv1.BookingReqTherapist{Id: therapistId},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return errorPayload(err, "failed to book appointment")
+	}
+
+	invoiceId := reservation.Invoice.Invoice_id
+	if invoiceId == "" && len(reservation.Invoices) > 0 {
+		invoiceId = reservation.Invoices[0].Invoice_id
+	}
+
+	return successPayload(map[string]interface{}{
+		"bookingId": booking.Id,
+		"invoiceId": invoiceId,
+		"guestId":   guestId,
+		"startTime": start.Format("2006-01-02T15:04:05"),
+	})
+}
+
 // Sales
 var (
 	zenotiCollectionSales = Node{
@@ -1271,6 +1443,32 @@ func mapZenotiAppointmentGroupStatusToNodePayload(apptGroup zenotiv1.Appointment
 	return res
 }
 
+func mapZenotiAppointmentNoShowToNodePayload(apptGroup zenotiv1.AppointmentGroupStatusWebhookData, appts []models.ZenotiAppointment) map[string]interface{} {
+	res := mapZenotiAppointmentGroupStatusToNodePayload(apptGroup)
+
+	services := []string{}
+	therapists := []string{}
+	var date time.Time
+	for _, a := range appts {
+		if a.ServiceName != "" && !slices.Contains(services, a.ServiceName) {
+			services = append(services, a.ServiceName)
+		}
+		if a.TherapistName != "" && !slices.Contains(therapists, a.TherapistName) {
+			therapists = append(therapists, a.TherapistName)
+		}
+		if date.IsZero() || a.StartTime.Before(date) {
+			date = a.StartTime
+		}
+	}
+	res["serviceNames"] = strings.Join(services, ", ")
+	res["therapistNames"] = strings.Join(therapists, ", ")
+	res["date"] = ""
+	if !date.IsZero() {
+		res["date"] = date.Format("2006-01-02")
+	}
+	return res
+}
+
 func zenotiStatusToString(status zenotiv1.ZenotiStatus) string {
 	switch status {
 	case zenotiv1.NoShowed:
@@ -36,6 +36,8 @@ type (
 		Color         string
 		Fields        []NodeField
 		Ports         []NodePort
+		// PortsFunc builds the ports of nodes whose payload is defined in their config
+		PortsFunc func(map[string]interface{}) ([]NodePort, error) `json:"-"`
 	}
 
 	NodePort struct {
@@ -61,21 +63,3 @@ const (
 	NodeTypeAction     NodeType = "action"
 	NodeTypeCollection NodeType = "collection"
 )
-
-var (
-	attributionPersonNodeFields = []NodeField{
-		{Key: "personId", Type: "string"},
-		{Key: "first_name", Type: "string"},
-		{Key: "last_name", Type: "string"},
-		{Key: "email", Type: "string"},
-		{Key: "phone", Type: "string"},
-	}
-
-	attributionStageHitNodeFields = []NodeField{
-		{Key: "personId", Type: "string"},
-		{Key: "first_name", Type: "string"},
-		{Key: "last_name", Type: "string"},
-		{Key: "email", Type: "string"},
-		{Key: "phone", Type: "string"},
-	}
-)
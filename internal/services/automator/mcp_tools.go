@@ -0,0 +1,220 @@
+package automator
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	McpToolTriggerType   = "mcp.tool"
+	automationReturnType = "automation.return"
+
+	// McpToolTimeout bounds a synchronous MCP tool run
+	McpToolTimeout = 60 * time.Second
+)
+
+// McpToolAutomation is an active automation published as an MCP tool through
+// its mcp.tool trigger
+type McpToolAutomation struct {
+	Automation  models.Automation
+	Name        string
+	Description string
+	Inputs      []NodeField
+
+	entry models.APINode
+}
+
+// McpToolRunResult is the outcome of a tool run. Result is the payload of the
+// automation.return node the run ended on, nil if it never reached one.
+type McpToolRunResult struct {
+	RunID  string                     `json:"runId"`
+	Status models.AutomationRunStatus `json:"status"`
+	Result map[string]interface{}     `json:"result"`
+	Error  string                     `json:"error,omitempty"`
+}
+
+// ListMcpToolAutomations returns the active automations with an mcp.tool
+// trigger. A nil locationIds lists them for all locations.
+func ListMcpToolAutomations(locationIds []string) ([]McpToolAutomation, error) {
+	query := db.DB.
+		Preload("Nodes").
+		Preload("Edges").
+		Where("state = ?", models.StateActive).
+		Where("id IN (?)", db.DB.Model(&models.Node{}).Select("automation_id").Where("type = ?", McpToolTriggerType))
+	if locationIds != nil {
+		query = query.Where("location_id IN ?", locationIds)
+	}
+
+	var automations []models.Automation
+	if err := query.Find(&automations).Error; err != nil {
+		return nil, fmt.Errorf("automator: find mcp tool automations: %w", err)
+	}
+
+	res := make([]McpToolAutomation, 0, len(automations))
+	for _, a := range automations {
+		if tool, ok := mcpToolFromAutomation(a); ok {
+			res = append(res, tool)
+		}
+	}
+	return res, nil
+}
+
+// GetMcpToolAutomation loads an active mcp.tool automation ready to be run
+func GetMcpToolAutomation(automationId string) (McpToolAutomation, error) {
+	var automation models.Automation
+	err := db.DB.
+		Preload("Nodes").
+		Preload("Edges").
+		Preload("Location").
+		Preload("Location.ZenotiApiObj").
+		Preload("Location.CerboApiObj").
+		Where("id = ? AND state = ?", automationId, models.StateActive).
+		First(&automation).Error
+	if err != nil {
+		return McpToolAutomation{}, fmt.Errorf("automation not found: %w", err)
+	}
+
+	tool, ok := mcpToolFromAutomation(automation)
+	if !ok {
+		return McpToolAutomation{}, errors.New("automation has no mcp.tool trigger")
+	}
+	return tool, nil
+}
+
+// mcpToolFromAutomation reads the tool declaration from the first mcp.tool
+// entry node. Name and description fall back to the automation's own.
+func mcpToolFromAutomation(a models.Automation) (McpToolAutomation, bool) {
+	for _, entryId := range a.Graph.Entry {
+		for _, node := range a.Graph.Nodes {
+			if node.ID != entryId || node.Type != McpToolTriggerType {
+				continue
+			}
+
+			config := node.Config.EdgeConfig("")
+			tool := McpToolAutomation{
+				Automation:  a,
+				Name:        strings.TrimSpace(configString(config["name"])),
+				Description: strings.TrimSpace(configString(config["description"])),
+				Inputs:      parseMcpToolInputs(configString(config["inputs"]), configString(config["requiredInputs"])),
+				entry:       node,
+			}
+			if tool.Name == "" {
+				tool.Name = a.Name
+			}
+			if tool.Description == "" {
+				tool.Description = a.Description
+			}
+			return tool, true
+		}
+	}
+	return McpToolAutomation{}, false
+}
+
+// parseMcpToolInputs parses "email, amount:number, vip:boolean" into fields.
+// Types default to string.
+func parseMcpToolInputs(inputs, required string) []NodeField {
+	requiredKeys := map[string]bool{}
+	for _, key := range strings.Split(required, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			requiredKeys[key] = true
+		}
+	}
+
+	fields := []NodeField{}
+	for _, input := range strings.Split(inputs, ",") {
+		key, typ, _ := strings.Cut(strings.TrimSpace(input), ":")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		typ = strings.ToLower(strings.TrimSpace(typ))
+		if typ == "" {
+			typ = "string"
+		}
+		fields = append(fields, NodeField{Key: key, Type: typ, Required: requiredKeys[key]})
+	}
+	return fields
+}
+
+// configString reads a node config value as text
+func configString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// Run executes the automation synchronously from its mcp.tool trigger with the
+// arguments as the trigger payload. It is recorded as a regular AutomationRun
+// and gives up after McpToolTimeout.
+func (t McpToolAutomation) Run(ctx context.Context, args map[string]interface{}) (McpToolRunResult, error) {
+	payload := make(map[string]interface{}, len(t.Inputs))
+	for _, f := range t.Inputs {
+		v, ok := args[f.Key]
+		if !ok || v == nil {
+			if f.Required {
+				return McpToolRunResult{}, fmt.Errorf("%s is required", f.Key)
+			}
+			continue
+		}
+		payload[f.Key] = v
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, McpToolTimeout)
+	defer cancel()
+
+	runtime := newAutomationRuntime(t.Automation)
+	runtime.runStatus = &models.AutomationRun{
+		ID:             uuid.New().String(),
+		AutomationID:   t.Automation.ID,
+		LocationID:     t.Automation.LocationId,
+		Status:         models.RunRunning,
+		TriggerType:    McpToolTriggerType,
+		TriggerPort:    defaultPortOut,
+		TriggerPayload: payload,
+		StartedAt:      time.Now(),
+		RunNodes:       []models.AutomationRunNode{},
+	}
+	db.DB.Save(&runtime.runStatus)
+
+	payloads := map[string]map[string]interface{}{
+		defaultPortOut: clonePayload(payload),
+	}
+	runErr := runtime.startFromEntry(ctx, t.entry, payloads)
+
+	finishedAt := time.Now()
+	runtime.runStatus.CompletedAt = &finishedAt
+	switch {
+	case errors.Is(runErr, context.DeadlineExceeded):
+		runtime.runStatus.Status = models.RunFailed
+		runtime.runStatus.ErrorMessage = fmt.Sprintf("timed out after %s", McpToolTimeout)
+	case runErr != nil:
+		runtime.runStatus.Status = models.RunWithErrors
+		runtime.runStatus.ErrorMessage = runErr.Error()
+	default:
+		runtime.runStatus.Status = models.RunSuccess
+	}
+	db.DB.Save(&runtime.runStatus)
+
+	res := McpToolRunResult{
+		RunID:  runtime.runStatus.ID,
+		Status: runtime.runStatus.Status,
+		Error:  runtime.runStatus.ErrorMessage,
+	}
+	for _, runNode := range runtime.runStatus.RunNodes {
+		if runNode.NodeType == automationReturnType {
+			res.Result = runNode.OutputPayloads[defaultPortSuccess]
+		}
+	}
+	return res, nil
+}
@@ -12,6 +12,7 @@ import (
 
 	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/svc_openai"
 
 	"github.com/google/uuid"
 )
@@ -414,6 +415,11 @@ func (rt *automationRuntime) entryNodesForTypeWithFilter(input TriggerInput) []m
 }
 
 func (rt *automationRuntime) startFromEntry(ctx context.Context, entry models.APINode, payload map[string]map[string]interface{}) error {
+	// AI usage of the nodes is attributed to this run
+	ctx = svc_openai.WithUsageSource(ctx, svc_openai.UsageSource{
+		LocationID:      rt.automation.LocationId,
+		AutomationRunID: rt.runStatus.ID,
+	})
 
 	entryWrapper := &queuedNode{node: entry}
 	queue := []*queuedNode{}
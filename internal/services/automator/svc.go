@@ -3,6 +3,8 @@ package automator
 import (
 	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/auth"
+	"client-runaway-zenoti/internal/services/svc_attribution"
 	"client-runaway-zenoti/internal/services/svc_cerbo"
 	"client-runaway-zenoti/internal/services/svc_googleads"
 	"client-runaway-zenoti/internal/services/svc_openai"
@@ -37,6 +39,8 @@ type (
 		preloadNodeRuns bool
 		nodesCountFrom  *int
 		nodesCountTo    *int
+		profileID       uint
+		locationIds     []string // nil means all locations of the profile
 	}
 )
 
@@ -70,13 +74,8 @@ func GetAutomationRuns(c *gin.Context) {
 func getAutomationRuns(filter automationRunFilter) ([]models.AutomationRun, int64, error) {
 	runs := []models.AutomationRun{}
 
-	query := db.DB.Model(&models.AutomationRun{})
-	if filter.automationId != "" {
-		query = query.Where("automation_id = ?", filter.automationId)
-	}
-
-	query = applyRunFilters(query, filter).
-		Order("created_at desc").
+	query := applyRunFilters(db.DB.Model(&models.AutomationRun{}), filter).
+		Order("automation_runs.created_at desc").
 		Limit(filter.limit).
 		Offset(filter.offset)
 
@@ -116,12 +115,7 @@ func getAutomationRuns(filter automationRunFilter) ([]models.AutomationRun, int6
 	}
 
 	var total int64
-	countQuery := db.DB.Model(&models.AutomationRun{})
-	if filter.automationId != "" {
-		countQuery = countQuery.Where("automation_id = ?", filter.automationId)
-	}
-	countQuery = applyRunFilters(countQuery, filter)
-	err = countQuery.Count(&total).Error
+	err = applyRunFilters(db.DB.Model(&models.AutomationRun{}), filter).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
@@ -177,6 +171,8 @@ func getAutomationRunFilterFromContext(c *gin.Context) automationRunFilter {
 		offset:         offset,
 		nodesCountFrom: nodesCountFrom,
 		nodesCountTo:   nodesCountTo,
+		profileID:      c.MustGet("user").(models.User).ProfileID,
+		locationIds:    auth.AllowedLocationIds(c),
 	}
 }
 
@@ -518,26 +514,35 @@ func parseQueryTime(c *gin.Context, key string) (*time.Time, error) {
 	return &parsed, nil
 }
 
+// applyRunFilters scopes runs to the profile and allowed locations through
+// their automation, then applies the request filters
 func applyRunFilters(tx *gorm.DB, filter automationRunFilter) *gorm.DB {
+	tx = tx.Joins("JOIN automations ON automations.id = automation_runs.automation_id").
+		Joins("JOIN locations ON locations.id = automations.location_id").
+		Where("locations.profile_id = ?", filter.profileID)
+	if filter.locationIds != nil {
+		tx = tx.Where("automations.location_id IN ?", filter.locationIds)
+	}
+
 	if filter.batchRunID != "" {
-		tx = tx.Where("batch_run_id = ?", filter.batchRunID)
+		tx = tx.Where("automation_runs.batch_run_id = ?", filter.batchRunID)
 	} else {
-		tx = tx.Where("batch_run_id IS NULL")
+		tx = tx.Where("automation_runs.batch_run_id IS NULL")
 	}
 	if filter.status != "" && filter.status != "all" {
-		tx = tx.Where("status = ?", filter.status)
+		tx = tx.Where("automation_runs.status = ?", filter.status)
 	}
 	if filter.startedAfter != nil {
-		tx = tx.Where("started_at >= ?", *filter.startedAfter)
+		tx = tx.Where("automation_runs.started_at >= ?", *filter.startedAfter)
 	}
 	if filter.startedBefore != nil {
-		tx = tx.Where("started_at <= ?", *filter.startedBefore)
+		tx = tx.Where("automation_runs.started_at <= ?", *filter.startedBefore)
 	}
 	if filter.searchQuery != "" {
-		tx = tx.Where("trigger_payload::text ILIKE ?", "%"+filter.searchQuery+"%")
+		tx = tx.Where("automation_runs.trigger_payload::text ILIKE ?", "%"+filter.searchQuery+"%")
 	}
 	if filter.automationId != "" {
-		tx = tx.Where("automation_id = ?", filter.automationId)
+		tx = tx.Where("automation_runs.automation_id = ?", filter.automationId)
 	}
 	if filter.nodesCountFrom != nil {
 		tx = tx.Where("(SELECT COUNT(*) FROM automation_run_nodes WHERE automation_run_nodes.run_id = automation_runs.id) >= ?", *filter.nodesCountFrom)
@@ -651,6 +656,12 @@ func GetLists(c *gin.Context) {
 
 		c.Data(lvn.Res(200, types, "OK"))
 		return
+	case "attributionFlows":
+		list, err := svc_attribution.GetFlowsList(location)
+		lvn.GinErr(c, 500, err, "failed to list attribution flows")
+
+		c.Data(lvn.Res(200, list, "OK"))
+		return
 	}
 
 	lvn.GinErr(c, 400, fmt.Errorf("unknown list name %q", listName), "unknown list name")
@@ -675,6 +686,8 @@ func GetCatalogListData(listName string, location models.Location) (interface{},
 		return svc_cerbo.GetEncounterTypesList(location)
 	case "cerboFreeTextTypes":
 		return svc_cerbo.ListFreeTextNoteTypes(location)
+	case "attributionFlows":
+		return svc_attribution.GetFlowsList(location)
 	default:
 		return nil, fmt.Errorf("unknown list name: %s", listName)
 	}
@@ -0,0 +1,115 @@
+package automator
+
+import (
+	"client-runaway-zenoti/internal/db/models"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// actionToolNodeIds are the catalog action nodes that can be published as
+// customer MCP tools. Nodes that only make sense inside a graph (merges,
+// conditions, delays) are left out.
+var actionToolNodeIds = []string{
+	"ghl.contact.find",
+	"ghl.contact.create",
+	"ghl.contact.update",
+	"ghl.opportunity.find",
+	"ghl.opportunity.create",
+	"ghl.opportunity.update",
+	"cerbo.patient.find",
+	"cerbo.encounter.create",
+	"zenoti.guest.find",
+	"zenoti.guest.get",
+	"zenoti.guest.create",
+	"zenoti.invoice.get",
+	"zenoti.appointment.book",
+}
+
+// ActionTool is a catalog action node exposed as a tool
+type ActionTool struct {
+	Name string
+	Node Node
+}
+
+// ActionToolResult is the outcome of running an action tool: the port the
+// node exited through and that port's payload
+type ActionToolResult struct {
+	Port    string                 `json:"port"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// ActionToolName turns a catalog node id into a tool name,
+// e.g. ghl.contact.find -> ghl_contact_find
+func ActionToolName(nodeId string) string {
+	return strings.ReplaceAll(nodeId, ".", "_")
+}
+
+// ActionTools returns the implemented action nodes that can be exposed as tools
+func ActionTools() []ActionTool {
+	res := make([]ActionTool, 0, len(actionToolNodeIds))
+	for _, id := range actionToolNodeIds {
+		node, ok := getCatalogNode(id)
+		if !ok || node.Type != NodeTypeAction || node.ExecFunc == nil {
+			continue
+		}
+		res = append(res, ActionTool{Name: ActionToolName(id), Node: node})
+	}
+	return res
+}
+
+// GetActionTool finds an action tool by name
+func GetActionTool(name string) (ActionTool, bool) {
+	for _, t := range ActionTools() {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return ActionTool{}, false
+}
+
+// Run executes the tool's node for the location. Only keys declared in the
+// node's fields are passed on. Exiting through the error port is returned as
+// an error.
+func (t ActionTool) Run(ctx context.Context, args map[string]interface{}, location models.Location) (ActionToolResult, error) {
+	fields := make(map[string]interface{}, len(t.Node.Fields))
+	for _, f := range t.Node.Fields {
+		v, ok := args[f.Key]
+		if !ok || v == nil {
+			if f.Required {
+				return ActionToolResult{}, fmt.Errorf("%s is required", f.Key)
+			}
+			continue
+		}
+		fields[f.Key] = v
+	}
+
+	result := t.Node.ExecFunc(ctx, fields, location)
+	if payload, ok := result[errorPort.Name]; ok {
+		msg, _ := payload["message"].(string)
+		if detail, _ := payload["error"].(string); detail != "" {
+			msg += ": " + detail
+		}
+		return ActionToolResult{}, fmt.Errorf("%s", msg)
+	}
+
+	// a node can exit through several ports; take the first in declaration
+	// order, then undeclared ones by name, so the result doesn't depend on
+	// map iteration
+	for _, port := range t.Node.Ports {
+		if payload, ok := result[port.Name]; ok {
+			return ActionToolResult{Port: port.Name, Payload: payload}, nil
+		}
+	}
+	ports := make([]string, 0, len(result))
+	for port := range result {
+		ports = append(ports, port)
+	}
+	if len(ports) > 0 {
+		sort.Strings(ports)
+		return ActionToolResult{Port: ports[0], Payload: result[ports[0]]}, nil
+	}
+
+	return ActionToolResult{}, fmt.Errorf("%s returned no result", t.Node.Id)
+}
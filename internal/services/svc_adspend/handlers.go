@@ -0,0 +1,175 @@
+package svc_adspend
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/svc_audit"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type backfillPayload struct {
+	LocationId string `json:"locationId" binding:"required"`
+	Start      string `json:"start" binding:"required"` // YYYY-MM-DD
+	End        string `json:"end" binding:"required"`   // YYYY-MM-DD
+	Network    string `json:"network"`                  // both when empty
+}
+
+// backfilling holds the locations with a backfill running
+var backfilling sync.Map
+
+// Backfill re-ingests a location's spend between two dates in the
+// background, e.g. after connecting an account with a long history. The
+// range is capped and a location runs one backfill at a time.
+func Backfill(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	payload := backfillPayload{}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		lvn.GinErr(c, 400, err, "Unable to bind JSON")
+		return
+	}
+
+	start, err := time.Parse("2006-01-02", payload.Start)
+	lvn.GinErr(c, 400, err, "Invalid start date")
+	if err != nil {
+		return
+	}
+	end, err := time.Parse("2006-01-02", payload.End)
+	lvn.GinErr(c, 400, err, "Invalid end date")
+	if err != nil {
+		return
+	}
+	if end.Before(start) {
+		c.Data(lvn.Res(400, nil, "End date is before start date"))
+		return
+	}
+	if end.Sub(start) > maxBackfillDays*24*time.Hour {
+		c.Data(lvn.Res(400, nil, fmt.Sprintf("A backfill covers at most %d days", maxBackfillDays)))
+		return
+	}
+
+	networks := []string{models.AdNetworkGoogle, models.AdNetworkMeta}
+	switch payload.Network {
+	case "":
+	case models.AdNetworkGoogle, models.AdNetworkMeta:
+		networks = []string{payload.Network}
+	default:
+		c.Data(lvn.Res(400, nil, fmt.Sprintf("network must be %s or %s", models.AdNetworkGoogle, models.AdNetworkMeta)))
+		return
+	}
+
+	loc := models.Location{}
+	err = db.DB.Where("id = ? AND profile_id = ?", payload.LocationId, user.ProfileID).First(&loc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Data(lvn.Res(404, nil, "Location not found"))
+		return
+	}
+	if err != nil {
+		lvn.GinErr(c, 500, err, "Unable to get location")
+		return
+	}
+
+	if _, running := backfilling.LoadOrStore(loc.Id, true); running {
+		c.Data(lvn.Res(409, nil, "A backfill of this location is already running"))
+		return
+	}
+
+	go func() {
+		defer backfilling.Delete(loc.Id)
+		for _, network := range networks {
+			accountID, err := accountOf(loc, network)
+			if errors.Is(err, errNoAccount) {
+				continue
+			}
+			if err == nil {
+				err = Ingest(loc, network, start, end)
+			}
+			if err != nil {
+				log.Printf("adspend: backfill %s spend of %s: %s", network, loc.Id, err.Error())
+				continue
+			}
+			extendSyncedFrom(loc.ProfileID, network, accountID, dayOf(start))
+		}
+	}()
+	svc_audit.Record(c, models.AuditUpdate, "ad_spend_backfill", loc.Id, loc.Id, nil, payload)
+
+	c.Data(lvn.Res(200, "Backfill started", ""))
+}
+
+// extendSyncedFrom records that the history of the account's locations now
+// starts earlier
+func extendSyncedFrom(profileId uint, network, accountId string, from time.Time) {
+	db.DB.Model(&models.AdSpendSync{}).
+		Where("profile_id = ? AND network = ? AND account_id = ? AND synced_from > ?", profileId, network, accountId, from).
+		Update("synced_from", from)
+}
+
+// GetAdSpend returns the daily spend of a location between ?start and ?end,
+// grouped by ?groupBy: campaign (default), adSet or ad
+func GetAdSpend(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	locationId := c.Query("locationId")
+	if locationId == "" {
+		c.Data(lvn.Res(400, nil, "locationId is required"))
+		return
+	}
+
+	start, err := time.Parse("2006-01-02", c.Query("start"))
+	lvn.GinErr(c, 400, err, "Invalid start date")
+	if err != nil {
+		return
+	}
+	end, err := time.Parse("2006-01-02", c.Query("end"))
+	lvn.GinErr(c, 400, err, "Invalid end date")
+	if err != nil {
+		return
+	}
+
+	var columns string
+	switch c.DefaultQuery("groupBy", "campaign") {
+	case "campaign":
+		columns = "network, account_id, date, currency, campaign_id, campaign_name"
+	case "adSet":
+		columns = "network, account_id, date, currency, campaign_id, campaign_name, ad_set_id, ad_set_name"
+	case "ad":
+		columns = "network, account_id, date, currency, campaign_id, campaign_name, ad_set_id, ad_set_name, ad_id, ad_name"
+	default:
+		c.Data(lvn.Res(400, nil, "groupBy must be campaign, adSet or ad"))
+		return
+	}
+
+	res := []struct {
+		Network      string    `json:"network"`
+		AccountId    string    `json:"accountId"`
+		Date         time.Time `json:"date"`
+		Currency     string    `json:"currency"`
+		CampaignId   string    `json:"campaignId"`
+		CampaignName string    `json:"campaignName"`
+		AdSetId      string    `json:"adSetId,omitempty"`
+		AdSetName    string    `json:"adSetName,omitempty"`
+		AdId         string    `json:"adId,omitempty"`
+		AdName       string    `json:"adName,omitempty"`
+		Spend        float64   `json:"spend"`
+		Clicks       int64     `json:"clicks"`
+		Impressions  int64     `json:"impressions"`
+	}{}
+	err = db.DB.Model(&models.AdSpendDaily{}).
+		Select(columns+", sum(spend) as spend, sum(clicks) as clicks, sum(impressions) as impressions").
+		Where("profile_id = ? AND location_id = ? AND date BETWEEN ? AND ?", user.ProfileID, locationId, start, end).
+		Group(columns).
+		Order("date, network, campaign_name").
+		Scan(&res).Error
+	if err != nil {
+		lvn.GinErr(c, 500, err, "Unable to get ad spend")
+		return
+	}
+
+	c.Data(lvn.Res(200, res, ""))
+}
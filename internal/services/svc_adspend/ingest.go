@@ -0,0 +1,347 @@
+package svc_adspend
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/svc_googleads"
+	"client-runaway-zenoti/internal/services/svc_meta"
+	"client-runaway-zenoti/packages/meta"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// history pulled the first time a location's ad account is ingested
+	backfillDays = 90
+	// trailing days pulled again on every run, networks keep correcting
+	// spend for a few days after it happened
+	correctionDays = 7
+	// days fetched per request
+	chunkDays = 30
+	// longest range a backfill can re-ingest
+	maxBackfillDays = 2 * 365
+)
+
+var errNoAccount = errors.New("no ad account set for the location")
+
+// IngestAll pulls the daily spend of every location with a Google Ads or
+// Meta ad account, from where the last run stopped. An account shared by
+// several locations is fetched once and split between them.
+func IngestAll() {
+	var locations []models.Location
+	err := db.DB.Where("id IN (?) OR id IN (?)",
+		db.DB.Model(&models.GoogleAdsLocationSetting{}).Select("location_id"),
+		db.DB.Model(&models.MetaLocationSetting{}).Select("location_id"),
+	).Find(&locations).Error
+	if err != nil {
+		log.Printf("adspend: load locations: %s", err.Error())
+		return
+	}
+
+	synced := map[string]bool{}
+	for _, loc := range locations {
+		for _, network := range []string{models.AdNetworkGoogle, models.AdNetworkMeta} {
+			accountID, err := accountOf(loc, network)
+			if errors.Is(err, errNoAccount) {
+				continue
+			}
+			if err == nil {
+				key := fmt.Sprintf("%d/%s/%s", loc.ProfileID, network, accountID)
+				if synced[key] {
+					continue
+				}
+				synced[key] = true
+				err = syncAccount(loc, network, accountID)
+			}
+			if err != nil {
+				log.Printf("adspend: ingest %s spend of %s: %s", network, loc.Id, err.Error())
+			}
+		}
+	}
+}
+
+// syncAccount backfills an account the first time one of its locations is
+// seen, then pulls the days since the last run plus the correction window
+func syncAccount(loc models.Location, network, accountID string) error {
+	today := dayOf(time.Now())
+
+	locs, err := accountLocations(loc, network, accountID)
+	if err != nil {
+		return err
+	}
+
+	states := make([]models.AdSpendSync, len(locs))
+	current := true
+	var through time.Time
+	for i, l := range locs {
+		err := db.DB.Where("location_id = ? AND network = ?", l.Id, network).First(&states[i]).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if states[i].ID == 0 || states[i].AccountId != accountID || states[i].SyncedThrough.IsZero() {
+			current = false
+			continue
+		}
+		if through.IsZero() || states[i].SyncedThrough.Before(through) {
+			through = states[i].SyncedThrough
+		}
+	}
+
+	// a location joining the account changes the split, so the history is
+	// pulled again for all of them
+	from := today.AddDate(0, 0, -backfillDays)
+	if current {
+		from = through.AddDate(0, 0, -correctionDays)
+	}
+
+	err = ingestAccount(locs, network, accountID, from, today)
+
+	var saveErr error
+	for i, l := range locs {
+		state := &states[i]
+		state.LocationId, state.Network, state.ProfileId = l.Id, network, l.ProfileID
+		if state.AccountId != accountID || state.SyncedFrom.IsZero() || from.Before(state.SyncedFrom) {
+			state.SyncedFrom = from
+		}
+		state.AccountId = accountID
+		state.LastRunAt = time.Now()
+		state.LastError = ""
+		if err != nil {
+			state.LastError = err.Error()
+		} else {
+			state.SyncedThrough = today
+		}
+		saveErr = errors.Join(saveErr, db.DB.Save(state).Error)
+	}
+	return errors.Join(err, saveErr)
+}
+
+// Ingest replaces the spend on the network between the two dates, both
+// inclusive, with what the network reports now, for the location and the
+// other locations sharing its account
+func Ingest(loc models.Location, network string, from, to time.Time) error {
+	accountID, err := accountOf(loc, network)
+	if err != nil {
+		return err
+	}
+	locs, err := accountLocations(loc, network, accountID)
+	if err != nil {
+		return err
+	}
+	return ingestAccount(locs, network, accountID, from, to)
+}
+
+// ingestAccount fetches the account of the first location once and stores
+// an even share of it under every location
+func ingestAccount(locs []models.Location, network, accountID string, from, to time.Time) error {
+	locationIds := make([]string, len(locs))
+	for i, l := range locs {
+		locationIds[i] = l.Id
+	}
+
+	from, to = dayOf(from), dayOf(to)
+	for start := from; !start.After(to); start = start.AddDate(0, 0, chunkDays) {
+		end := start.AddDate(0, 0, chunkDays-1)
+		if end.After(to) {
+			end = to
+		}
+
+		var rows []models.AdSpendDaily
+		var err error
+		switch network {
+		case models.AdNetworkGoogle:
+			rows, err = fetchGoogle(locs[0], start, end)
+		case models.AdNetworkMeta:
+			rows, err = fetchMeta(locs[0], start, end)
+		default:
+			err = fmt.Errorf("unknown ad network %q", network)
+		}
+		if err != nil {
+			return fmt.Errorf("%s to %s: %w", start.Format("2006-01-02"), end.Format("2006-01-02"), err)
+		}
+
+		if err := replaceRange(locationIds, network, accountID, start, end, SplitShared(rows, locationIds)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceRange swaps the stored rows of the days for the fetched ones, so
+// corrected spend and removed ads don't linger. Replacements of an account
+// are serialized, so a backfill and a scheduled run overlapping on a day
+// don't both insert its rows.
+func replaceRange(locationIds []string, network, accountID string, from, to time.Time, rows []models.AdSpendDaily) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "adspend/"+network+"/"+accountID).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Unscoped().
+			Where("location_id IN ? AND network = ? AND date BETWEEN ? AND ?", locationIds, network, from, to).
+			Delete(&models.AdSpendDaily{}).Error
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&rows, 500).Error
+	})
+}
+
+// accountLocations returns the locations of loc's profile set to the same
+// account on the network, loc first
+func accountLocations(loc models.Location, network, accountID string) ([]models.Location, error) {
+	var ids []string
+	var err error
+	switch network {
+	case models.AdNetworkGoogle:
+		err = db.DB.Model(&models.GoogleAdsLocationSetting{}).
+			Where("profile_id = ? AND (client_customer_id = ? OR (client_customer_id = '' AND customer_id = ?))", loc.ProfileID, accountID, accountID).
+			Pluck("location_id", &ids).Error
+	case models.AdNetworkMeta:
+		err = db.DB.Model(&models.MetaLocationSetting{}).
+			Where("profile_id = ? AND ad_account_id = ?", loc.ProfileID, accountID).
+			Pluck("location_id", &ids).Error
+	default:
+		err = fmt.Errorf("unknown ad network %q", network)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var others []models.Location
+	err = db.DB.Where("profile_id = ? AND id IN ? AND id <> ?", loc.ProfileID, ids, loc.Id).Order("id").Find(&others).Error
+	if err != nil {
+		return nil, err
+	}
+	return append([]models.Location{loc}, others...), nil
+}
+
+func accountOf(loc models.Location, network string) (string, error) {
+	switch network {
+	case models.AdNetworkGoogle:
+		setting := models.GoogleAdsLocationSetting{}
+		err := db.DB.Where("location_id = ? AND profile_id = ?", loc.Id, loc.ProfileID).First(&setting).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", errNoAccount
+		}
+		if err != nil {
+			return "", err
+		}
+		if setting.ClientCustomerID != "" {
+			return setting.ClientCustomerID, nil
+		}
+		return setting.CustomerID, nil
+	case models.AdNetworkMeta:
+		setting := models.MetaLocationSetting{}
+		err := db.DB.Where("location_id = ? AND profile_id = ?", loc.Id, loc.ProfileID).First(&setting).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", errNoAccount
+		}
+		if err != nil {
+			return "", err
+		}
+		return setting.AdAccountID, nil
+	}
+	return "", fmt.Errorf("unknown ad network %q", network)
+}
+
+func fetchGoogle(loc models.Location, from, to time.Time) ([]models.AdSpendDaily, error) {
+	cli, err := svc_googleads.CliForLocation(loc.Id, loc.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	stats, err := cli.DailyAdStats(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]models.AdSpendDaily, 0, len(stats))
+	for _, s := range stats {
+		rows = append(rows, models.AdSpendDaily{
+			ProfileId:    loc.ProfileID,
+			LocationId:   loc.Id,
+			Network:      models.AdNetworkGoogle,
+			AccountId:    cli.CustomerInfo.CustomerID,
+			Date:         s.Date,
+			CampaignId:   s.CampaignID,
+			CampaignName: s.CampaignName,
+			AdSetId:      s.AdGroupID,
+			AdSetName:    s.AdGroupName,
+			AdId:         s.AdID,
+			AdName:       s.AdName,
+			Spend:        s.Spend,
+			Clicks:       s.Clicks,
+			Impressions:  s.Impressions,
+			Currency:     s.Currency,
+		})
+	}
+	return rows, nil
+}
+
+func fetchMeta(loc models.Location, from, to time.Time) ([]models.AdSpendDaily, error) {
+	cli, setting, err := svc_meta.CliForLocation(loc.Id, loc.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+
+	insights, err := cli.InsightsAll(meta.InsightsReq{
+		AdAccountID:   setting.AdAccountID,
+		From:          from,
+		To:            to,
+		TimeIncrement: 1,
+		Level:         "ad",
+		Fields: []string{
+			"date_start", "campaign_id", "campaign_name", "adset_id", "adset_name",
+			"ad_id", "ad_name", "spend", "clicks", "impressions", "account_currency",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]models.AdSpendDaily, 0, len(insights))
+	for _, in := range insights {
+		date, err := time.Parse("2006-01-02", in["date_start"])
+		if err != nil {
+			return nil, fmt.Errorf("parse date %q: %w", in["date_start"], err)
+		}
+		spend, _ := strconv.ParseFloat(in["spend"], 64)
+		clicks, _ := strconv.ParseInt(in["clicks"], 10, 64)
+		impressions, _ := strconv.ParseInt(in["impressions"], 10, 64)
+
+		rows = append(rows, models.AdSpendDaily{
+			ProfileId:    loc.ProfileID,
+			LocationId:   loc.Id,
+			Network:      models.AdNetworkMeta,
+			AccountId:    setting.AdAccountID,
+			Date:         date,
+			CampaignId:   in["campaign_id"],
+			CampaignName: in["campaign_name"],
+			AdSetId:      in["adset_id"],
+			AdSetName:    in["adset_name"],
+			AdId:         in["ad_id"],
+			AdName:       in["ad_name"],
+			Spend:        spend,
+			Clicks:       clicks,
+			Impressions:  impressions,
+			Currency:     in["account_currency"],
+		})
+	}
+	return rows, nil
+}
+
+// dayOf is the UTC midnight of the day, the dates are stored as
+func dayOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
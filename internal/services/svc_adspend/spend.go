@@ -0,0 +1,60 @@
+package svc_adspend
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"time"
+)
+
+// SpendByNetwork sums a location's ingested spend per network between the
+// two dates, both inclusive
+func SpendByNetwork(locationId string, from, to time.Time) (map[string]float64, error) {
+	res := []struct {
+		Network string
+		Spend   float64
+	}{}
+	err := db.DB.Model(&models.AdSpendDaily{}).
+		Select("network, sum(spend) as spend").
+		Where("location_id = ? AND date BETWEEN ? AND ?", locationId, dayOf(from), dayOf(to)).
+		Group("network").
+		Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	spends := map[string]float64{}
+	for _, r := range res {
+		spends[r.Network] = r.Spend
+	}
+	return spends, nil
+}
+
+// SplitShared divides the rows of an account shared by the locations into
+// even shares, one per location, the way manual expenses are split and the
+// one split reports rely on. Click
+// and impression remainders go to the first locations.
+func SplitShared(rows []models.AdSpendDaily, locationIds []string) []models.AdSpendDaily {
+	n := int64(len(locationIds))
+	if n == 0 {
+		return nil
+	}
+
+	res := make([]models.AdSpendDaily, 0, len(rows)*len(locationIds))
+	for _, r := range rows {
+		for i, id := range locationIds {
+			share := r
+			share.LocationId = id
+			share.Spend = r.Spend / float64(n)
+			share.Clicks = r.Clicks / n
+			if int64(i) < r.Clicks%n {
+				share.Clicks++
+			}
+			share.Impressions = r.Impressions / n
+			if int64(i) < r.Impressions%n {
+				share.Impressions++
+			}
+			res = append(res, share)
+		}
+	}
+	return res
+}
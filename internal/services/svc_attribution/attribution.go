@@ -1 +1,109 @@
 package svc_attribution
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HitInput is a person reaching a stage of an attribution flow
+type HitInput struct {
+	PersonId   uint
+	FlowId     uint
+	Stage      string
+	LocationId string
+	Revenue    float64
+	OccurredAt time.Time // now when zero
+
+	RefSystem string // e.g. "zenoti", "ghl"
+	RefId     string // e.g. zenoti invoice id
+	RefLink   string
+}
+
+// RecordStageHit saves a stage hit of a person. A hit with the same ref as
+// one already recorded for the stage isn't saved again, the existing one is
+// returned and recorded is false.
+func RecordStageHit(profileID uint, input HitInput) (hit models.StageHit, person models.Person, recorded bool, err error) {
+	input.Stage = strings.TrimSpace(input.Stage)
+	if input.Stage == "" {
+		return hit, person, false, errors.New("stage is required")
+	}
+	if input.Revenue < 0 {
+		return hit, person, false, errors.New("revenue must be non-negative")
+	}
+
+	var flow models.AttributionFlow
+	err = db.DB.Where("profile_id = ? AND id = ?", profileID, input.FlowId).First(&flow).Error
+	if err != nil {
+		return hit, person, false, fmt.Errorf("attribution flow %d not found", input.FlowId)
+	}
+	if !slices.Contains(flow.Stages, input.Stage) {
+		return hit, person, false, fmt.Errorf("stage %q is not in flow %q", input.Stage, flow.FlowName)
+	}
+
+	person, err = GetPerson(profileID, input.PersonId)
+	if err != nil {
+		return hit, person, false, fmt.Errorf("person %d not found", input.PersonId)
+	}
+
+	personID := strconv.FormatUint(uint64(person.ID), 10)
+	if input.RefId != "" {
+		err = db.DB.Where("profile_id = ? AND attribution_flow_id = ? AND stage = ? AND person_id = ? AND ref_system = ? AND ref_id = ?",
+			profileID, flow.ID, input.Stage, personID, input.RefSystem, input.RefId).
+			First(&hit).Error
+		if err == nil {
+			return hit, person, false, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return hit, person, false, err
+		}
+	}
+
+	if input.OccurredAt.IsZero() {
+		input.OccurredAt = time.Now()
+	}
+	locationID := input.LocationId
+	if locationID == "" {
+		locationID = person.LocationId
+	}
+
+	hit = models.StageHit{
+		ProfileId:         profileID,
+		LocationId:        locationID,
+		PersonId:          personID,
+		AttributionFlowId: flow.ID,
+		Stage:             input.Stage,
+		OccurredAt:        input.OccurredAt,
+		Revenue:           input.Revenue,
+		RefSystem:         input.RefSystem,
+		RefId:             input.RefId,
+		RefLink:           input.RefLink,
+	}
+	if err := db.DB.Create(&hit).Error; err != nil {
+		return hit, person, false, err
+	}
+	return hit, person, true, nil
+}
+
+// GetFlowsList returns the attribution flows of the location's profile,
+// name to id
+func GetFlowsList(location models.Location) (map[string]uint, error) {
+	var flows []models.AttributionFlow
+	err := db.DB.Where("profile_id = ?", location.ProfileID).Order("flow_name asc").Find(&flows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]uint, len(flows))
+	for _, flow := range flows {
+		res[flow.FlowName] = flow.ID
+	}
+	return res, nil
+}
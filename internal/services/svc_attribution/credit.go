@@ -0,0 +1,72 @@
+package svc_attribution
+
+import (
+	"math"
+	"time"
+)
+
+// Attribution models, how the revenue of a conversion is split among the
+// touchpoints that led to it
+const (
+	ModelFirstTouch    = "first_touch"
+	ModelLastTouch     = "last_touch"
+	ModelLinear        = "linear"
+	ModelTimeDecay     = "time_decay"
+	ModelPositionBased = "position_based"
+
+	// SourceDirect is the source of conversions without a tracked touchpoint
+	SourceDirect = "direct"
+
+	// timeDecayHalfLife halves the credit of a touchpoint for every week
+	// it happened before the conversion
+	timeDecayHalfLife = 7 * 24 * time.Hour
+)
+
+var AttributionModels = []string{ModelFirstTouch, ModelLastTouch, ModelLinear, ModelTimeDecay, ModelPositionBased}
+
+// CreditWeights returns the share of a conversion credited to each
+// touchpoint. Touchpoints are in time order and at or before the
+// conversion. The weights add up to 1.
+func CreditWeights(model string, touches []time.Time, conversionAt time.Time) []float64 {
+	n := len(touches)
+	weights := make([]float64, n)
+	if n == 0 {
+		return weights
+	}
+
+	switch model {
+	case ModelFirstTouch:
+		weights[0] = 1
+	case ModelLastTouch:
+		weights[n-1] = 1
+	case ModelTimeDecay:
+		var total float64
+		for i, at := range touches {
+			age := max(conversionAt.Sub(at), 0)
+			weights[i] = math.Pow(0.5, float64(age)/float64(timeDecayHalfLife))
+			total += weights[i]
+		}
+		for i := range weights {
+			weights[i] /= total
+		}
+	case ModelPositionBased:
+		// 40% to the first and the last touchpoint, 20% split among the
+		// ones in between
+		switch n {
+		case 1:
+			weights[0] = 1
+		case 2:
+			weights[0], weights[1] = 0.5, 0.5
+		default:
+			weights[0], weights[n-1] = 0.4, 0.4
+			for i := 1; i < n-1; i++ {
+				weights[i] = 0.2 / float64(n-2)
+			}
+		}
+	default: // linear
+		for i := range weights {
+			weights[i] = 1 / float64(n)
+		}
+	}
+	return weights
+}
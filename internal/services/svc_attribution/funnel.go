@@ -0,0 +1,214 @@
+package svc_attribution
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/auth"
+	"fmt"
+	"sort"
+	"time"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// FunnelResponse is the funnel of an attribution flow over a date range
+type FunnelResponse struct {
+	FlowId   uint           `json:"flowId"`
+	FlowName string         `json:"flowName"`
+	Start    string         `json:"start"`
+	End      string         `json:"end"`
+	Stages   []FunnelStage  `json:"stages"`
+	Sources  []FunnelSource `json:"sources"`
+}
+
+// FunnelStage is one stage of the funnel, in flow order
+type FunnelStage struct {
+	Stage   string  `json:"stage"`
+	People  int     `json:"people"`
+	Hits    int     `json:"hits"`
+	Revenue float64 `json:"revenue"`
+	// ConversionRate is the share of the previous stage's people that
+	// reached this stage, nil on the first stage
+	ConversionRate *float64 `json:"conversionRate"`
+	// AvgHoursInStage is the average time from reaching this stage to
+	// reaching the next, nil when nobody moved on
+	AvgHoursInStage *float64 `json:"avgHoursInStage"`
+}
+
+// FunnelSource is the revenue of the people of one lead source
+type FunnelSource struct {
+	Source  string  `json:"source"`
+	People  int     `json:"people"`
+	Revenue float64 `json:"revenue"`
+}
+
+type funnelHit struct {
+	PersonId   string
+	Stage      string
+	OccurredAt time.Time
+	Revenue    float64
+	Source     string
+}
+
+// GetFlowFunnel returns the funnel of a flow. Accepts ?start=YYYY-MM-DD&end=YYYY-MM-DD
+// (defaults to the last 30 days) and ?locationId=.
+func GetFlowFunnel(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var flow models.AttributionFlow
+	err := db.DB.Where("profile_id = ? AND id = ?", user.ProfileID, c.Param("flowId")).First(&flow).Error
+	if err != nil {
+		lvn.GinErr(c, 404, err, "Attribution flow not found")
+		return
+	}
+
+	start, end, err := funnelRange(c.Query("start"), c.Query("end"))
+	if err != nil {
+		c.Data(lvn.Res(400, nil, err.Error()))
+		return
+	}
+
+	query := db.DB.Table("stage_hits").
+		Select("stage_hits.person_id, stage_hits.stage, stage_hits.occurred_at, stage_hits.revenue, people.source").
+		Joins("LEFT JOIN people ON people.id::text = stage_hits.person_id").
+		Where("stage_hits.deleted_at IS NULL AND stage_hits.profile_id = ? AND stage_hits.attribution_flow_id = ?", user.ProfileID, flow.ID).
+		Where("stage_hits.occurred_at >= ? AND stage_hits.occurred_at < ?", start, end)
+	if locationID := c.Query("locationId"); locationID != "" {
+		query = query.Where("stage_hits.location_id = ?", locationID)
+	}
+	if allowed := auth.AllowedLocationIds(c); allowed != nil {
+		query = query.Where("stage_hits.location_id IN ?", allowed)
+	}
+
+	var hits []funnelHit
+	if err := query.Scan(&hits).Error; err != nil {
+		lvn.GinErr(c, 500, err, "Unable to load stage hits")
+		return
+	}
+
+	stages, sources := buildFunnel(flow.Stages, hits)
+	c.Data(lvn.Res(200, FunnelResponse{
+		FlowId:   flow.ID,
+		FlowName: flow.FlowName,
+		Start:    start.Format("2006-01-02"),
+		End:      end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Stages:   stages,
+		Sources:  sources,
+	}, ""))
+}
+
+// buildFunnel aggregates the hits per stage of the flow and per source.
+// Hits of stages no longer in the flow are left out.
+func buildFunnel(flowStages []string, hits []funnelHit) ([]FunnelStage, []FunnelSource) {
+	stageIndex := make(map[string]int, len(flowStages))
+	stages := make([]FunnelStage, len(flowStages))
+	for i, stage := range flowStages {
+		stageIndex[stage] = i
+		stages[i] = FunnelStage{Stage: stage}
+	}
+
+	// first time each person reached each stage
+	reached := map[string]map[int]time.Time{}
+	sourcesByName := map[string]*FunnelSource{}
+	sourcePeople := map[string]map[string]bool{}
+	for _, hit := range hits {
+		i, ok := stageIndex[hit.Stage]
+		if !ok {
+			continue
+		}
+		stages[i].Hits++
+		stages[i].Revenue += hit.Revenue
+
+		if reached[hit.PersonId] == nil {
+			reached[hit.PersonId] = map[int]time.Time{}
+		}
+		if first, ok := reached[hit.PersonId][i]; !ok || hit.OccurredAt.Before(first) {
+			reached[hit.PersonId][i] = hit.OccurredAt
+		}
+
+		source := hit.Source
+		if source == "" {
+			source = "unknown"
+		}
+		if sourcesByName[source] == nil {
+			sourcesByName[source] = &FunnelSource{Source: source}
+			sourcePeople[source] = map[string]bool{}
+		}
+		sourcesByName[source].Revenue += hit.Revenue
+		sourcePeople[source][hit.PersonId] = true
+	}
+
+	for i := range stages {
+		var moved int
+		var total time.Duration
+		var reachedBoth int
+		for _, times := range reached {
+			at, ok := times[i]
+			if !ok {
+				continue
+			}
+			stages[i].People++
+
+			if i+1 < len(stages) {
+				if next, ok := times[i+1]; ok && !next.Before(at) {
+					moved++
+					total += next.Sub(at)
+				}
+			}
+			if i > 0 {
+				if _, ok := times[i-1]; ok {
+					reachedBoth++
+				}
+			}
+		}
+
+		if moved > 0 {
+			hours := total.Hours() / float64(moved)
+			stages[i].AvgHoursInStage = &hours
+		}
+		if i > 0 && stages[i-1].People > 0 {
+			rate := float64(reachedBoth) / float64(stages[i-1].People)
+			stages[i].ConversionRate = &rate
+		}
+	}
+
+	sources := make([]FunnelSource, 0, len(sourcesByName))
+	for name, source := range sourcesByName {
+		source.People = len(sourcePeople[name])
+		sources = append(sources, *source)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].Revenue != sources[j].Revenue {
+			return sources[i].Revenue > sources[j].Revenue
+		}
+		return sources[i].Source < sources[j].Source
+	})
+
+	return stages, sources
+}
+
+// funnelRange parses the inclusive date range, end is returned exclusive
+func funnelRange(startStr, endStr string) (time.Time, time.Time, error) {
+	if startStr == "" && endStr == "" {
+		now := time.Now().UTC()
+		end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+		return end.AddDate(0, 0, -30), end, nil
+	}
+	if startStr == "" || endStr == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("start and end are required")
+	}
+
+	start, err := time.Parse("2006-01-02", startStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("start must be YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", endStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("end must be YYYY-MM-DD")
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end must not be before start")
+	}
+	return start, end.AddDate(0, 0, 1), nil
+}
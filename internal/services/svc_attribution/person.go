@@ -0,0 +1,154 @@
+package svc_attribution
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Identity is what one system (GHL, Zenoti or Cerbo) knows about a person.
+// Empty fields are unknown.
+type Identity struct {
+	FirstName string
+	LastName  string
+	Email     string
+	Phone     string
+	Source    string
+
+	ContactId     string // GHL contact
+	OpportunityId string // GHL opportunity
+
+	EmrSystem      string // zenoti or cerbo
+	EmrContactId   string
+	EmrContactLink string
+}
+
+// NormalizeEmail lowercases and trims an email
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizePhone keeps the digits of a phone number. US numbers without the
+// country code get it, so "(555) 123-4567" and "+1 555 123 4567" match.
+func NormalizePhone(phone string) string {
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	digits := b.String()
+	if len(digits) == 10 {
+		digits = "1" + digits
+	}
+	return digits
+}
+
+// FindPerson returns the profile's person matching the identity: by the GHL
+// or EMR contact id first, then by email, then by phone.
+// Returns gorm.ErrRecordNotFound when nobody matches.
+func FindPerson(profileID uint, identity Identity) (models.Person, error) {
+	var person models.Person
+	base := func() *gorm.DB {
+		return db.DB.Where("profile_id = ?", profileID).Order("id asc")
+	}
+
+	lookups := []*gorm.DB{}
+	if identity.ContactId != "" {
+		lookups = append(lookups, base().Where("contact_id = ?", identity.ContactId))
+	}
+	if identity.EmrSystem != "" && identity.EmrContactId != "" {
+		lookups = append(lookups, base().Where("emr_system = ? AND emr_contact_id = ?", identity.EmrSystem, identity.EmrContactId))
+	}
+	if email := NormalizeEmail(identity.Email); email != "" {
+		lookups = append(lookups, base().Where("email_normalized = ?", email))
+	}
+	if phone := NormalizePhone(identity.Phone); phone != "" {
+		lookups = append(lookups, base().Where("phone_normalized = ?", phone))
+	}
+
+	for _, lookup := range lookups {
+		err := lookup.First(&person).Error
+		if err == nil {
+			return person, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return person, err
+		}
+	}
+	return person, gorm.ErrRecordNotFound
+}
+
+// ResolvePerson merges the identity into the matching person, or creates a
+// new person when nobody matches. Known fields of a matched person are kept.
+// Reports whether the person was created.
+func ResolvePerson(profileID uint, locationID string, identity Identity) (models.Person, bool, error) {
+	if NormalizeEmail(identity.Email) == "" && NormalizePhone(identity.Phone) == "" &&
+		identity.ContactId == "" && identity.EmrContactId == "" {
+		return models.Person{}, false, errors.New("email, phone or a contact id is required")
+	}
+
+	person, err := FindPerson(profileID, identity)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		person = models.Person{ProfileId: profileID, LocationId: locationID}
+		applyIdentity(&person, identity, true)
+		return person, true, db.DB.Create(&person).Error
+	}
+	if err != nil {
+		return person, false, err
+	}
+
+	applyIdentity(&person, identity, false)
+	if person.LocationId == "" {
+		person.LocationId = locationID
+	}
+	return person, false, db.DB.Save(&person).Error
+}
+
+// UpdatePerson overwrites the person's fields with the known fields of the
+// identity
+func UpdatePerson(profileID, personID uint, identity Identity) (models.Person, error) {
+	var person models.Person
+	err := db.DB.Where("profile_id = ? AND id = ?", profileID, personID).First(&person).Error
+	if err != nil {
+		return person, err
+	}
+
+	applyIdentity(&person, identity, true)
+	return person, db.DB.Save(&person).Error
+}
+
+// GetPerson returns a person of the profile
+func GetPerson(profileID, personID uint) (models.Person, error) {
+	var person models.Person
+	err := db.DB.Where("profile_id = ? AND id = ?", profileID, personID).First(&person).Error
+	return person, err
+}
+
+// applyIdentity copies the known fields of the identity into the person.
+// Without overwrite only the person's empty fields are filled.
+func applyIdentity(person *models.Person, identity Identity, overwrite bool) {
+	set := func(field *string, value string) {
+		value = strings.TrimSpace(value)
+		if value != "" && (overwrite || *field == "") {
+			*field = value
+		}
+	}
+
+	set(&person.FirstName, identity.FirstName)
+	set(&person.LastName, identity.LastName)
+	set(&person.Email, identity.Email)
+	set(&person.Phone, identity.Phone)
+	set(&person.Source, identity.Source)
+	set(&person.ContactId, identity.ContactId)
+	set(&person.OpportunityId, identity.OpportunityId)
+	set(&person.EmrSystem, identity.EmrSystem)
+	set(&person.EmrContactId, identity.EmrContactId)
+	set(&person.EMRContactLink, identity.EmrContactLink)
+
+	person.EmailNormalized = NormalizeEmail(person.Email)
+	person.PhoneNormalized = NormalizePhone(person.Phone)
+}
@@ -0,0 +1,233 @@
+package svc_attribution
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/auth"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// AttributionReport is the revenue of the stage hits in a date range
+// credited to the sources and campaigns of the people's touchpoints
+type AttributionReport struct {
+	Model        string           `json:"model"`
+	Start        string           `json:"start"`
+	End          string           `json:"end"`
+	Conversions  int              `json:"conversions"`
+	TotalRevenue float64          `json:"totalRevenue"`
+	Rows         []AttributionRow `json:"rows"`
+}
+
+// AttributionRow is the credit of a source and campaign at a location.
+// Conversions are fractional, a conversion is split like its revenue.
+type AttributionRow struct {
+	Source       string  `json:"source"`
+	Campaign     string  `json:"campaign"`
+	LocationId   string  `json:"locationId"`
+	LocationName string  `json:"locationName"`
+	Conversions  float64 `json:"conversions"`
+	Revenue      float64 `json:"revenue"`
+}
+
+type conversion struct {
+	PersonId   string
+	LocationId string
+	At         time.Time
+	Revenue    float64
+}
+
+type touch struct {
+	At       time.Time
+	Source   string
+	Campaign string
+}
+
+// GetAttributionReport credits revenue with an attribution model. Accepts
+// ?model= (linear by default), ?start=YYYY-MM-DD&end=YYYY-MM-DD (the last
+// 30 days by default), ?locationId= and ?flowId=.
+func GetAttributionReport(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	model := c.DefaultQuery("model", ModelLinear)
+	if !slices.Contains(AttributionModels, model) {
+		c.Data(lvn.Res(400, nil, "model must be one of first_touch, last_touch, linear, time_decay, position_based"))
+		return
+	}
+
+	start, end, err := funnelRange(c.Query("start"), c.Query("end"))
+	if err != nil {
+		c.Data(lvn.Res(400, nil, err.Error()))
+		return
+	}
+
+	// conversions are the stage hits with revenue
+	var hits []models.StageHit
+	query := db.DB.Where("profile_id = ? AND revenue > 0 AND occurred_at >= ? AND occurred_at < ?", user.ProfileID, start, end)
+	if locationID := c.Query("locationId"); locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+	if allowed := auth.AllowedLocationIds(c); allowed != nil {
+		query = query.Where("location_id IN ?", allowed)
+	}
+	if flowID := c.Query("flowId"); flowID != "" {
+		query = query.Where("attribution_flow_id = ?", flowID)
+	}
+	if err := query.Order("occurred_at asc").Find(&hits).Error; err != nil {
+		lvn.GinErr(c, 500, err, "Unable to load stage hits")
+		return
+	}
+
+	conversions := make([]conversion, 0, len(hits))
+	personIDs := []string{}
+	seen := map[string]bool{}
+	for _, hit := range hits {
+		conversions = append(conversions, conversion{
+			PersonId:   hit.PersonId,
+			LocationId: hit.LocationId,
+			At:         hit.OccurredAt,
+			Revenue:    hit.Revenue,
+		})
+		if !seen[hit.PersonId] {
+			seen[hit.PersonId] = true
+			personIDs = append(personIDs, hit.PersonId)
+		}
+	}
+
+	touches, err := personTouches(user.ProfileID, personIDs, end)
+	if err != nil {
+		lvn.GinErr(c, 500, err, "Unable to load touchpoints")
+		return
+	}
+
+	rows := attributeConversions(model, conversions, touches)
+
+	var locations []models.Location
+	db.DB.Where("profile_id = ?", user.ProfileID).Find(&locations)
+	names := make(map[string]string, len(locations))
+	for _, l := range locations {
+		names[l.Id] = l.Name
+	}
+
+	report := AttributionReport{
+		Model:       model,
+		Start:       start.Format("2006-01-02"),
+		End:         end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Conversions: len(conversions),
+		Rows:        rows,
+	}
+	for i := range report.Rows {
+		report.Rows[i].LocationName = names[report.Rows[i].LocationId]
+		report.TotalRevenue += report.Rows[i].Revenue
+	}
+
+	c.Data(lvn.Res(200, report, ""))
+}
+
+// personTouches loads the touchpoints of the people before the end of the
+// range, in time order. People without touchpoints get one touch of their
+// own lead source when they were created. Stage hits aren't touches: they
+// carry no campaign and would take credit from the touches that led to
+// them.
+func personTouches(profileID uint, personIDs []string, end time.Time) (map[string][]touch, error) {
+	res := map[string][]touch{}
+	if len(personIDs) == 0 {
+		return res, nil
+	}
+
+	ids := make([]uint, 0, len(personIDs))
+	for _, id := range personIDs {
+		if parsed, err := strconv.ParseUint(id, 10, 64); err == nil {
+			ids = append(ids, uint(parsed))
+		}
+	}
+
+	var touchpoints []models.Touchpoint
+	err := db.DB.Where("profile_id = ? AND person_id IN ? AND occurred_at < ?", profileID, ids, end).
+		Order("occurred_at, id").Find(&touchpoints).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, tp := range touchpoints {
+		personID := strconv.FormatUint(uint64(tp.PersonId), 10)
+		res[personID] = append(res[personID], touch{At: tp.OccurredAt, Source: tp.Source, Campaign: tp.Campaign})
+	}
+
+	var people []models.Person
+	if err := db.DB.Where("profile_id = ? AND id IN ?", profileID, ids).Find(&people).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range people {
+		personID := strconv.FormatUint(uint64(p.ID), 10)
+		if len(res[personID]) == 0 && p.Source != "" {
+			res[personID] = []touch{{At: p.CreatedAt, Source: p.Source}}
+		}
+	}
+	return res, nil
+}
+
+// attributeConversions splits the revenue of each conversion among the
+// person's touches up to it, grouped by
+// source, campaign and the location of the conversion
+func attributeConversions(model string, conversions []conversion, touches map[string][]touch) []AttributionRow {
+	type rowKey struct{ source, campaign, location string }
+	rows := map[rowKey]*AttributionRow{}
+
+	for _, conv := range conversions {
+		var before []touch
+		for _, t := range touches[conv.PersonId] {
+			if t.At.After(conv.At) {
+				break
+			}
+			before = append(before, t)
+		}
+		if len(before) == 0 {
+			before = []touch{{At: conv.At}}
+		}
+
+		times := make([]time.Time, len(before))
+		for i, t := range before {
+			times[i] = t.At
+		}
+		weights := CreditWeights(model, times, conv.At)
+
+		for i, t := range before {
+			if weights[i] == 0 {
+				continue
+			}
+			source := t.Source
+			if source == "" {
+				source = SourceDirect
+			}
+			key := rowKey{source, t.Campaign, conv.LocationId}
+			if rows[key] == nil {
+				rows[key] = &AttributionRow{Source: source, Campaign: t.Campaign, LocationId: conv.LocationId}
+			}
+			rows[key].Conversions += weights[i]
+			rows[key].Revenue += weights[i] * conv.Revenue
+		}
+	}
+
+	res := make([]AttributionRow, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, *row)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Revenue != res[j].Revenue {
+			return res[i].Revenue > res[j].Revenue
+		}
+		if res[i].Source != res[j].Source {
+			return res[i].Source < res[j].Source
+		}
+		if res[i].Campaign != res[j].Campaign {
+			return res[i].Campaign < res[j].Campaign
+		}
+		return res[i].LocationId < res[j].LocationId
+	})
+	return res
+}
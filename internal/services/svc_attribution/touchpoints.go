@@ -0,0 +1,147 @@
+package svc_attribution
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	runwayv2 "client-runaway-zenoti/packages/runwayV2"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecordGhlContactCreated resolves the person of a contact created in GHL
+// and records the sessions GHL tracked the contact from as touchpoints
+func RecordGhlContactCreated(body []byte) error {
+	contact := runwayv2.ContactWebhook{}
+	if err := json.Unmarshal(body, &contact); err != nil {
+		return err
+	}
+	if contact.Id == "" || contact.LocationId == "" {
+		return errors.New("contact id and location id are required")
+	}
+
+	var location models.Location
+	err := db.DB.Where("id = ?", contact.LocationId).First(&location).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil // not one of our locations
+	}
+	if err != nil {
+		return err
+	}
+
+	first := touchpointFromGhl(contact.AttributionSource, contact.Source)
+	person, _, err := ResolvePerson(location.ProfileID, location.Id, Identity{
+		FirstName: contact.FirstName,
+		LastName:  contact.LastName,
+		Email:     contact.Email,
+		Phone:     contact.Phone,
+		Source:    first.Source,
+		ContactId: contact.Id,
+	})
+	if err != nil {
+		return fmt.Errorf("resolve person of contact %s: %w", contact.Id, err)
+	}
+
+	occurredAt, err := time.Parse(time.RFC3339, contact.DateAdded)
+	if err != nil {
+		occurredAt = time.Now()
+	}
+
+	first.OccurredAt = occurredAt
+	touchpoints := []models.Touchpoint{first}
+	if contact.LastAttributionSource != nil {
+		last := touchpointFromGhl(contact.LastAttributionSource, contact.Source)
+		if last.Source != first.Source || last.Campaign != first.Campaign {
+			// the last session is as recent as the contact's last update
+			last.OccurredAt = occurredAt
+			if updatedAt, err := time.Parse(time.RFC3339, contact.DateUpdated); err == nil && updatedAt.After(occurredAt) {
+				last.OccurredAt = updatedAt
+			}
+			last.RefId = contact.Id + ":last"
+			touchpoints = append(touchpoints, last)
+		}
+	}
+	for _, tp := range touchpoints {
+		tp.ProfileId = location.ProfileID
+		tp.LocationId = location.Id
+		tp.PersonId = person.ID
+		tp.RefSystem = "ghl"
+		if tp.RefId == "" {
+			tp.RefId = contact.Id
+		}
+		if err := RecordTouchpoint(tp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordTouchpoint saves a touchpoint once per ref
+func RecordTouchpoint(tp models.Touchpoint) error {
+	if tp.RefId != "" {
+		var count int64
+		err := db.DB.Model(&models.Touchpoint{}).
+			Where("profile_id = ? AND ref_system = ? AND ref_id = ?", tp.ProfileId, tp.RefSystem, tp.RefId).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+	}
+	return db.DB.Create(&tp).Error
+}
+
+// touchpointFromGhl maps a tracked session to a touchpoint. The source is
+// the utm source, else the ad network of the click id, else the session
+// source GHL detected, else the contact's source.
+func touchpointFromGhl(attr *runwayv2.ContactAttribution, contactSource string) models.Touchpoint {
+	if attr == nil {
+		attr = &runwayv2.ContactAttribution{}
+	}
+
+	tp := models.Touchpoint{
+		Medium:   strings.ToLower(strings.TrimSpace(attr.UtmMedium)),
+		Campaign: strings.TrimSpace(attr.UtmCampaign),
+		Content:  strings.TrimSpace(attr.UtmContent),
+		Term:     strings.TrimSpace(attr.UtmTerm),
+		Gclid:    strings.TrimSpace(attr.Gclid),
+		Fbclid:   strings.TrimSpace(attr.Fbclid),
+		Url:      attr.Url,
+		Referrer: attr.Referrer,
+	}
+	if tp.Campaign == "" {
+		tp.Campaign = strings.TrimSpace(attr.Campaign)
+	}
+
+	switch {
+	case strings.TrimSpace(attr.UtmSource) != "":
+		tp.Source = attr.UtmSource
+	case tp.Gclid != "":
+		tp.Source = "google"
+		if tp.Medium == "" {
+			tp.Medium = "cpc"
+		}
+	case tp.Fbclid != "":
+		tp.Source = "facebook"
+		if tp.Medium == "" {
+			tp.Medium = "paid_social"
+		}
+	case strings.TrimSpace(attr.SessionSource) != "":
+		tp.Source = attr.SessionSource
+	case strings.TrimSpace(contactSource) != "":
+		tp.Source = contactSource
+	default:
+		tp.Source = SourceDirect
+	}
+	tp.Source = strings.ToLower(strings.TrimSpace(tp.Source))
+	if tp.Medium == "" {
+		tp.Medium = strings.ToLower(strings.TrimSpace(attr.Medium))
+	}
+	return tp
+}
@@ -0,0 +1,110 @@
+package svc_audit
+
+import (
+	"bytes"
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"encoding/json"
+	"fmt"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// Record writes an audit event for a change made by the request's user.
+// before is nil for creates and after is nil for deletes. Entities are
+// stored the way they serialize to clients, so secrets stay redacted.
+// Failures are logged and never fail the request.
+func Record(c *gin.Context, action models.AuditAction, entityType, entityId, locationId string, before, after any) {
+	RecordForProfile(c, 0, action, entityType, entityId, locationId, before, after)
+}
+
+// RecordForProfile is Record for requests without an authenticated user,
+// like OAuth callbacks, where the profile comes from elsewhere
+func RecordForProfile(c *gin.Context, profileID uint, action models.AuditAction, entityType, entityId, locationId string, before, after any) {
+	event := models.AuditEvent{
+		ProfileID:  profileID,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityId,
+		LocationID: locationId,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+
+	if u, ok := c.Get("user"); ok {
+		user := u.(models.User)
+		event.ActorID = user.ID
+		event.ActorEmail = user.Email
+		event.ProfileID = user.ProfileID
+	}
+
+	var err error
+	event.Before, err = snapshot(before)
+	if err == nil {
+		event.After, err = snapshot(after)
+	}
+	if err == nil {
+		event.Diff, err = diff(event.Before, event.After)
+	}
+	if err == nil {
+		err = db.DB.Create(&event).Error
+	}
+	if err != nil {
+		lvn.Logger.Errorf("audit: %s %s %s: %s", action, entityType, entityId, err.Error())
+	}
+}
+
+func snapshot(v any) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// diff maps every top-level field that differs to {"before": .., "after": ..}
+func diff(before, after []byte) ([]byte, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	type change struct {
+		Before json.RawMessage `json:"before,omitempty"`
+		After  json.RawMessage `json:"after,omitempty"`
+	}
+	changes := map[string]change{}
+	for k, v := range b {
+		if !bytes.Equal(v, a[k]) {
+			changes[k] = change{Before: v, After: a[k]}
+		}
+	}
+	for k, v := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = change{After: v}
+		}
+	}
+
+	return json.Marshal(changes)
+}
+
+func fields(raw []byte) (map[string]json.RawMessage, error) {
+	res := map[string]json.RawMessage{}
+	if raw == nil {
+		return res, nil
+	}
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, fmt.Errorf("entity is not a json object: %w", err)
+	}
+	for k, v := range res {
+		compact := bytes.Buffer{}
+		if err := json.Compact(&compact, v); err == nil {
+			res[k] = compact.Bytes()
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,86 @@
+package svc_audit
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/auth"
+	"errors"
+	"strconv"
+	"time"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListAuditEvents returns the active profile's audit events, newest first.
+// Filters: entityType, entityId, actorId, action, locationId and the
+// RFC3339 from/to bounds on createdAt.
+func ListAuditEvents(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	query := db.DB.Model(&models.AuditEvent{}).Where("profile_id = ?", user.ProfileID)
+
+	// location-scoped members only see their locations and profile-wide events
+	if allowed := auth.AllowedLocationIds(c); allowed != nil {
+		query = query.Where("location_id = '' OR location_id IN ?", allowed)
+	}
+
+	for param, column := range map[string]string{
+		"entityType": "entity_type",
+		"entityId":   "entity_id",
+		"actorId":    "actor_id",
+		"action":     "action",
+		"locationId": "location_id",
+	} {
+		if v := c.Query(param); v != "" {
+			query = query.Where(column+" = ?", v)
+		}
+	}
+
+	for param, cond := range map[string]string{
+		"from": "created_at >= ?",
+		"to":   "created_at < ?",
+	} {
+		v := c.Query(param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			lvn.GinErr(c, 400, errors.New(param+" must be RFC3339"), param+" must be RFC3339")
+			return
+		}
+		query = query.Where(cond, t)
+	}
+
+	// share the filters between the count and the page query
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	err := query.Count(&total).Error
+	lvn.GinErr(c, 500, err, "error while counting audit events")
+
+	events := []models.AuditEvent{}
+	err = query.Order("created_at desc, id desc").Limit(limit).Offset((page - 1) * limit).Find(&events).Error
+	lvn.GinErr(c, 500, err, "error while getting audit events")
+
+	c.Data(lvn.Res(200, gin.H{
+		"events": events,
+		"pagination": gin.H{
+			"page":    page,
+			"limit":   limit,
+			"total":   total,
+			"hasMore": int64(page*limit) < total,
+		},
+	}, ""))
+}
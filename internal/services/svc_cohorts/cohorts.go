@@ -0,0 +1,164 @@
+package svc_cohorts
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	PeriodWeek  = "week"  // cohorts start on Mondays
+	PeriodMonth = "month" // cohorts start on the 1st
+
+	// AllSources labels the row of a cohort's leads of every source
+	AllSources = "All sources"
+)
+
+var DefaultOffsets = []int{30, 60, 90}
+
+type (
+	// CohortLead is a lead with the first time it reached each stage, nil
+	// when it didn't
+	CohortLead struct {
+		CreatedAt time.Time
+		Source    string
+		BookedAt  *time.Time
+		ShowedAt  *time.Time
+		SoldAt    *time.Time
+		Payments  []CohortPayment
+	}
+
+	CohortPayment struct {
+		Date  time.Time
+		Total float64
+	}
+
+	// CohortRow is the leads of a source created in a cohort period
+	CohortRow struct {
+		Cohort  string         `json:"cohort"` // first day of the period, YYYY-MM-DD
+		Source  string         `json:"source"`
+		Leads   int            `json:"leads"`
+		Offsets []CohortOffset `json:"offsets"`
+	}
+
+	// CohortOffset is where the cohort's leads stood the given number of
+	// days after each was created
+	CohortOffset struct {
+		Day int `json:"day"`
+		// Complete is false while some leads of the cohort are younger
+		// than Day, the numbers can still grow
+		Complete bool `json:"complete"`
+
+		Booked int `json:"booked"`
+		Showed int `json:"showed"`
+		Sold   int `json:"sold"`
+
+		BookingRate    float64 `json:"bookingRate"` // booked of the leads, %
+		ShowRate       float64 `json:"showRate"`    // showed of the booked, %
+		SalesRate      float64 `json:"salesRate"`   // sold of the leads, %
+		Revenue        float64 `json:"revenue"`
+		RevenuePerLead float64 `json:"revenuePerLead"`
+	}
+)
+
+// CohortStart returns the first day of the period the time falls in
+func CohortStart(t time.Time, period string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if period == PeriodMonth {
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+func cohortEnd(start time.Time, period string) time.Time {
+	if period == PeriodMonth {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 7)
+}
+
+// BuildCohorts groups the leads by creation period and source, with a row of
+// all sources first in every period, and computes their cumulative
+// progress at each day offset
+func BuildCohorts(leads []CohortLead, period string, offsets []int, now time.Time) []CohortRow {
+	type key struct {
+		cohort time.Time
+		source string
+	}
+	groups := map[key][]CohortLead{}
+	for _, l := range leads {
+		cohort := CohortStart(l.CreatedAt, period)
+		groups[key{cohort, AllSources}] = append(groups[key{cohort, AllSources}], l)
+		groups[key{cohort, l.Source}] = append(groups[key{cohort, l.Source}], l)
+	}
+
+	keys := make([]key, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].cohort.Equal(keys[j].cohort) {
+			return keys[i].cohort.Before(keys[j].cohort)
+		}
+		if (keys[i].source == AllSources) != (keys[j].source == AllSources) {
+			return keys[i].source == AllSources
+		}
+		return keys[i].source < keys[j].source
+	})
+
+	rows := make([]CohortRow, 0, len(keys))
+	for _, k := range keys {
+		group := groups[k]
+		row := CohortRow{
+			Cohort: k.cohort.Format("2006-01-02"),
+			Source: k.source,
+			Leads:  len(group),
+		}
+		for _, day := range offsets {
+			row.Offsets = append(row.Offsets, offsetStats(group, day, !cohortEnd(k.cohort, period).AddDate(0, 0, day).After(now)))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func offsetStats(leads []CohortLead, day int, complete bool) CohortOffset {
+	res := CohortOffset{Day: day, Complete: complete}
+	reached := func(at *time.Time, deadline time.Time) bool {
+		return at != nil && at.Before(deadline)
+	}
+
+	for _, l := range leads {
+		deadline := l.CreatedAt.AddDate(0, 0, day)
+		if reached(l.BookedAt, deadline) {
+			res.Booked++
+		}
+		if reached(l.ShowedAt, deadline) {
+			res.Showed++
+		}
+		if reached(l.SoldAt, deadline) {
+			res.Sold++
+		}
+		for _, p := range l.Payments {
+			if p.Date.Before(deadline) {
+				res.Revenue += p.Total
+			}
+		}
+	}
+
+	res.BookingRate = percent(res.Booked, len(leads))
+	res.ShowRate = percent(res.Showed, res.Booked)
+	res.SalesRate = percent(res.Sold, len(leads))
+	if len(leads) > 0 {
+		res.RevenuePerLead = math.Round(100*res.Revenue/float64(len(leads))) / 100
+	}
+	res.Revenue = math.Round(100*res.Revenue) / 100
+	return res
+}
+
+func percent(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(1000*float64(part)/float64(whole)) / 10
+}
@@ -0,0 +1,98 @@
+package svc_cohorts
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func exportHeader(offsets []int) []string {
+	header := []string{"Cohort", "Source", "Leads"}
+	for _, day := range offsets {
+		header = append(header,
+			fmt.Sprintf("Booking rate %% (day %d)", day),
+			fmt.Sprintf("Show rate %% (day %d)", day),
+			fmt.Sprintf("Sales rate %% (day %d)", day),
+			fmt.Sprintf("Revenue (day %d)", day),
+			fmt.Sprintf("Revenue per lead (day %d)", day),
+			fmt.Sprintf("Complete (day %d)", day),
+		)
+	}
+	return header
+}
+
+func exportRow(row CohortRow) []interface{} {
+	values := []interface{}{row.Cohort, row.Source, row.Leads}
+	for _, o := range row.Offsets {
+		values = append(values, o.BookingRate, o.ShowRate, o.SalesRate, o.Revenue, o.RevenuePerLead, o.Complete)
+	}
+	return values
+}
+
+// CohortsCSV exports the cohorts with a column group per offset
+func CohortsCSV(rows []CohortRow, offsets []int) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	if err := w.Write(exportHeader(offsets)); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		values := exportRow(row)
+		record := make([]string, len(values))
+		for i, v := range values {
+			switch v := v.(type) {
+			case float64:
+				record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// CohortsXLSX exports the cohorts as CohortsCSV does, on a single sheet
+func CohortsXLSX(rows []CohortRow, offsets []int) ([]byte, error) {
+	const sheet = "cohorts"
+	f := excelize.NewFile()
+	defer f.Close()
+	f.SetSheetName("Sheet1", sheet)
+
+	header := exportHeader(offsets)
+	headerRow := make([]interface{}, len(header))
+	for i, h := range header {
+		headerRow[i] = h
+	}
+	if err := f.SetSheetRow(sheet, "A1", &headerRow); err != nil {
+		return nil, err
+	}
+	if err := f.SetPanes(sheet, &excelize.Panes{Freeze: true, XSplit: 3, YSplit: 1, TopLeftCell: "D2", ActivePane: "bottomRight"}); err != nil {
+		return nil, err
+	}
+
+	for i, row := range rows {
+		addr, err := excelize.JoinCellName("A", i+2)
+		if err != nil {
+			return nil, err
+		}
+		values := exportRow(row)
+		if err := f.SetSheetRow(sheet, addr, &values); err != nil {
+			return nil, err
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,220 @@
+package svc_cohorts
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/auth"
+	"client-runaway-zenoti/internal/types"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxOffsets keeps exports readable
+const maxOffsets = 12
+
+// GetCohortReport returns the leads created between ?start and ?end
+// (YYYY-MM-DD, both inclusive) by ?period (week or month) and source, with
+// their progress at ?offsets days (comma separated, 30,60,90 by default).
+// ?locationId narrows it to a location and ?format=csv or xlsx exports it.
+func GetCohortReport(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	start, err := time.Parse("2006-01-02", c.Query("start"))
+	if err != nil {
+		c.Data(lvn.Res(400, nil, "start must be YYYY-MM-DD"))
+		return
+	}
+	end, err := time.Parse("2006-01-02", c.Query("end"))
+	if err != nil {
+		c.Data(lvn.Res(400, nil, "end must be YYYY-MM-DD"))
+		return
+	}
+	if end.Before(start) {
+		c.Data(lvn.Res(400, nil, "end must not be before start"))
+		return
+	}
+
+	period := c.DefaultQuery("period", PeriodMonth)
+	if period != PeriodWeek && period != PeriodMonth {
+		c.Data(lvn.Res(400, nil, fmt.Sprintf("period must be %s or %s", PeriodWeek, PeriodMonth)))
+		return
+	}
+
+	offsets, err := parseOffsets(c.Query("offsets"))
+	if err != nil {
+		c.Data(lvn.Res(400, nil, err.Error()))
+		return
+	}
+
+	locationIds, err := reportLocations(c, user.ProfileID, c.Query("locationId"))
+	if err != nil {
+		c.Data(lvn.Res(400, nil, err.Error()))
+		return
+	}
+
+	leads, err := loadLeads(locationIds, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		lvn.GinErr(c, 500, err, "Unable to load leads")
+		return
+	}
+	rows := BuildCohorts(leads, period, offsets, time.Now())
+
+	name := fmt.Sprintf("cohorts %s_%s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.Data(lvn.Res(200, rows, ""))
+	case "csv":
+		data, err := CohortsCSV(rows, offsets)
+		if err != nil {
+			lvn.GinErr(c, 500, err, "Unable to build csv file")
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", name))
+		c.Data(200, "text/csv", data)
+	case "xlsx":
+		data, err := CohortsXLSX(rows, offsets)
+		if err != nil {
+			lvn.GinErr(c, 500, err, "Unable to build xls file")
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.xlsx", name))
+		c.Data(200, "application/octet-stream", data)
+	default:
+		c.Data(lvn.Res(400, nil, "format must be json, csv or xlsx"))
+	}
+}
+
+func parseOffsets(s string) ([]int, error) {
+	if s == "" {
+		return DefaultOffsets, nil
+	}
+
+	var offsets []int
+	for _, part := range strings.Split(s, ",") {
+		day, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || day <= 0 {
+			return nil, fmt.Errorf("offsets must be positive numbers of days, got %q", part)
+		}
+		offsets = append(offsets, day)
+	}
+	slices.Sort(offsets)
+	offsets = slices.Compact(offsets)
+	if len(offsets) > maxOffsets {
+		return nil, fmt.Errorf("at most %d offsets are allowed", maxOffsets)
+	}
+	return offsets, nil
+}
+
+// reportLocations returns the requested location, or all the member may see
+// in the profile
+func reportLocations(c *gin.Context, profileID uint, locationId string) ([]string, error) {
+	query := db.DB.Model(&models.Location{}).Where("profile_id = ?", profileID)
+	if locationId != "" {
+		query = query.Where("id = ?", locationId)
+	}
+	if allowed := auth.AllowedLocationIds(c); allowed != nil {
+		query = query.Where("id IN ?", allowed)
+	}
+
+	var ids []string
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no locations to report on")
+	}
+	return ids, nil
+}
+
+// loadLeads reads the new leads of the locations created in [from, to) with
+// their appointments and invoices since the day they were created
+func loadLeads(locationIds []string, from, to time.Time) ([]CohortLead, error) {
+	var newLeads []models.JpmReportNewLead
+	leadsQuery := func() *gorm.DB {
+		return db.DB.Model(&models.JpmReportNewLead{}).Where("location_id IN ? AND date >= ? AND date < ?", locationIds, from, to)
+	}
+	if err := leadsQuery().Find(&newLeads).Error; err != nil {
+		return nil, err
+	}
+
+	var appts []models.Appointment
+	err := db.DB.Select("contact_id, date, status").
+		Where("contact_id IN (?) AND date >= ?", leadsQuery().Select("contact_id"), from).
+		Find(&appts).Error
+	if err != nil {
+		return nil, err
+	}
+	apptsByContact := map[string][]models.Appointment{}
+	for _, a := range appts {
+		apptsByContact[a.ContactId] = append(apptsByContact[a.ContactId], a)
+	}
+
+	var invoices []models.JpmReportInvoice
+	err = db.DB.Select("guest_id, date, total").
+		Where("location_id IN ? AND guest_id IN (?) AND date >= ?", locationIds, leadsQuery().Where("guest_id != ''").Select("guest_id"), from).
+		Find(&invoices).Error
+	if err != nil {
+		return nil, err
+	}
+	invoicesByGuest := map[string][]models.JpmReportInvoice{}
+	for _, inv := range invoices {
+		invoicesByGuest[inv.GuestId] = append(invoicesByGuest[inv.GuestId], inv)
+	}
+
+	leads := make([]CohortLead, 0, len(newLeads))
+	for _, nl := range newLeads {
+		leads = append(leads, cohortLead(nl, apptsByContact[nl.ContactId], invoicesByGuest[nl.GuestId]))
+	}
+	return leads, nil
+}
+
+// cohortLead finds when the lead first booked, showed and bought. An invoice
+// counts as a booking and a show too, as in the funnel report.
+func cohortLead(nl models.JpmReportNewLead, appts []models.Appointment, invoices []models.JpmReportInvoice) CohortLead {
+	lead := CohortLead{
+		CreatedAt: nl.Date,
+		Source:    lvn.Ternary(nl.Source == "", "Unknown", nl.Source),
+	}
+	// same-day bookings may be stored with an earlier time than the lead
+	since := time.Date(nl.Date.Year(), nl.Date.Month(), nl.Date.Day(), 0, 0, 0, 0, nl.Date.Location())
+
+	first := func(at **time.Time, t time.Time) {
+		if *at == nil || t.Before(**at) {
+			*at = &t
+		}
+	}
+
+	for _, a := range appts {
+		if a.Date.Before(since) || a.Status == types.Canceled {
+			continue
+		}
+		first(&lead.BookedAt, a.Date)
+		if a.Status == types.Closed || a.Status == types.CheckedIn {
+			first(&lead.ShowedAt, a.Date)
+		}
+	}
+
+	sort.Slice(invoices, func(i, j int) bool { return invoices[i].Date.Before(invoices[j].Date) })
+	for _, inv := range invoices {
+		if inv.Date.Before(since) {
+			continue
+		}
+		first(&lead.BookedAt, inv.Date)
+		first(&lead.ShowedAt, inv.Date)
+		if inv.Total > 0 {
+			first(&lead.SoldAt, inv.Date)
+			lead.Payments = append(lead.Payments, CohortPayment{Date: inv.Date, Total: inv.Total})
+		}
+	}
+
+	return lead
+}
@@ -3,6 +3,8 @@ package svc_config
 import (
 	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/svc_audit"
+	"strconv"
 
 	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
 	"github.com/gin-gonic/gin"
@@ -29,6 +31,8 @@ func CreateCerboApi(c *gin.Context) {
 	err = db.DB.Create(&payload).Error
 	lvn.GinErr(c, 400, err, "error while creating cerbo api")
 
+	svc_audit.Record(c, models.AuditCreate, "cerbo_api", strconv.FormatUint(uint64(payload.ID), 10), "", nil, payload)
+
 	c.Data(lvn.Res(200, payload, ""))
 }
 
@@ -38,21 +42,34 @@ func UpdateCerboApi(c *gin.Context) {
 	err := c.BindJSON(&payload)
 	lvn.GinErr(c, 400, err, "error while binding json")
 
+	user := c.MustGet("user").(models.User)
 	var cerboApi models.CerboApi
-	err = db.DB.First(&cerboApi, "id = ?", cerboApiId).Error
+	err = db.DB.First(&cerboApi, "id = ? AND profile_id = ?", cerboApiId, user.ProfileID).Error
 	lvn.GinErr(c, 400, err, "error while getting cerbo api")
+	before := cerboApi
 
+	payload.ProfileId = user.ProfileID
 	err = db.DB.Model(&cerboApi).Updates(payload).Error
 	lvn.GinErr(c, 400, err, "error while updating cerbo api")
 
+	svc_audit.Record(c, models.AuditUpdate, "cerbo_api", cerboApiId, "", before, cerboApi)
+
 	c.Data(lvn.Res(200, cerboApi, ""))
 }
 
 func DeleteCerboApi(c *gin.Context) {
 	cerboApiId := c.Param("cerboApiId")
 
-	err := db.DB.Delete(&models.CerboApi{}, cerboApiId).Error
+	user := c.MustGet("user").(models.User)
+
+	var cerboApi models.CerboApi
+	err := db.DB.First(&cerboApi, "id = ? AND profile_id = ?", cerboApiId, user.ProfileID).Error
+	lvn.GinErr(c, 400, err, "error while getting cerbo api")
+
+	err = db.DB.Delete(&cerboApi).Error
 	lvn.GinErr(c, 400, err, "error while deleting cerbo api")
 
+	svc_audit.Record(c, models.AuditDelete, "cerbo_api", cerboApiId, "", cerboApi, nil)
+
 	c.Data(lvn.Res(200, "", ""))
 }
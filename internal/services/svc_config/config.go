@@ -3,6 +3,9 @@ package svc_config
 import (
 	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/auth"
+	"client-runaway-zenoti/internal/services/svc_audit"
+	"errors"
 	"fmt"
 
 	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
@@ -130,10 +133,13 @@ func ListLocations(c *gin.Context) {
 	user := c.MustGet("user").(models.User)
 
 	var locations []models.Location
-	err := db.DB.
+	query := db.DB.
 		Select("id, name").
-		Where("profile_id = ?", user.ProfileID).
-		Find(&locations).Error
+		Where("profile_id = ?", user.ProfileID)
+	if allowed := auth.AllowedLocationIds(c); allowed != nil {
+		query = query.Where("id IN ?", allowed)
+	}
+	err := query.Find(&locations).Error
 
 	if err != nil {
 		lvn.GinErr(c, 500, err, "Failed to list locations")
@@ -151,9 +157,51 @@ func ListLocations(c *gin.Context) {
 	c.Data(lvn.Res(200, response, ""))
 }
 
+// updateLocationPayload lists the location fields editable from settings.
+// Ids, ownership and credentials can't be changed through it.
+type updateLocationPayload struct {
+	Name               *string
+	ZenotiCenterId     *string
+	ZenotiCenterName   *string
+	ZenotiApiObjId     *uint
+	CerboApiObjId      *uint
+	SyncCalendars      *bool
+	SyncContacts       *bool
+	AutoCreateContacts *bool
+}
+
+func (p updateLocationPayload) updates() map[string]any {
+	res := map[string]any{}
+	if p.Name != nil {
+		res["name"] = *p.Name
+	}
+	if p.ZenotiCenterId != nil {
+		res["zenoti_center_id"] = *p.ZenotiCenterId
+	}
+	if p.ZenotiCenterName != nil {
+		res["zenoti_center_name"] = *p.ZenotiCenterName
+	}
+	if p.ZenotiApiObjId != nil {
+		res["zenoti_api_obj_id"] = lvn.Ternary[any](*p.ZenotiApiObjId == 0, nil, *p.ZenotiApiObjId)
+	}
+	if p.CerboApiObjId != nil {
+		res["cerbo_api_obj_id"] = lvn.Ternary[any](*p.CerboApiObjId == 0, nil, *p.CerboApiObjId)
+	}
+	if p.SyncCalendars != nil {
+		res["sync_calendars"] = *p.SyncCalendars
+	}
+	if p.SyncContacts != nil {
+		res["sync_contacts"] = *p.SyncContacts
+	}
+	if p.AutoCreateContacts != nil {
+		res["auto_create_contacts"] = *p.AutoCreateContacts
+	}
+	return res
+}
+
 func UpdateLocation(c *gin.Context) {
 	locationId := c.Param("locationId")
-	payload := models.Location{}
+	payload := updateLocationPayload{}
 	err := c.BindJSON(&payload)
 	lvn.GinErr(c, 400, err, "error while binding json")
 
@@ -164,15 +212,36 @@ func UpdateLocation(c *gin.Context) {
 	// check if profile id matches
 	user := c.MustGet("user").(models.User)
 	if location.ProfileID != user.ProfileID {
-		lvn.GinErr(c, 403, nil, "forbidden")
+		lvn.GinErr(c, 403, errors.New("location belongs to another profile"), "forbidden")
+	}
+
+	// linked credentials must belong to the same profile
+	if payload.ZenotiApiObjId != nil && *payload.ZenotiApiObjId != 0 {
+		err = db.DB.Where("id = ? AND profile_id = ?", *payload.ZenotiApiObjId, user.ProfileID).First(&models.ZenotiApi{}).Error
+		lvn.GinErr(c, 400, err, "zenoti api not found")
+	}
+	if payload.CerboApiObjId != nil && *payload.CerboApiObjId != 0 {
+		err = db.DB.Where("id = ? AND profile_id = ?", *payload.CerboApiObjId, user.ProfileID).First(&models.CerboApi{}).Error
+		lvn.GinErr(c, 400, err, "cerbo api not found")
 	}
 
-	err = db.DB.Model(&location).Updates(payload).Error
-	lvn.GinErr(c, 400, err, "error while updating location")
+	if updates := payload.updates(); len(updates) > 0 {
+		before := location
+		err = db.DB.Model(&location).Updates(updates).Error
+		lvn.GinErr(c, 400, err, "error while updating location")
+
+		svc_audit.Record(c, models.AuditUpdate, "location", location.Id, location.Id, auditLocation(before), auditLocation(location))
+	}
 
 	c.Data(lvn.Res(200, location, ""))
 }
 
+// auditLocation is the location as written to the audit log
+func auditLocation(l models.Location) models.Location {
+	l.WidgetKey = widgetKeyHint(l.WidgetKey)
+	return l
+}
+
 // DeleteLocation deletes a location and all connected objects
 func DeleteLocation(c *gin.Context) {
 	locationId := c.Param("locationId")
@@ -276,6 +345,8 @@ func DeleteLocation(c *gin.Context) {
 
 	tx.Commit()
 
+	svc_audit.Record(c, models.AuditDelete, "location", location.Id, location.Id, auditLocation(location), nil)
+
 	c.Data(lvn.Res(200, nil, "Location and all related data deleted successfully"))
 }
 
@@ -0,0 +1,124 @@
+package svc_config
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/svc_audit"
+	"fmt"
+	"time"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+)
+
+type calendarWidgetSettings struct {
+	WorkingHoursFrom *string `json:"workingHoursFrom"`
+	WorkingHoursTo   *string `json:"workingHoursTo"`
+	TimeZone         *string `json:"timeZone"`
+	SlotMinutes      *int    `json:"slotMinutes"`
+}
+
+// RotateWidgetKey generates a new chatly widget key for the location.
+// The previous key stops working immediately.
+func RotateWidgetKey(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	location, err := GetLocationForProfile(user.ProfileID, c.Param("locationId"))
+	lvn.GinErr(c, 404, err, "Location not found")
+
+	key, err := models.GenerateWidgetKey()
+	lvn.GinErr(c, 500, err, "error while generating widget key")
+
+	err = db.DB.Model(location).Update("widget_key", key).Error
+	lvn.GinErr(c, 500, err, "error while saving widget key")
+
+	svc_audit.Record(c, models.AuditUpdate, "location_widget_key", location.Id, location.Id,
+		gin.H{"widgetKey": widgetKeyHint(location.WidgetKey)}, gin.H{"widgetKey": widgetKeyHint(key)})
+
+	c.Data(lvn.Res(200, gin.H{"widgetKey": key}, ""))
+}
+
+// ListLocationCalendars returns the therapist calendars of a location with
+// their widget settings
+func ListLocationCalendars(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	location, err := GetLocationForProfile(user.ProfileID, c.Param("locationId"))
+	lvn.GinErr(c, 404, err, "Location not found")
+
+	calendars := []models.Calendar{}
+	err = db.DB.Where("location_id = ?", location.Id).Order("therapist_name").Find(&calendars).Error
+	lvn.GinErr(c, 500, err, "error while getting calendars")
+
+	c.Data(lvn.Res(200, calendars, ""))
+}
+
+// UpdateCalendarWidgetSettings sets working hours, time zone and slot length
+// of a calendar
+func UpdateCalendarWidgetSettings(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	location, err := GetLocationForProfile(user.ProfileID, c.Param("locationId"))
+	lvn.GinErr(c, 404, err, "Location not found")
+
+	payload := calendarWidgetSettings{}
+	err = c.BindJSON(&payload)
+	lvn.GinErr(c, 400, err, "error while binding json")
+
+	calendar := models.Calendar{}
+	err = db.DB.Where("location_id = ? AND calendar_id = ?", location.Id, c.Param("calendarId")).First(&calendar).Error
+	lvn.GinErr(c, 404, err, "Calendar not found")
+	before := calendar
+
+	if payload.WorkingHoursFrom != nil {
+		calendar.WorkingHoursFrom = *payload.WorkingHoursFrom
+	}
+	if payload.WorkingHoursTo != nil {
+		calendar.WorkingHoursTo = *payload.WorkingHoursTo
+	}
+	if payload.TimeZone != nil {
+		calendar.TimeZone = *payload.TimeZone
+	}
+	if payload.SlotMinutes != nil {
+		calendar.SlotMinutes = *payload.SlotMinutes
+	}
+
+	err = validateCalendarWidgetSettings(calendar)
+	if err != nil {
+		lvn.GinErr(c, 400, err, err.Error())
+	}
+
+	err = db.DB.Model(&calendar).Updates(map[string]any{
+		"working_hours_from": calendar.WorkingHoursFrom,
+		"working_hours_to":   calendar.WorkingHoursTo,
+		"time_zone":          calendar.TimeZone,
+		"slot_minutes":       calendar.SlotMinutes,
+	}).Error
+	lvn.GinErr(c, 500, err, "error while updating calendar")
+
+	svc_audit.Record(c, models.AuditUpdate, "calendar", calendar.CalendarId, location.Id, before, calendar)
+
+	c.Data(lvn.Res(200, calendar, ""))
+}
+
+// widgetKeyHint keeps enough of a widget key to tell keys apart in the
+// audit log
+func widgetKeyHint(key string) string {
+	if len(key) <= 8 {
+		return key
+	}
+	return key[:8] + "..."
+}
+
+func validateCalendarWidgetSettings(calendar models.Calendar) error {
+	if _, err := calendar.TimeLocation(); err != nil {
+		return fmt.Errorf("invalid time zone %q", calendar.TimeZone)
+	}
+	if _, _, err := calendar.WorkingHours(time.Now()); err != nil {
+		return err
+	}
+	if calendar.SlotMinutes < 0 || calendar.SlotMinutes > 24*60 {
+		return fmt.Errorf("slotMinutes must be between 0 and %d", 24*60)
+	}
+	return nil
+}
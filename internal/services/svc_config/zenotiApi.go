@@ -3,6 +3,8 @@ package svc_config
 import (
 	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/svc_audit"
+	"strconv"
 
 	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
 	"github.com/gin-gonic/gin"
@@ -30,6 +32,8 @@ func CreateZenotiApi(c *gin.Context) {
 	err = db.DB.Create(&payload).Error
 	lvn.GinErr(c, 400, err, "error while creating zenoti api")
 
+	svc_audit.Record(c, models.AuditCreate, "zenoti_api", strconv.FormatUint(uint64(payload.ID), 10), "", nil, payload)
+
 	c.Data(lvn.Res(200, payload, ""))
 }
 
@@ -39,21 +43,34 @@ func UpdateZenotiApi(c *gin.Context) {
 	err := c.BindJSON(&payload)
 	lvn.GinErr(c, 400, err, "error while binding json")
 
+	user := c.MustGet("user").(models.User)
 	var zenotiApi models.ZenotiApi
-	err = db.DB.First(&zenotiApi, "id = ?", zenotiApiId).Error
+	err = db.DB.First(&zenotiApi, "id = ? AND profile_id = ?", zenotiApiId, user.ProfileID).Error
 	lvn.GinErr(c, 400, err, "error while getting zenoti api")
+	before := zenotiApi
 
+	payload.ProfileId = user.ProfileID
 	err = db.DB.Model(&zenotiApi).Updates(payload).Error
 	lvn.GinErr(c, 400, err, "error while updating zenoti api")
 
+	svc_audit.Record(c, models.AuditUpdate, "zenoti_api", zenotiApiId, "", before, zenotiApi)
+
 	c.Data(lvn.Res(200, zenotiApi, ""))
 }
 
 func DeleteZenotiApi(c *gin.Context) {
 	zenotiApiId := c.Param("zenotiApiId")
 
-	err := db.DB.Delete(&models.ZenotiApi{}, zenotiApiId).Error
+	user := c.MustGet("user").(models.User)
+
+	var zenotiApi models.ZenotiApi
+	err := db.DB.First(&zenotiApi, "id = ? AND profile_id = ?", zenotiApiId, user.ProfileID).Error
+	lvn.GinErr(c, 400, err, "error while getting zenoti api")
+
+	err = db.DB.Delete(&zenotiApi).Error
 	lvn.GinErr(c, 400, err, "error while deleting zenoti api")
 
+	svc_audit.Record(c, models.AuditDelete, "zenoti_api", zenotiApiId, "", zenotiApi, nil)
+
 	c.Data(lvn.Res(200, "", ""))
 }
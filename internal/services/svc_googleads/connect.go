@@ -3,6 +3,7 @@ package svc_googleads
 import (
 	"client-runaway-zenoti/internal/config"
 	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/svc_audit"
 	"client-runaway-zenoti/packages/googleads"
 	"errors"
 	"fmt"
@@ -85,6 +86,12 @@ func OAuthCallback(c *gin.Context) {
 		return
 	}
 
+	svc_audit.RecordForProfile(c, profileID, models.AuditCreate, "google_ads_connection", strconv.FormatUint(uint64(conn.ID), 10), "", nil, gin.H{
+		"id":          conn.ID,
+		"displayName": conn.DisplayName,
+		"email":       conn.Email,
+	})
+
 	if method == http.MethodPost {
 		c.Data(lvn.Res(200, conn, "Connection saved"))
 		return
@@ -3,6 +3,7 @@ package svc_googleads
 import (
 	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/svc_audit"
 	"fmt"
 	"strconv"
 
@@ -50,15 +51,19 @@ func DeleteConnection(c *gin.Context) {
 		return
 	}
 
-	res := db.DB.Where("profile_id = ? AND id = ?", user.ProfileID, id).Delete(&models.GoogleAdsConnection{})
-	if res.Error != nil {
-		lvn.GinErr(c, 400, res.Error, "unable to delete connection")
+	conn := models.GoogleAdsConnection{}
+	err = db.DB.Where("profile_id = ? AND id = ?", user.ProfileID, id).First(&conn).Error
+	lvn.GinErr(c, 404, err, "connection not found")
+	if err != nil {
 		return
 	}
-	if res.RowsAffected == 0 {
-		lvn.GinErr(c, 404, nil, "connection not found")
+
+	if err := db.DB.Delete(&conn).Error; err != nil {
+		lvn.GinErr(c, 400, err, "unable to delete connection")
 		return
 	}
 
+	svc_audit.Record(c, models.AuditDelete, "google_ads_connection", strconv.FormatUint(id, 10), "", conn, nil)
+
 	c.Data(lvn.Res(200, gin.H{"deleted": id}, "OK"))
 }
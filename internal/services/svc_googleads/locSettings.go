@@ -3,6 +3,7 @@ package svc_googleads
 import (
 	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/svc_audit"
 	"errors"
 	"fmt"
 
@@ -70,6 +71,12 @@ func SaveLocationSetting(c *gin.Context) {
 		ClientName:       payload.ClientName,
 	}
 
+	var before *models.GoogleAdsLocationSetting
+	existing := models.GoogleAdsLocationSetting{}
+	if db.DB.Where("location_id = ? AND profile_id = ?", locID, user.ProfileID).First(&existing).Error == nil {
+		before = &existing
+	}
+
 	// Upsert by (locationId, profileId)
 	err = db.DB.Clauses(
 		clause.OnConflict{
@@ -82,6 +89,15 @@ func SaveLocationSetting(c *gin.Context) {
 		return
 	}
 
+	if before == nil {
+		svc_audit.Record(c, models.AuditCreate, "google_ads_location_setting", locID, locID, nil, setting)
+	} else {
+		// the upsert doesn't return the existing row's id and creation time
+		after := setting
+		after.ID, after.CreatedAt = before.ID, before.CreatedAt
+		svc_audit.Record(c, models.AuditUpdate, "google_ads_location_setting", locID, locID, before, after)
+	}
+
 	c.Data(lvn.Res(200, setting, "saved"))
 }
 
@@ -46,11 +46,13 @@ func updateTokens(conn googleads.Connection) error {
 	if conn.ID == 0 {
 		return fmt.Errorf("googleads oauth: connection id is required to update tokens")
 	}
+	// struct update so the tokens go through the encrypted serializer
 	return db.DB.Model(&models.GoogleAdsConnection{}).Where("id = ?", conn.ID).
-		Updates(map[string]any{
-			"access_token":  conn.AccessToken,
-			"refresh_token": conn.RefreshToken,
-			"token_expiry":  conn.TokenExpiry,
+		Select("access_token", "refresh_token", "token_expiry").
+		Updates(models.GoogleAdsConnection{
+			AccessToken:  conn.AccessToken,
+			RefreshToken: conn.RefreshToken,
+			TokenExpiry:  conn.TokenExpiry,
 		}).Error
 }
 
@@ -4,17 +4,21 @@ import (
 	"client-runaway-zenoti/internal/config"
 	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/llm"
+	"client-runaway-zenoti/internal/services/svc_openai"
 	openaiv1 "client-runaway-zenoti/packages/openaiV1"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	mcpclient "github.com/mark3labs/mcp-go/client"
 	mcptransport "github.com/mark3labs/mcp-go/client/transport"
 	mcptypes "github.com/mark3labs/mcp-go/mcp"
@@ -144,14 +148,27 @@ func Stream(c *gin.Context) {
 
 	sendEvent(c, "status", streamEvent{Type: "starting"})
 
-	openaiClient, err := newOpenAIClient()
+	provider, err := newProvider()
 	if err != nil {
 		sendEvent(c, "error", streamEvent{Type: "openai_client_error", Data: err.Error()})
 		sendEvent(c, "done", streamEvent{Type: "done"})
 		return
 	}
 
-	threadID, err := resolveInternalAssistantThreadID(openaiClient, user.ProfileID, assistantID, req.ThreadID)
+	assistant, err := loadInternalAssistant(assistantID)
+	if err != nil {
+		sendEvent(c, "error", streamEvent{Type: "assistant_error", Data: err.Error()})
+		sendEvent(c, "done", streamEvent{Type: "done"})
+		return
+	}
+
+	if err := svc_openai.CheckBudget(ctx, user.ProfileID, assistant.ID); err != nil {
+		sendEvent(c, "error", streamEvent{Type: "budget_exceeded", Data: err.Error()})
+		sendEvent(c, "done", streamEvent{Type: "done"})
+		return
+	}
+
+	thread, err := resolveInternalAssistantThread(user.ProfileID, assistantID, req.ThreadID)
 	if err != nil {
 		sendEvent(c, "error", streamEvent{Type: "thread_error", Data: err.Error()})
 		sendEvent(c, "done", streamEvent{Type: "done"})
@@ -161,10 +178,17 @@ func Stream(c *gin.Context) {
 	sendEvent(c, "thread", streamEvent{
 		Type: "thread",
 		Data: map[string]any{
-			"thread_id": threadID,
+			"thread_id": thread.ThreadID,
 		},
 	})
 
+	input, err := threadInput(provider, &thread, req.Message)
+	if err != nil {
+		sendEvent(c, "error", streamEvent{Type: "thread_error", Data: err.Error()})
+		sendEvent(c, "done", streamEvent{Type: "done"})
+		return
+	}
+
 	mcpURL, err := resolveMCPURL()
 	if err != nil {
 		sendEvent(c, "error", streamEvent{Type: "mcp_url_error", Data: err.Error()})
@@ -186,16 +210,26 @@ func Stream(c *gin.Context) {
 		return
 	}
 
+	saveThreadMessage(thread.ThreadID, "user", req.Message, "")
+
 	runInstructions := buildRunInstructions(req.Instructions, req.Context)
-	message, err := runAssistant(ctx, openaiClient, mcpClient, assistantID, threadID, req.Message, runInstructions, tools, func(eventType string, payload any) {
+	message, responseID, err := runAssistant(ctx, provider, mcpClient, user.ProfileID, assistant, thread.LastResponseID, input, runInstructions, tools, func(eventType string, payload any) {
 		sendEvent(c, eventType, payload)
 	})
+	if errors.Is(err, svc_openai.ErrBudgetExceeded) {
+		sendEvent(c, "error", streamEvent{Type: "budget_exceeded", Data: err.Error()})
+		sendEvent(c, "done", streamEvent{Type: "done"})
+		return
+	}
 	if err != nil {
 		sendEvent(c, "error", streamEvent{Type: "run_error", Data: err.Error()})
 		sendEvent(c, "done", streamEvent{Type: "done"})
 		return
 	}
 
+	saveThreadMessage(thread.ThreadID, "assistant", message, responseID)
+	_ = db.DB.Model(&thread).Update("last_response_id", responseID).Error
+
 	sendEvent(c, "message", streamEvent{Type: "final", Data: message})
 	sendEvent(c, "done", streamEvent{Type: "done"})
 }
@@ -228,14 +262,16 @@ func getInternalMCPKey(profileID uint) (string, error) {
 	}
 
 	if key.ID != 0 {
+		// struct update so plain_key goes through the encrypted serializer
 		err = db.DB.Model(&models.MCPApiKey{}).
 			Where("id = ?", key.ID).
-			Updates(map[string]any{
-				"key_hash":    keyHash,
-				"key_prefix":  keyPrefix,
-				"plain_key":   plainKey,
-				"is_active":   true,
-				"is_internal": true,
+			Select("key_hash", "key_prefix", "plain_key", "is_active", "is_internal").
+			Updates(models.MCPApiKey{
+				KeyHash:    keyHash,
+				KeyPrefix:  keyPrefix,
+				PlainKey:   plainKey,
+				IsActive:   true,
+				IsInternal: true,
 			}).Error
 		if err != nil {
 			return "", err
@@ -275,9 +311,9 @@ func sendEvent(c *gin.Context, event string, payload any) {
 	}
 }
 
-func resolveInternalAssistantThreadID(client openaiv1.Client, profileID uint, assistantID string, requestedThreadID string) (string, error) {
+func resolveInternalAssistantThread(profileID uint, assistantID string, requestedThreadID string) (models.InternalAssistantThread, error) {
 	if profileID == 0 || assistantID == "" {
-		return "", fmt.Errorf("profile id and assistant id are required")
+		return models.InternalAssistantThread{}, fmt.Errorf("profile id and assistant id are required")
 	}
 
 	requestedThreadID = strings.TrimSpace(requestedThreadID)
@@ -288,29 +324,149 @@ func resolveInternalAssistantThreadID(client openaiv1.Client, profileID uint, as
 			First(&existing).Error
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return "", fmt.Errorf("thread not found")
+				return existing, fmt.Errorf("thread not found")
 			}
-			return "", err
+			return existing, err
 		}
 		_ = db.DB.Model(&existing).Update("updated_at", time.Now()).Error
-		return existing.ThreadID, nil
-	}
-
-	created, err := client.ThreadsCreate(openaiv1.ThreadRequest{})
-	if err != nil {
-		return "", err
+		return existing, nil
 	}
 
 	newThread := models.InternalAssistantThread{
 		ProfileID:   profileID,
 		AssistantID: assistantID,
-		ThreadID:    created.ID,
+		ThreadID:    "conv_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
 	}
 	if err := db.DB.Create(&newThread).Error; err != nil {
-		return "", err
+		return newThread, err
+	}
+
+	return newThread, nil
+}
+
+// threadInput is the input of the next response in the thread. On a
+// stateful provider a thread continues from its last response, threads
+// carried over from the Assistants API have none and start with their
+// imported history. Stateless providers get the whole history every time.
+func threadInput(provider llm.Provider, thread *models.InternalAssistantThread, message string) ([]llm.Message, error) {
+	input := []llm.Message{}
+	if thread.LastResponseID == "" || !provider.Stateful() {
+		if err := importLegacyThread(thread); err != nil {
+			return nil, err
+		}
+
+		var history []models.InternalAssistantMessage
+		err := db.DB.Where("thread_id = ?", thread.ThreadID).Order("id asc").Find(&history).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range history {
+			input = append(input, llm.Message{Role: msg.Role, Content: msg.Content})
+		}
+	}
+
+	return append(input, llm.Message{Role: "user", Content: message}), nil
+}
+
+// importLegacyThread copies the messages of an Assistants API thread into
+// the local history, once. The thread row is claimed before copying, so of
+// concurrent requests only one imports and the others wait for it.
+func importLegacyThread(thread *models.InternalAssistantThread) error {
+	if !strings.HasPrefix(thread.ThreadID, "thread_") || thread.ImportedAt != nil {
+		return nil
+	}
+
+	client, err := newOpenAIClient()
+	if err != nil {
+		return err
+	}
+	messages, err := client.ThreadsMessagesList(thread.ThreadID, 100)
+	if err != nil {
+		return fmt.Errorf("import thread %s: %w", thread.ThreadID, err)
+	}
+
+	now := time.Now()
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.InternalAssistantThread{}).
+			Where("id = ? AND imported_at IS NULL", thread.ID).
+			Update("imported_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+
+		// the list is newest first
+		for i := len(messages.Data) - 1; i >= 0; i-- {
+			msg := messages.Data[i]
+			text := threadMessageText(msg)
+			if text == "" {
+				continue
+			}
+			record := models.InternalAssistantMessage{
+				ThreadID: thread.ThreadID,
+				Role:     msg.Role,
+				Content:  text,
+			}
+			record.CreatedAt = time.Unix(msg.CreatedAt, 0)
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		thread.ImportedAt = &now
+	}
+	return err
+}
+
+func saveThreadMessage(threadID, role, content, responseID string) {
+	err := db.DB.Create(&models.InternalAssistantMessage{
+		ThreadID:   threadID,
+		Role:       role,
+		Content:    content,
+		ResponseID: responseID,
+	}).Error
+	if err != nil {
+		log.Printf("internal assistant: save message of thread %s: %s", threadID, err.Error())
+	}
+}
+
+// loadInternalAssistant returns the local config of an internal assistant.
+// Configured ids are either local assistant ids or OpenAI assistant ids,
+// which are imported into a local config the first time they are used.
+func loadInternalAssistant(assistantID string) (models.OpenAIAssistant, error) {
+	var assistant models.OpenAIAssistant
+
+	if id, err := strconv.ParseUint(assistantID, 10, 64); err == nil {
+		err = db.DB.Where("profile_id = ? AND id = ?", 0, id).First(&assistant).Error
+		if err != nil {
+			return assistant, fmt.Errorf("assistant %s not found", assistantID)
+		}
+		return assistant, nil
+	}
+
+	err := db.DB.Where("profile_id = ? AND assistant_id = ?", 0, assistantID).First(&assistant).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return assistant, err
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		assistant = models.OpenAIAssistant{AssistantID: assistantID}
+	}
+	if assistant.ImportedAt != nil {
+		return assistant, nil
 	}
 
-	return created.ID, nil
+	client, err := newOpenAIClient()
+	if err != nil {
+		return assistant, err
+	}
+	if err := svc_openai.ImportLegacyAssistant(client, &assistant); err != nil {
+		return assistant, err
+	}
+	return assistant, nil
 }
 
 func ListThreads(c *gin.Context) {
@@ -370,26 +526,30 @@ func ListThreadMessages(c *gin.Context) {
 		limit = parsed
 	}
 
-	openaiClient, err := newOpenAIClient()
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "openai client error"})
+	if err := importLegacyThread(&thread); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to load messages"})
 		return
 	}
 
-	messages, err := openaiClient.ThreadsMessagesList(threadID, limit)
-	if err != nil {
+	// the latest messages, oldest first
+	query := db.DB.Where("thread_id = ?", thread.ThreadID).Order("id desc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var messages []models.InternalAssistantMessage
+	if err := query.Find(&messages).Error; err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to load messages"})
 		return
 	}
 
-	resp := make([]threadMessageResponse, 0, len(messages.Data))
-	for i := len(messages.Data) - 1; i >= 0; i-- {
-		msg := messages.Data[i]
+	resp := make([]threadMessageResponse, 0, len(messages))
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
 		resp = append(resp, threadMessageResponse{
-			ID:        msg.ID,
+			ID:        strconv.FormatUint(uint64(msg.ID), 10),
 			Role:      msg.Role,
-			Content:   threadMessageText(msg),
-			CreatedAt: msg.CreatedAt,
+			Content:   msg.Content,
+			CreatedAt: msg.CreatedAt.Unix(),
 		})
 	}
 
@@ -412,6 +572,18 @@ func newOpenAIClient() (openaiv1.Client, error) {
 	return service.NewClient("")
 }
 
+// newProvider returns the provider internal assistants run on
+func newProvider() (llm.Provider, error) {
+	settings := config.Confs.Settings
+	return llm.New(llm.Settings{
+		Provider:     settings.AIProvider,
+		APIKey:       settings.OpenAIInternalAPIKey,
+		BaseURL:      settings.OpenAIBaseURL,
+		Organization: settings.OpenAIOrganization,
+		Project:      settings.OpenAIProject,
+	})
+}
+
 func resolveMCPURL() (string, error) {
 	raw := strings.TrimSpace(config.Confs.Settings.MCPURL)
 	if raw == "" {
@@ -502,117 +674,100 @@ func formatContext(items []contextItem) string {
 	return strings.Join(lines, "\n")
 }
 
+// maxToolRounds bounds the responses created for one message, each round
+// answers the function calls of the previous response
+const maxToolRounds = 20
+
+// runAssistant sends the input to the provider and executes the MCP tool
+// calls until the model answers. Text deltas are streamed through onEvent.
+// Returns the answer and, on a stateful provider, the id of the last
+// response, which continues the conversation.
 func runAssistant(
 	ctx context.Context,
-	client openaiv1.Client,
+	provider llm.Provider,
 	mcpClient *mcpclient.Client,
-	assistantID string,
-	threadID string,
-	message string,
+	profileID uint,
+	assistant models.OpenAIAssistant,
+	previousResponseID string,
+	input []llm.Message,
 	instructions string,
 	tools []mcptypes.Tool,
 	onEvent func(eventType string, payload any),
-) (string, error) {
-	openaiTools, err := toOpenAITools(tools)
+) (string, string, error) {
+	// functions are served by the MCP server, the configured function tools
+	// are left out
+	functionTools, err := toLLMTools(tools)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-
-	threadID = strings.TrimSpace(threadID)
-	if threadID == "" {
-		thread, err := client.ThreadsCreate(openaiv1.ThreadRequest{})
-		if err != nil {
-			return "", err
-		}
-		threadID = thread.ID
-	}
-
-	_, err = client.ThreadsMessagesCreate(threadID, openaiv1.ThreadMessageRequest{
-		Role:    "user",
-		Content: message,
-	})
+	hostedTools, err := svc_openai.HostedTools(assistant)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	run, err := client.RunsCreate(threadID, openaiv1.RunRequest{
-		AssistantID:  assistantID,
-		Instructions: instructions,
-		Tools:        openaiTools,
-	})
-	if err != nil {
-		return "", err
-	}
+	instructions = strings.TrimSpace(strings.Join([]string{assistant.Instructions, instructions}, "\n\n"))
 
-	run, err = waitForRun(ctx, client, mcpClient, threadID, run, onEvent)
-	if err != nil {
-		return "", err
-	}
+	conversation := llm.Conversation{Provider: provider, PreviousResponseID: previousResponseID}
+	conversation.Add(input...)
 
-	messages, err := client.ThreadsMessagesList(threadID, 20)
-	if err != nil {
-		return "", err
-	}
+	for round := 0; round < maxToolRounds; round++ {
+		if err := ctx.Err(); err != nil {
+			return "", "", err
+		}
+		if err := svc_openai.CheckBudget(ctx, profileID, assistant.ID); err != nil {
+			return "", conversation.PreviousResponseID, err
+		}
 
-	return firstAssistantMessage(messages)
-}
+		resp, err := provider.Respond(ctx, conversation.Request(llm.Request{
+			Model:        assistant.GptModel,
+			Instructions: instructions,
+			Tools:        functionTools,
+			HostedTools:  hostedTools,
+			Temperature:  assistant.Temperature,
+		}), func(delta string) {
+			onEvent("delta", streamEvent{Type: "delta", Data: delta})
+		})
+		if err != nil {
+			return "", "", err
+		}
+		conversation.Done(resp)
 
-func waitForRun(
-	ctx context.Context,
-	client openaiv1.Client,
-	mcpClient *mcpclient.Client,
-	threadID string,
-	run openaiv1.Run,
-	onEvent func(eventType string, payload any),
-) (openaiv1.Run, error) {
-	for i := 0; i < 60; i++ {
-		switch run.Status {
-		case "completed":
-			return run, nil
-		case "failed", "cancelled", "expired":
-			return run, fmt.Errorf("run %s", run.Status)
-		case "requires_action":
-			if run.RequiredAction == nil || run.RequiredAction.Type != "submit_tool_outputs" || run.RequiredAction.SubmitToolOutputs == nil {
-				return run, fmt.Errorf("unsupported required_action")
-			}
-			outputs, err := handleToolCalls(ctx, mcpClient, run.RequiredAction.SubmitToolOutputs.ToolCalls, onEvent)
-			if err != nil {
-				return run, err
-			}
-			run, err = client.RunsSubmitToolOutputs(threadID, run.ID, openaiv1.RunSubmitToolOutputsRequest{
-				ToolOutputs: outputs,
-			})
-			if err != nil {
-				return run, err
-			}
-		default:
-			time.Sleep(2 * time.Second)
-			updated, err := client.RunsGet(threadID, run.ID)
-			if err != nil {
-				return run, err
+		modelName := resp.Model
+		if modelName == "" {
+			modelName = assistant.GptModel
+		}
+		if err := svc_openai.RecordAIUsage(ctx, provider.Name(), profileID, assistant.ID, modelName, resp.Usage); err != nil {
+			log.Printf("internal assistant: record usage of profile %d: %s", profileID, err.Error())
+		}
+
+		if len(resp.ToolCalls) == 0 {
+			if resp.Text == "" {
+				return "", conversation.PreviousResponseID, fmt.Errorf("assistant message not found")
 			}
-			run = updated
+			return resp.Text, conversation.PreviousResponseID, nil
 		}
+
+		outputs, err := handleToolCalls(ctx, mcpClient, resp.ToolCalls, onEvent)
+		if err != nil {
+			return "", conversation.PreviousResponseID, err
+		}
+		conversation.Add(outputs...)
 	}
 
-	return run, fmt.Errorf("run timed out")
+	return "", conversation.PreviousResponseID, fmt.Errorf("run exceeded %d tool rounds", maxToolRounds)
 }
 
 func handleToolCalls(
 	ctx context.Context,
 	mcpClient *mcpclient.Client,
-	toolCalls []openaiv1.RunToolCall,
+	toolCalls []llm.ToolCall,
 	onEvent func(eventType string, payload any),
-) ([]openaiv1.RunToolOutput, error) {
-	outputs := make([]openaiv1.RunToolOutput, 0, len(toolCalls))
+) ([]llm.Message, error) {
+	outputs := make([]llm.Message, 0, len(toolCalls))
 	for _, call := range toolCalls {
-		if call.Type != "function" || call.Function == nil {
-			return nil, fmt.Errorf("unsupported tool call")
-		}
-
 		args := map[string]any{}
-		if strings.TrimSpace(call.Function.Arguments) != "" {
-			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+		if strings.TrimSpace(call.Arguments) != "" {
+			if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
 				return nil, fmt.Errorf("invalid tool arguments: %w", err)
 			}
 		}
@@ -620,21 +775,22 @@ func handleToolCalls(
 		onEvent("tool_call", streamEvent{
 			Type: "tool_call",
 			Data: map[string]any{
-				"name":      call.Function.Name,
+				"name":      call.Name,
 				"arguments": args,
 			},
 		})
 
 		result, err := mcpClient.CallTool(ctx, mcptypes.CallToolRequest{
 			Params: mcptypes.CallToolParams{
-				Name:      call.Function.Name,
+				Name:      call.Name,
 				Arguments: args,
 			},
 		})
 		if err != nil {
-			outputs = append(outputs, openaiv1.RunToolOutput{
+			outputs = append(outputs, llm.Message{
+				Role:       "tool",
 				ToolCallID: call.ID,
-				Output:     fmt.Sprintf(`{"error":"%s"}`, escapeJSON(err.Error())),
+				Content:    fmt.Sprintf(`{"error":"%s"}`, escapeJSON(err.Error())),
 			})
 			continue
 		}
@@ -644,9 +800,10 @@ func handleToolCalls(
 			return nil, err
 		}
 
-		outputs = append(outputs, openaiv1.RunToolOutput{
+		outputs = append(outputs, llm.Message{
+			Role:       "tool",
 			ToolCallID: call.ID,
-			Output:     payload,
+			Content:    payload,
 		})
 	}
 
@@ -681,23 +838,20 @@ func threadMessageText(msg openaiv1.ThreadMessageResponse) string {
 	return strings.Join(parts, "\n")
 }
 
-func toOpenAITools(tools []mcptypes.Tool) ([]openaiv1.Tool, error) {
-	openaiTools := make([]openaiv1.Tool, 0, len(tools))
+func toLLMTools(tools []mcptypes.Tool) ([]llm.Tool, error) {
+	llmTools := make([]llm.Tool, 0, len(tools))
 	for _, tool := range tools {
 		schema, err := toolSchema(tool)
 		if err != nil {
 			return nil, err
 		}
-		openaiTools = append(openaiTools, openaiv1.Tool{
-			Type: "function",
-			Function: &openaiv1.ToolFunction{
-				Name:        tool.Name,
-				Description: tool.Description,
-				Parameters:  schema,
-			},
+		llmTools = append(llmTools, llm.Tool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  schema,
 		})
 	}
-	return openaiTools, nil
+	return llmTools, nil
 }
 
 func toolSchema(tool mcptypes.Tool) (map[string]interface{}, error) {
@@ -737,20 +891,6 @@ func ensureObjectSchemaProperties(schema map[string]interface{}) {
 	schema["properties"] = map[string]interface{}{}
 }
 
-func firstAssistantMessage(messages openaiv1.ThreadMessagesListResponse) (string, error) {
-	for _, msg := range messages.Data {
-		if msg.Role != "assistant" {
-			continue
-		}
-		for _, item := range msg.Content {
-			if item.Type == "text" && item.Text != nil {
-				return item.Text.Value, nil
-			}
-		}
-	}
-	return "", fmt.Errorf("assistant message not found")
-}
-
 func escapeJSON(input string) string {
 	encoded, _ := json.Marshal(input)
 	escaped := string(encoded)
@@ -762,7 +902,7 @@ func escapeJSON(input string) string {
 
 // RunSubAssistant runs a sub-assistant with MCP tools and returns the response.
 // This is used by the orchestrator to delegate to builder/validator/helper assistants.
-func RunSubAssistant(ctx context.Context, mcpAPIKey string, assistantID string, message string) (string, error) {
+func RunSubAssistant(ctx context.Context, profileID uint, mcpAPIKey string, assistantID string, message string) (string, error) {
 	if assistantID == "" {
 		return "", fmt.Errorf("assistant ID is required")
 	}
@@ -770,10 +910,9 @@ func RunSubAssistant(ctx context.Context, mcpAPIKey string, assistantID string,
 		return "", fmt.Errorf("message is required")
 	}
 
-	// Create OpenAI client
-	openaiClient, err := newOpenAIClient()
+	provider, err := newProvider()
 	if err != nil {
-		return "", fmt.Errorf("failed to create OpenAI client: %v", err)
+		return "", fmt.Errorf("failed to create AI provider: %v", err)
 	}
 
 	// Resolve MCP URL
@@ -794,14 +933,20 @@ func RunSubAssistant(ctx context.Context, mcpAPIKey string, assistantID string,
 		return "", fmt.Errorf("failed to list MCP tools: %v", err)
 	}
 
-	// Run the assistant (creates new thread, no events callback)
-	response, err := runAssistant(
+	assistant, err := loadInternalAssistant(assistantID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load assistant: %v", err)
+	}
+
+	// Run the assistant (no conversation to continue, no events callback)
+	response, _, err := runAssistant(
 		ctx,
-		openaiClient,
+		provider,
 		mcpClient,
-		assistantID,
-		"", // empty threadID = create new thread
-		message,
+		profileID,
+		assistant,
+		"", // no previous response
+		[]llm.Message{{Role: "user", Content: message}},
 		"", // no additional instructions
 		tools,
 		func(eventType string, payload any) {}, // no-op event handler
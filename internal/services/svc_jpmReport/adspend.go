@@ -1,47 +1,22 @@
 package svc_jpmreport
 
 import (
-	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
-	"client-runaway-zenoti/packages/googleads"
-	"client-runaway-zenoti/packages/meta"
-	"context"
-	"strconv"
+	"client-runaway-zenoti/internal/services/svc_adspend"
+	"fmt"
 	"time"
 )
 
-const (
-	googleAdsId = "3082004096"
-	metaAdsId   = "act_552470297607717"
-)
-
-func getAdSpends(loc models.Location, startDate, endDate time.Time) (adwords float64, meta float64, err error) {
-	googleadsSpend, err := googleads.GetAdSpendGoPkg(context.Background(), googleAdsId, startDate, endDate)
-
-	metaSpend, err := getMetaAdSpend(startDate, endDate)
-	if err != nil {
-		return 0, 0, err
-	}
-
-	return googleadsSpend, metaSpend, nil
-}
-
-func getMetaAdSpend(startDate, endDate time.Time) (float64, error) {
-	token := models.Setting{}
-	err := db.DB.First(&token, "key = ?", "meta_token").Error
-	if err != nil {
-		return 0, err
-	}
-
-	spend, err := meta.GetMetaAdSpend(token.Value, metaAdsId, "2025-09-01", "2025-09-30")
-	if err != nil {
-		return 0, err
-	}
-
-	spendFloat, err := strconv.ParseFloat(spend, 64)
+// getAdSpends returns the Google Ads and Meta spend ingested for the
+// location. A location without an account has no spend on it.
+func getAdSpends(loc models.Location, startDate, endDate time.Time) (adSpend, error) {
+	spends, err := svc_adspend.SpendByNetwork(loc.Id, startDate, endDate)
 	if err != nil {
-		return 0, err
+		return adSpend{}, fmt.Errorf("ad spend of %s: %w", loc.Name, err)
 	}
 
-	return spendFloat, nil
+	return adSpend{
+		adwords: spends[models.AdNetworkGoogle],
+		meta:    spends[models.AdNetworkMeta],
+	}, nil
 }
@@ -0,0 +1,184 @@
+package svc_jpmreport
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/auth"
+	"client-runaway-zenoti/internal/services/svc_audit"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type reportDefinitionPayload struct {
+	Name            string   `json:"name" binding:"required"`
+	LocationIds     []string `json:"locationIds" binding:"required"`
+	GroupBy         string   `json:"groupBy"`
+	GoogleAdsSource string   `json:"googleAdsSource"`
+	MetaSource      string   `json:"metaSource"`
+}
+
+func ListReportDefinitions(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	defs := []models.ReportDefinition{}
+
+	err := db.DB.Where("profile_id = ?", user.ProfileID).Order("id").Find(&defs).Error
+	if err != nil {
+		lvn.GinErr(c, 500, err, "Unable to get report definitions")
+		return
+	}
+
+	// location-scoped members only see the reports of their locations
+	defs = slices.DeleteFunc(defs, func(def models.ReportDefinition) bool {
+		return !auth.CanSeeLocations(c, def.LocationIds)
+	})
+
+	c.Data(lvn.Res(200, defs, ""))
+}
+
+func CreateReportDefinition(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	payload := reportDefinitionPayload{}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		lvn.GinErr(c, 400, err, "Unable to bind JSON")
+		return
+	}
+
+	def := models.ReportDefinition{ProfileId: user.ProfileID}
+	if !auth.CanSeeLocations(c, payload.LocationIds) {
+		c.Data(lvn.Res(403, nil, "No access to some of the locations"))
+		return
+	}
+	if err := applyDefinitionPayload(&def, payload); err != nil {
+		c.Data(lvn.Res(400, nil, err.Error()))
+		return
+	}
+
+	if err := db.DB.Create(&def).Error; err != nil {
+		lvn.GinErr(c, 500, err, "Unable to create report definition")
+		return
+	}
+	svc_audit.Record(c, models.AuditCreate, "report_definition", strconv.FormatUint(uint64(def.ID), 10), "", nil, def)
+
+	c.Data(lvn.Res(200, def, ""))
+}
+
+func UpdateReportDefinition(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	payload := reportDefinitionPayload{}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		lvn.GinErr(c, 400, err, "Unable to bind JSON")
+		return
+	}
+
+	def, ok := loadReportDefinition(c, user.ProfileID)
+	if !ok {
+		return
+	}
+	before := def
+
+	if !auth.CanSeeLocations(c, payload.LocationIds) {
+		c.Data(lvn.Res(403, nil, "No access to some of the locations"))
+		return
+	}
+	if err := applyDefinitionPayload(&def, payload); err != nil {
+		c.Data(lvn.Res(400, nil, err.Error()))
+		return
+	}
+
+	if err := db.DB.Save(&def).Error; err != nil {
+		lvn.GinErr(c, 500, err, "Unable to update report definition")
+		return
+	}
+	svc_audit.Record(c, models.AuditUpdate, "report_definition", strconv.FormatUint(uint64(def.ID), 10), "", before, def)
+
+	c.Data(lvn.Res(200, def, ""))
+}
+
+func DeleteReportDefinition(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	def, ok := loadReportDefinition(c, user.ProfileID)
+	if !ok {
+		return
+	}
+
+	// subscriptions to the report have nothing left to send
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.ReportSubscription{}).
+			Where("report_type = ? AND report_definition_id = ?", models.ReportTypeFunnel, def.ID).
+			Update("enabled", false).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&def).Error
+	})
+	if err != nil {
+		lvn.GinErr(c, 500, err, "Unable to delete report definition")
+		return
+	}
+	svc_audit.Record(c, models.AuditDelete, "report_definition", strconv.FormatUint(uint64(def.ID), 10), "", def, nil)
+
+	c.Data(lvn.Res(200, "", ""))
+}
+
+func loadReportDefinition(c *gin.Context, profileID uint) (models.ReportDefinition, bool) {
+	def := models.ReportDefinition{}
+	err := db.DB.Where("id = ? AND profile_id = ?", c.Param("reportId"), profileID).First(&def).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Data(lvn.Res(404, nil, "Report not found"))
+		return def, false
+	}
+	if err != nil {
+		lvn.GinErr(c, 500, err, "Unable to get report definition")
+		return def, false
+	}
+	if !auth.CanSeeLocations(c, def.LocationIds) {
+		c.Data(lvn.Res(403, nil, "No access to some of the report's locations"))
+		return def, false
+	}
+	return def, true
+}
+
+// applyDefinitionPayload validates the payload against the definition's
+// profile and copies it over
+func applyDefinitionPayload(def *models.ReportDefinition, payload reportDefinitionPayload) error {
+	if len(payload.LocationIds) == 0 {
+		return errors.New("at least one location is required")
+	}
+
+	groupBy := lvn.Ternary(payload.GroupBy == "", models.ReportGroupByLocation, payload.GroupBy)
+	if groupBy != models.ReportGroupByLocation && groupBy != models.ReportGroupByTotal {
+		return fmt.Errorf("groupBy must be %s or %s", models.ReportGroupByLocation, models.ReportGroupByTotal)
+	}
+
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, id := range payload.LocationIds {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	var count int64
+	err := db.DB.Model(&models.Location{}).Where("profile_id = ? AND id IN ?", def.ProfileId, ids).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if int(count) != len(ids) {
+		return errors.New("some locations are not in this profile")
+	}
+
+	def.Name = payload.Name
+	def.LocationIds = ids
+	def.GroupBy = groupBy
+	def.GoogleAdsSource = lvn.Ternary(payload.GoogleAdsSource == "", defaultGoogleAdsSource, payload.GoogleAdsSource)
+	def.MetaSource = lvn.Ternary(payload.MetaSource == "", defaultMetaSource, payload.MetaSource)
+	return nil
+}
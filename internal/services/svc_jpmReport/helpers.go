@@ -1,11 +1,17 @@
 package svc_jpmreport
 
 import (
+	"client-runaway-zenoti/internal/db/models"
 	"math"
 
 	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
 )
 
+const (
+	defaultGoogleAdsSource = "Paid Search"
+	defaultMetaSource      = "Facebook paid"
+)
+
 func calculateTotals(data []ReportData) []ReportData {
 	total := ReportData{}
 	total.SourceBreakdown = make(map[string]SourceBreakdown)
@@ -63,6 +69,7 @@ func calculateTotals(data []ReportData) []ReportData {
 
 			sb.Leads += v.Leads
 			sb.Source = v.Source
+			sb.AdSpend += v.AdSpend
 			sb.Consultations += v.Consultations
 			sb.Revenue += v.Revenue
 			total.SourceBreakdown[k] = sb
@@ -74,55 +81,39 @@ func calculateTotals(data []ReportData) []ReportData {
 	return data
 }
 
-func distributeAdSpends(data []ReportData) []ReportData {
-
-	metaCostPerLead := float64(0)
-	adwordsCostPerLead := float64(0)
-
-	for i, d := range data {
-		if d.Label == "Total" {
-
-			sb, ok := d.SourceBreakdown["Paid Search"]
-			if !ok {
-				sb = SourceBreakdown{}
-			}
-			if sb.Leads > 0 {
-				adwordsCostPerLead = adwordsAdSpend / float64(sb.Leads)
-			}
-			sb = fillBreakdown(sb, adwordsAdSpend)
-			data[i].SourceBreakdown["Paid Search"] = sb
+// distributeAdSpends credits the spend of each location's ad accounts to
+// the lead sources of the definition. An account shared by locations is
+// already stored as an even share under each of them.
+func distributeAdSpends(data []ReportData, spends []adSpend, def models.ReportDefinition) []ReportData {
+	googleSource := lvn.Ternary(def.GoogleAdsSource == "", defaultGoogleAdsSource, def.GoogleAdsSource)
+	metaSource := lvn.Ternary(def.MetaSource == "", defaultMetaSource, def.MetaSource)
 
-			sb, ok = d.SourceBreakdown["Facebook paid"]
+	for i := range data {
+		if data[i].SourceBreakdown == nil {
+			data[i].SourceBreakdown = make(map[string]SourceBreakdown)
+		}
+		for source, spend := range map[string]float64{googleSource: spends[i].adwords, metaSource: spends[i].meta} {
+			sb, ok := data[i].SourceBreakdown[source]
 			if !ok {
-				sb = SourceBreakdown{}
-			}
-			sb = fillBreakdown(sb, metaAdSpend)
-			if sb.Leads > 0 {
-				metaCostPerLead = metaAdSpend / float64(sb.Leads)
+				sb = SourceBreakdown{Source: source}
 			}
-			data[i].SourceBreakdown["Facebook paid"] = sb
+			data[i].SourceBreakdown[source] = fillBreakdown(sb, spend)
 		}
 	}
 
+	return data
+}
+
+// fillTotalAdSpends recalculates the cost ratios of the total from its
+// summed spend
+func fillTotalAdSpends(data []ReportData) []ReportData {
 	for i, d := range data {
-		if d.Label == "Total" {
+		if d.Label != "Total" {
 			continue
 		}
-
-		sb, ok := d.SourceBreakdown["Paid Search"]
-		if !ok {
-			sb = SourceBreakdown{}
+		for source, sb := range d.SourceBreakdown {
+			data[i].SourceBreakdown[source] = fillBreakdown(sb, sb.AdSpend)
 		}
-		sb = fillBreakdown(sb, adwordsCostPerLead*float64(sb.Leads))
-		data[i].SourceBreakdown["Paid Search"] = sb
-
-		sb, ok = d.SourceBreakdown["Facebook paid"]
-		if !ok {
-			sb = SourceBreakdown{}
-		}
-		sb = fillBreakdown(sb, metaCostPerLead*float64(sb.Leads))
-		data[i].SourceBreakdown["Facebook paid"] = sb
-
 	}
 
 	return data
@@ -1,13 +1,18 @@
 package svc_jpmreport
 
 import (
+	"client-runaway-zenoti/internal/config"
+	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
+	"crypto/subtle"
+	"errors"
 	"math"
 	"sync"
 	"time"
 
 	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type (
@@ -62,6 +67,11 @@ type (
 		Appointments    []LeadAppointment
 	}
 
+	adSpend struct {
+		adwords float64
+		meta    float64
+	}
+
 	LeadAppointment struct {
 		Date   time.Time
 		Status string
@@ -70,55 +80,152 @@ type (
 	}
 )
 
-var (
-	metaAdSpend    float64
-	adwordsAdSpend float64
-	locations      = []string{"Young Medical Spa", "Young Medical Spa - Lansdale", "Young Medical Spa - Wilkes-Barre/Scranton"}
-)
+// GetFunnelReport builds the report of a definition for
+// ?start=YYYY-MM-DD&end=YYYY-MM-DD, both inclusive
+func GetFunnelReport(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	startDate, endDate, ok := reportRange(c)
+	if !ok {
+		return
+	}
+
+	def, ok := loadReportDefinition(c, user.ProfileID)
+	if !ok {
+		return
+	}
+
+	res, err := BuildReport(def, startDate, endDate)
+	if err != nil {
+		lvn.GinErr(c, 500, err, "Unable to get report for locations")
+		return
+	}
+
+	c.JSON(200, res)
+}
+
+// GetLegacyJpmReport serves the JPM definition at the old /jpm/report
+// route until its consumer moves to /reports/funnel. It takes the same
+// range as GetFunnelReport and ?token= matching the JpmReportToken
+// setting; without the setting the route is off.
+func GetLegacyJpmReport(c *gin.Context) {
+	token := config.Confs.Settings.JpmReportToken
+	if token == "" || subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(token)) != 1 {
+		c.Data(lvn.Res(401, nil, "Unauthorized"))
+		return
+	}
+
+	startDate, endDate, ok := reportRange(c)
+	if !ok {
+		return
+	}
+
+	def := models.ReportDefinition{}
+	err := db.DB.Where("name = ?", "JPM").Order("id").First(&def).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Data(lvn.Res(404, nil, "Report not found"))
+		return
+	}
+	if err != nil {
+		lvn.GinErr(c, 500, err, "Unable to get report definition")
+		return
+	}
 
-func GetReport(c *gin.Context) {
-	// collect params
+	res, err := BuildReport(def, startDate, endDate)
+	if err != nil {
+		lvn.GinErr(c, 500, err, "Unable to get report for locations")
+		return
+	}
+
+	c.JSON(200, res)
+}
+
+// reportRange parses ?start=YYYY-MM-DD&end=YYYY-MM-DD, the end inclusive
+func reportRange(c *gin.Context) (time.Time, time.Time, bool) {
 	startDateString := c.Query("start")
 	endDateString := c.Query("end")
 
-	if startDateString == "" && endDateString == "" {
+	if startDateString == "" || endDateString == "" {
 		c.Data(lvn.Res(400, "Both start and end are required", "Bad request"))
-		return
+		return time.Time{}, time.Time{}, false
 	}
 
 	startDate, err := time.Parse("2006-01-02", startDateString)
-	lvn.GinErr(c, 400, err, "Invalid start")
+	if err != nil {
+		lvn.GinErr(c, 400, err, "Invalid start")
+		return time.Time{}, time.Time{}, false
+	}
 	endDate, err := time.Parse("2006-01-02", endDateString)
-	lvn.GinErr(c, 400, err, "Invalid end")
-	endDate = endDate.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+	if err != nil {
+		lvn.GinErr(c, 400, err, "Invalid end")
+		return time.Time{}, time.Time{}, false
+	}
+	return startDate, endDate.Add(23*time.Hour + 59*time.Minute + 59*time.Second), true
+}
 
-	// build data
-	res := []ReportData{}
+// BuildReport computes the locations of the definition concurrently and
+// adds their total in front
+func BuildReport(def models.ReportDefinition, startDate, endDate time.Time) ([]ReportData, error) {
+	locations, err := definitionLocations(def)
+	if err != nil {
+		return nil, err
+	}
+
+	// every goroutine writes only its own index
+	res := make([]ReportData, len(locations))
+	spends := make([]adSpend, len(locations))
+	errs := make([]error, len(locations))
 	wg := sync.WaitGroup{}
-	for _, locName := range locations {
-		loc := models.Location{}
-		models.DB.Where("name = ?", locName).First(&loc)
+	for i, loc := range locations {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			_, err = getReportForLocation(loc, startDate, endDate, &res)
+			res[i], errs[i] = getReportForLocation(loc, startDate, endDate)
+			if errs[i] != nil {
+				return
+			}
+			spends[i], errs[i] = getAdSpends(loc, startDate, endDate)
 		}()
 	}
 	wg.Wait()
-	if err != nil {
-		lvn.GinErr(c, 500, err, "Unable to get report for locations")
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
 
+	res = distributeAdSpends(res, spends, def)
 	res = calculateTotals(res)
+	res = fillTotalAdSpends(res)
+
+	if def.GroupBy == models.ReportGroupByTotal && len(res) > 0 {
+		res = res[:1]
+	}
+	return res, nil
+}
 
-	adwordsAdSpend, metaAdSpend, err = getAdSpends(models.Location{}, startDate, endDate)
-	lvn.GinErr(c, 500, err, "Unable to get ad spends")
-	res = distributeAdSpends(res)
+// definitionLocations loads the locations of the definition in its order,
+// skipping the ones no longer in the profile
+func definitionLocations(def models.ReportDefinition) ([]models.Location, error) {
+	var found []models.Location
+	err := db.DB.Where("profile_id = ? AND id IN ?", def.ProfileId, []string(def.LocationIds)).Find(&found).Error
+	if err != nil {
+		return nil, err
+	}
 
-	c.JSON(200, res)
+	byId := make(map[string]models.Location, len(found))
+	for _, l := range found {
+		byId[l.Id] = l
+	}
+	locations := make([]models.Location, 0, len(found))
+	for _, id := range def.LocationIds {
+		if l, ok := byId[id]; ok {
+			locations = append(locations, l)
+			delete(byId, id)
+		}
+	}
+	return locations, nil
 }
 
-func getReportForLocation(loc models.Location, startDate, endDate time.Time, results *[]ReportData) (ReportData, error) {
+func getReportForLocation(loc models.Location, startDate, endDate time.Time) (ReportData, error) {
 	res := ReportData{
 		Label: loc.Name,
 	}
@@ -149,6 +256,5 @@ func getReportForLocation(loc models.Location, startDate, endDate time.Time, res
 	res.SourceBreakdown = breakBySource(leadsData)
 	res.LeadRawData = leadsData
 
-	*results = append(*results, res)
 	return res, nil
 }
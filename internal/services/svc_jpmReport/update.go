@@ -15,19 +15,33 @@ import (
 	"gorm.io/gorm/clause"
 )
 
+// UpdateAllLocationsReportDataForLargePeriod syncs the leads and sales of
+// every location used by a report definition
 func UpdateAllLocationsReportDataForLargePeriod(start, end time.Time) error {
-	// get all locations
-	for _, lId := range locations {
-		loc := models.Location{}
-		err := models.DB.Where("name = ?", lId).First(&loc).Error
+	defs := []models.ReportDefinition{}
+	err := models.DB.Find(&defs).Error
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for _, def := range defs {
+		locations, err := definitionLocations(def)
 		if err != nil {
 			return err
 		}
 
-		err = UpdateReportDataForLargePeriod(start, end, loc)
-		if err != nil {
-			tgbot.Notify("jpm_report_errors", fmt.Sprintf("Error updating report data for location %s: %v", loc.Name, err), true)
-			continue
+		for _, loc := range locations {
+			if seen[loc.Id] {
+				continue
+			}
+			seen[loc.Id] = true
+
+			err = UpdateReportDataForLargePeriod(start, end, loc)
+			if err != nil {
+				tgbot.Notify("jpm_report_errors", fmt.Sprintf("Error updating report data for location %s: %v", loc.Name, err), true)
+				continue
+			}
 		}
 	}
 	return nil
@@ -3,6 +3,10 @@ package svc_mcp
 import (
 	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/automator"
+	"client-runaway-zenoti/internal/services/svc_audit"
+	"fmt"
+	"strconv"
 
 	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
 	"github.com/gin-gonic/gin"
@@ -12,6 +16,15 @@ import (
 type CreateAPIKeyRequest struct {
 	Name        string   `json:"name" binding:"required"`
 	LocationIDs []string `json:"locationIds" binding:"required"`
+	Tools       []string `json:"tools"`
+	APIKeyLimits
+}
+
+// APIKeyLimits are the per-key call limits, 0 means unlimited
+type APIKeyLimits struct {
+	RateLimitPerMinute int `json:"rateLimitPerMinute"`
+	RateLimitBurst     int `json:"rateLimitBurst"`
+	DailyQuota         int `json:"dailyQuota"`
 }
 
 // CreateAPIKeyResponse includes the plain key (shown only once)
@@ -29,12 +42,24 @@ type APIKeyListItem struct {
 	KeyPrefix   string   `json:"keyPrefix"`
 	IsActive    bool     `json:"isActive"`
 	LocationIDs []string `json:"locationIds"`
+	Tools       []string `json:"tools"`
+	APIKeyLimits
+	OAuthClientID string `json:"oauthClientId,omitempty"` // set on keys issued to an MCP client through OAuth
 }
 
 // UpdateAPIKeyRequest is the request body for updating an API key
 type UpdateAPIKeyRequest struct {
-	Name        *string  `json:"name"`
-	LocationIDs []string `json:"locationIds"`
+	Name        *string       `json:"name"`
+	LocationIDs []string      `json:"locationIds"`
+	Tools       []string      `json:"tools"`
+	Limits      *APIKeyLimits `json:"limits"`
+}
+
+// ActionToolItem describes an automator action that can be allowed on a key
+type ActionToolItem struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
 }
 
 // CreateAPIKey creates a new MCP API key with location access
@@ -47,6 +72,15 @@ func CreateAPIKey(c *gin.Context) {
 		return
 	}
 
+	if err := validateTools(req.Tools); err != nil {
+		lvn.GinErr(c, 400, err, err.Error())
+		return
+	}
+	if err := req.APIKeyLimits.validate(); err != nil {
+		lvn.GinErr(c, 400, err, err.Error())
+		return
+	}
+
 	// Generate API key
 	plainKey, keyHash, keyPrefix, err := models.GenerateMCPApiKey()
 	if err != nil {
@@ -56,11 +90,16 @@ func CreateAPIKey(c *gin.Context) {
 
 	// Create key record
 	apiKey := models.MCPApiKey{
-		Name:      req.Name,
-		KeyHash:   keyHash,
-		KeyPrefix: keyPrefix,
-		ProfileID: user.ProfileID,
-		IsActive:  true,
+		Name:         req.Name,
+		KeyHash:      keyHash,
+		KeyPrefix:    keyPrefix,
+		ProfileID:    user.ProfileID,
+		IsActive:     true,
+		AllowedTools: req.Tools,
+
+		RateLimitPerMinute: req.RateLimitPerMinute,
+		RateLimitBurst:     req.RateLimitBurst,
+		DailyQuota:         req.DailyQuota,
 	}
 
 	// Start transaction
@@ -95,6 +134,16 @@ func CreateAPIKey(c *gin.Context) {
 
 	tx.Commit()
 
+	svc_audit.Record(c, models.AuditCreate, "mcp_api_key", strconv.FormatUint(uint64(apiKey.ID), 10), "", nil, APIKeyListItem{
+		ID:           apiKey.ID,
+		Name:         apiKey.Name,
+		KeyPrefix:    apiKey.KeyPrefix,
+		IsActive:     apiKey.IsActive,
+		LocationIDs:  req.LocationIDs,
+		Tools:        req.Tools,
+		APIKeyLimits: req.APIKeyLimits,
+	})
+
 	// Return response with plain key (only time it's shown)
 	c.Data(lvn.Res(200, CreateAPIKeyResponse{
 		ID:        apiKey.ID,
@@ -122,13 +171,7 @@ func ListAPIKeys(c *gin.Context) {
 	// Map to response format
 	response := make([]APIKeyListItem, len(keys))
 	for i, key := range keys {
-		response[i] = APIKeyListItem{
-			ID:          key.ID,
-			Name:        key.Name,
-			KeyPrefix:   key.KeyPrefix,
-			IsActive:    key.IsActive,
-			LocationIDs: key.GetLocationIDs(),
-		}
+		response[i] = toListItem(key)
 	}
 
 	c.Data(lvn.Res(200, response, ""))
@@ -139,21 +182,20 @@ func RevokeAPIKey(c *gin.Context) {
 	user := c.MustGet("user").(models.User)
 	keyID := c.Param("keyId")
 
-	result := db.DB.
-		Model(&models.MCPApiKey{}).
-		Where("id = ? AND profile_id = ?", keyID, user.ProfileID).
-		Update("is_active", false)
-
-	if result.Error != nil {
-		lvn.GinErr(c, 500, result.Error, "Failed to revoke API key")
+	var apiKey models.MCPApiKey
+	if err := db.DB.Where("id = ? AND profile_id = ?", keyID, user.ProfileID).Preload("AllowedLocations").First(&apiKey).Error; err != nil {
+		lvn.GinErr(c, 404, err, "API key not found")
 		return
 	}
+	before := toListItem(apiKey)
 
-	if result.RowsAffected == 0 {
-		lvn.GinErr(c, 404, nil, "API key not found")
+	if err := db.DB.Model(&apiKey).Update("is_active", false).Error; err != nil {
+		lvn.GinErr(c, 500, err, "Failed to revoke API key")
 		return
 	}
 
+	svc_audit.Record(c, models.AuditUpdate, "mcp_api_key", keyID, "", before, toListItem(apiKey))
+
 	c.Data(lvn.Res(200, nil, "API key revoked"))
 }
 
@@ -168,12 +210,25 @@ func UpdateAPIKey(c *gin.Context) {
 		return
 	}
 
+	if err := validateTools(req.Tools); err != nil {
+		lvn.GinErr(c, 400, err, err.Error())
+		return
+	}
+	if req.Limits != nil {
+		if err := req.Limits.validate(); err != nil {
+			lvn.GinErr(c, 400, err, err.Error())
+			return
+		}
+	}
+
 	// Verify key belongs to user's profile
 	var apiKey models.MCPApiKey
-	if err := db.DB.Where("id = ? AND profile_id = ?", keyID, user.ProfileID).First(&apiKey).Error; err != nil {
+	if err := db.DB.Where("id = ? AND profile_id = ?", keyID, user.ProfileID).Preload("AllowedLocations").First(&apiKey).Error; err != nil {
 		lvn.GinErr(c, 404, err, "API key not found")
 		return
 	}
+	before := toListItem(apiKey)
+	after := before
 
 	// Start transaction
 	tx := db.DB.Begin()
@@ -181,7 +236,8 @@ func UpdateAPIKey(c *gin.Context) {
 	// Update name if provided
 	if req.Name != nil {
 		apiKey.Name = *req.Name
-		if err := tx.Save(&apiKey).Error; err != nil {
+		after.Name = apiKey.Name
+		if err := tx.Model(&apiKey).Update("name", apiKey.Name).Error; err != nil {
 			tx.Rollback()
 			lvn.GinErr(c, 500, err, "Failed to update API key")
 			return
@@ -217,10 +273,37 @@ func UpdateAPIKey(c *gin.Context) {
 				return
 			}
 		}
+		after.LocationIDs = req.LocationIDs
+	}
+
+	// Update tool allowlist if provided
+	if req.Tools != nil {
+		apiKey.AllowedTools = req.Tools
+		if err := tx.Model(&apiKey).Select("allowed_tools").Updates(&apiKey).Error; err != nil {
+			tx.Rollback()
+			lvn.GinErr(c, 500, err, "Failed to update tools")
+			return
+		}
+		after.Tools = req.Tools
+	}
+
+	// Update limits if provided
+	if req.Limits != nil {
+		apiKey.RateLimitPerMinute = req.Limits.RateLimitPerMinute
+		apiKey.RateLimitBurst = req.Limits.RateLimitBurst
+		apiKey.DailyQuota = req.Limits.DailyQuota
+		if err := tx.Model(&apiKey).Select("rate_limit_per_minute", "rate_limit_burst", "daily_quota").Updates(&apiKey).Error; err != nil {
+			tx.Rollback()
+			lvn.GinErr(c, 500, err, "Failed to update limits")
+			return
+		}
+		after.APIKeyLimits = *req.Limits
 	}
 
 	tx.Commit()
 
+	svc_audit.Record(c, models.AuditUpdate, "mcp_api_key", keyID, "", before, after)
+
 	c.Data(lvn.Res(200, nil, "API key updated"))
 }
 
@@ -236,6 +319,14 @@ func RegenerateAPIKey(c *gin.Context) {
 		return
 	}
 
+	before := toListItem(apiKey)
+
+	// OAuth clients get new tokens by signing in again
+	if apiKey.OAuthClientID != "" {
+		c.Data(lvn.Res(400, nil, "Keys issued through OAuth can't be regenerated"))
+		return
+	}
+
 	// Only allow regeneration of revoked keys
 	if apiKey.IsActive {
 		lvn.GinErr(c, 400, nil, "Cannot regenerate an active key. Revoke it first.")
@@ -259,6 +350,8 @@ func RegenerateAPIKey(c *gin.Context) {
 		return
 	}
 
+	svc_audit.Record(c, models.AuditUpdate, "mcp_api_key", keyID, "", before, toListItem(apiKey))
+
 	c.Data(lvn.Res(200, CreateAPIKeyResponse{
 		ID:        apiKey.ID,
 		Name:      apiKey.Name,
@@ -272,6 +365,12 @@ func DeleteAPIKey(c *gin.Context) {
 	user := c.MustGet("user").(models.User)
 	keyID := c.Param("keyId")
 
+	var apiKey models.MCPApiKey
+	if err := db.DB.Where("id = ? AND profile_id = ?", keyID, user.ProfileID).Preload("AllowedLocations").First(&apiKey).Error; err != nil {
+		lvn.GinErr(c, 404, err, "API key not found")
+		return
+	}
+
 	// Start transaction
 	tx := db.DB.Begin()
 
@@ -298,5 +397,56 @@ func DeleteAPIKey(c *gin.Context) {
 
 	tx.Commit()
 
+	svc_audit.Record(c, models.AuditDelete, "mcp_api_key", keyID, "", toListItem(apiKey), nil)
+
 	c.Data(lvn.Res(200, nil, "API key deleted"))
 }
+
+func toListItem(key models.MCPApiKey) APIKeyListItem {
+	return APIKeyListItem{
+		ID:          key.ID,
+		Name:        key.Name,
+		KeyPrefix:   key.KeyPrefix,
+		IsActive:    key.IsActive,
+		LocationIDs: key.GetLocationIDs(),
+		Tools:       key.AllowedTools,
+		APIKeyLimits: APIKeyLimits{
+			RateLimitPerMinute: key.RateLimitPerMinute,
+			RateLimitBurst:     key.RateLimitBurst,
+			DailyQuota:         key.DailyQuota,
+		},
+		OAuthClientID: key.OAuthClientID,
+	}
+}
+
+// ListActionTools returns the automator actions that can be allowed on a key
+func ListActionTools(c *gin.Context) {
+	tools := automator.ActionTools()
+
+	response := make([]ActionToolItem, len(tools))
+	for i, t := range tools {
+		response[i] = ActionToolItem{
+			Name:        t.Name,
+			Title:       t.Node.Title,
+			Description: t.Node.Description,
+		}
+	}
+
+	c.Data(lvn.Res(200, response, ""))
+}
+
+func (l APIKeyLimits) validate() error {
+	if l.RateLimitPerMinute < 0 || l.RateLimitBurst < 0 || l.DailyQuota < 0 {
+		return fmt.Errorf("limits must be non-negative")
+	}
+	return nil
+}
+
+func validateTools(tools []string) error {
+	for _, name := range tools {
+		if _, ok := automator.GetActionTool(name); !ok {
+			return fmt.Errorf("unknown tool %q", name)
+		}
+	}
+	return nil
+}
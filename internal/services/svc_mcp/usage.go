@@ -0,0 +1,156 @@
+package svc_mcp
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"fmt"
+	"time"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyUsageResponse reports a key's limits and metered tool calls
+type APIKeyUsageResponse struct {
+	Limits APIKeyLimits    `json:"limits"`
+	Today  APIKeyUsageDay  `json:"today"`
+	Tools  []ToolUsageItem `json:"tools"` // totals per tool over the range
+	Daily  []ToolUsageItem `json:"daily"` // per tool and day
+}
+
+// APIKeyUsageDay is the key's usage of the current UTC day
+type APIKeyUsageDay struct {
+	Calls     int  `json:"calls"`
+	Remaining *int `json:"remaining"` // nil when the key has no daily quota
+}
+
+// ToolUsageItem is the usage of one tool
+type ToolUsageItem struct {
+	Date         string  `json:"date,omitempty"`
+	Tool         string  `json:"tool"`
+	Calls        int     `json:"calls"`
+	Errors       int     `json:"errors"`
+	Rejected     int     `json:"rejected"`
+	AvgLatencyMs float64 `json:"avgLatencyMs"`
+	MaxLatencyMs int64   `json:"maxLatencyMs"`
+}
+
+// GetAPIKeyUsage returns the tool usage of a key. Accepts ?month=YYYY-MM or
+// ?start=YYYY-MM-DD&end=YYYY-MM-DD, defaults to the current month.
+func GetAPIKeyUsage(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	keyID := c.Param("keyId")
+
+	var apiKey models.MCPApiKey
+	if err := db.DB.Where("id = ? AND profile_id = ?", keyID, user.ProfileID).First(&apiKey).Error; err != nil {
+		lvn.GinErr(c, 404, err, "API key not found")
+		return
+	}
+
+	start, end, err := usageRange(c)
+	lvn.GinErr(c, 400, err, "invalid date range")
+	if err != nil {
+		return
+	}
+
+	var usage []models.MCPToolUsageDaily
+	err = db.DB.
+		Where("mcp_api_key_id = ? AND usage_date >= ? AND usage_date < ?", apiKey.ID, start, end).
+		Order("usage_date asc, tool_name asc").
+		Find(&usage).Error
+	if err != nil {
+		lvn.GinErr(c, 500, err, "Failed to load usage")
+		return
+	}
+
+	var todayCalls int64
+	now := time.Now().UTC()
+	db.DB.Model(&models.MCPToolUsageDaily{}).
+		Where("mcp_api_key_id = ? AND usage_date = ?", apiKey.ID, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)).
+		Select("COALESCE(SUM(calls), 0)").
+		Scan(&todayCalls)
+
+	resp := APIKeyUsageResponse{
+		Limits: APIKeyLimits{
+			RateLimitPerMinute: apiKey.RateLimitPerMinute,
+			RateLimitBurst:     apiKey.RateLimitBurst,
+			DailyQuota:         apiKey.DailyQuota,
+		},
+		Today: APIKeyUsageDay{Calls: int(todayCalls)},
+		Tools: []ToolUsageItem{},
+		Daily: make([]ToolUsageItem, 0, len(usage)),
+	}
+	if apiKey.DailyQuota > 0 {
+		remaining := max(apiKey.DailyQuota-int(todayCalls), 0)
+		resp.Today.Remaining = &remaining
+	}
+
+	totals := map[string]*models.MCPToolUsageDaily{}
+	order := []string{}
+	for _, u := range usage {
+		resp.Daily = append(resp.Daily, toToolUsageItem(u, u.UsageDate.Format("2006-01-02")))
+
+		total, ok := totals[u.ToolName]
+		if !ok {
+			total = &models.MCPToolUsageDaily{ToolName: u.ToolName}
+			totals[u.ToolName] = total
+			order = append(order, u.ToolName)
+		}
+		total.Calls += u.Calls
+		total.Errors += u.Errors
+		total.Rejected += u.Rejected
+		total.TotalLatencyMs += u.TotalLatencyMs
+		total.MaxLatencyMs = max(total.MaxLatencyMs, u.MaxLatencyMs)
+	}
+	for _, tool := range order {
+		resp.Tools = append(resp.Tools, toToolUsageItem(*totals[tool], ""))
+	}
+
+	c.Data(lvn.Res(200, resp, ""))
+}
+
+func toToolUsageItem(u models.MCPToolUsageDaily, date string) ToolUsageItem {
+	item := ToolUsageItem{
+		Date:         date,
+		Tool:         u.ToolName,
+		Calls:        u.Calls,
+		Errors:       u.Errors,
+		Rejected:     u.Rejected,
+		MaxLatencyMs: u.MaxLatencyMs,
+	}
+	if u.Calls > 0 {
+		item.AvgLatencyMs = float64(u.TotalLatencyMs) / float64(u.Calls)
+	}
+	return item
+}
+
+func usageRange(c *gin.Context) (time.Time, time.Time, error) {
+	if month := c.Query("month"); month != "" {
+		start, err := time.Parse("2006-01", month)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		return start, start.AddDate(0, 1, 0), nil
+	}
+
+	startStr := c.Query("start")
+	endStr := c.Query("end")
+	if startStr == "" && endStr == "" {
+		now := time.Now().UTC()
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0), nil
+	}
+	if startStr == "" || endStr == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("start and end are required")
+	}
+
+	start, err := time.Parse("2006-01-02", startStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := time.Parse("2006-01-02", endStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, end.AddDate(0, 0, 1), nil
+}
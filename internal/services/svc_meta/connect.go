@@ -0,0 +1,205 @@
+package svc_meta
+
+import (
+	"client-runaway-zenoti/internal/config"
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/svc_audit"
+	"client-runaway-zenoti/packages/meta"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// states expire this long after the login URL was built
+const stateTTL = 15 * time.Minute
+
+// GetAuthURL returns a profile-scoped Facebook login URL. Requires auth to bind to the caller's profile.
+func GetAuthURL(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	state, err := signState(user.ProfileID, time.Now().Add(stateTTL))
+	lvn.GinErr(c, 500, err, "unable to build oauth state")
+	if err != nil {
+		return
+	}
+	url, err := meta.AuthURL(config.Confs.Settings.MetaAppId, config.Confs.Settings.MetaRedirectUrl, state, meta.AdsScopes)
+	lvn.GinErr(c, 400, err, "unable to build oauth url")
+	if err != nil {
+		return
+	}
+
+	c.Data(lvn.Res(200, gin.H{
+		"url":   url,
+		"state": state,
+	}, "OK"))
+}
+
+// signState builds the OAuth state "profile:<id>:<nonce>:<expiry>:<mac>",
+// signed with the app secret so the callback can trust the profile in it
+func signState(profileID uint, expiry time.Time) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	unsigned := fmt.Sprintf("profile:%d:%s:%d", profileID, hex.EncodeToString(nonce), expiry.Unix())
+	return unsigned + ":" + stateMAC(unsigned), nil
+}
+
+// parseState verifies a state built by signState and returns its profile
+func parseState(state string) (uint, error) {
+	i := strings.LastIndex(state, ":")
+	if i < 0 {
+		return 0, fmt.Errorf("unexpected state format")
+	}
+	unsigned, mac := state[:i], state[i+1:]
+	if !hmac.Equal([]byte(mac), []byte(stateMAC(unsigned))) {
+		return 0, fmt.Errorf("invalid state signature")
+	}
+
+	parts := strings.Split(unsigned, ":")
+	if len(parts) != 4 || parts[0] != "profile" {
+		return 0, fmt.Errorf("unexpected state format")
+	}
+	expiry, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse state expiry: %w", err)
+	}
+	if time.Now().Unix() >= expiry {
+		return 0, fmt.Errorf("state expired")
+	}
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse profile id: %w", err)
+	}
+	return uint(id), nil
+}
+
+func stateMAC(unsigned string) string {
+	mac := hmac.New(sha256.New, []byte(config.Confs.Settings.MetaAppSecret))
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// OAuthCallback exchanges the code for a long-lived token and stores the connection.
+// On GET, it redirects to /oauth/callback?meta_connected=true (or error=<slug>).
+// On POST (JSON), it returns the stored connection.
+func OAuthCallback(c *gin.Context) {
+	method := c.Request.Method
+	var code, state string
+
+	if method == http.MethodPost {
+		var body struct {
+			Code  string `json:"code"`
+			State string `json:"state"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			lvn.GinErr(c, 400, err, "invalid payload")
+			return
+		}
+		code = body.Code
+		state = body.State
+	} else {
+		if c.Query("error") != "" {
+			handleCallbackError(c, method, "access_denied")
+			return
+		}
+		code = c.Query("code")
+		state = c.Query("state")
+	}
+
+	profileID, err := parseState(state)
+	if err != nil {
+		handleCallbackError(c, method, "state_mismatch")
+		return
+	}
+
+	if code == "" {
+		handleCallbackError(c, method, "missing_code")
+		return
+	}
+
+	conn, err := exchangeCode(c, profileID, code)
+	if err != nil {
+		handleCallbackError(c, method, "exchange_failed")
+		return
+	}
+
+	svc_audit.RecordForProfile(c, profileID, models.AuditCreate, "meta_connection", strconv.FormatUint(uint64(conn.ID), 10), "", nil, gin.H{
+		"id":          conn.ID,
+		"displayName": conn.DisplayName,
+		"metaUserId":  conn.MetaUserID,
+	})
+
+	if method == http.MethodPost {
+		c.Data(lvn.Res(200, connectionResponse(conn), "Connection saved"))
+		return
+	}
+
+	redirectURL := config.Confs.Settings.AppDomain + "/oauth/callback?meta_connected=true"
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// exchangeCode trades the code for a long-lived token and saves it as a
+// connection of the profile. Reconnecting the same Meta user renews its
+// connection instead of adding another one.
+func exchangeCode(c *gin.Context, profileID uint, code string) (models.MetaConnection, error) {
+	settings := config.Confs.Settings
+	short, err := meta.ExchangeCode(c, settings.MetaAppId, settings.MetaAppSecret, settings.MetaRedirectUrl, code)
+	if err != nil {
+		return models.MetaConnection{}, err
+	}
+	long, err := meta.ExchangeForLongLivedToken(c, settings.MetaAppId, settings.MetaAppSecret, short.AccessToken)
+	if err != nil {
+		return models.MetaConnection{}, err
+	}
+
+	conn := models.MetaConnection{
+		ProfileID:   profileID,
+		AccessToken: long.AccessToken,
+		TokenExpiry: tokenExpiry(long),
+	}
+
+	// best-effort, the connection works without a name
+	cli, _ := Svc.NewClient(long.AccessToken)
+	if me, err := cli.Me(); err == nil {
+		conn.MetaUserID = me.ID
+		if me.Name != "" {
+			conn.DisplayName = fmt.Sprintf("%s's meta ads", me.Name)
+		}
+	}
+	if conn.DisplayName == "" {
+		conn.DisplayName = "meta ads connection"
+	}
+
+	if conn.MetaUserID != "" {
+		existing := models.MetaConnection{}
+		if db.DB.Where("profile_id = ? AND meta_user_id = ?", profileID, conn.MetaUserID).First(&existing).Error == nil {
+			conn.ID, conn.CreatedAt = existing.ID, existing.CreatedAt
+		}
+	}
+
+	if err := db.DB.Save(&conn).Error; err != nil {
+		return conn, err
+	}
+	return conn, nil
+}
+
+func handleCallbackError(c *gin.Context, method, code string) {
+	if method == http.MethodPost {
+		lvn.GinErr(c, 400, errors.New(code), code)
+		return
+	}
+	c.Redirect(http.StatusFound, config.Confs.Settings.AppDomain+"/oauth/callback?error="+code)
+}
@@ -0,0 +1,110 @@
+package svc_meta
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/svc_audit"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// ListConnections returns all Meta connections for the authenticated user's profile.
+func ListConnections(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	var conns []models.MetaConnection
+
+	err := db.DB.Where("profile_id = ?", user.ProfileID).Find(&conns).Error
+	lvn.GinErr(c, 400, err, "unable to list connections")
+	if err != nil {
+		return
+	}
+
+	resp := make([]gin.H, 0, len(conns))
+	for _, cn := range conns {
+		resp = append(resp, connectionResponse(cn))
+	}
+
+	c.Data(lvn.Res(200, resp, "OK"))
+}
+
+// DeleteConnection removes a Meta connection for the authenticated user's profile.
+func DeleteConnection(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	id, err := strconv.ParseUint(c.Param("accountId"), 10, 64)
+	lvn.GinErr(c, 400, err, "invalid account id")
+	if err != nil {
+		return
+	}
+
+	conn := models.MetaConnection{}
+	err = db.DB.Where("profile_id = ? AND id = ?", user.ProfileID, id).First(&conn).Error
+	lvn.GinErr(c, 404, err, "connection not found")
+	if err != nil {
+		return
+	}
+
+	if err := db.DB.Delete(&conn).Error; err != nil {
+		lvn.GinErr(c, 400, err, "unable to delete connection")
+		return
+	}
+
+	svc_audit.Record(c, models.AuditDelete, "meta_connection", strconv.FormatUint(id, 10), "", conn, nil)
+
+	c.Data(lvn.Res(200, gin.H{"deleted": id}, "OK"))
+}
+
+// ListAdAccounts returns the ad accounts the connection's Meta user can read,
+// to pick from for a location.
+func ListAdAccounts(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	conn := models.MetaConnection{}
+	err := db.DB.Where("profile_id = ? AND id = ?", user.ProfileID, c.Param("accountId")).First(&conn).Error
+	lvn.GinErr(c, 404, err, "connection not found")
+	if err != nil {
+		return
+	}
+
+	conn, err = refreshIfNeeded(context.Background(), conn)
+	lvn.GinErr(c, 400, err, "unable to renew meta token")
+	if err != nil {
+		return
+	}
+
+	cli, err := Svc.NewClient(conn.AccessToken)
+	lvn.GinErr(c, 400, err, "unable to create meta client")
+	if err != nil {
+		return
+	}
+
+	accounts, err := cli.MyAdAccounts()
+	lvn.GinErr(c, 400, err, "unable to list ad accounts")
+	if err != nil {
+		return
+	}
+
+	c.Data(lvn.Res(200, accounts, "OK"))
+}
+
+func connectionResponse(cn models.MetaConnection) gin.H {
+	accountName := cn.DisplayName
+	if accountName == "" {
+		accountName = fmt.Sprintf("connection %d", cn.ID)
+	}
+	var expiresAt *time.Time
+	if !cn.TokenExpiry.IsZero() {
+		expiresAt = &cn.TokenExpiry
+	}
+	return gin.H{
+		"id":          cn.ID,
+		"accountName": accountName,
+		"metaUserId":  cn.MetaUserID,
+		"expiresAt":   expiresAt,
+		"expired":     expiresAt != nil && time.Now().After(cn.TokenExpiry),
+		"connectedAt": cn.CreatedAt,
+	}
+}
@@ -0,0 +1,121 @@
+package svc_meta
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/svc_audit"
+	"errors"
+	"fmt"
+	"strings"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaveLocationSetting creates or updates the Meta ad account of a location for the authenticated profile.
+func SaveLocationSetting(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	locID := c.Param("locationId")
+	if locID == "" {
+		lvn.GinErr(c, 400, fmt.Errorf("location id required"), "invalid location id")
+		return
+	}
+
+	var payload struct {
+		ConnectionID  uint   `json:"connectionId" binding:"required"`
+		AdAccountID   string `json:"adAccountId" binding:"required"`
+		AdAccountName string `json:"adAccountName"`
+		PixelID       string `json:"pixelId"`
+	}
+	err := c.ShouldBindJSON(&payload)
+	lvn.GinErr(c, 400, err, "invalid payload")
+	if err != nil {
+		return
+	}
+
+	// the insights endpoint takes the act_ prefixed id
+	adAccountID := strings.TrimSpace(payload.AdAccountID)
+	if !strings.HasPrefix(adAccountID, "act_") {
+		adAccountID = "act_" + adAccountID
+	}
+
+	// Ensure location and connection belong to the profile.
+	location := models.Location{}
+	if err := db.DB.Where("profile_id = ? AND id = ?", user.ProfileID, locID).First(&location).Error; err != nil {
+		lvn.GinErr(c, 404, err, "location not found")
+		return
+	}
+	conn := models.MetaConnection{}
+	if err := db.DB.Where("profile_id = ? AND id = ?", user.ProfileID, payload.ConnectionID).First(&conn).Error; err != nil {
+		lvn.GinErr(c, 400, err, "connection not found")
+		return
+	}
+	connectionName := conn.DisplayName
+	if connectionName == "" {
+		connectionName = fmt.Sprintf("connection %d", conn.ID)
+	}
+
+	setting := models.MetaLocationSetting{
+		LocationId:     locID,
+		ProfileID:      user.ProfileID,
+		ConnectionID:   payload.ConnectionID,
+		ConnectionName: connectionName,
+		AdAccountID:    adAccountID,
+		AdAccountName:  payload.AdAccountName,
+		PixelID:        strings.TrimSpace(payload.PixelID),
+	}
+
+	var before *models.MetaLocationSetting
+	existing := models.MetaLocationSetting{}
+	if db.DB.Where("location_id = ? AND profile_id = ?", locID, user.ProfileID).First(&existing).Error == nil {
+		before = &existing
+	}
+
+	// Upsert by (locationId, profileId)
+	err = db.DB.Clauses(
+		clause.OnConflict{
+			Columns:   []clause.Column{{Name: "location_id"}, {Name: "profile_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"connection_id", "connection_name", "ad_account_id", "ad_account_name", "pixel_id", "updated_at"}),
+		},
+	).Create(&setting).Error
+	lvn.GinErr(c, 400, err, "unable to save setting")
+	if err != nil {
+		return
+	}
+
+	if before == nil {
+		svc_audit.Record(c, models.AuditCreate, "meta_location_setting", locID, locID, nil, setting)
+	} else {
+		// the upsert doesn't return the existing row's id and creation time
+		after := setting
+		after.ID, after.CreatedAt = before.ID, before.CreatedAt
+		svc_audit.Record(c, models.AuditUpdate, "meta_location_setting", locID, locID, before, after)
+	}
+
+	c.Data(lvn.Res(200, setting, "saved"))
+}
+
+// GetLocationSetting returns the Meta setting for a given location (if any).
+func GetLocationSetting(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	locID := c.Param("locationId")
+	if locID == "" {
+		lvn.GinErr(c, 400, fmt.Errorf("location id required"), "invalid location id")
+		return
+	}
+
+	setting := models.MetaLocationSetting{}
+	err := db.DB.Where("location_id = ? AND profile_id = ?", locID, user.ProfileID).First(&setting).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Data(lvn.Res(200, nil, "not found"))
+			return
+		}
+		lvn.GinErr(c, 400, err, "unable to fetch setting")
+		return
+	}
+
+	c.Data(lvn.Res(200, setting, "success"))
+}
@@ -0,0 +1,70 @@
+package svc_meta
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/mailer"
+	"client-runaway-zenoti/internal/tgbot"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// RefreshConnections renews the tokens about to expire and warns the profile
+// owner, once per token, about the ones that can't be renewed. Expired
+// tokens can't be renewed either, so they are left alone once warned about.
+func RefreshConnections() {
+	now := time.Now()
+	var conns []models.MetaConnection
+	err := db.DB.Where("token_expiry > ? AND token_expiry < ?", time.Time{}, now.Add(tokenRenewWindow)).
+		Where("token_expiry >= ? OR expiry_alerted_at IS NULL", now).
+		Find(&conns).Error
+	if err != nil {
+		log.Printf("meta: load connections to renew: %s", err.Error())
+		return
+	}
+
+	for _, conn := range conns {
+		_, err := refreshIfNeeded(context.Background(), conn)
+		if err == nil {
+			continue
+		}
+		log.Printf("meta: renew connection %d: %s", conn.ID, err.Error())
+		if conn.ExpiryAlertedAt != nil {
+			continue // already warned about this token
+		}
+
+		err = db.DB.Model(&models.MetaConnection{}).Where("id = ?", conn.ID).Update("expiry_alerted_at", time.Now()).Error
+		if err != nil {
+			log.Printf("meta: save alert of connection %d: %s", conn.ID, err.Error())
+			continue
+		}
+		sendExpiryAlert(conn)
+	}
+}
+
+func sendExpiryAlert(conn models.MetaConnection) {
+	subject := "Meta Ads connection expiring"
+	body := fmt.Sprintf("The Meta Ads connection \"%s\" expires on %s and could not be renewed automatically.",
+		conn.DisplayName, conn.TokenExpiry.Format("2006-01-02"))
+	if time.Now().After(conn.TokenExpiry) {
+		subject = "Meta Ads connection expired"
+		body = fmt.Sprintf("The Meta Ads connection \"%s\" expired on %s.", conn.DisplayName, conn.TokenExpiry.Format("2006-01-02"))
+	}
+	body += "\n\nReconnect the account in the settings to keep reporting ad spend."
+
+	tgbot.Notify("Meta Ads", fmt.Sprintf("profile %d: %s", conn.ProfileID, body), true)
+
+	if !mailer.Configured() {
+		return
+	}
+	owner := models.User{}
+	err := db.DB.Joins("JOIN profiles ON profiles.owner_id = users.id").Where("profiles.id = ?", conn.ProfileID).First(&owner).Error
+	if err != nil || owner.Email == "" {
+		return
+	}
+	if err := mailer.Send([]string{owner.Email}, subject, body); err != nil {
+		log.Printf("meta: send expiry alert of connection %d: %s", conn.ID, err.Error())
+	}
+}
@@ -0,0 +1,108 @@
+package svc_meta
+
+import (
+	"client-runaway-zenoti/internal/config"
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/packages/meta"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// tokens are renewed, and owners warned, this long before they expire
+	tokenRenewWindow = 7 * 24 * time.Hour
+)
+
+var (
+	Svc = &meta.Service{}
+
+	ErrNoLocationSetting = errors.New("location meta settings not found")
+)
+
+// CliForLocation returns a client for the connection of the location and the
+// ad account selected for it
+func CliForLocation(locationId string, profileId uint) (meta.Client, models.MetaLocationSetting, error) {
+	setting := models.MetaLocationSetting{}
+	err := db.DB.Where("location_id = ? AND profile_id = ?", locationId, profileId).First(&setting).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return meta.Client{}, setting, ErrNoLocationSetting
+		}
+		return meta.Client{}, setting, fmt.Errorf("unable to fetch setting: %w", err)
+	}
+
+	conn := models.MetaConnection{}
+	err = db.DB.Where("id = ? AND profile_id = ?", setting.ConnectionID, profileId).First(&conn).Error
+	if err != nil {
+		return meta.Client{}, setting, fmt.Errorf("meta connection %d: %w", setting.ConnectionID, err)
+	}
+
+	conn, err = refreshIfNeeded(context.Background(), conn)
+	if err != nil {
+		return meta.Client{}, setting, err
+	}
+
+	cli, err := Svc.NewClient(conn.AccessToken)
+	return cli, setting, err
+}
+
+// AdSpend returns the spend of the location's ad account between the two
+// dates, both inclusive. A location without an ad account has no spend.
+func AdSpend(loc models.Location, startDate, endDate time.Time) (float64, error) {
+	cli, setting, err := CliForLocation(loc.Id, loc.ProfileID)
+	if errors.Is(err, ErrNoLocationSetting) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return cli.AdSpend(setting.AdAccountID, startDate, endDate)
+}
+
+// refreshIfNeeded renews a long-lived token that expires within the renew
+// window. Meta only renews tokens that are still valid.
+func refreshIfNeeded(ctx context.Context, conn models.MetaConnection) (models.MetaConnection, error) {
+	if conn.TokenExpiry.IsZero() || time.Now().Add(tokenRenewWindow).Before(conn.TokenExpiry) {
+		return conn, nil
+	}
+	if time.Now().After(conn.TokenExpiry) {
+		return conn, fmt.Errorf("meta connection %d: token expired on %s, reconnect the account", conn.ID, conn.TokenExpiry.Format("2006-01-02"))
+	}
+
+	tok, err := meta.ExchangeForLongLivedToken(ctx, config.Confs.Settings.MetaAppId, config.Confs.Settings.MetaAppSecret, conn.AccessToken)
+	if err != nil {
+		return conn, fmt.Errorf("renew meta token: %w", err)
+	}
+
+	conn.AccessToken = tok.AccessToken
+	conn.TokenExpiry = tokenExpiry(tok)
+	conn.ExpiryAlertedAt = nil
+
+	// struct update so the token goes through the encrypted serializer
+	err = db.DB.Model(&models.MetaConnection{}).Where("id = ?", conn.ID).
+		Select("access_token", "token_expiry", "expiry_alerted_at").
+		Updates(models.MetaConnection{
+			AccessToken:     conn.AccessToken,
+			TokenExpiry:     conn.TokenExpiry,
+			ExpiryAlertedAt: nil,
+		}).Error
+	if err != nil {
+		return conn, fmt.Errorf("persist renewed meta token: %w", err)
+	}
+	return conn, nil
+}
+
+// tokenExpiry is when an exchanged token expires. Meta leaves expires_in out
+// for tokens that don't expire.
+func tokenExpiry(tok meta.ExchangeResponse) time.Time {
+	if tok.ExpiresIn <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+}
@@ -0,0 +1,137 @@
+package svc_noshows
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/auth"
+	"client-runaway-zenoti/internal/types"
+	"strconv"
+	"time"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetNoShowReport returns the no-show and cancellation rates of the
+// appointments between ?start and ?end (YYYY-MM-DD, both inclusive) and how
+// many of the guests rebooked within ?recoveryDays (14 by default).
+// ?locationId narrows it to a location.
+func GetNoShowReport(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	start, err := time.Parse("2006-01-02", c.Query("start"))
+	if err != nil {
+		c.Data(lvn.Res(400, nil, "start must be YYYY-MM-DD"))
+		return
+	}
+	end, err := time.Parse("2006-01-02", c.Query("end"))
+	if err != nil {
+		c.Data(lvn.Res(400, nil, "end must be YYYY-MM-DD"))
+		return
+	}
+	if end.Before(start) {
+		c.Data(lvn.Res(400, nil, "end must not be before start"))
+		return
+	}
+
+	recoveryDays := DefaultRecoveryDays
+	if s := c.Query("recoveryDays"); s != "" {
+		recoveryDays, err = strconv.Atoi(s)
+		if err != nil || recoveryDays <= 0 || recoveryDays > 365 {
+			c.Data(lvn.Res(400, nil, "recoveryDays must be between 1 and 365"))
+			return
+		}
+	}
+
+	query := db.DB.Model(&models.Location{}).Where("profile_id = ?", user.ProfileID)
+	if locationId := c.Query("locationId"); locationId != "" {
+		query = query.Where("id = ?", locationId)
+	}
+	if allowed := auth.AllowedLocationIds(c); allowed != nil {
+		query = query.Where("id IN ?", allowed)
+	}
+	var locationIds []string
+	if err := query.Pluck("id", &locationIds).Error; err != nil {
+		lvn.GinErr(c, 500, err, "Unable to get locations")
+		return
+	}
+	if len(locationIds) == 0 {
+		c.Data(lvn.Res(400, nil, "No locations to report on"))
+		return
+	}
+
+	appts, guestAppts, err := loadAppointments(locationIds, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		lvn.GinErr(c, 500, err, "Unable to load appointments")
+		return
+	}
+
+	c.Data(lvn.Res(200, BuildNoShowReport(appts, guestAppts, recoveryDays), ""))
+}
+
+// loadAppointments reads the appointments of the locations that started in
+// [from, to) and the later bookings of the guests who missed theirs. The
+// bookings the calendar sync moved are left out.
+func loadAppointments(locationIds []string, from, to time.Time) ([]NoShowAppointment, []NoShowAppointment, error) {
+	inRange := func() *gorm.DB {
+		// only appointments that already happened have an outcome, and the
+		// invoices the calendar sync canceled to move a booking aren't misses
+		return db.DB.Model(&models.ZenotiAppointment{}).
+			Where("location_id IN ? AND start_time >= ? AND start_time < ? AND start_time_utc < ?", locationIds, from, to, time.Now()).
+			Where("invoice_id NOT IN (?)", db.DB.Model(&models.CalendarSyncCanceledInvoice{}).Select("invoice_id"))
+	}
+
+	var appts []models.ZenotiAppointment
+	if err := inRange().Find(&appts).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var guestAppts []models.ZenotiAppointment
+	err := db.DB.
+		Where("guest_id IN (?) AND booked_at >= ?",
+			inRange().Where("status IN ? AND guest_id <> ''", []types.ZenotiStatus{types.NoShowed, types.Canceled}).Select("guest_id"),
+			from.AddDate(0, 0, -1)).
+		Find(&guestAppts).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// guests are credited to the source of their first lead
+	var leadSources []struct {
+		GuestId string
+		Source  string
+	}
+	err = db.DB.Model(&models.JpmReportNewLead{}).
+		Select("DISTINCT ON (guest_id) guest_id, source").
+		Where("guest_id IN (?)", inRange().Where("guest_id <> ''").Select("guest_id")).
+		Order("guest_id, date").
+		Scan(&leadSources).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	sources := make(map[string]string, len(leadSources))
+	for _, s := range leadSources {
+		sources[s.GuestId] = s.Source
+	}
+
+	return toReport(appts, sources), toReport(guestAppts, sources), nil
+}
+
+func toReport(appts []models.ZenotiAppointment, sources map[string]string) []NoShowAppointment {
+	res := make([]NoShowAppointment, 0, len(appts))
+	for _, a := range appts {
+		res = append(res, NoShowAppointment{
+			AppointmentId: a.AppointmentId,
+			GuestId:       a.GuestId,
+			Therapist:     a.TherapistName,
+			Service:       a.ServiceName,
+			Source:        sources[a.GuestId],
+			StartTime:     a.StartTime,
+			BookedAt:      a.BookedAt,
+			Status:        a.Status,
+			MissedAt:      a.MissedAt,
+		})
+	}
+	return res
+}
@@ -0,0 +1,172 @@
+package svc_noshows
+
+import (
+	"client-runaway-zenoti/internal/types"
+	"math"
+	"sort"
+	"time"
+)
+
+const DefaultRecoveryDays = 14
+
+type (
+	// NoShowAppointment is an appointment as the report sees it
+	NoShowAppointment struct {
+		AppointmentId string
+		GuestId       string
+		Therapist     string
+		Service       string
+		Source        string // lead source of the guest
+		StartTime     time.Time
+		BookedAt      time.Time
+		Status        types.ZenotiStatus
+		MissedAt      *time.Time
+	}
+
+	NoShowReport struct {
+		RecoveryDays int            `json:"recoveryDays"`
+		Total        NoShowBucket   `json:"total"`
+		ByTherapist  []NoShowBucket `json:"byTherapist"`
+		ByService    []NoShowBucket `json:"byService"`
+		BySource     []NoShowBucket `json:"bySource"`
+		ByDayOfWeek  []NoShowBucket `json:"byDayOfWeek"`
+	}
+
+	NoShowBucket struct {
+		Key              string  `json:"key"`
+		Appointments     int     `json:"appointments"`
+		NoShows          int     `json:"noShows"`
+		Cancellations    int     `json:"cancellations"`
+		NoShowRate       float64 `json:"noShowRate"`       // % of the appointments
+		CancellationRate float64 `json:"cancellationRate"` // % of the appointments
+		// Recovered are the no-shows and cancellations whose guest rebooked
+		// within the recovery days
+		Recovered    int     `json:"recovered"`
+		RecoveryRate float64 `json:"recoveryRate"` // % of the no-shows and cancellations
+	}
+)
+
+// BuildNoShowReport breaks the appointments down by therapist, service, lead
+// source and day of the week. guestAppts are all known appointments of the
+// guests, in which the rebookings are looked for.
+func BuildNoShowReport(appts, guestAppts []NoShowAppointment, recoveryDays int) NoShowReport {
+	byGuest := map[string][]NoShowAppointment{}
+	for _, a := range guestAppts {
+		if a.GuestId != "" {
+			byGuest[a.GuestId] = append(byGuest[a.GuestId], a)
+		}
+	}
+
+	report := NoShowReport{RecoveryDays: recoveryDays, Total: NoShowBucket{Key: "Total"}}
+	therapists := map[string]*NoShowBucket{}
+	services := map[string]*NoShowBucket{}
+	sources := map[string]*NoShowBucket{}
+	days := make([]NoShowBucket, 7)
+	for i := range days {
+		days[i].Key = time.Weekday((i + 1) % 7).String() // Monday first
+	}
+
+	for _, a := range appts {
+		recovered := isMissed(a) && rebooked(a, byGuest[a.GuestId], recoveryDays)
+		for _, b := range []*NoShowBucket{
+			&report.Total,
+			bucket(therapists, a.Therapist),
+			bucket(services, a.Service),
+			bucket(sources, a.Source),
+			&days[(int(a.StartTime.Weekday())+6)%7],
+		} {
+			b.add(a, recovered)
+		}
+	}
+
+	report.Total.rates()
+	report.ByTherapist = sortedBuckets(therapists)
+	report.ByService = sortedBuckets(services)
+	report.BySource = sortedBuckets(sources)
+	for i := range days {
+		days[i].rates()
+	}
+	report.ByDayOfWeek = days
+	return report
+}
+
+func isMissed(a NoShowAppointment) bool {
+	return a.Status == types.NoShowed || a.Status == types.Canceled
+}
+
+// rebooked reports whether the guest booked another appointment, not
+// cancelled, within the recovery days of the miss. The miss is dated by the
+// status change when seen, by the appointment start otherwise, and
+// rebookings made on the same day, like reschedules, count.
+func rebooked(missed NoShowAppointment, guestAppts []NoShowAppointment, recoveryDays int) bool {
+	missedAt := missed.StartTime
+	if missed.MissedAt != nil {
+		missedAt = *missed.MissedAt
+	}
+	from := missedAt.Add(-24 * time.Hour)
+	to := missedAt.AddDate(0, 0, recoveryDays)
+
+	for _, a := range guestAppts {
+		if a.AppointmentId == missed.AppointmentId || a.Status == types.Canceled {
+			continue
+		}
+		if a.BookedAt.After(missed.BookedAt) && !a.BookedAt.Before(from) && !a.BookedAt.After(to) {
+			return true
+		}
+	}
+	return false
+}
+
+func bucket(buckets map[string]*NoShowBucket, key string) *NoShowBucket {
+	if key == "" {
+		key = "Unknown"
+	}
+	b, ok := buckets[key]
+	if !ok {
+		b = &NoShowBucket{Key: key}
+		buckets[key] = b
+	}
+	return b
+}
+
+func (b *NoShowBucket) add(a NoShowAppointment, recovered bool) {
+	b.Appointments++
+	switch a.Status {
+	case types.NoShowed:
+		b.NoShows++
+	case types.Canceled:
+		b.Cancellations++
+	}
+	if recovered {
+		b.Recovered++
+	}
+}
+
+func (b *NoShowBucket) rates() {
+	b.NoShowRate = percent(b.NoShows, b.Appointments)
+	b.CancellationRate = percent(b.Cancellations, b.Appointments)
+	b.RecoveryRate = percent(b.Recovered, b.NoShows+b.Cancellations)
+}
+
+// sortedBuckets orders the buckets by most appointments
+func sortedBuckets(buckets map[string]*NoShowBucket) []NoShowBucket {
+	res := make([]NoShowBucket, 0, len(buckets))
+	for _, b := range buckets {
+		b.rates()
+		res = append(res, *b)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Appointments != res[j].Appointments {
+			return res[i].Appointments > res[j].Appointments
+		}
+		return res[i].Key < res[j].Key
+	})
+	return res
+}
+
+func percent(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(1000*float64(part)/float64(whole)) / 10
+}
@@ -0,0 +1,227 @@
+package svc_noshows
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/types"
+	zenotiv1 "client-runaway-zenoti/packages/zenotiV1"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// statuses settle after the visit, so the recent past is pulled again
+	syncPastDays = 30
+	// future bookings are the rebookings of recent misses
+	syncFutureDays = 60
+)
+
+// SyncAppointments stores the appointments of every Zenoti location around
+// today, including no-shows and cancellations
+func SyncAppointments() {
+	var locations []models.Location
+	err := db.DB.Preload("ZenotiApiObj").
+		Where("zenoti_center_id <> '' AND zenoti_api_obj_id IS NOT NULL").
+		Find(&locations).Error
+	if err != nil {
+		log.Printf("noshows: load locations: %s", err.Error())
+		return
+	}
+
+	now := time.Now()
+	for _, loc := range locations {
+		err := SyncLocation(loc, now.AddDate(0, 0, -syncPastDays), now.AddDate(0, 0, syncFutureDays))
+		if err != nil {
+			log.Printf("noshows: sync appointments of %s: %s", loc.Id, err.Error())
+		}
+	}
+}
+
+// SyncLocation stores the location's appointments between the two times
+func SyncLocation(loc models.Location, from, to time.Time) error {
+	zcli, err := zenotiv1.NewClient(loc.Id, loc.ZenotiCenterId, loc.ZenotiApiObj.ApiKey)
+	if err != nil {
+		return err
+	}
+
+	appts, err := zcli.AppointmentsListAllAppointments(zenotiv1.AppointmentFilter{
+		StartDate:           from,
+		EndDate:             to,
+		IncludeNoShowCancel: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(appts))
+	for _, a := range appts {
+		ids = append(ids, a.Id)
+	}
+	var existing []models.ZenotiAppointment
+	if err := db.DB.Where("appointment_id IN ?", ids).Find(&existing).Error; err != nil {
+		return err
+	}
+	known := make(map[string]models.ZenotiAppointment, len(existing))
+	for _, e := range existing {
+		known[e.AppointmentId] = e
+	}
+
+	// statuses received by webhook before the invoices were synced
+	invoiceIds := make([]string, 0, len(appts))
+	for _, a := range appts {
+		if a.Invoice_id != "" {
+			invoiceIds = append(invoiceIds, a.Invoice_id)
+		}
+	}
+	var markers []models.ZenotiInvoiceStatus
+	if err := db.DB.Where("invoice_id IN ?", invoiceIds).Find(&markers).Error; err != nil {
+		return err
+	}
+	missedAt := make(map[string]*time.Time, len(markers))
+	for _, m := range markers {
+		missedAt[m.InvoiceId] = m.MissedAt
+	}
+
+	now := time.Now()
+	rows := make([]models.ZenotiAppointment, 0, len(appts))
+	for _, a := range appts {
+		// therapist breaks come back as appointments without a service
+		if a.Id == "" || a.Service.Id == "" {
+			continue
+		}
+
+		row := models.ZenotiAppointment{
+			ProfileId:     loc.ProfileID,
+			LocationId:    loc.Id,
+			AppointmentId: a.Id,
+			InvoiceId:     a.Invoice_id,
+			GuestId:       strings.ToLower(a.Guest.Id),
+			GuestName:     strings.TrimSpace(a.Guest.First_name + " " + a.Guest.Last_name),
+			TherapistId:   a.Therapist.Id,
+			TherapistName: therapistName(a.Therapist),
+			ServiceId:     a.Service.Id,
+			ServiceName:   a.Service.Name,
+			StartTime:     a.Start_time.Time,
+			StartTimeUtc:  a.Start_time_utc.Time,
+			BookedAt:      a.Creation_date.Time,
+			Status:        types.ZenotiStatus(a.Status),
+		}
+		if prev, ok := known[a.Id]; ok {
+			row.MissedAt = prev.MissedAt
+			if row.Missed() && !prev.Missed() && row.MissedAt == nil {
+				row.MissedAt = &now
+			}
+		} else if row.Missed() {
+			row.MissedAt = missedAt[a.Invoice_id]
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	err = db.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "appointment_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"invoice_id", "guest_id", "guest_name", "therapist_id", "therapist_name", "service_id", "service_name",
+			"start_time", "start_time_utc", "booked_at", "status", "missed_at", "updated_at",
+		}),
+	}).CreateInBatches(&rows, 500).Error
+	if err != nil || len(markers) == 0 {
+		return err
+	}
+
+	// the synced appointments hold the status now
+	return db.DB.Delete(&markers).Error
+}
+
+// RecordStatus applies an appointment group status webhook to the stored
+// appointments of its invoice. It returns the appointments and whether the
+// group just turned into a no-show or cancellation. An invoice never synced
+// gets a ZenotiInvoiceStatus, so the next webhooks of the group are compared
+// with it instead of turning again. Invoices the calendar sync canceled
+// itself to move a booking are ignored.
+func RecordStatus(invoiceId string, status types.ZenotiStatus) ([]models.ZenotiAppointment, bool, error) {
+	missed := status == types.NoShowed || status == types.Canceled
+	if invoiceId == "" {
+		return nil, missed, nil
+	}
+
+	var moved int64
+	err := db.DB.Model(&models.CalendarSyncCanceledInvoice{}).Where("invoice_id = ?", invoiceId).Count(&moved).Error
+	if err != nil || moved > 0 {
+		return nil, false, err
+	}
+
+	var appts []models.ZenotiAppointment
+	if err := db.DB.Where("invoice_id = ?", invoiceId).Find(&appts).Error; err != nil {
+		return nil, false, err
+	}
+	var markers []models.ZenotiInvoiceStatus
+	if err := db.DB.Where("invoice_id = ?", invoiceId).Limit(1).Find(&markers).Error; err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	if len(appts) == 0 {
+		marker := models.ZenotiInvoiceStatus{InvoiceId: invoiceId, Status: status}
+		turned := missed
+		if len(markers) > 0 {
+			marker.MissedAt = markers[0].MissedAt
+			turned = missed && !markers[0].Missed()
+		}
+		if missed && marker.MissedAt == nil {
+			marker.MissedAt = &now
+		}
+		return nil, turned, db.DB.Save(&marker).Error
+	}
+
+	// the marker alone tells whether the group turned, it's no longer
+	// needed once the appointments are there
+	turned := false
+	if len(markers) > 0 {
+		turned = missed && !markers[0].Missed()
+		if err := db.DB.Delete(&markers[0]).Error; err != nil {
+			return nil, false, err
+		}
+	}
+
+	for i, a := range appts {
+		if a.Status == status {
+			continue
+		}
+		if len(markers) == 0 {
+			turned = turned || (missed && !a.Missed())
+		}
+		if err := setStatus(&appts[i], status, now); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return appts, turned, nil
+}
+
+// setStatus stores the new status of the appointment, stamping when it
+// was first missed
+func setStatus(a *models.ZenotiAppointment, status types.ZenotiStatus, now time.Time) error {
+	updates := map[string]interface{}{"status": status}
+	if (status == types.NoShowed || status == types.Canceled) && a.MissedAt == nil {
+		updates["missed_at"] = now
+		a.MissedAt = &now
+	}
+	if err := db.DB.Model(a).Updates(updates).Error; err != nil {
+		return err
+	}
+	a.Status = status
+	return nil
+}
+
+func therapistName(t zenotiv1.Therapist) string {
+	if t.Display_name != "" {
+		return t.Display_name
+	}
+	return strings.TrimSpace(t.First_name + " " + t.Last_name)
+}
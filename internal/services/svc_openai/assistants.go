@@ -3,7 +3,10 @@ package svc_openai
 import (
 	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/svc_audit"
 	openaiv1 "client-runaway-zenoti/packages/openaiV1"
+	"encoding/json"
+	"log"
 	"strconv"
 
 	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
@@ -11,16 +14,21 @@ import (
 )
 
 type assistantPayload struct {
-	Name         string `json:"name"`
-	Model        string `json:"model"`
-	Instructions string `json:"instructions,omitempty"`
+	Name         string                   `json:"name"`
+	Model        string                   `json:"model"`
+	Instructions *string                  `json:"instructions,omitempty"`
+	Tools        *[]openaiv1.ResponseTool `json:"tools,omitempty"`
+	Temperature  *float64                 `json:"temperature,omitempty"`
 }
 
 type assistantResponse struct {
-	ID        uint   `json:"id"`
-	Name      string `json:"name"`
-	Model     string `json:"model"`
-	CreatedAt int64  `json:"createdAt"`
+	ID           uint                    `json:"id"`
+	Name         string                  `json:"name"`
+	Model        string                  `json:"model"`
+	Instructions string                  `json:"instructions"`
+	Tools        []openaiv1.ResponseTool `json:"tools"`
+	Temperature  *float64                `json:"temperature"`
+	CreatedAt    int64                   `json:"createdAt"`
 }
 
 func ListAssistants(c *gin.Context) {
@@ -35,12 +43,7 @@ func ListAssistants(c *gin.Context) {
 
 	resp := make([]assistantResponse, 0, len(assistants))
 	for _, a := range assistants {
-		resp = append(resp, assistantResponse{
-			ID:        a.ID,
-			Name:      a.Name,
-			Model:     a.GptModel,
-			CreatedAt: a.CreatedAt.Unix(),
-		})
+		resp = append(resp, toAssistantResponse(a))
 	}
 
 	c.Data(lvn.Res(200, resp, "OK"))
@@ -78,12 +81,9 @@ func GetAssistant(c *gin.Context) {
 		return
 	}
 
-	c.Data(lvn.Res(200, assistantResponse{
-		ID:        assistant.ID,
-		Name:      assistant.Name,
-		Model:     assistant.GptModel,
-		CreatedAt: assistant.CreatedAt.Unix(),
-	}, "OK"))
+	importLegacy(&assistant)
+
+	c.Data(lvn.Res(200, toAssistantResponse(assistant), "OK"))
 }
 
 func CreateAssistant(c *gin.Context) {
@@ -96,31 +96,25 @@ func CreateAssistant(c *gin.Context) {
 	}
 
 	if payload.Name == "" || payload.Model == "" {
-		lvn.GinErr(c, 400, nil, "name and model are required")
-		return
-	}
-
-	client, err := openAIClient()
-	lvn.GinErr(c, 400, err, "unable to init ai client")
-	if err != nil {
-		return
-	}
-
-	assistant, err := client.AssistantsCreate(openaiv1.AssistantRequest{
-		Model:        payload.Model,
-		Name:         payload.Name,
-		Instructions: payload.Instructions,
-	})
-	lvn.GinErr(c, 400, err, "unable to create assistant")
-	if err != nil {
+		c.Data(lvn.Res(400, nil, "name and model are required"))
 		return
 	}
 
 	record := models.OpenAIAssistant{
-		AssistantID: assistant.ID,
 		ProfileID:   user.ProfileID,
 		Name:        payload.Name,
 		GptModel:    payload.Model,
+		Temperature: payload.Temperature,
+	}
+	if payload.Instructions != nil {
+		record.Instructions = *payload.Instructions
+	}
+	if payload.Tools != nil {
+		record.Tools, err = json.Marshal(*payload.Tools)
+		lvn.GinErr(c, 400, err, "invalid tools")
+		if err != nil {
+			return
+		}
 	}
 
 	err = db.DB.Create(&record).Error
@@ -129,12 +123,9 @@ func CreateAssistant(c *gin.Context) {
 		return
 	}
 
-	c.Data(lvn.Res(200, assistantResponse{
-		ID:        record.ID,
-		Name:      record.Name,
-		Model:     record.GptModel,
-		CreatedAt: record.CreatedAt.Unix(),
-	}, "OK"))
+	svc_audit.Record(c, models.AuditCreate, "openai_assistant", strconv.FormatUint(uint64(record.ID), 10), "", nil, toAssistantAudit(record))
+
+	c.Data(lvn.Res(200, toAssistantResponse(record), "OK"))
 }
 
 func UpdateAssistant(c *gin.Context) {
@@ -152,8 +143,8 @@ func UpdateAssistant(c *gin.Context) {
 		return
 	}
 
-	if payload.Name == "" && payload.Model == "" && payload.Instructions == "" {
-		lvn.GinErr(c, 400, nil, "no fields provided")
+	if payload.Name == "" && payload.Model == "" && payload.Instructions == nil && payload.Tools == nil && payload.Temperature == nil {
+		c.Data(lvn.Res(400, nil, "no fields provided"))
 		return
 	}
 
@@ -164,28 +155,10 @@ func UpdateAssistant(c *gin.Context) {
 		return
 	}
 
-	client, err := openAIClient()
-	lvn.GinErr(c, 400, err, "unable to init ai client")
-	if err != nil {
-		return
-	}
+	// import first so the fields not in the payload keep the legacy config
+	importLegacy(&record)
 
-	updateReq := openaiv1.AssistantUpdateRequest{}
-	if payload.Name != "" {
-		updateReq.Name = &payload.Name
-	}
-	if payload.Model != "" {
-		updateReq.Model = &payload.Model
-	}
-	if payload.Instructions != "" {
-		updateReq.Instructions = &payload.Instructions
-	}
-
-	_, err = client.AssistantsUpdate(record.AssistantID, updateReq)
-	lvn.GinErr(c, 400, err, "unable to update assistant")
-	if err != nil {
-		return
-	}
+	before := toAssistantAudit(record)
 
 	updates := map[string]interface{}{}
 	if payload.Name != "" {
@@ -196,19 +169,31 @@ func UpdateAssistant(c *gin.Context) {
 		updates["gpt_model"] = payload.Model
 		record.GptModel = payload.Model
 	}
-	if len(updates) > 0 {
-		if err := db.DB.Model(&record).Updates(updates).Error; err != nil {
-			lvn.GinErr(c, 400, err, "unable to update assistant record")
+	if payload.Instructions != nil {
+		updates["instructions"] = *payload.Instructions
+		record.Instructions = *payload.Instructions
+	}
+	if payload.Tools != nil {
+		record.Tools, err = json.Marshal(*payload.Tools)
+		lvn.GinErr(c, 400, err, "invalid tools")
+		if err != nil {
 			return
 		}
+		updates["tools"] = record.Tools
+	}
+	if payload.Temperature != nil {
+		updates["temperature"] = *payload.Temperature
+		record.Temperature = payload.Temperature
 	}
 
-	c.Data(lvn.Res(200, assistantResponse{
-		ID:        record.ID,
-		Name:      record.Name,
-		Model:     record.GptModel,
-		CreatedAt: record.CreatedAt.Unix(),
-	}, "OK"))
+	if err := db.DB.Model(&record).Updates(updates).Error; err != nil {
+		lvn.GinErr(c, 400, err, "unable to update assistant record")
+		return
+	}
+
+	svc_audit.Record(c, models.AuditUpdate, "openai_assistant", strconv.FormatUint(uint64(record.ID), 10), "", before, toAssistantAudit(record))
+
+	c.Data(lvn.Res(200, toAssistantResponse(record), "OK"))
 }
 
 func DeleteAssistant(c *gin.Context) {
@@ -226,16 +211,13 @@ func DeleteAssistant(c *gin.Context) {
 		return
 	}
 
-	client, err := openAIClient()
-	lvn.GinErr(c, 400, err, "unable to init ai client")
-	if err != nil {
-		return
-	}
-
-	_, err = client.AssistantsDelete(record.AssistantID)
-	lvn.GinErr(c, 400, err, "unable to delete assistant")
-	if err != nil {
-		return
+	// legacy assistants also have an OpenAI object, it isn't used anymore
+	if record.AssistantID != "" {
+		if client, err := openAIClient(); err == nil {
+			if _, err := client.AssistantsDelete(record.AssistantID); err != nil {
+				log.Printf("openai: delete legacy assistant %s: %s", record.AssistantID, err.Error())
+			}
+		}
 	}
 
 	if err := db.DB.Delete(&record).Error; err != nil {
@@ -243,9 +225,63 @@ func DeleteAssistant(c *gin.Context) {
 		return
 	}
 
+	svc_audit.Record(c, models.AuditDelete, "openai_assistant", strconv.FormatUint(uint64(record.ID), 10), "", toAssistantAudit(record), nil)
+
 	c.Data(lvn.Res(200, gin.H{"deleted": record.ID}, "OK"))
 }
 
+// assistantAudit is the assistant as written to the audit log
+type assistantAudit struct {
+	AssistantID  string          `json:"assistantId,omitempty"`
+	Name         string          `json:"name"`
+	Model        string          `json:"model"`
+	Instructions string          `json:"instructions,omitempty"`
+	Tools        json.RawMessage `json:"tools,omitempty"`
+	Temperature  *float64        `json:"temperature,omitempty"`
+}
+
+func toAssistantAudit(a models.OpenAIAssistant) assistantAudit {
+	return assistantAudit{
+		AssistantID:  a.AssistantID,
+		Name:         a.Name,
+		Model:        a.GptModel,
+		Instructions: a.Instructions,
+		Tools:        json.RawMessage(a.Tools),
+		Temperature:  a.Temperature,
+	}
+}
+
+func toAssistantResponse(a models.OpenAIAssistant) assistantResponse {
+	tools, err := ResponseTools(a)
+	if err != nil {
+		tools = []openaiv1.ResponseTool{}
+	}
+	return assistantResponse{
+		ID:           a.ID,
+		Name:         a.Name,
+		Model:        a.GptModel,
+		Instructions: a.Instructions,
+		Tools:        tools,
+		Temperature:  a.Temperature,
+		CreatedAt:    a.CreatedAt.Unix(),
+	}
+}
+
+// importLegacy imports a legacy assistant's config when it is shown or
+// edited. Failures are logged, the import is retried on the next run.
+func importLegacy(record *models.OpenAIAssistant) {
+	if record.AssistantID == "" || record.ImportedAt != nil {
+		return
+	}
+	client, err := openAIClient()
+	if err == nil {
+		err = ImportLegacyAssistant(client, record)
+	}
+	if err != nil {
+		log.Printf("openai: %s", err.Error())
+	}
+}
+
 func ListModels(c *gin.Context) {
 	models := []gin.H{
 		{"id": "gpt-5.2", "name": "GPT-5.2"},
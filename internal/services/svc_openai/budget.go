@@ -0,0 +1,193 @@
+package svc_openai
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/mailer"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// ErrBudgetExceeded is returned for AI calls refused by a budget
+var ErrBudgetExceeded = errors.New("AI budget exceeded")
+
+// UsageSource is what an AI call is made for. The automator puts it in the
+// context of node executions so usage is attributed to the location and run.
+type UsageSource struct {
+	LocationID      string
+	AutomationRunID string
+}
+
+type usageSourceKey struct{}
+
+func WithUsageSource(ctx context.Context, source UsageSource) context.Context {
+	return context.WithValue(ctx, usageSourceKey{}, source)
+}
+
+func usageSourceFrom(ctx context.Context) UsageSource {
+	if ctx == nil {
+		return UsageSource{}
+	}
+	source, _ := ctx.Value(usageSourceKey{}).(UsageSource)
+	return source
+}
+
+// CheckBudget refuses the call if a budget of the profile that covers the
+// location in the context and the assistant has reached its limit. Limits
+// are soft: the cost of a call is only known once it returns, so nothing is
+// reserved up front and calls that pass the check together can overshoot
+// the limit by their cost.
+func CheckBudget(ctx context.Context, profileID, assistantID uint) error {
+	if profileID == 0 {
+		return nil
+	}
+
+	source := usageSourceFrom(ctx)
+	budgets, err := applicableBudgets(profileID, source.LocationID, assistantID)
+	if err != nil {
+		// don't stop automations because the budgets can't be loaded
+		log.Printf("openai: load budgets of profile %d: %s", profileID, err.Error())
+		return nil
+	}
+
+	now := time.Now()
+	for _, budget := range budgets {
+		spent, err := budgetSpent(budget, budgetPeriodStart(budget.Period, now))
+		if err != nil {
+			log.Printf("openai: sum spend of budget %d: %s", budget.ID, err.Error())
+			continue
+		}
+		if spent >= budget.LimitCredits {
+			return fmt.Errorf("%w: %s limit of %.2f credits reached%s", ErrBudgetExceeded, budget.Period, budget.LimitCredits, budgetScope(budget))
+		}
+	}
+	return nil
+}
+
+func applicableBudgets(profileID uint, locationID string, assistantID uint) ([]models.AIBudget, error) {
+	var budgets []models.AIBudget
+	err := db.DB.
+		Where("profile_id = ? AND is_active = ?", profileID, true).
+		Where("location_id = '' OR location_id = ?", locationID).
+		Where("assistant_id = 0 OR assistant_id = ?", assistantID).
+		Find(&budgets).Error
+	return budgets, err
+}
+
+func budgetSpent(budget models.AIBudget, periodStart time.Time) (float64, error) {
+	query := db.DB.Model(&models.AIUsageEvent{}).
+		Where("profile_id = ? AND created_at >= ?", budget.ProfileID, periodStart)
+	if budget.LocationID != "" {
+		query = query.Where("location_id = ?", budget.LocationID)
+	}
+	if budget.AssistantID != 0 {
+		query = query.Where("assistant_id = ?", budget.AssistantID)
+	}
+
+	var spent float64
+	err := query.Select("COALESCE(SUM(credits), 0)").Scan(&spent).Error
+	return spent, err
+}
+
+func budgetPeriodStart(period string, now time.Time) time.Time {
+	now = now.UTC()
+	if period == models.AIBudgetDaily {
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func budgetScope(budget models.AIBudget) string {
+	switch {
+	case budget.LocationID != "" && budget.AssistantID != 0:
+		return fmt.Sprintf(" for assistant %d in location %s", budget.AssistantID, budget.LocationID)
+	case budget.LocationID != "":
+		return " for location " + budget.LocationID
+	case budget.AssistantID != 0:
+		return fmt.Sprintf(" for assistant %d", budget.AssistantID)
+	}
+	return ""
+}
+
+// checkBudgetAlerts sends the alerts of the thresholds the spend has
+// crossed, each once per period. Reaching the limit always alerts.
+func checkBudgetAlerts(profileID uint, locationID string, assistantID uint) {
+	budgets, err := applicableBudgets(profileID, locationID, assistantID)
+	if err != nil {
+		log.Printf("openai: load budgets of profile %d: %s", profileID, err.Error())
+		return
+	}
+
+	now := time.Now()
+	for _, budget := range budgets {
+		if budget.LimitCredits <= 0 {
+			continue
+		}
+		periodStart := budgetPeriodStart(budget.Period, now)
+		spent, err := budgetSpent(budget, periodStart)
+		if err != nil {
+			log.Printf("openai: sum spend of budget %d: %s", budget.ID, err.Error())
+			continue
+		}
+
+		thresholds := append([]int{}, budget.AlertThresholds...)
+		if !slices.Contains(thresholds, 100) {
+			thresholds = append(thresholds, 100)
+		}
+		slices.Sort(thresholds)
+
+		// only the highest crossed threshold is worth an alert
+		crossed := 0
+		for _, threshold := range thresholds {
+			if threshold > 0 && spent >= budget.LimitCredits*float64(threshold)/100 {
+				crossed = threshold
+			}
+		}
+		if crossed == 0 {
+			continue
+		}
+
+		alert := models.AIBudgetAlert{
+			BudgetID:    budget.ID,
+			PeriodStart: periodStart,
+			Threshold:   crossed,
+			Credits:     spent,
+		}
+		res := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&alert)
+		if res.Error != nil {
+			log.Printf("openai: save alert of budget %d: %s", budget.ID, res.Error.Error())
+			continue
+		}
+		if res.RowsAffected == 0 {
+			continue // already sent this period
+		}
+
+		go sendBudgetAlert(budget, crossed, spent)
+	}
+}
+
+func sendBudgetAlert(budget models.AIBudget, threshold int, spent float64) {
+	subject := fmt.Sprintf("AI budget at %d%%", threshold)
+	if threshold >= 100 {
+		subject = "AI budget limit reached"
+	}
+	body := fmt.Sprintf("The %s AI budget%s has used %.2f of %.2f credits (%d%%).",
+		budget.Period, budgetScope(budget), spent, budget.LimitCredits, threshold)
+	if threshold >= 100 {
+		body += "\n\nAI nodes and the assistant are paused until the next period or until the limit is raised."
+	}
+
+	log.Printf("openai: budget %d of profile %d: %s", budget.ID, budget.ProfileID, body)
+	if len(budget.AlertEmails) == 0 || !mailer.Configured() {
+		return
+	}
+	if err := mailer.Send(budget.AlertEmails, subject, body); err != nil {
+		log.Printf("openai: send alert of budget %d: %s", budget.ID, err.Error())
+	}
+}
@@ -0,0 +1,302 @@
+package svc_openai
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/services/auth"
+	"client-runaway-zenoti/internal/services/svc_audit"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type budgetPayload struct {
+	LocationID      string   `json:"locationId"`
+	AssistantID     uint     `json:"assistantId"`
+	Period          string   `json:"period"`
+	LimitCredits    float64  `json:"limitCredits"`
+	AlertThresholds []int    `json:"alertThresholds"`
+	AlertEmails     []string `json:"alertEmails"`
+	IsActive        *bool    `json:"isActive,omitempty"`
+}
+
+type budgetResponse struct {
+	ID              uint     `json:"id"`
+	LocationID      string   `json:"locationId"`
+	AssistantID     uint     `json:"assistantId"`
+	Period          string   `json:"period"`
+	LimitCredits    float64  `json:"limitCredits"`
+	AlertThresholds []int    `json:"alertThresholds"`
+	AlertEmails     []string `json:"alertEmails"`
+	IsActive        bool     `json:"isActive"`
+	PeriodStart     string   `json:"periodStart"`
+	SpentCredits    float64  `json:"spentCredits"`
+}
+
+type usageBreakdownItem struct {
+	Key          string  `json:"key"` // location id, assistant id or automation run id
+	Calls        int     `json:"calls"`
+	InputTokens  int     `json:"inputTokens"`
+	OutputTokens int     `json:"outputTokens"`
+	Credits      float64 `json:"credits"`
+}
+
+func ListBudgets(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var budgets []models.AIBudget
+	err := scopeBudgets(c, db.DB.Where("profile_id = ?", user.ProfileID)).Order("created_at asc").Find(&budgets).Error
+	lvn.GinErr(c, 400, err, "unable to list budgets")
+	if err != nil {
+		return
+	}
+
+	resp := make([]budgetResponse, 0, len(budgets))
+	for _, b := range budgets {
+		resp = append(resp, toBudgetResponse(b))
+	}
+
+	c.Data(lvn.Res(200, resp, "OK"))
+}
+
+func CreateBudget(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	payload := budgetPayload{}
+	err := c.BindJSON(&payload)
+	lvn.GinErr(c, 400, err, "error while binding json")
+	if err != nil {
+		return
+	}
+
+	if msg := validateBudgetPayload(user.ProfileID, &payload); msg != "" {
+		c.Data(lvn.Res(400, nil, msg))
+		return
+	}
+	if !auth.CanSeeLocations(c, []string{payload.LocationID}) {
+		c.Data(lvn.Res(403, nil, "You don't have access to this location"))
+		return
+	}
+
+	record := models.AIBudget{ProfileID: user.ProfileID, IsActive: true}
+	applyBudgetPayload(&record, payload)
+
+	err = db.DB.Create(&record).Error
+	lvn.GinErr(c, 400, err, "unable to save budget")
+	if err != nil {
+		return
+	}
+
+	svc_audit.Record(c, models.AuditCreate, "ai_budget", strconv.FormatUint(uint64(record.ID), 10), record.LocationID, nil, record)
+
+	c.Data(lvn.Res(200, toBudgetResponse(record), "OK"))
+}
+
+func UpdateBudget(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	id, err := strconv.ParseUint(c.Param("budgetId"), 10, 64)
+	lvn.GinErr(c, 400, err, "invalid budget id")
+	if err != nil {
+		return
+	}
+
+	payload := budgetPayload{}
+	err = c.BindJSON(&payload)
+	lvn.GinErr(c, 400, err, "error while binding json")
+	if err != nil {
+		return
+	}
+
+	if msg := validateBudgetPayload(user.ProfileID, &payload); msg != "" {
+		c.Data(lvn.Res(400, nil, msg))
+		return
+	}
+	if !auth.CanSeeLocations(c, []string{payload.LocationID}) {
+		c.Data(lvn.Res(403, nil, "You don't have access to this location"))
+		return
+	}
+
+	var record models.AIBudget
+	err = scopeBudgets(c, db.DB.Where("profile_id = ? AND id = ?", user.ProfileID, id)).First(&record).Error
+	lvn.GinErr(c, 404, err, "budget not found")
+	if err != nil {
+		return
+	}
+
+	before := record
+	applyBudgetPayload(&record, payload)
+
+	err = db.DB.Model(&record).
+		Select("location_id", "assistant_id", "period", "limit_credits", "alert_thresholds", "alert_emails", "is_active").
+		Updates(&record).Error
+	lvn.GinErr(c, 400, err, "unable to update budget")
+	if err != nil {
+		return
+	}
+
+	svc_audit.Record(c, models.AuditUpdate, "ai_budget", strconv.FormatUint(uint64(record.ID), 10), record.LocationID, before, record)
+
+	c.Data(lvn.Res(200, toBudgetResponse(record), "OK"))
+}
+
+func DeleteBudget(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	id, err := strconv.ParseUint(c.Param("budgetId"), 10, 64)
+	lvn.GinErr(c, 400, err, "invalid budget id")
+	if err != nil {
+		return
+	}
+
+	var record models.AIBudget
+	err = scopeBudgets(c, db.DB.Where("profile_id = ? AND id = ?", user.ProfileID, id)).First(&record).Error
+	lvn.GinErr(c, 404, err, "budget not found")
+	if err != nil {
+		return
+	}
+
+	err = db.DB.Delete(&record).Error
+	lvn.GinErr(c, 400, err, "unable to delete budget")
+	if err != nil {
+		return
+	}
+
+	svc_audit.Record(c, models.AuditDelete, "ai_budget", strconv.FormatUint(uint64(record.ID), 10), record.LocationID, record, nil)
+
+	c.Data(lvn.Res(200, gin.H{"deleted": record.ID}, "OK"))
+}
+
+// ListUsageBreakdown sums the AI usage per location, assistant or
+// automation run. Accepts ?groupBy=location|assistant|run and the same date
+// range as ListUsage.
+func ListUsageBreakdown(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	column := map[string]string{
+		"location":  "location_id",
+		"assistant": "assistant_id",
+		"run":       "automation_run_id",
+	}[c.DefaultQuery("groupBy", "location")]
+	if column == "" {
+		c.Data(lvn.Res(400, nil, "groupBy must be location, assistant or run"))
+		return
+	}
+
+	start, end, err := usageRange(c)
+	lvn.GinErr(c, 400, err, "invalid date range")
+	if err != nil {
+		return
+	}
+
+	query := db.DB.Model(&models.AIUsageEvent{}).
+		Where("profile_id = ? AND created_at >= ? AND created_at < ?", user.ProfileID, start, end)
+	if locationID := c.Query("locationId"); locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+	if allowed := auth.AllowedLocationIds(c); allowed != nil {
+		query = query.Where("location_id IN ?", allowed)
+	}
+
+	resp := []usageBreakdownItem{}
+	err = query.
+		Select(fmt.Sprintf("CAST(%s AS text) AS key, COUNT(*) AS calls, SUM(input_tokens) AS input_tokens, SUM(output_tokens) AS output_tokens, SUM(credits) AS credits", column)).
+		Group(column).
+		Order("credits desc").
+		Scan(&resp).Error
+	lvn.GinErr(c, 400, err, "unable to list usage")
+	if err != nil {
+		return
+	}
+
+	c.Data(lvn.Res(200, resp, "OK"))
+}
+
+// scopeBudgets limits a budget query to the locations the member may see.
+// Profile-wide budgets cover every location, so restricted members don't
+// see them.
+func scopeBudgets(c *gin.Context, query *gorm.DB) *gorm.DB {
+	if allowed := auth.AllowedLocationIds(c); allowed != nil {
+		query = query.Where("location_id IN ?", allowed)
+	}
+	return query
+}
+
+// validateBudgetPayload normalizes the payload and returns the error
+// message for invalid ones
+func validateBudgetPayload(profileID uint, payload *budgetPayload) string {
+	payload.Period = strings.ToLower(strings.TrimSpace(payload.Period))
+	if payload.Period != models.AIBudgetDaily && payload.Period != models.AIBudgetMonthly {
+		return "period must be daily or monthly"
+	}
+	if payload.LimitCredits <= 0 {
+		return "limitCredits must be positive"
+	}
+	for _, threshold := range payload.AlertThresholds {
+		if threshold < 1 || threshold > 100 {
+			return "alert thresholds must be between 1 and 100 percent"
+		}
+	}
+	for i, email := range payload.AlertEmails {
+		payload.AlertEmails[i] = strings.TrimSpace(email)
+		if !strings.Contains(payload.AlertEmails[i], "@") {
+			return fmt.Sprintf("invalid alert email %q", email)
+		}
+	}
+
+	payload.LocationID = strings.TrimSpace(payload.LocationID)
+	if payload.LocationID != "" {
+		var count int64
+		db.DB.Model(&models.Location{}).Where("id = ? AND profile_id = ?", payload.LocationID, profileID).Count(&count)
+		if count == 0 {
+			return "location not found"
+		}
+	}
+	if payload.AssistantID != 0 {
+		var count int64
+		db.DB.Model(&models.OpenAIAssistant{}).Where("id = ? AND profile_id = ?", payload.AssistantID, profileID).Count(&count)
+		if count == 0 {
+			return "assistant not found"
+		}
+	}
+	return ""
+}
+
+func applyBudgetPayload(record *models.AIBudget, payload budgetPayload) {
+	record.LocationID = payload.LocationID
+	record.AssistantID = payload.AssistantID
+	record.Period = payload.Period
+	record.LimitCredits = payload.LimitCredits
+	record.AlertThresholds = payload.AlertThresholds
+	record.AlertEmails = payload.AlertEmails
+	if payload.IsActive != nil {
+		record.IsActive = *payload.IsActive
+	}
+}
+
+func toBudgetResponse(b models.AIBudget) budgetResponse {
+	periodStart := budgetPeriodStart(b.Period, time.Now())
+	spent, _ := budgetSpent(b, periodStart)
+
+	resp := budgetResponse{
+		ID:              b.ID,
+		LocationID:      b.LocationID,
+		AssistantID:     b.AssistantID,
+		Period:          b.Period,
+		LimitCredits:    b.LimitCredits,
+		AlertThresholds: b.AlertThresholds,
+		AlertEmails:     b.AlertEmails,
+		IsActive:        b.IsActive,
+		PeriodStart:     periodStart.Format("2006-01-02"),
+		SpentCredits:    spent,
+	}
+	if resp.AlertThresholds == nil {
+		resp.AlertThresholds = []int{}
+	}
+	if resp.AlertEmails == nil {
+		resp.AlertEmails = []string{}
+	}
+	return resp
+}
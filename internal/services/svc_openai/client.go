@@ -2,6 +2,7 @@ package svc_openai
 
 import (
 	"client-runaway-zenoti/internal/config"
+	"client-runaway-zenoti/internal/llm"
 	openaiv1 "client-runaway-zenoti/packages/openaiV1"
 	"fmt"
 )
@@ -21,3 +22,15 @@ func openAIClient() (openaiv1.Client, error) {
 
 	return service.NewClient("")
 }
+
+// aiProvider returns the provider assistants run on
+func aiProvider() (llm.Provider, error) {
+	settings := config.Confs.Settings
+	return llm.New(llm.Settings{
+		Provider:     settings.AIProvider,
+		APIKey:       settings.OpenAIAPIKey,
+		BaseURL:      settings.OpenAIBaseURL,
+		Organization: settings.OpenAIOrganization,
+		Project:      settings.OpenAIProject,
+	})
+}
@@ -3,6 +3,8 @@ package svc_openai
 import (
 	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/llm"
+	"client-runaway-zenoti/internal/services/svc_audit"
 	"strings"
 	"time"
 
@@ -17,12 +19,14 @@ type pricingPayload struct {
 }
 
 type pricingBatchItem struct {
+	Provider         string  `json:"provider"` // defaults to openai
 	Model            string  `json:"model"`
 	InputCentsPer1K  float64 `json:"inputCentsPer1k"`
 	OutputCentsPer1K float64 `json:"outputCentsPer1k"`
 }
 
 type pricingResponse struct {
+	Provider         string  `json:"provider"`
 	Model            string  `json:"model"`
 	InputCentsPer1K  float64 `json:"inputCentsPer1k"`
 	OutputCentsPer1K float64 `json:"outputCentsPer1k"`
@@ -32,7 +36,7 @@ type pricingResponse struct {
 func ListPricing(c *gin.Context) {
 	var pricing []models.OpenAIModelPricing
 
-	err := db.DB.Order("model asc").Find(&pricing).Error
+	err := db.DB.Order("provider asc, model asc").Find(&pricing).Error
 	lvn.GinErr(c, 400, err, "unable to list pricing")
 	if err != nil {
 		return
@@ -41,6 +45,7 @@ func ListPricing(c *gin.Context) {
 	resp := make([]pricingResponse, 0, len(pricing))
 	for _, p := range pricing {
 		resp = append(resp, pricingResponse{
+			Provider:         p.Provider,
 			Model:            p.Model,
 			InputCentsPer1K:  p.InputCentsPer1K,
 			OutputCentsPer1K: p.OutputCentsPer1K,
@@ -51,7 +56,10 @@ func ListPricing(c *gin.Context) {
 	c.Data(lvn.Res(200, resp, "OK"))
 }
 
+// UpsertPricing sets the pricing of a model, of the provider in ?provider=
+// (openai by default)
 func UpsertPricing(c *gin.Context) {
+	provider := strings.TrimSpace(c.DefaultQuery("provider", llm.ProviderOpenAI))
 	model := strings.TrimSpace(c.Param("model"))
 	if model == "" {
 		lvn.GinErr(c, 400, nil, "model is required")
@@ -71,17 +79,19 @@ func UpsertPricing(c *gin.Context) {
 	}
 
 	record := models.OpenAIModelPricing{
+		Provider:         provider,
 		Model:            model,
 		InputCentsPer1K:  payload.InputCentsPer1K,
 		OutputCentsPer1K: payload.OutputCentsPer1K,
 	}
+	existing := existingPricing([]pricingBatchItem{{Provider: provider, Model: model}})
 
 	err = db.DB.Clauses(clause.OnConflict{
-		Columns: []clause.Column{{Name: "model"}},
+		Columns: []clause.Column{{Name: "provider"}, {Name: "model"}},
 		DoUpdates: clause.Assignments(map[string]interface{}{
-			"input_cents_per1k":  payload.InputCentsPer1K,
-			"output_cents_per1k": payload.OutputCentsPer1K,
-			"updated_at":         time.Now().UTC(),
+			"input_cents_per1_k":  payload.InputCentsPer1K,
+			"output_cents_per1_k": payload.OutputCentsPer1K,
+			"updated_at":          time.Now().UTC(),
 		}),
 	}).Create(&record).Error
 	lvn.GinErr(c, 400, err, "unable to save pricing")
@@ -89,7 +99,15 @@ func UpsertPricing(c *gin.Context) {
 		return
 	}
 
+	auditPricing(c, existing, pricingBatchItem{
+		Provider:         provider,
+		Model:            model,
+		InputCentsPer1K:  payload.InputCentsPer1K,
+		OutputCentsPer1K: payload.OutputCentsPer1K,
+	})
+
 	resp := pricingResponse{
+		Provider:         record.Provider,
 		Model:            record.Model,
 		InputCentsPer1K:  record.InputCentsPer1K,
 		OutputCentsPer1K: record.OutputCentsPer1K,
@@ -112,9 +130,13 @@ func UpsertPricingBatch(c *gin.Context) {
 		return
 	}
 
-	orderedModels := make([]string, 0, len(payload))
-	itemsByModel := make(map[string]pricingBatchItem, len(payload))
+	orderedKeys := make([]string, 0, len(payload))
+	itemsByKey := make(map[string]pricingBatchItem, len(payload))
 	for _, item := range payload {
+		provider := strings.TrimSpace(item.Provider)
+		if provider == "" {
+			provider = llm.ProviderOpenAI
+		}
 		model := strings.TrimSpace(item.Model)
 		if model == "" {
 			lvn.GinErr(c, 400, nil, "model is required")
@@ -125,27 +147,34 @@ func UpsertPricingBatch(c *gin.Context) {
 			return
 		}
 
-		if _, exists := itemsByModel[model]; !exists {
-			orderedModels = append(orderedModels, model)
-		}
+		item.Provider = provider
 		item.Model = model
-		itemsByModel[model] = item
+		key := pricingKey(provider, model)
+		if _, exists := itemsByKey[key]; !exists {
+			orderedKeys = append(orderedKeys, key)
+		}
+		itemsByKey[key] = item
 	}
 
 	now := time.Now().UTC()
-	records := make([]models.OpenAIModelPricing, 0, len(orderedModels))
-	for _, model := range orderedModels {
-		item := itemsByModel[model]
+	items := make([]pricingBatchItem, 0, len(orderedKeys))
+	records := make([]models.OpenAIModelPricing, 0, len(orderedKeys))
+	for _, key := range orderedKeys {
+		item := itemsByKey[key]
+		items = append(items, item)
 		records = append(records, models.OpenAIModelPricing{
-			Model:            model,
+			Provider:         item.Provider,
+			Model:            item.Model,
 			InputCentsPer1K:  item.InputCentsPer1K,
 			OutputCentsPer1K: item.OutputCentsPer1K,
 			UpdatedAt:        now,
 		})
 	}
 
+	existing := existingPricing(items)
+
 	err = db.DB.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "model"}},
+		Columns:   []clause.Column{{Name: "provider"}, {Name: "model"}},
 		DoUpdates: clause.AssignmentColumns([]string{"input_cents_per1_k", "output_cents_per1_k", "updated_at"}),
 	}).Create(&records).Error
 	lvn.GinErr(c, 400, err, "unable to save pricing")
@@ -153,11 +182,12 @@ func UpsertPricingBatch(c *gin.Context) {
 		return
 	}
 
-	resp := make([]pricingResponse, 0, len(orderedModels))
-	for _, model := range orderedModels {
-		item := itemsByModel[model]
+	resp := make([]pricingResponse, 0, len(items))
+	for _, item := range items {
+		auditPricing(c, existing, item)
 		resp = append(resp, pricingResponse{
-			Model:            model,
+			Provider:         item.Provider,
+			Model:            item.Model,
 			InputCentsPer1K:  item.InputCentsPer1K,
 			OutputCentsPer1K: item.OutputCentsPer1K,
 			UpdatedAt:        now.Unix(),
@@ -166,3 +196,40 @@ func UpsertPricingBatch(c *gin.Context) {
 
 	c.Data(lvn.Res(200, resp, "OK"))
 }
+
+// existingPricing loads the current pricing of the items, keyed by pricingKey
+func existingPricing(items []pricingBatchItem) map[string]pricingBatchItem {
+	pairs := make([][]interface{}, 0, len(items))
+	for _, item := range items {
+		pairs = append(pairs, []interface{}{item.Provider, item.Model})
+	}
+
+	var pricing []models.OpenAIModelPricing
+	db.DB.Where("(provider, model) IN ?", pairs).Find(&pricing)
+
+	res := make(map[string]pricingBatchItem, len(pricing))
+	for _, p := range pricing {
+		res[pricingKey(p.Provider, p.Model)] = pricingBatchItem{
+			Provider:         p.Provider,
+			Model:            p.Model,
+			InputCentsPer1K:  p.InputCentsPer1K,
+			OutputCentsPer1K: p.OutputCentsPer1K,
+		}
+	}
+	return res
+}
+
+func auditPricing(c *gin.Context, existing map[string]pricingBatchItem, item pricingBatchItem) {
+	key := pricingKey(item.Provider, item.Model)
+	if before, ok := existing[key]; ok {
+		if before != item {
+			svc_audit.Record(c, models.AuditUpdate, "openai_model_pricing", key, "", before, item)
+		}
+		return
+	}
+	svc_audit.Record(c, models.AuditCreate, "openai_model_pricing", key, "", nil, item)
+}
+
+func pricingKey(provider, model string) string {
+	return provider + "/" + model
+}
@@ -0,0 +1,109 @@
+package svc_openai
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	openaiv1 "client-runaway-zenoti/packages/openaiV1"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ImportLegacyAssistant copies the model, instructions and tools of an
+// assistant created with the Assistants API into the local record. Records
+// without an OpenAI assistant id, or already imported, are left alone.
+func ImportLegacyAssistant(client openaiv1.Client, record *models.OpenAIAssistant) error {
+	if record.AssistantID == "" || record.ImportedAt != nil {
+		return nil
+	}
+
+	remote, err := client.AssistantsGet(record.AssistantID)
+	if err != nil {
+		return fmt.Errorf("import assistant %s: %w", record.AssistantID, err)
+	}
+
+	tools, err := json.Marshal(legacyResponseTools(remote.Tools))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	record.Instructions = remote.Instructions
+	record.Tools = tools
+	record.ImportedAt = &now
+	if record.GptModel == "" {
+		record.GptModel = remote.Model
+	}
+	if record.Name == "" {
+		record.Name = remote.Name
+	}
+
+	if record.ID == 0 {
+		return db.DB.Create(record).Error
+	}
+	return db.DB.Model(record).
+		Select("instructions", "tools", "imported_at", "gpt_model", "name").
+		Updates(record).Error
+}
+
+// ResponseTools returns the tools stored on the assistant
+func ResponseTools(record models.OpenAIAssistant) ([]openaiv1.ResponseTool, error) {
+	tools := []openaiv1.ResponseTool{}
+	if len(record.Tools) == 0 {
+		return tools, nil
+	}
+	if err := json.Unmarshal(record.Tools, &tools); err != nil {
+		return nil, fmt.Errorf("assistant %d has invalid tools: %w", record.ID, err)
+	}
+	return tools, nil
+}
+
+// HostedTools returns the assistant's built-in provider tools, e.g.
+// code_interpreter. Function tools are left out, the caller provides them.
+func HostedTools(record models.OpenAIAssistant) ([]map[string]interface{}, error) {
+	tools := []map[string]interface{}{}
+	if len(record.Tools) == 0 {
+		return tools, nil
+	}
+
+	stored := []map[string]interface{}{}
+	if err := json.Unmarshal(record.Tools, &stored); err != nil {
+		return nil, fmt.Errorf("assistant %d has invalid tools: %w", record.ID, err)
+	}
+	for _, tool := range stored {
+		if tool["type"] == "function" {
+			continue
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
+
+// legacyResponseTools converts Assistants API tools. File search is dropped,
+// its vector stores were attached to the assistant and aren't carried over.
+func legacyResponseTools(tools []openaiv1.Tool) []openaiv1.ResponseTool {
+	converted := []openaiv1.ResponseTool{}
+	for _, tool := range tools {
+		switch tool.Type {
+		case "function":
+			if tool.Function == nil {
+				continue
+			}
+			converted = append(converted, openaiv1.ResponseTool{
+				Type:        "function",
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  tool.Function.Parameters,
+			})
+		case "code_interpreter":
+			converted = append(converted, openaiv1.ResponseTool{
+				Type:      "code_interpreter",
+				Container: map[string]string{"type": "auto"},
+			})
+		default:
+			log.Printf("openai: assistant tool %q is not supported by the Responses API, skipped", tool.Type)
+		}
+	}
+	return converted
+}
@@ -3,7 +3,9 @@ package svc_openai
 import (
 	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
-	openaiv1 "client-runaway-zenoti/packages/openaiV1"
+	"client-runaway-zenoti/internal/llm"
+	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
@@ -13,7 +15,29 @@ import (
 	"gorm.io/gorm/clause"
 )
 
-func RunThroughAssistant(id, text string) (string, error) {
+func RunThroughAssistant(ctx context.Context, id, text string) (string, error) {
+	return runAssistantResponse(ctx, id, text, nil)
+}
+
+// ExtractThroughAssistant runs the text through the assistant with a JSON
+// schema as the response format and returns the decoded object.
+func ExtractThroughAssistant(ctx context.Context, id, text string, schema map[string]interface{}) (map[string]interface{}, error) {
+	responseText, err := runAssistantResponse(ctx, id, text, &llm.Schema{
+		Name:   "extraction",
+		Schema: schema,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(responseText), &result); err != nil {
+		return nil, fmt.Errorf("assistant returned invalid json: %w", err)
+	}
+	return result, nil
+}
+
+func runAssistantResponse(ctx context.Context, id, text string, schema *llm.Schema) (string, error) {
 	assistantID, err := parseAssistantID(id)
 	if err != nil {
 		return "", err
@@ -29,55 +53,60 @@ func RunThroughAssistant(id, text string) (string, error) {
 		return "", fmt.Errorf("assistant not found")
 	}
 
-	client, err := openAIClient()
-	if err != nil {
+	if err := CheckBudget(ctx, assistant.ProfileID, assistant.ID); err != nil {
 		return "", err
 	}
 
-	thread, err := client.ThreadsCreate(openaiv1.ThreadRequest{})
-	if err != nil {
-		return "", err
+	if assistant.AssistantID != "" && assistant.ImportedAt == nil {
+		client, err := openAIClient()
+		if err != nil {
+			return "", err
+		}
+		if err := ImportLegacyAssistant(client, &assistant); err != nil {
+			return "", err
+		}
 	}
 
-	_, err = client.ThreadsMessagesCreate(thread.ID, openaiv1.ThreadMessageRequest{
-		Role:    "user",
-		Content: text,
-	})
+	provider, err := aiProvider()
 	if err != nil {
 		return "", err
 	}
 
-	run, err := client.RunsCreate(thread.ID, openaiv1.RunRequest{
-		AssistantID: assistant.AssistantID,
-	})
+	tools, err := HostedTools(assistant)
 	if err != nil {
 		return "", err
 	}
 
-	run, err = waitForRunCompletion(client, thread.ID, run.ID)
+	// stream like the internal assistant does; the deltas back up the final
+	// text in case the completed event carries no output
+	var streamed strings.Builder
+	resp, err := provider.Respond(ctx, llm.Request{
+		Model:        assistant.GptModel,
+		Instructions: assistant.Instructions,
+		Input:        []llm.Message{{Role: "user", Content: text}},
+		HostedTools:  tools,
+		Schema:       schema,
+		Temperature:  assistant.Temperature,
+	}, func(delta string) { streamed.WriteString(delta) })
 	if err != nil {
 		return "", err
 	}
 
-	modelName := run.Model
+	modelName := resp.Model
 	if modelName == "" {
 		modelName = assistant.GptModel
 	}
-	if modelName != "" {
-		err = recordAIUsage(assistant.ProfileID, modelName, run.Usage)
-		if err != nil {
-			return "", err
-		}
-	}
-
-	messages, err := client.ThreadsMessagesList(thread.ID, 20)
+	err = RecordAIUsage(ctx, provider.Name(), assistant.ProfileID, assistant.ID, modelName, resp.Usage)
 	if err != nil {
 		return "", err
 	}
 
-	responseText, err := firstAssistantMessage(messages)
-	if err != nil {
-		return "", err
+	responseText := resp.Text
+	if responseText == "" {
+		responseText = streamed.String()
+	}
+	if responseText == "" {
+		return "", fmt.Errorf("assistant message not found")
 	}
 
 	return responseText, nil
@@ -95,41 +124,10 @@ func parseAssistantID(raw string) (uint, error) {
 	return uint(parsed), nil
 }
 
-func waitForRunCompletion(client openaiv1.Client, threadID, runID string) (openaiv1.Run, error) {
-	run := openaiv1.Run{}
-	for i := 0; i < 30; i++ {
-		res, err := client.RunsGet(threadID, runID)
-		if err != nil {
-			return run, err
-		}
-		run = res
-		switch run.Status {
-		case "completed":
-			return run, nil
-		case "failed", "cancelled", "expired", "requires_action":
-			return run, fmt.Errorf("run %s", run.Status)
-		}
-		time.Sleep(2 * time.Second)
-	}
-
-	return run, fmt.Errorf("run timed out")
-}
-
-func firstAssistantMessage(messages openaiv1.ThreadMessagesListResponse) (string, error) {
-	for _, msg := range messages.Data {
-		if msg.Role != "assistant" {
-			continue
-		}
-		for _, item := range msg.Content {
-			if item.Type == "text" && item.Text != nil {
-				return item.Text.Value, nil
-			}
-		}
-	}
-	return "", fmt.Errorf("assistant message not found")
-}
-
-func recordAIUsage(profileID uint, model string, usage openaiv1.ResponseUsage) error {
+// RecordAIUsage adds a call's tokens and credits to the profile's daily
+// usage and to the usage events the budgets are checked against. Credits
+// come from the pricing of the provider's model.
+func RecordAIUsage(ctx context.Context, provider string, profileID, assistantID uint, model string, usage llm.Usage) error {
 	if profileID == 0 || model == "" {
 		return nil
 	}
@@ -138,31 +136,35 @@ func recordAIUsage(profileID uint, model string, usage openaiv1.ResponseUsage) e
 	usageDate = time.Date(usageDate.Year(), usageDate.Month(), usageDate.Day(), 0, 0, 0, 0, time.UTC)
 
 	pricing := models.OpenAIModelPricing{}
-	pricingErr := db.DB.First(&pricing, "model = ?", model).Error
+	pricingErr := db.DB.First(&pricing, "provider = ? AND model = ?", provider, model).Error
+
+	inputTokens := usage.InputTokens
+	outputTokens := usage.OutputTokens
 
 	inputCost := 0.0
 	outputCost := 0.0
 	if pricingErr == nil {
-		inputCost = (float64(usage.InputTokens) + float64(usage.PromptTokens)/1000.0) * pricing.InputCentsPer1K
-		outputCost = (float64(usage.OutputTokens) + float64(usage.CompletionTokens)/1000.0) * pricing.OutputCentsPer1K
+		inputCost = float64(inputTokens) / 1000.0 * pricing.InputCentsPer1K
+		outputCost = float64(outputTokens) / 1000.0 * pricing.OutputCentsPer1K
 	}
 
 	costCents := inputCost + outputCost
 
 	record := models.OpenAIUsageDaily{
 		ProfileID:    profileID,
+		Provider:     provider,
 		Model:        model,
 		UsageDate:    usageDate,
-		InputTokens:  usage.InputTokens + usage.PromptTokens,
-		OutputTokens: usage.OutputTokens + usage.CompletionTokens,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
 		Credits:      costCents,
 	}
 
 	tbl := "open_ai_usage_dailies"
 
-	return db.DB.Clauses(clause.OnConflict{
+	err := db.DB.Clauses(clause.OnConflict{
 		Columns: []clause.Column{
-			{Name: "profile_id"}, {Name: "model"}, {Name: "usage_date"},
+			{Name: "profile_id"}, {Name: "provider"}, {Name: "model"}, {Name: "usage_date"},
 		},
 		DoUpdates: clause.Assignments(map[string]interface{}{
 			"input_tokens":  gorm.Expr(tbl + ".input_tokens + EXCLUDED.input_tokens"),
@@ -171,4 +173,26 @@ func recordAIUsage(profileID uint, model string, usage openaiv1.ResponseUsage) e
 			"updated_at":    time.Now().UTC(),
 		}),
 	}).Create(&record).Error
+	if err != nil {
+		return err
+	}
+
+	source := usageSourceFrom(ctx)
+	err = db.DB.Create(&models.AIUsageEvent{
+		ProfileID:       profileID,
+		LocationID:      source.LocationID,
+		AssistantID:     assistantID,
+		AutomationRunID: source.AutomationRunID,
+		Provider:        provider,
+		Model:           model,
+		InputTokens:     inputTokens,
+		OutputTokens:    outputTokens,
+		Credits:         costCents,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	checkBudgetAlerts(profileID, source.LocationID, assistantID)
+	return nil
 }
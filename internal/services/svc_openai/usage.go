@@ -12,6 +12,7 @@ import (
 
 type usageResponse struct {
 	Date         string  `json:"date"`
+	Provider     string  `json:"provider"`
 	Model        string  `json:"model"`
 	InputTokens  int     `json:"inputTokens"`
 	OutputTokens int     `json:"outputTokens"`
@@ -28,6 +29,9 @@ func ListUsage(c *gin.Context) {
 	}
 
 	query := db.DB.Where("profile_id = ? AND usage_date >= ? AND usage_date < ?", user.ProfileID, start, end)
+	if provider := c.Query("provider"); provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
 	if model := c.Query("model"); model != "" {
 		query = query.Where("model = ?", model)
 	}
@@ -43,6 +47,7 @@ func ListUsage(c *gin.Context) {
 	for _, u := range usage {
 		resp = append(resp, usageResponse{
 			Date:         u.UsageDate.Format("2006-01-02"),
+			Provider:     u.Provider,
 			Model:        u.Model,
 			InputTokens:  u.InputTokens,
 			OutputTokens: u.OutputTokens,
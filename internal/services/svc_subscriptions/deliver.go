@@ -0,0 +1,107 @@
+package svc_subscriptions
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/mailer"
+	"client-runaway-zenoti/internal/tgbot"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// DeliverDue sends the subscriptions whose send time has come. Runs missed
+// while the server was down send only the latest period.
+func DeliverDue() {
+	var subs []models.ReportSubscription
+	err := db.DB.Where("enabled AND next_run_at <= ?", time.Now()).Find(&subs).Error
+	if err != nil {
+		log.Printf("subscriptions: load due subscriptions: %s", err.Error())
+		return
+	}
+
+	for _, sub := range subs {
+		deliver(sub, sub.NextRunAt)
+
+		next, err := NextRun(sub, time.Now())
+		if err != nil {
+			// can't be scheduled any more, stop retrying every tick
+			log.Printf("subscriptions: schedule subscription %d: %s", sub.ID, err.Error())
+			db.DB.Model(&sub).Update("enabled", false)
+			continue
+		}
+		if err := db.DB.Model(&sub).Update("next_run_at", next).Error; err != nil {
+			log.Printf("subscriptions: save next run of subscription %d: %s", sub.ID, err.Error())
+		}
+	}
+}
+
+// deliver sends the report of the period ended before runAt and logs the
+// delivery
+func deliver(sub models.ReportSubscription, runAt time.Time) models.ReportDelivery {
+	delivery := models.ReportDelivery{
+		SubscriptionId: sub.ID,
+		ProfileId:      sub.ProfileId,
+		Channel:        sub.Channel,
+		Recipients:     sub.Recipients,
+		Status:         models.ReportDeliverySent,
+	}
+
+	err := func() error {
+		start, end, err := ReportPeriod(sub, runAt)
+		if err != nil {
+			return err
+		}
+		delivery.PeriodStart, delivery.PeriodEnd = start, end
+
+		file, err := render(sub, start, end)
+		if err != nil {
+			return fmt.Errorf("render: %w", err)
+		}
+		delivery.FileName = file.Name
+
+		return send(sub, file)
+	}()
+	if err != nil {
+		delivery.Status = models.ReportDeliveryFailed
+		delivery.Error = err.Error()
+		log.Printf("subscriptions: deliver subscription %d: %s", sub.ID, err.Error())
+		tgbot.Notify("Report subscriptions", fmt.Sprintf("profile %d: subscription \"%s\" failed: %s", sub.ProfileId, sub.Name, err.Error()), false)
+	} else {
+		now := time.Now()
+		db.DB.Model(&sub).Update("last_sent_at", now)
+	}
+
+	if err := db.DB.Create(&delivery).Error; err != nil {
+		log.Printf("subscriptions: log delivery of subscription %d: %s", sub.ID, err.Error())
+	}
+	return delivery
+}
+
+func send(sub models.ReportSubscription, file rendered) error {
+	switch sub.Channel {
+	case models.ReportChannelEmail:
+		body := file.Summary + "\n\nThe full report is attached."
+		return mailer.SendWithAttachments(sub.Recipients, sub.Name, body, []mailer.Attachment{{
+			Name:        file.Name,
+			ContentType: file.ContentType,
+			Data:        file.Data,
+		}})
+	case models.ReportChannelTelegram:
+		var errs []error
+		for _, recipient := range sub.Recipients {
+			chatId, err := strconv.ParseInt(recipient, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("chat id %q: %w", recipient, err))
+				continue
+			}
+			if err := tgbot.SendDocument(chatId, file.Name, file.Data, file.Summary); err != nil {
+				errs = append(errs, fmt.Errorf("chat %d: %w", chatId, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+	return fmt.Errorf("unknown channel %q", sub.Channel)
+}
@@ -0,0 +1,150 @@
+package svc_subscriptions
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 in points
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	pageMargin = 40.0
+)
+
+type (
+	// pdfDoc is a text-only PDF using the standard Helvetica fonts, enough
+	// for tabular summaries without pulling in a PDF library
+	pdfDoc struct {
+		pages []*bytes.Buffer
+		y     float64 // baseline of the next line on the last page
+	}
+
+	// pdfCol is a column of a table row, right aligned columns end at X
+	pdfCol struct {
+		X     float64
+		Text  string
+		Right bool
+	}
+)
+
+func newPDF() *pdfDoc {
+	d := &pdfDoc{}
+	d.newPage()
+	return d
+}
+
+func (d *pdfDoc) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pageHeight - pageMargin
+}
+
+// row writes the columns on the next line, breaking the page when full
+func (d *pdfDoc) row(size float64, bold bool, cols ...pdfCol) {
+	lineHeight := size * 1.4
+	if d.y-lineHeight < pageMargin {
+		d.newPage()
+	}
+	d.y -= lineHeight
+
+	font := fontName(bold)
+	page := d.pages[len(d.pages)-1]
+	for _, col := range cols {
+		text := winAnsi(col.Text)
+		x := col.X
+		if col.Right {
+			x -= textWidth(text, size)
+		}
+		fmt.Fprintf(page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, d.y, escapePDF(text))
+	}
+}
+
+// text writes a line at the left margin
+func (d *pdfDoc) text(size float64, bold bool, s string) {
+	d.row(size, bold, pdfCol{X: pageMargin, Text: s})
+}
+
+// gap leaves vertical space
+func (d *pdfDoc) gap(points float64) {
+	d.y -= points
+}
+
+func (d *pdfDoc) bytes() []byte {
+	out := &bytes.Buffer{}
+	offsets := []int{}
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	// 1 catalog, 2 page tree, 3 and 4 fonts, then a page and its content per page
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range d.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+2*i))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+func fontName(bold bool) string {
+	if bold {
+		return "F2"
+	}
+	return "F1"
+}
+
+// winAnsi keeps the characters the standard fonts can show
+func winAnsi(s string) string {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r < 32 || r > 255 {
+			r = '?'
+		}
+		b = append(b, byte(r))
+	}
+	return string(b)
+}
+
+func escapePDF(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(s)
+}
+
+// textWidth estimates the width of Helvetica text, close enough to right
+// align numbers, which all have the same width
+func textWidth(s string, size float64) float64 {
+	var units float64
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= '0' && c <= '9', c == '$':
+			units += 556
+		case c == '.', c == ',', c == ' ':
+			units += 278
+		case c == '%':
+			units += 889
+		case c >= 'A' && c <= 'Z':
+			units += 667
+		default:
+			units += 500
+		}
+	}
+	return units * size / 1000
+}
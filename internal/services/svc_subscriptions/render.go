@@ -0,0 +1,177 @@
+package svc_subscriptions
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/runway"
+	svc_jpmreport "client-runaway-zenoti/internal/services/svc_jpmReport"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	contentTypePDF  = "application/pdf"
+	contentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// rendered is a report file ready to be sent
+type rendered struct {
+	Name        string
+	ContentType string
+	Data        []byte
+	Summary     string // short text for the message body
+}
+
+// render builds the subscription's report for the period, both days
+// inclusive
+func render(sub models.ReportSubscription, start, end time.Time) (rendered, error) {
+	switch sub.ReportType {
+	case models.ReportTypeFunnel:
+		return renderFunnel(sub, start, end)
+	case models.ReportTypeDetails:
+		return renderDetails(sub, start, end)
+	}
+	return rendered{}, fmt.Errorf("unknown report type %q", sub.ReportType)
+}
+
+// renderFunnel is a PDF of the core metrics and the source breakdown of
+// the report definition
+func renderFunnel(sub models.ReportSubscription, start, end time.Time) (rendered, error) {
+	def := models.ReportDefinition{}
+	err := db.DB.Where("id = ? AND profile_id = ?", sub.ReportDefinitionId, sub.ProfileId).First(&def).Error
+	if err != nil {
+		return rendered{}, fmt.Errorf("report definition %d: %w", sub.ReportDefinitionId, err)
+	}
+
+	data, err := svc_jpmreport.BuildReport(def, start, end.Add(24*time.Hour-time.Second))
+	if err != nil {
+		return rendered{}, err
+	}
+
+	period := periodLabel(start, end)
+	summary := def.Name + ", " + period
+	if len(data) > 0 {
+		m := data[0].CoreMetrics
+		summary += fmt.Sprintf("\n%s: %d leads, %d booked, %d sales, %s revenue", data[0].Label, m.Leads, m.Booked, m.Sales, Money(m.Revenue))
+	}
+
+	return rendered{
+		Name:        fmt.Sprintf("%s %s.pdf", def.Name, fileDates(start, end)),
+		ContentType: contentTypePDF,
+		Data:        FunnelPDF(def.Name, period, data),
+		Summary:     summary,
+	}, nil
+}
+
+// FunnelPDF lays out the report data under a title and the period label,
+// a section per label
+func FunnelPDF(title, period string, data []svc_jpmreport.ReportData) []byte {
+	doc := newPDF()
+	doc.text(16, true, title)
+	doc.text(10, false, period)
+	for _, d := range data {
+		doc.gap(12)
+		writeFunnelSection(doc, d)
+	}
+	return doc.bytes()
+}
+
+func writeFunnelSection(doc *pdfDoc, d svc_jpmreport.ReportData) {
+	m := d.CoreMetrics
+	doc.text(13, true, d.Label)
+
+	metric := func(label, value string) {
+		doc.row(10, false, pdfCol{X: pageMargin, Text: label}, pdfCol{X: 260, Text: value, Right: true})
+	}
+	metric("Leads", fmt.Sprint(m.Leads))
+	metric("Booked", fmt.Sprint(m.Booked))
+	metric("Leads to booked", fmt.Sprintf("%.0f%%", m.LeadsToBooked))
+	metric("Sales", fmt.Sprint(m.Sales))
+	metric("Booked to sales", fmt.Sprintf("%.0f%%", m.BookedToSales))
+	metric("Revenue", Money(m.Revenue))
+
+	if len(d.SourceBreakdown) == 0 {
+		return
+	}
+
+	sources := make([]svc_jpmreport.SourceBreakdown, 0, len(d.SourceBreakdown))
+	for source, sb := range d.SourceBreakdown {
+		sb.Source = source
+		sources = append(sources, sb)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].Revenue != sources[j].Revenue {
+			return sources[i].Revenue > sources[j].Revenue
+		}
+		return sources[i].Source < sources[j].Source
+	})
+
+	xs := []float64{pageMargin, 230, 275, 330, 380, 445, 510, 555}
+	row := func(bold bool, texts ...string) {
+		cols := []pdfCol{{X: xs[0], Text: texts[0]}}
+		for i, t := range texts[1:] {
+			cols = append(cols, pdfCol{X: xs[i+1], Text: t, Right: true})
+		}
+		doc.row(9, bold, cols...)
+	}
+
+	doc.gap(6)
+	row(true, "Source", "Ad spend", "Leads", "CPL", "Consults", "Cost/consult", "Revenue", "ROAS")
+	for _, sb := range sources {
+		source := sb.Source
+		if len(source) > 32 {
+			source = source[:31] + "..."
+		}
+		row(false, source, Money(sb.AdSpend), fmt.Sprint(sb.Leads), Money(sb.CostPerLead), fmt.Sprint(sb.Consultations),
+			Money(sb.CostPerConsultation), Money(sb.Revenue), fmt.Sprintf("%.2f", sb.Roas))
+	}
+}
+
+// renderDetails is the XLSX of the leads by stage the report app exports
+func renderDetails(sub models.ReportSubscription, start, end time.Time) (rendered, error) {
+	res, err := runway.GetOpportunitiesForLocations(start, end.Add(24*time.Hour-time.Second), sub.LocationIds, nil, "")
+	if err != nil {
+		return rendered{}, err
+	}
+
+	data, err := runway.GetDetails(res)
+	if err != nil {
+		return rendered{}, err
+	}
+
+	return rendered{
+		Name:        fmt.Sprintf("%s %s.xlsx", sub.Name, fileDates(start, end)),
+		ContentType: contentTypeXLSX,
+		Data:        data,
+		Summary:     fmt.Sprintf("%s, %s\n%d opportunities worth %s", sub.Name, periodLabel(start, end), res.Count, Money(res.Sum)),
+	}, nil
+}
+
+func periodLabel(start, end time.Time) string {
+	if start.Equal(end) {
+		return start.Format("Jan 2, 2006")
+	}
+	return start.Format("Jan 2, 2006") + " - " + end.Format("Jan 2, 2006")
+}
+
+func fileDates(start, end time.Time) string {
+	return start.Format("2006-01-02") + "_" + end.Format("2006-01-02")
+}
+
+// Money formats dollars with thousands separators and cents
+func Money(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	neg := v < 0
+	if neg {
+		s = s[1:]
+	}
+	intPart, frac := s[:len(s)-3], s[len(s)-3:]
+	for i := len(intPart) - 3; i > 0; i -= 3 {
+		intPart = intPart[:i] + "," + intPart[i:]
+	}
+	if neg {
+		return "-$" + intPart + frac
+	}
+	return "$" + intPart + frac
+}
@@ -0,0 +1,64 @@
+package svc_subscriptions
+
+import (
+	"client-runaway-zenoti/internal/db/models"
+	"fmt"
+	"time"
+)
+
+// NextRun returns the first send time of the subscription after the given
+// time, at its hour in its time zone
+func NextRun(sub models.ReportSubscription, after time.Time) (time.Time, error) {
+	tz, err := sub.TimeLocation()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	local := after.In(tz)
+	run := time.Date(local.Year(), local.Month(), local.Day(), sub.SendHour, 0, 0, 0, tz)
+	switch sub.Cadence {
+	case models.ReportCadenceDaily:
+		for !run.After(after) {
+			run = run.AddDate(0, 0, 1)
+		}
+	case models.ReportCadenceWeekly:
+		for !run.After(after) || run.Weekday() != time.Monday {
+			run = run.AddDate(0, 0, 1)
+		}
+	case models.ReportCadenceMonthly:
+		run = time.Date(local.Year(), local.Month(), 1, sub.SendHour, 0, 0, 0, tz)
+		for !run.After(after) {
+			run = run.AddDate(0, 1, 0)
+		}
+	default:
+		return time.Time{}, fmt.Errorf("unknown cadence %q", sub.Cadence)
+	}
+	return run, nil
+}
+
+// ReportPeriod returns the first and last day of the period that ended
+// before a send, in the subscription's time zone
+func ReportPeriod(sub models.ReportSubscription, runAt time.Time) (start, end time.Time, err error) {
+	tz, err := sub.TimeLocation()
+	if err != nil {
+		return start, end, err
+	}
+
+	local := runAt.In(tz)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, tz)
+	switch sub.Cadence {
+	case models.ReportCadenceDaily:
+		start = today.AddDate(0, 0, -1)
+	case models.ReportCadenceWeekly:
+		// the Monday to Sunday before
+		sinceMonday := (int(today.Weekday()) + 6) % 7
+		start = today.AddDate(0, 0, -sinceMonday-7)
+		today = start.AddDate(0, 0, 7)
+	case models.ReportCadenceMonthly:
+		today = time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, tz)
+		start = today.AddDate(0, -1, 0)
+	default:
+		return start, end, fmt.Errorf("unknown cadence %q", sub.Cadence)
+	}
+	return start, today.AddDate(0, 0, -1), nil
+}
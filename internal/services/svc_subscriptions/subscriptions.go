@@ -0,0 +1,299 @@
+package svc_subscriptions
+
+import (
+	"client-runaway-zenoti/internal/db"
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/mailer"
+	"client-runaway-zenoti/internal/services/auth"
+	"client-runaway-zenoti/internal/services/svc_audit"
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type subscriptionPayload struct {
+	Name               string   `json:"name" binding:"required"`
+	ReportType         string   `json:"reportType" binding:"required"`
+	ReportDefinitionId uint     `json:"reportDefinitionId"`
+	LocationIds        []string `json:"locationIds"`
+	Cadence            string   `json:"cadence" binding:"required"`
+	SendHour           *int     `json:"sendHour"` // 8 when not set
+	TimeZone           string   `json:"timeZone"`
+	Channel            string   `json:"channel" binding:"required"`
+	Recipients         []string `json:"recipients" binding:"required"`
+	Enabled            *bool    `json:"enabled"` // true when not set
+}
+
+func ListSubscriptions(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	subs := []models.ReportSubscription{}
+
+	err := db.DB.Where("profile_id = ?", user.ProfileID).Order("id").Find(&subs).Error
+	if err != nil {
+		lvn.GinErr(c, 500, err, "Unable to get subscriptions")
+		return
+	}
+
+	subs = slices.DeleteFunc(subs, func(sub models.ReportSubscription) bool {
+		return !auth.CanSeeLocations(c, subscriptionLocations(sub))
+	})
+
+	c.Data(lvn.Res(200, subs, ""))
+}
+
+func CreateSubscription(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	payload := subscriptionPayload{}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		lvn.GinErr(c, 400, err, "Unable to bind JSON")
+		return
+	}
+
+	sub := models.ReportSubscription{ProfileId: user.ProfileID}
+	if err := applySubscriptionPayload(&sub, payload); err != nil {
+		c.Data(lvn.Res(400, nil, err.Error()))
+		return
+	}
+	if !auth.CanSeeLocations(c, subscriptionLocations(sub)) {
+		c.Data(lvn.Res(403, nil, "No access to some of the locations"))
+		return
+	}
+
+	if err := db.DB.Create(&sub).Error; err != nil {
+		lvn.GinErr(c, 500, err, "Unable to create subscription")
+		return
+	}
+	svc_audit.Record(c, models.AuditCreate, "report_subscription", strconv.FormatUint(uint64(sub.ID), 10), "", nil, sub)
+
+	c.Data(lvn.Res(200, sub, ""))
+}
+
+func UpdateSubscription(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	payload := subscriptionPayload{}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		lvn.GinErr(c, 400, err, "Unable to bind JSON")
+		return
+	}
+
+	sub, ok := loadSubscription(c, user.ProfileID)
+	if !ok {
+		return
+	}
+	before := sub
+
+	if err := applySubscriptionPayload(&sub, payload); err != nil {
+		c.Data(lvn.Res(400, nil, err.Error()))
+		return
+	}
+	if !auth.CanSeeLocations(c, subscriptionLocations(sub)) {
+		c.Data(lvn.Res(403, nil, "No access to some of the locations"))
+		return
+	}
+
+	if err := db.DB.Save(&sub).Error; err != nil {
+		lvn.GinErr(c, 500, err, "Unable to update subscription")
+		return
+	}
+	svc_audit.Record(c, models.AuditUpdate, "report_subscription", strconv.FormatUint(uint64(sub.ID), 10), "", before, sub)
+
+	c.Data(lvn.Res(200, sub, ""))
+}
+
+func DeleteSubscription(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	sub, ok := loadSubscription(c, user.ProfileID)
+	if !ok {
+		return
+	}
+
+	if err := db.DB.Delete(&sub).Error; err != nil {
+		lvn.GinErr(c, 500, err, "Unable to delete subscription")
+		return
+	}
+	svc_audit.Record(c, models.AuditDelete, "report_subscription", strconv.FormatUint(uint64(sub.ID), 10), "", sub, nil)
+
+	c.Data(lvn.Res(200, "", ""))
+}
+
+// SendSubscription sends the report of the last full period right away,
+// e.g. to check the recipients, without moving the schedule
+func SendSubscription(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	sub, ok := loadSubscription(c, user.ProfileID)
+	if !ok {
+		return
+	}
+
+	delivery := deliver(sub, time.Now())
+	if delivery.Status == models.ReportDeliveryFailed {
+		c.Data(lvn.Res(502, delivery, delivery.Error))
+		return
+	}
+
+	c.Data(lvn.Res(200, delivery, ""))
+}
+
+// ListDeliveries returns the latest sends of a subscription
+func ListDeliveries(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	sub, ok := loadSubscription(c, user.ProfileID)
+	if !ok {
+		return
+	}
+
+	deliveries := []models.ReportDelivery{}
+	err := db.DB.Where("subscription_id = ?", sub.ID).Order("id DESC").Limit(100).Find(&deliveries).Error
+	if err != nil {
+		lvn.GinErr(c, 500, err, "Unable to get deliveries")
+		return
+	}
+
+	c.Data(lvn.Res(200, deliveries, ""))
+}
+
+func loadSubscription(c *gin.Context, profileID uint) (models.ReportSubscription, bool) {
+	sub := models.ReportSubscription{}
+	err := db.DB.Where("id = ? AND profile_id = ?", c.Param("subscriptionId"), profileID).First(&sub).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Data(lvn.Res(404, nil, "Subscription not found"))
+		return sub, false
+	}
+	if err != nil {
+		lvn.GinErr(c, 500, err, "Unable to get subscription")
+		return sub, false
+	}
+	if !auth.CanSeeLocations(c, subscriptionLocations(sub)) {
+		c.Data(lvn.Res(403, nil, "No access to some of the subscription's locations"))
+		return sub, false
+	}
+	return sub, true
+}
+
+// subscriptionLocations returns the locations the subscription reports on.
+// A deleted definition still scopes the subscriptions it leaves disabled.
+func subscriptionLocations(sub models.ReportSubscription) []string {
+	if sub.ReportType != models.ReportTypeFunnel {
+		return sub.LocationIds
+	}
+
+	def := models.ReportDefinition{}
+	err := db.DB.Unscoped().Where("id = ? AND profile_id = ?", sub.ReportDefinitionId, sub.ProfileId).First(&def).Error
+	if err != nil {
+		return nil
+	}
+	return def.LocationIds
+}
+
+// applySubscriptionPayload validates the payload against the
+// subscription's profile, copies it over and schedules the next send
+func applySubscriptionPayload(sub *models.ReportSubscription, payload subscriptionPayload) error {
+	switch payload.ReportType {
+	case models.ReportTypeFunnel:
+		var count int64
+		err := db.DB.Model(&models.ReportDefinition{}).Where("id = ? AND profile_id = ?", payload.ReportDefinitionId, sub.ProfileId).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return errors.New("report definition not found")
+		}
+		payload.LocationIds = nil
+	case models.ReportTypeDetails:
+		if len(payload.LocationIds) == 0 {
+			return errors.New("at least one location is required")
+		}
+		slices.Sort(payload.LocationIds)
+		payload.LocationIds = slices.Compact(payload.LocationIds)
+
+		var count int64
+		err := db.DB.Model(&models.Location{}).Where("profile_id = ? AND id IN ?", sub.ProfileId, payload.LocationIds).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if int(count) != len(payload.LocationIds) {
+			return errors.New("some locations are not in this profile")
+		}
+		payload.ReportDefinitionId = 0
+	default:
+		return fmt.Errorf("reportType must be %s or %s", models.ReportTypeFunnel, models.ReportTypeDetails)
+	}
+
+	switch payload.Cadence {
+	case models.ReportCadenceDaily, models.ReportCadenceWeekly, models.ReportCadenceMonthly:
+	default:
+		return fmt.Errorf("cadence must be %s, %s or %s", models.ReportCadenceDaily, models.ReportCadenceWeekly, models.ReportCadenceMonthly)
+	}
+
+	sendHour := 8
+	if payload.SendHour != nil {
+		sendHour = *payload.SendHour
+	}
+	if sendHour < 0 || sendHour > 23 {
+		return errors.New("sendHour must be between 0 and 23")
+	}
+	if _, err := time.LoadLocation(payload.TimeZone); err != nil {
+		return fmt.Errorf("unknown time zone %q", payload.TimeZone)
+	}
+
+	recipients, err := validRecipients(payload.Channel, payload.Recipients)
+	if err != nil {
+		return err
+	}
+
+	sub.Name = payload.Name
+	sub.ReportType = payload.ReportType
+	sub.ReportDefinitionId = payload.ReportDefinitionId
+	sub.LocationIds = payload.LocationIds
+	sub.Cadence = payload.Cadence
+	sub.SendHour = sendHour
+	sub.TimeZone = payload.TimeZone
+	sub.Channel = payload.Channel
+	sub.Recipients = recipients
+	sub.Enabled = payload.Enabled == nil || *payload.Enabled
+
+	sub.NextRunAt, err = NextRun(*sub, time.Now())
+	return err
+}
+
+func validRecipients(channel string, recipients []string) ([]string, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("at least one recipient is required")
+	}
+
+	res := make([]string, 0, len(recipients))
+	for _, r := range recipients {
+		r = strings.TrimSpace(r)
+		switch channel {
+		case models.ReportChannelEmail:
+			addr, err := mail.ParseAddress(r)
+			if err != nil {
+				return nil, fmt.Errorf("invalid email %q", r)
+			}
+			r = addr.Address
+		case models.ReportChannelTelegram:
+			if _, err := strconv.ParseInt(r, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid telegram chat id %q", r)
+			}
+		default:
+			return nil, fmt.Errorf("channel must be %s or %s", models.ReportChannelEmail, models.ReportChannelTelegram)
+		}
+		res = append(res, r)
+	}
+
+	if channel == models.ReportChannelEmail && !mailer.Configured() {
+		return nil, errors.New("email delivery is not configured on this server")
+	}
+	return res, nil
+}
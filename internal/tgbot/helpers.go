@@ -1,6 +1,7 @@
 package tgbot
 
 import (
+	"bytes"
 	"client-runaway-zenoti/internal/config"
 	"fmt"
 
@@ -97,3 +98,20 @@ func NewTestService() (*Service, error) {
 		MainGroupId: -1002445791126,
 	})
 }
+
+// SendDocument sends a file to a chat with an optional caption
+func (svc *Service) SendDocument(chatId int64, name string, data []byte, caption string) error {
+	_, err := svc.bot.SendDocument(chatId, gotgbot.InputFileByReader(name, bytes.NewReader(data)), &gotgbot.SendDocumentOpts{
+		Caption: caption,
+	})
+	return err
+}
+
+func SendDocument(chatId int64, name string, data []byte, caption string) error {
+	svc, err := NewTestService()
+	if err != nil {
+		return err
+	}
+
+	return svc.SendDocument(chatId, name, data, caption)
+}
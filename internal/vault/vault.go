@@ -0,0 +1,256 @@
+package vault
+
+import (
+	"client-runaway-zenoti/internal/config"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Envelope encryption for integration secrets. Every value gets its own
+// data key (AES-256-GCM); the data key is wrapped with a master key.
+//
+// Master keys come from the VAULT_MASTER_KEYS env var or Settings.VaultMasterKeys,
+// as "keyId:base64(32 bytes)" entries. The first entry is the active key used
+// for encryption; the others are only used for decryption. To rotate, put a
+// new key first and restart: the startup migration rewraps every stored
+// secret with it, after which the old key can be dropped.
+//
+// Encrypted values look like vault:v1:<keyId>:<wrapped data key>:<ciphertext>.
+// Values without the prefix are treated as legacy plaintext.
+
+const prefix = "vault:v1:"
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+var (
+	keys     []masterKey
+	loadOnce sync.Once
+	loadErr  error
+)
+
+func load() {
+	raw := os.Getenv("VAULT_MASTER_KEYS")
+	entries := strings.Split(raw, ",")
+	if strings.TrimSpace(raw) == "" {
+		entries = config.Confs.Settings.VaultMasterKeys
+	}
+
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		id, b64, ok := strings.Cut(e, ":")
+		if !ok || id == "" {
+			loadErr = fmt.Errorf("vault: master key entry must be keyId:base64key")
+			return
+		}
+		secret, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil || len(secret) != 32 {
+			loadErr = fmt.Errorf("vault: master key %s must be 32 base64 encoded bytes", id)
+			return
+		}
+		aead, err := newAEAD(secret)
+		if err != nil {
+			loadErr = err
+			return
+		}
+		keys = append(keys, masterKey{id: id, aead: aead})
+	}
+}
+
+func getKeys() ([]masterKey, error) {
+	loadOnce.Do(load)
+	return keys, loadErr
+}
+
+// Err returns why the configured master keys couldn't be loaded, nil when
+// they were or when none are configured
+func Err() error {
+	_, err := getKeys()
+	return err
+}
+
+// Enabled reports whether a master key is configured. Without one secrets
+// are stored as plaintext.
+func Enabled() bool {
+	k, err := getKeys()
+	return err == nil && len(k) > 0
+}
+
+// ActiveKeyId returns the id of the key new secrets are encrypted with
+func ActiveKeyId() string {
+	k, err := getKeys()
+	if err != nil || len(k) == 0 {
+		return ""
+	}
+	return k[0].id
+}
+
+// IsEncrypted reports whether value is a vault ciphertext
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Encrypt encrypts value with the active master key. Empty and already
+// encrypted values are returned as is; so is everything when no master key
+// is configured.
+func Encrypt(value string) (string, error) {
+	if value == "" || IsEncrypted(value) {
+		return value, nil
+	}
+	k, err := getKeys()
+	if err != nil {
+		return "", err
+	}
+	if len(k) == 0 {
+		return value, nil
+	}
+	active := k[0]
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	wrapped, err := seal(active.aead, dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataAEAD, []byte(value))
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + active.id + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt returns the plaintext of a vault ciphertext. Values that are not
+// encrypted are returned as is.
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("vault: malformed ciphertext")
+	}
+
+	mk, err := findKey(parts[0])
+	if err != nil {
+		return "", err
+	}
+
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("vault: malformed data key: %w", err)
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("vault: malformed ciphertext: %w", err)
+	}
+
+	dataKey, err := open(mk.aead, wrapped)
+	if err != nil {
+		return "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(dataAEAD, ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plain), nil
+}
+
+// NeedsRewrap reports whether value should be re-encrypted: it is plaintext
+// or was encrypted with a key that is no longer active.
+func NeedsRewrap(value string) bool {
+	if value == "" || !Enabled() {
+		return false
+	}
+	if !IsEncrypted(value) {
+		return true
+	}
+	keyId, _, _ := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	return keyId != ActiveKeyId()
+}
+
+// Rewrap re-encrypts value with the active master key
+func Rewrap(value string) (string, error) {
+	plain, err := Decrypt(value)
+	if err != nil {
+		return "", err
+	}
+	return Encrypt(plain)
+}
+
+// GenerateMasterKey returns a new "keyId:base64key" entry for VAULT_MASTER_KEYS
+func GenerateMasterKey(keyId string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return keyId + ":" + base64.StdEncoding.EncodeToString(secret), nil
+}
+
+func findKey(id string) (masterKey, error) {
+	k, err := getKeys()
+	if err != nil {
+		return masterKey{}, err
+	}
+	for _, mk := range k {
+		if mk.id == id {
+			return mk, nil
+		}
+	}
+	return masterKey{}, fmt.Errorf("vault: master key %s is not configured", id)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plain []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func open(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("vault: ciphertext too short")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("vault: unable to decrypt, wrong master key or corrupted value")
+	}
+	return plain, nil
+}
@@ -0,0 +1,80 @@
+package webServer
+
+import (
+	"client-runaway-zenoti/internal/db/models"
+	integrations_zenoti "client-runaway-zenoti/internal/integrations/zenoti"
+	"client-runaway-zenoti/internal/services/svc_config"
+	"time"
+
+	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
+	"github.com/gin-gonic/gin"
+)
+
+type calendarSyncReport struct {
+	DryRun    bool                                   `json:"dryRun"`
+	From      time.Time                              `json:"from"`
+	To        time.Time                              `json:"to"`
+	Created   int                                    `json:"created"`
+	Updated   int                                    `json:"updated"`
+	Deleted   int                                    `json:"deleted"`
+	Calendars []integrations_zenoti.CalendarSyncPlan `json:"calendars"`
+}
+
+// calendarSync reports the block slot changes for a location's calendars in the
+// from..to window (defaults to -5..+30 days). GET is always a dry run, POST
+// applies them unless dryRun=true is passed.
+func calendarSync(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	l, err := svc_config.GetLocationForProfile(user.ProfileID, c.Param("locationId"))
+	lvn.GinErr(c, 404, err, "location not found")
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	from := now.AddDate(0, 0, -5)
+	to := now.AddDate(0, 0, 30)
+	if q := c.Query("from"); q != "" {
+		from, err = time.Parse("2006-01-02", q)
+		lvn.GinErr(c, 400, err, "invalid from date")
+		if err != nil {
+			return
+		}
+	}
+	if q := c.Query("to"); q != "" {
+		to, err = time.Parse("2006-01-02", q)
+		lvn.GinErr(c, 400, err, "invalid to date")
+		if err != nil {
+			return
+		}
+	}
+	if !to.After(from) {
+		c.Data(lvn.Res(400, nil, "to must be after from"))
+		return
+	}
+
+	dryRun := c.Request.Method == "GET" || c.Query("dryRun") == "true"
+
+	plans, err := integrations_zenoti.SyncCalendarsForWindow(*l, from, to, dryRun)
+	if plans == nil {
+		lvn.GinErr(c, 500, err, "unable to sync calendars")
+		if err != nil {
+			return
+		}
+	}
+
+	res := calendarSyncReport{
+		DryRun:    dryRun,
+		From:      from,
+		To:        to,
+		Calendars: plans,
+	}
+	for _, p := range plans {
+		res.Created += len(p.Create)
+		res.Updated += len(p.Update)
+		res.Deleted += len(p.Delete)
+	}
+
+	c.Data(lvn.Res(200, res, "OK"))
+}
@@ -3,22 +3,34 @@ package webServer
 import (
 	"client-runaway-zenoti/internal/db"
 	"client-runaway-zenoti/internal/db/models"
+	integrations_zenoti "client-runaway-zenoti/internal/integrations/zenoti"
+	"errors"
 	"time"
 
 	lvn "github.com/Lavina-Tech-LLC/lavinagopackage/v2"
 	"github.com/gin-gonic/gin"
 )
 
+// Public API of the chatly booking widget. Every route is scoped to a
+// location and authenticated by the location's widget key, passed in the
+// X-Widget-Key header or the key query param.
+
 type (
-	blockSlotsMsg struct {
-		WorkingHoursFrom string
-		WorkingHoursTo   string
-		BlockSlots       []BlockSlots
+	chatlyCalendar struct {
+		CalendarId       string `json:"calendarId"`
+		TherapistName    string `json:"therapistName"`
+		WorkingHoursFrom string `json:"workingHoursFrom"`
+		WorkingHoursTo   string `json:"workingHoursTo"`
+		TimeZone         string `json:"timeZone"`
+		SlotMinutes      int    `json:"slotMinutes"`
 	}
 
-	BlockSlots struct {
-		From time.Time
-		To   time.Time
+	chatlySlotsMsg struct {
+		Date             string                              `json:"date"`
+		TimeZone         string                              `json:"timeZone"`
+		WorkingHoursFrom time.Time                           `json:"workingHoursFrom"`
+		WorkingHoursTo   time.Time                           `json:"workingHoursTo"`
+		Slots            []integrations_zenoti.AvailableSlot `json:"slots"`
 	}
 
 	bookMsg struct {
@@ -29,69 +41,111 @@ type (
 	}
 )
 
-var (
-	testLocId        = "VNDSQQmMekLitfnlMBqH"
-	testCalendarId   = "N3rTXsSEbXzKfltPChch"
-	workingHoursFrom = "09:00:00 -0700"
-	workingHoursTo   = "18:00:00 -0700"
-)
-
 func setChatlyRoutes(router *gin.Engine) {
-	router.GET("/chatly/block-slots/:date", getBlockSlots)
-	router.POST("/chatly/book", book)
+	chatly := router.Group("/chatly/:locationId", chatlyAuth)
+	chatly.GET("/calendars", chatlyCalendars)
+	chatly.GET("/calendars/:calendarId/slots/:date", chatlySlots)
+	chatly.POST("/calendars/:calendarId/book", chatlyBook)
 }
 
-func getBlockSlots(c *gin.Context) {
-
-	fromString := c.Param("date")
+func chatlyAuth(c *gin.Context) {
+	key := c.GetHeader("X-Widget-Key")
+	if key == "" {
+		key = c.Query("key")
+	}
 
-	from, err := time.Parse("2006-01-02", fromString)
-	lvn.GinErr(c, 400, err, "Invalid date")
+	l := models.Location{}
+	err := db.DB.Where("id = ?", c.Param("locationId")).First(&l).Error
+	if err != nil || !l.CheckWidgetKey(key) {
+		c.Data(lvn.Res(401, "", "Invalid widget key"))
+		c.Abort()
+		return
+	}
 
-	blocks, err := getBlockSlotsForPeriod(from, from.AddDate(0, 0, 1))
-	lvn.GinErr(c, 500, err, "Error getting block slots")
+	c.Set("location", l)
+	c.Next()
+}
 
-	res := blockSlotsMsg{
-		WorkingHoursFrom: workingHoursFrom,
-		WorkingHoursTo:   workingHoursTo,
-		BlockSlots:       blocks,
+func chatlyCalendars(c *gin.Context) {
+	l := c.MustGet("location").(models.Location)
+
+	calendars := []models.Calendar{}
+	err := db.DB.Where("location_id = ? AND calendar_id <> ''", l.Id).Order("therapist_name").Find(&calendars).Error
+	lvn.GinErr(c, 500, err, "Error getting calendars")
+
+	res := []chatlyCalendar{}
+	for _, cal := range calendars {
+		tz, _ := cal.TimeLocation()
+		res = append(res, chatlyCalendar{
+			CalendarId:       cal.CalendarId,
+			TherapistName:    cal.TherapistName,
+			WorkingHoursFrom: lvn.Ternary(cal.WorkingHoursFrom != "", cal.WorkingHoursFrom, models.CalendarDefaultWorkingHoursFrom),
+			WorkingHoursTo:   lvn.Ternary(cal.WorkingHoursTo != "", cal.WorkingHoursTo, models.CalendarDefaultWorkingHoursTo),
+			TimeZone:         tz.String(),
+			SlotMinutes:      int(cal.SlotDuration() / time.Minute),
+		})
 	}
 
 	c.Data(lvn.Res(200, res, "Success"))
 }
 
-func getBlockSlotsForPeriod(from, to time.Time) ([]BlockSlots, error) {
-	blocks := []models.BlockSlot{}
-	db.DB.Where("calendar_id = ? AND (start_time>? and start_time < ? OR end_time > ? and end_time < ?)", testCalendarId, from, to, from, to).Order("start_time asc").Find(&blocks)
+func chatlySlots(c *gin.Context) {
+	l := c.MustGet("location").(models.Location)
 
-	res := []BlockSlots{}
-	for _, b := range blocks {
-		res = append(res, BlockSlots{
-			From: b.StartTime,
-			To:   b.EndTime,
-		})
+	calendar, err := chatlyGetCalendar(l, c.Param("calendarId"))
+	lvn.GinErr(c, 404, err, "Calendar not found")
+
+	tz, err := calendar.TimeLocation()
+	lvn.GinErr(c, 500, err, "Invalid calendar time zone")
+
+	day, err := time.ParseInLocation("2006-01-02", c.Param("date"), tz)
+	lvn.GinErr(c, 400, err, "Invalid date")
+
+	workFrom, workTo, err := calendar.WorkingHours(day)
+	lvn.GinErr(c, 500, err, "Invalid calendar working hours")
+
+	slots, err := integrations_zenoti.CalendarAvailableSlots(l, calendar, day)
+	lvn.GinErr(c, 500, err, "Error getting available slots")
+
+	res := chatlySlotsMsg{
+		Date:             day.Format("2006-01-02"),
+		TimeZone:         tz.String(),
+		WorkingHoursFrom: workFrom,
+		WorkingHoursTo:   workTo,
+		Slots:            slots,
 	}
-	return res, nil
+
+	c.Data(lvn.Res(200, res, "Success"))
 }
 
-func book(c *gin.Context) {
+func chatlyBook(c *gin.Context) {
+	l := c.MustGet("location").(models.Location)
+
+	calendar, err := chatlyGetCalendar(l, c.Param("calendarId"))
+	lvn.GinErr(c, 404, err, "Calendar not found")
 
 	body := bookMsg{}
-	err := c.BindJSON(&body)
+	err = c.BindJSON(&body)
 	lvn.GinErr(c, 400, err, "Invalid body")
 
-	bs := models.BlockSlot{
-		LocationId: testLocId,
-		CalendarId: testCalendarId,
-		StartTime:  body.From,
-		EndTime:    body.To,
-		Title:      body.Customer_name,
-		Notes:      body.Customer_phone,
+	if body.Customer_name == "" || body.Customer_phone == "" {
+		lvn.GinErr(c, 400, errors.New("customer name and phone are required"), "Invalid body")
 	}
 
-	err = db.DB.Save(&bs).Error
-	lvn.GinErr(c, 500, err, "Error saving block slot")
+	available, err := integrations_zenoti.IsSlotAvailable(l, calendar, body.From, body.To)
+	lvn.GinErr(c, 500, err, "Error checking slot availability")
+	if !available {
+		lvn.GinErr(c, 409, errors.New("slot is not available"), "Slot is not available")
+	}
 
-	c.Data(lvn.Res(200, "BlockSlot for user has been created", "OK"))
+	_, err = integrations_zenoti.BookCalendarSlot(calendar.CalendarId, body.From, body.To, body.Customer_name, body.Customer_phone)
+	lvn.GinErr(c, 409, err, "Error booking the slot")
+
+	c.Data(lvn.Res(200, "Appointment for user has been booked", "OK"))
+}
 
+func chatlyGetCalendar(l models.Location, calendarId string) (models.Calendar, error) {
+	calendar := models.Calendar{}
+	err := db.DB.Where("location_id = ? AND calendar_id = ?", l.Id, calendarId).First(&calendar).Error
+	return calendar, err
 }
@@ -13,7 +13,7 @@ import (
 var corsMiddleware = cors.New(cors.Config{
 	//AllowOrigins:     allowedOrigins,
 	AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-	AllowHeaders:     []string{"Origin", "Content-Length", "Content-type", "Authorization"},
+	AllowHeaders:     []string{"Origin", "Content-Length", "Content-type", "Authorization", "X-Widget-Key"},
 	ExposeHeaders:    []string{"Content-Length", "Content-type"},
 	AllowCredentials: true,
 	AllowOriginFunc:  checkOrigin,
@@ -48,11 +48,12 @@ func setExpense(c *gin.Context) {
 		From      time.Time
 		To        time.Time
 		Total     float64
+		Network   string // google, meta or other
 	}{}
 
 	c.Bind(&body)
 
-	reports.SetExpenses(body.From, body.To, body.Total, body.Locations)
+	reports.SetExpenses(body.From, body.To, body.Total, body.Locations, body.Network)
 }
 
 func getSettings(c *gin.Context) {
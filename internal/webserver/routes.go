@@ -2,24 +2,36 @@ package webServer
 
 import (
 	"client-runaway-zenoti/internal/cerbo"
+	"client-runaway-zenoti/internal/db/models"
 	"client-runaway-zenoti/internal/runway"
 	"client-runaway-zenoti/internal/services/auth"
 	"client-runaway-zenoti/internal/services/automator"
+	"client-runaway-zenoti/internal/services/svc_adspend"
 	"client-runaway-zenoti/internal/services/svc_attribution"
+	"client-runaway-zenoti/internal/services/svc_audit"
 	"client-runaway-zenoti/internal/services/svc_cerbo"
+	"client-runaway-zenoti/internal/services/svc_cohorts"
 	"client-runaway-zenoti/internal/services/svc_config"
 	"client-runaway-zenoti/internal/services/svc_ghl"
 	"client-runaway-zenoti/internal/services/svc_googleads"
 	"client-runaway-zenoti/internal/services/svc_internal_assistant"
 	svc_jpmreport "client-runaway-zenoti/internal/services/svc_jpmReport"
 	"client-runaway-zenoti/internal/services/svc_mcp"
+	"client-runaway-zenoti/internal/services/svc_meta"
+	"client-runaway-zenoti/internal/services/svc_noshows"
 	"client-runaway-zenoti/internal/services/svc_openai"
+	"client-runaway-zenoti/internal/services/svc_subscriptions"
 	"client-runaway-zenoti/internal/services/svc_zenoti"
 
 	"github.com/gin-gonic/gin"
 )
 
 func setRoutes(router *gin.Engine) {
+	// RBAC: reads need the first role, writes the second
+	builderAccess := auth.Access(models.RoleViewer, models.RoleBuilder)
+	adminAccess := auth.Access(models.RoleViewer, models.RoleAdmin)
+	adminOnly := auth.Access(models.RoleAdmin, models.RoleAdmin)
+
 	// GHL Trigger
 	router.POST("/hl/trigger", runway.TriggerSubscriptionsHandler)
 	router.POST("/hl/webhookv2", svc_ghl.WebhookAuthMiddle, ghlWebhookHandler)
@@ -27,113 +39,180 @@ func setRoutes(router *gin.Engine) {
 	// Cerbo webhooks
 	router.POST("/cerbo/webhook/:secret", cerbo.WebhookHandler)
 
-	// JPM Report
-	router.GET("/jpm/report", svc_jpmreport.GetReport)
+	// Deprecated: the JPM report for its old consumer, token protected
+	router.GET("/jpm/report", svc_jpmreport.GetLegacyJpmReport)
 
 	// Auth routes
 	router.POST("/register", auth.Register)
 	router.GET("/login", auth.Auth, auth.Login)
+	router.POST("/auth/login", auth.TokenLogin)
+	router.POST("/auth/refresh", auth.RefreshTokens)
+	router.POST("/auth/logout", auth.Logout)
+	router.POST("/auth/password/change", auth.Auth, auth.ChangePassword)
+	router.POST("/auth/password/forgot", auth.ForgotPassword)
+	router.POST("/auth/password/reset", auth.ResetPassword)
 	router.GET("/auth/hl", svc_ghl.AddLocationAuthHandler)
 
+	// Profiles and invites
+	router.GET("/profiles", auth.Auth, auth.ListMyProfiles)
+	router.POST("/invites/accept", auth.Auth, auth.AcceptInvite)
+
 	// Automations routes
 	auto := router.Group("/auto")
-	auto.GET("/catalog", auth.Auth, automator.GetCatalog)
-	auto.GET("/catalog/:locationId/list/:listName", auth.Auth, automator.GetLists)
+	auto.GET("/catalog", auth.Auth, builderAccess, automator.GetCatalog)
+	auto.GET("/catalog/:locationId/list/:listName", auth.Auth, builderAccess, automator.GetLists)
 
-	auto.GET("/:locationId", auth.Auth, automator.GetAutomations)
-	auto.POST("/:locationId", auth.Auth, automator.CreateAutomation)
-	auto.PATCH("/:automationId", auth.Auth, automator.UpdateAutomation)
-	auto.DELETE("/:automationId", auth.Auth, automator.DeleteAutomation)
-	auto.POST("/duplicate/:automationId", auth.Auth, automator.DuplicateAutomation)
+	auto.GET("/:locationId", auth.Auth, builderAccess, automator.GetAutomations)
+	auto.POST("/:locationId", auth.Auth, builderAccess, automator.CreateAutomation)
+	auto.PATCH("/:automationId", auth.Auth, builderAccess, automator.UpdateAutomation)
+	auto.DELETE("/:automationId", auth.Auth, builderAccess, automator.DeleteAutomation)
+	auto.POST("/duplicate/:automationId", auth.Auth, builderAccess, automator.DuplicateAutomation)
 
-	auto.GET("/runs", auth.Auth, automator.GetAutomationRuns)
-	auto.GET("/runs/export", auth.Auth, automator.ExportAutomationRuns)
-	auto.GET("/run-details/:runId", auth.Auth, automator.GetAutomationRunDetails)
-	auto.POST("/run/:runId/restart", auth.Auth, automator.StartFromAutomationRun)
-	auto.POST("/trigger/:automationId", auth.Auth, automator.StartTriggerForAutomation)
+	auto.GET("/runs", auth.Auth, builderAccess, automator.GetAutomationRuns)
+	auto.GET("/runs/export", auth.Auth, builderAccess, automator.ExportAutomationRuns)
+	auto.GET("/run-details/:runId", auth.Auth, builderAccess, automator.GetAutomationRunDetails)
+	auto.POST("/run/:runId/restart", auth.Auth, builderAccess, automator.StartFromAutomationRun)
+	auto.POST("/trigger/:automationId", auth.Auth, builderAccess, automator.StartTriggerForAutomation)
 
 	// Batch runs
-	auto.POST("/batch-run", auth.Auth, automator.StartBatchRun)
-	auto.GET("/batch-runs/:locationId", auth.Auth, automator.GetBatchRuns)
-	auto.GET("/batch-run/:batchRunId", auth.Auth, automator.GetBatchRunDetails)
-	auto.PATCH("/batch-run/:batchRunId/cancel", auth.Auth, automator.CancelBatchRun)
-	auto.POST("/runs/restart", auth.Auth, automator.RestartMultipleAutomationRuns)
+	auto.POST("/batch-run", auth.Auth, builderAccess, automator.StartBatchRun)
+	auto.GET("/batch-runs/:locationId", auth.Auth, builderAccess, automator.GetBatchRuns)
+	auto.GET("/batch-run/:batchRunId", auth.Auth, builderAccess, automator.GetBatchRunDetails)
+	auto.PATCH("/batch-run/:batchRunId/cancel", auth.Auth, builderAccess, automator.CancelBatchRun)
+	auto.POST("/runs/restart", auth.Auth, builderAccess, automator.RestartMultipleAutomationRuns)
 
 	// GHL Routes
 	ghl := router.Group("/ghl")
-	ghl.GET("/:locationId/pipelines", auth.Auth, svc_ghl.GetPipelines)
+	ghl.GET("/:locationId/pipelines", auth.Auth, builderAccess, svc_ghl.GetPipelines)
 
 	// Zenoti API Settings
 	settings := router.Group("/settings")
-	settings.GET("/zenoti/apis", auth.Auth, svc_config.GetZenotiApis)
-	settings.POST("/zenoti/apis", auth.Auth, svc_config.CreateZenotiApi)
-	settings.PATCH("/zenoti/apis/:zenotiApiId", auth.Auth, svc_config.UpdateZenotiApi)
-	settings.DELETE("/zenoti/apis/:zenotiApiId", auth.Auth, svc_config.DeleteZenotiApi)
+	settings.GET("/zenoti/apis", auth.Auth, adminAccess, svc_config.GetZenotiApis)
+	settings.POST("/zenoti/apis", auth.Auth, adminAccess, svc_config.CreateZenotiApi)
+	settings.PATCH("/zenoti/apis/:zenotiApiId", auth.Auth, adminAccess, svc_config.UpdateZenotiApi)
+	settings.DELETE("/zenoti/apis/:zenotiApiId", auth.Auth, adminAccess, svc_config.DeleteZenotiApi)
 
 	// Cerbo API Settings
-	settings.GET("/cerbo/apis", auth.Auth, svc_config.GetCerboApis)
-	settings.POST("/cerbo/apis", auth.Auth, svc_config.CreateCerboApi)
-	settings.PATCH("/cerbo/apis/:cerboApiId", auth.Auth, svc_config.UpdateCerboApi)
-	settings.DELETE("/cerbo/apis/:cerboApiId", auth.Auth, svc_config.DeleteCerboApi)
+	settings.GET("/cerbo/apis", auth.Auth, adminAccess, svc_config.GetCerboApis)
+	settings.POST("/cerbo/apis", auth.Auth, adminAccess, svc_config.CreateCerboApi)
+	settings.PATCH("/cerbo/apis/:cerboApiId", auth.Auth, adminAccess, svc_config.UpdateCerboApi)
+	settings.DELETE("/cerbo/apis/:cerboApiId", auth.Auth, adminAccess, svc_config.DeleteCerboApi)
 
 	// Locations Settings
-	settings.GET("/locations/list", auth.Auth, svc_config.ListLocations)
-	settings.PATCH("/locations/:locationId", auth.Auth, svc_config.UpdateLocation)
-	settings.DELETE("/locations/:locationId", auth.Auth, svc_config.DeleteLocation)
-	settings.GET("/locations/:locationId/delete-preview", auth.Auth, svc_config.DeleteLocationDryRun)
-	settings.GET("/oauth/link", auth.Auth, svc_ghl.GetGhlOauthLink)
+	settings.GET("/locations/list", auth.Auth, adminAccess, svc_config.ListLocations)
+	settings.PATCH("/locations/:locationId", auth.Auth, adminAccess, svc_config.UpdateLocation)
+	settings.DELETE("/locations/:locationId", auth.Auth, adminAccess, svc_config.DeleteLocation)
+	settings.GET("/locations/:locationId/delete-preview", auth.Auth, adminAccess, svc_config.DeleteLocationDryRun)
+	settings.POST("/locations/:locationId/widget-key", auth.Auth, adminAccess, svc_config.RotateWidgetKey)
+	settings.GET("/locations/:locationId/calendars", auth.Auth, adminAccess, svc_config.ListLocationCalendars)
+	settings.PATCH("/locations/:locationId/calendars/:calendarId", auth.Auth, adminAccess, svc_config.UpdateCalendarWidgetSettings)
+	settings.GET("/oauth/link", auth.Auth, adminAccess, svc_ghl.GetGhlOauthLink)
+
+	// Members and invites
+	settings.GET("/members", auth.Auth, adminOnly, auth.ListMembers)
+	settings.PATCH("/members/:memberId", auth.Auth, adminOnly, auth.UpdateMember)
+	settings.DELETE("/members/:memberId", auth.Auth, adminOnly, auth.RemoveMember)
+	settings.GET("/invites", auth.Auth, adminOnly, auth.ListInvites)
+	settings.POST("/invites", auth.Auth, adminOnly, auth.CreateInvite)
+	settings.DELETE("/invites/:inviteId", auth.Auth, adminOnly, auth.RevokeInvite)
+
+	// Audit log
+	settings.GET("/audit", auth.Auth, adminOnly, svc_audit.ListAuditEvents)
 
 	// Attribution Flows settings
-	settings.POST("/flows", auth.Auth, svc_attribution.CreateAttributionFlow)
-	settings.GET("/flows", auth.Auth, svc_attribution.GetAttributionFlows)
-	settings.DELETE("/flows/:flowId", auth.Auth, svc_attribution.DeleteAttributionFlow)
+	settings.POST("/flows", auth.Auth, adminAccess, svc_attribution.CreateAttributionFlow)
+	settings.GET("/flows", auth.Auth, adminAccess, svc_attribution.GetAttributionFlows)
+	settings.DELETE("/flows/:flowId", auth.Auth, adminAccess, svc_attribution.DeleteAttributionFlow)
+	settings.GET("/flows/:flowId/funnel", auth.Auth, adminAccess, svc_attribution.GetFlowFunnel)
+
+	// Attribution reports
+	attribution := router.Group("/attribution")
+	attribution.GET("/report", auth.Auth, adminAccess, svc_attribution.GetAttributionReport)
+
+	// Funnel reports
+	reports := router.Group("/reports")
+	reports.GET("/definitions", auth.Auth, adminAccess, svc_jpmreport.ListReportDefinitions)
+	reports.POST("/definitions", auth.Auth, adminAccess, svc_jpmreport.CreateReportDefinition)
+	reports.PUT("/definitions/:reportId", auth.Auth, adminAccess, svc_jpmreport.UpdateReportDefinition)
+	reports.DELETE("/definitions/:reportId", auth.Auth, adminAccess, svc_jpmreport.DeleteReportDefinition)
+	reports.GET("/funnel/:reportId", auth.Auth, adminAccess, svc_jpmreport.GetFunnelReport)
+	reports.GET("/ad-spend", auth.Auth, adminAccess, svc_adspend.GetAdSpend)
+	reports.POST("/ad-spend/backfill", auth.Auth, adminAccess, svc_adspend.Backfill)
+	reports.GET("/cohorts", auth.Auth, adminAccess, svc_cohorts.GetCohortReport)
+	reports.GET("/no-shows", auth.Auth, adminAccess, svc_noshows.GetNoShowReport)
+	reports.GET("/subscriptions", auth.Auth, adminAccess, svc_subscriptions.ListSubscriptions)
+	reports.POST("/subscriptions", auth.Auth, adminAccess, svc_subscriptions.CreateSubscription)
+	reports.PUT("/subscriptions/:subscriptionId", auth.Auth, adminAccess, svc_subscriptions.UpdateSubscription)
+	reports.DELETE("/subscriptions/:subscriptionId", auth.Auth, adminAccess, svc_subscriptions.DeleteSubscription)
+	reports.POST("/subscriptions/:subscriptionId/send", auth.Auth, adminAccess, svc_subscriptions.SendSubscription)
+	reports.GET("/subscriptions/:subscriptionId/deliveries", auth.Auth, adminAccess, svc_subscriptions.ListDeliveries)
 
 	// Integrations routes
 	integrations := router.Group("/integrations")
-	integrations.GET("/zenoti/centers/:zenotiApiId", auth.Auth, svc_zenoti.GetZenotiCenters)
-	integrations.GET("/cerbo/encounter-types/:locationId", auth.Auth, svc_cerbo.GetEncounterTypesForLocation)
+	integrations.GET("/zenoti/centers/:zenotiApiId", auth.Auth, adminAccess, svc_zenoti.GetZenotiCenters)
+	integrations.GET("/cerbo/encounter-types/:locationId", auth.Auth, adminAccess, svc_cerbo.GetEncounterTypesForLocation)
+	integrations.GET("/zenoti/calendar-sync/:locationId", auth.Auth, adminAccess, calendarSync)
+	integrations.POST("/zenoti/calendar-sync/:locationId", auth.Auth, adminAccess, calendarSync)
 
-	// Google Ads OAuth (new flow; legacy jpmReport remains unchanged)
+	// Google Ads OAuth
 	ga := router.Group("/google-ads")
-	ga.GET("/auth-url", auth.Auth, svc_googleads.GetAuthURL)
+	ga.GET("/auth-url", auth.Auth, adminAccess, svc_googleads.GetAuthURL)
 	ga.POST("/callback", svc_googleads.OAuthCallback)
 	ga.GET("/callback", svc_googleads.OAuthCallback)
-	ga.GET("/accounts", auth.Auth, svc_googleads.ListConnections)
-	ga.DELETE("/accounts/:accountId", auth.Auth, svc_googleads.DeleteConnection)
-	ga.GET("/accounts/:accountId/hierarchy", auth.Auth, svc_googleads.ListAccountHierarchy)
-	ga.POST("/locations/:locationId/settings", auth.Auth, svc_googleads.SaveLocationSetting)
-	ga.GET("/locations/:locationId/settings", auth.Auth, svc_googleads.GetLocationSetting)
-	ga.GET("/locations/:locationId/conversion-actions", auth.Auth, svc_googleads.GetLocationConversionActions)
+	ga.GET("/accounts", auth.Auth, adminAccess, svc_googleads.ListConnections)
+	ga.DELETE("/accounts/:accountId", auth.Auth, adminAccess, svc_googleads.DeleteConnection)
+	ga.GET("/accounts/:accountId/hierarchy", auth.Auth, adminAccess, svc_googleads.ListAccountHierarchy)
+	ga.POST("/locations/:locationId/settings", auth.Auth, adminAccess, svc_googleads.SaveLocationSetting)
+	ga.GET("/locations/:locationId/settings", auth.Auth, adminAccess, svc_googleads.GetLocationSetting)
+	ga.GET("/locations/:locationId/conversion-actions", auth.Auth, adminAccess, svc_googleads.GetLocationConversionActions)
+
+	// Meta Ads OAuth
+	metaAds := router.Group("/meta-ads")
+	metaAds.GET("/auth-url", auth.Auth, adminAccess, svc_meta.GetAuthURL)
+	metaAds.POST("/callback", svc_meta.OAuthCallback)
+	metaAds.GET("/callback", svc_meta.OAuthCallback)
+	metaAds.GET("/accounts", auth.Auth, adminAccess, svc_meta.ListConnections)
+	metaAds.DELETE("/accounts/:accountId", auth.Auth, adminAccess, svc_meta.DeleteConnection)
+	metaAds.GET("/accounts/:accountId/ad-accounts", auth.Auth, adminAccess, svc_meta.ListAdAccounts)
+	metaAds.POST("/locations/:locationId/settings", auth.Auth, adminAccess, svc_meta.SaveLocationSetting)
+	metaAds.GET("/locations/:locationId/settings", auth.Auth, adminAccess, svc_meta.GetLocationSetting)
 
 	// Legacy aliases to avoid breaking existing references
 	legacyGA := router.Group("/googleads")
-	legacyGA.GET("/oauth/url", auth.Auth, svc_googleads.GetAuthURL)
-	legacyGA.GET("/connections", auth.Auth, svc_googleads.ListConnections)
-	legacyGA.DELETE("/connections/:accountId", auth.Auth, svc_googleads.DeleteConnection)
+	legacyGA.GET("/oauth/url", auth.Auth, adminAccess, svc_googleads.GetAuthURL)
+	legacyGA.GET("/connections", auth.Auth, adminAccess, svc_googleads.ListConnections)
+	legacyGA.DELETE("/connections/:accountId", auth.Auth, adminAccess, svc_googleads.DeleteConnection)
 	router.GET("/googleads/oauth/callback", svc_googleads.OAuthCallback)
 
 	// AI Assistants
 	ai := router.Group("/ai")
-	ai.GET("/models", auth.Auth, svc_openai.ListModels)
-	ai.GET("/pricing", auth.Auth, svc_openai.ListPricing)
-	ai.PUT("/pricing", auth.Auth, svc_openai.UpsertPricingBatch)
-	ai.PUT("/pricing/:model", auth.Auth, svc_openai.UpsertPricing)
-
-	ai.GET("/usage", auth.Auth, svc_openai.ListUsage)
-	ai.GET("/assistants", auth.Auth, svc_openai.ListAssistants)
-	ai.GET("/assistants/:assistantId", auth.Auth, svc_openai.GetAssistant)
-	ai.POST("/assistants", auth.Auth, svc_openai.CreateAssistant)
-	ai.PATCH("/assistants/:assistantId", auth.Auth, svc_openai.UpdateAssistant)
-	ai.DELETE("/assistants/:assistantId", auth.Auth, svc_openai.DeleteAssistant)
+	ai.GET("/models", auth.Auth, builderAccess, svc_openai.ListModels)
+	ai.GET("/pricing", auth.Auth, builderAccess, svc_openai.ListPricing)
+	ai.PUT("/pricing", auth.Auth, adminAccess, svc_openai.UpsertPricingBatch)
+	ai.PUT("/pricing/:model", auth.Auth, adminAccess, svc_openai.UpsertPricing)
+
+	ai.GET("/usage", auth.Auth, builderAccess, svc_openai.ListUsage)
+	ai.GET("/usage/breakdown", auth.Auth, builderAccess, svc_openai.ListUsageBreakdown)
+	ai.GET("/budgets", auth.Auth, builderAccess, svc_openai.ListBudgets)
+	ai.POST("/budgets", auth.Auth, adminAccess, svc_openai.CreateBudget)
+	ai.PUT("/budgets/:budgetId", auth.Auth, adminAccess, svc_openai.UpdateBudget)
+	ai.DELETE("/budgets/:budgetId", auth.Auth, adminAccess, svc_openai.DeleteBudget)
+	ai.GET("/assistants", auth.Auth, builderAccess, svc_openai.ListAssistants)
+	ai.GET("/assistants/:assistantId", auth.Auth, builderAccess, svc_openai.GetAssistant)
+	ai.POST("/assistants", auth.Auth, builderAccess, svc_openai.CreateAssistant)
+	ai.PATCH("/assistants/:assistantId", auth.Auth, builderAccess, svc_openai.UpdateAssistant)
+	ai.DELETE("/assistants/:assistantId", auth.Auth, builderAccess, svc_openai.DeleteAssistant)
 
 	// MCP API Key management
 	mcpKeys := router.Group("/mcp/keys")
-	mcpKeys.POST("", auth.Auth, svc_mcp.CreateAPIKey)
-	mcpKeys.GET("", auth.Auth, svc_mcp.ListAPIKeys)
-	mcpKeys.PATCH("/:keyId", auth.Auth, svc_mcp.UpdateAPIKey)
-	mcpKeys.PATCH("/:keyId/revoke", auth.Auth, svc_mcp.RevokeAPIKey)
-	mcpKeys.POST("/:keyId/regenerate", auth.Auth, svc_mcp.RegenerateAPIKey)
-	mcpKeys.DELETE("/:keyId", auth.Auth, svc_mcp.DeleteAPIKey)
+	mcpKeys.POST("", auth.Auth, adminOnly, svc_mcp.CreateAPIKey)
+	mcpKeys.GET("", auth.Auth, adminOnly, svc_mcp.ListAPIKeys)
+	mcpKeys.PATCH("/:keyId", auth.Auth, adminOnly, svc_mcp.UpdateAPIKey)
+	mcpKeys.PATCH("/:keyId/revoke", auth.Auth, adminOnly, svc_mcp.RevokeAPIKey)
+	mcpKeys.POST("/:keyId/regenerate", auth.Auth, adminOnly, svc_mcp.RegenerateAPIKey)
+	mcpKeys.DELETE("/:keyId", auth.Auth, adminOnly, svc_mcp.DeleteAPIKey)
+	mcpKeys.GET("/:keyId/usage", auth.Auth, adminOnly, svc_mcp.GetAPIKeyUsage)
+	router.GET("/mcp/tools", auth.Auth, adminOnly, svc_mcp.ListActionTools)
 
 	// Internal assistant (MCP key auth)
 	internal := router.Group("/internal")
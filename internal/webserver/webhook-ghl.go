@@ -3,10 +3,14 @@ package webServer
 import (
 	"bytes"
 	"client-runaway-zenoti/internal/config"
+	integrations_zenoti "client-runaway-zenoti/internal/integrations/zenoti"
 	"client-runaway-zenoti/internal/services/automator"
+	"client-runaway-zenoti/internal/services/svc_attribution"
+	"client-runaway-zenoti/internal/tgbot"
 	runwayv2 "client-runaway-zenoti/packages/runwayV2"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 
@@ -27,16 +31,34 @@ func ghlWebhookHandler(c *gin.Context) {
 	}
 
 	switch genericPayload.Type {
+	case "AppointmentCreate", "AppointmentDelete":
+		syncGhlAppointment(genericPayload.Type, body)
 	case "AppointmentUpdate":
+		syncGhlAppointment(genericPayload.Type, body)
 		automator.GhlTriggerAppointmentUpdated(context.Background(), body)
 		//transferToDevServer(body, xWhSignature)
 	case "ContactCreate":
+		recordGhlContact(body)
 	}
 
 	// respond with 200 OK to GHL
 	c.Data(lvn.Res(200, "Success", "ok"))
 }
 
+func syncGhlAppointment(eventType string, body []byte) {
+	err := integrations_zenoti.GhlAppointmentWebhookHandler(eventType, body)
+	if err != nil {
+		tgbot.Notify("Webhook Data", fmt.Sprintf("%s\nDATA: %s", err.Error(), string(body)), true)
+	}
+}
+
+func recordGhlContact(body []byte) {
+	err := svc_attribution.RecordGhlContactCreated(body)
+	if err != nil {
+		tgbot.Notify("Webhook Data", fmt.Sprintf("%s\nDATA: %s", err.Error(), string(body)), true)
+	}
+}
+
 func transferToDevServer(body []byte, xWhSignature string) {
 	if config.Confs.Settings.SrvDomain == "https://salesbridge-api.lavina.tech" {
 		// send the body with x-wh-signature to the dev server
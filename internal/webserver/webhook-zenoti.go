@@ -1,6 +1,7 @@
 package webServer
 
 import (
+	integrations_zenoti "client-runaway-zenoti/internal/integrations/zenoti"
 	"client-runaway-zenoti/internal/runway"
 	"client-runaway-zenoti/internal/services/automator"
 	"client-runaway-zenoti/internal/tgbot"
@@ -86,15 +87,33 @@ func zenotiWebhook(c *gin.Context) {
 			tgbot.Notify("Webhook Data", fmt.Sprintf("%s\nDATA: %s", err.Error(), string(bodyBytes)), true)
 		}
 
+		err = integrations_zenoti.ZenotiAppointmentWebhookHandler(body.Event_type, bodyBytes)
+		if err != nil {
+			tgbot.Notify("Webhook Data", fmt.Sprintf("%s\nDATA: %s", err.Error(), string(bodyBytes)), true)
+		}
+
 		err = automator.ZenotiTriggerAppointmentCreated(context.Background(), bodyBytes)
 		lvn.GinErr(c, 500, err, "error in automation")
 
 		c.Data(lvn.Res(200, nil, "Success"))
 
 	case "AppointmentGroup.Status":
+		err = integrations_zenoti.ZenotiAppointmentGroupStatusWebhookHandler(bodyBytes)
+		if err != nil {
+			tgbot.Notify("Webhook Data", fmt.Sprintf("%s\nDATA: %s", err.Error(), string(bodyBytes)), true)
+		}
+
+		err = integrations_zenoti.ZenotiAppointmentWebhookHandler(body.Event_type, bodyBytes)
+		if err != nil {
+			tgbot.Notify("Webhook Data", fmt.Sprintf("%s\nDATA: %s", err.Error(), string(bodyBytes)), true)
+		}
+
 		err = automator.ZenotiTriggerAppointmentGroupStatus(context.Background(), bodyBytes)
 		lvn.GinErr(c, 500, err, "error in automation")
 
+		err = automator.ZenotiTriggerAppointmentNoShow(context.Background(), bodyBytes)
+		lvn.GinErr(c, 500, err, "error in automation")
+
 		c.Data(lvn.Res(200, nil, "Success"))
 	default:
 		c.Data(lvn.Res(200, nil, "Success"))
@@ -4,6 +4,7 @@ import (
 	"client-runaway-zenoti/internal/config"
 	"client-runaway-zenoti/internal/db/models"
 	"client-runaway-zenoti/internal/runway"
+	"client-runaway-zenoti/internal/services/auth"
 	"client-runaway-zenoti/internal/tgbot"
 	"client-runaway-zenoti/internal/zenoti"
 	runwayv2 "client-runaway-zenoti/packages/runwayV2"
@@ -20,6 +21,7 @@ import (
 )
 
 func Listen() {
+	auth.RequireJwtSecret()
 
 	router := gin.Default()
 	router.Use(ErrorHandler)
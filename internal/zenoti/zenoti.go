@@ -13,7 +13,13 @@ func MustGetClientFromLocation(l models.Location) zenotiv1.Client {
 }
 
 func GetUsedUrlFromAPI(api string) string {
-	loc := models.Location{}
-	db.DB.Where("zenoti_api = ?", api).First(&loc)
-	return loc.ZenotiUrl
+	// zenoti_api is encrypted at rest, so it can't be matched in SQL
+	locs := []models.Location{}
+	db.DB.Select("id, zenoti_api, zenoti_url").Where("zenoti_url <> ''").Find(&locs)
+	for _, loc := range locs {
+		if loc.ZenotiApi == api {
+			return loc.ZenotiUrl
+		}
+	}
+	return ""
 }
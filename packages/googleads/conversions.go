@@ -9,6 +9,8 @@ import (
 )
 
 // ConversionRequest carries conversion details plus optional UTM custom variable mapping.
+// Leads without a click id are matched by their email and phone (enhanced
+// conversions for leads); both are sent hashed.
 type ConversionRequest struct {
 	ConversionActionID string
 	Gclid              string
@@ -16,6 +18,9 @@ type ConversionRequest struct {
 	Wbraid             string
 	OrderId            string
 
+	Email string
+	Phone string
+
 	ConversionValue float64
 	CurrencyCode    string
 	EventTime       time.Time
@@ -32,13 +37,15 @@ type ConversionRequest struct {
 
 // SendConversion uploads a click conversion with optional UTM custom variables.
 // ctx must already include auth/developer headers (Service.WithHeaders).
+// A rejected conversion is returned as a *PartialFailureError.
 func (c *Client) SendConversion(req ConversionRequest) error {
 
 	if req.ConversionActionID == "" {
 		return fmt.Errorf("googleads: conversion action id required")
 	}
-	if req.Gclid == "" && req.Gbraid == "" && req.Wbraid == "" {
-		return fmt.Errorf("googleads: one of gclid, gbraid, or wbraid is required")
+	identifiers := userIdentifiers(req.Email, req.Phone)
+	if req.Gclid == "" && req.Gbraid == "" && req.Wbraid == "" && len(identifiers) == 0 {
+		return fmt.Errorf("googleads: one of gclid, gbraid, wbraid, email or phone is required")
 	}
 
 	eventTime := req.EventTime
@@ -78,20 +85,28 @@ func (c *Client) SendConversion(req ConversionRequest) error {
 		CurrencyCode:       &currency,
 		ConversionValue:    &req.ConversionValue,
 		CustomVariables:    customVars,
-		Gclid:              &req.Gclid,
 		Gbraid:             req.Gbraid,
 		Wbraid:             req.Wbraid,
-		OrderId:            &req.OrderId,
+		UserIdentifiers:    identifiers,
+	}
+	if req.Gclid != "" {
+		click.Gclid = &req.Gclid
+	}
+	if req.OrderId != "" {
+		click.OrderId = &req.OrderId
 	}
 
 	svc := services.NewConversionUploadServiceClient(c.grpcConn)
-	_, err := svc.UploadClickConversions(c.ctx, &services.UploadClickConversionsRequest{
+	resp, err := svc.UploadClickConversions(c.ctx, &services.UploadClickConversionsRequest{
 		CustomerId:     c.CustomerInfo.CustomerID,
 		ValidateOnly:   req.ValidateOnly,
 		Conversions:    []*services.ClickConversion{click},
 		PartialFailure: true,
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	return partialFailure(resp.GetPartialFailureError(), "conversions")
 }
 
 // ConversionActionSummary is a lightweight view of a conversion action.
@@ -157,10 +172,16 @@ type ConversionAdjustmentRequest struct {
 	RestatementCurrency string
 	AdjustmentTime      time.Time
 	ValidateOnly        bool
+
+	// enhancements match the conversion's user by email and phone
+	Email     string
+	Phone     string
+	UserAgent string
 }
 
 // SendConversionAdjustment uploads a conversion adjustment (restatement/retraction/enhancement).
 // ctx must already include auth/developer headers (Service.WithHeaders).
+// A rejected adjustment is returned as a *PartialFailureError.
 func (c *Client) SendConversionAdjustment(req ConversionAdjustmentRequest) error {
 
 	if req.ConversionActionID == "" {
@@ -183,6 +204,9 @@ func (c *Client) SendConversionAdjustment(req ConversionAdjustmentRequest) error
 	if adjType == enums.ConversionAdjustmentTypeEnum_UNSPECIFIED {
 		adjType = enums.ConversionAdjustmentTypeEnum_RESTATEMENT
 	}
+	if adjType == enums.ConversionAdjustmentTypeEnum_ENHANCEMENT && req.OrderID == "" {
+		return fmt.Errorf("googleads: order_id required for enhancements")
+	}
 
 	adjTimeStr := adjTime.Format("2006-01-02 15:04:05-07:00")
 	origTimeStr := origTime.Format("2006-01-02 15:04:05-07:00")
@@ -203,7 +227,13 @@ func (c *Client) SendConversionAdjustment(req ConversionAdjustmentRequest) error
 	if req.OrderID != "" {
 		convAdj.OrderId = &req.OrderID
 	}
-	if adjType == enums.ConversionAdjustmentTypeEnum_RESTATEMENT || adjType == enums.ConversionAdjustmentTypeEnum_ENHANCEMENT {
+	if adjType == enums.ConversionAdjustmentTypeEnum_ENHANCEMENT {
+		convAdj.UserIdentifiers = userIdentifiers(req.Email, req.Phone)
+		if req.UserAgent != "" {
+			convAdj.UserAgent = &req.UserAgent
+		}
+	}
+	if adjType == enums.ConversionAdjustmentTypeEnum_RESTATEMENT || (adjType == enums.ConversionAdjustmentTypeEnum_ENHANCEMENT && req.RestatementValue != 0) {
 		currency := req.RestatementCurrency
 		if currency == "" {
 			currency = "USD"
@@ -215,11 +245,14 @@ func (c *Client) SendConversionAdjustment(req ConversionAdjustmentRequest) error
 	}
 
 	svc := services.NewConversionAdjustmentUploadServiceClient(c.grpcConn)
-	_, err := svc.UploadConversionAdjustments(c.ctx, &services.UploadConversionAdjustmentsRequest{
+	resp, err := svc.UploadConversionAdjustments(c.ctx, &services.UploadConversionAdjustmentsRequest{
 		CustomerId:            c.CustomerInfo.CustomerID,
 		ValidateOnly:          req.ValidateOnly,
 		ConversionAdjustments: []*services.ConversionAdjustment{convAdj},
 		PartialFailure:        true,
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	return partialFailure(resp.GetPartialFailureError(), "conversion_adjustments")
 }
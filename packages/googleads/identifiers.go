@@ -0,0 +1,65 @@
+package googleads
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/shenzhencenter/google-ads-pb/common"
+	"github.com/shenzhencenter/google-ads-pb/enums"
+)
+
+// userIdentifiers hashes the email and phone of a lead for enhanced
+// conversions. Empty values are left out.
+func userIdentifiers(email, phone string) []*common.UserIdentifier {
+	var ids []*common.UserIdentifier
+	if hashed := HashEmail(email); hashed != "" {
+		ids = append(ids, &common.UserIdentifier{
+			UserIdentifierSource: enums.UserIdentifierSourceEnum_FIRST_PARTY,
+			Identifier:           &common.UserIdentifier_HashedEmail{HashedEmail: hashed},
+		})
+	}
+	if hashed := HashPhone(phone); hashed != "" {
+		ids = append(ids, &common.UserIdentifier{
+			UserIdentifierSource: enums.UserIdentifierSourceEnum_FIRST_PARTY,
+			Identifier:           &common.UserIdentifier_HashedPhoneNumber{HashedPhoneNumber: hashed},
+		})
+	}
+	return ids
+}
+
+// HashEmail normalizes an email the way Google Ads matches it and hashes it.
+// Dots in gmail.com and googlemail.com names are dropped.
+func HashEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return ""
+	}
+	if name, domain, ok := strings.Cut(email, "@"); ok && (domain == "gmail.com" || domain == "googlemail.com") {
+		email = strings.ReplaceAll(name, ".", "") + "@" + domain
+	}
+	return hash(email)
+}
+
+// HashPhone formats a phone as E.164 and hashes it. 10 digit numbers are
+// taken as US numbers.
+func HashPhone(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	if digits == "" {
+		return ""
+	}
+	if len(digits) == 10 {
+		digits = "1" + digits
+	}
+	return hash("+" + digits)
+}
+
+func hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,67 @@
+package googleads
+
+import (
+	"fmt"
+	"strings"
+
+	gaerrors "github.com/shenzhencenter/google-ads-pb/errors"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/encoding/prototext"
+)
+
+// RowError is a row Google Ads rejected in an upload with partial failure
+type RowError struct {
+	Index   int    // position of the row in the upload
+	Code    string // e.g. conversion_upload_error:UNPARSEABLE_GCLID
+	Message string
+}
+
+// PartialFailureError is returned when some rows of an upload failed while
+// the rest were accepted
+type PartialFailureError struct {
+	Rows []RowError
+}
+
+func (e *PartialFailureError) Error() string {
+	msgs := make([]string, 0, len(e.Rows))
+	for _, row := range e.Rows {
+		msgs = append(msgs, fmt.Sprintf("row %d: %s (%s)", row.Index, row.Message, row.Code))
+	}
+	return "googleads: upload partially failed: " + strings.Join(msgs, "; ")
+}
+
+// partialFailure turns the partial failure status of an upload response into
+// a PartialFailureError, nil when every row was accepted. field is the
+// request field the rows were sent in, e.g. "conversions".
+func partialFailure(st *status.Status, field string) error {
+	if st == nil || (st.GetCode() == 0 && len(st.GetDetails()) == 0) {
+		return nil
+	}
+
+	res := &PartialFailureError{}
+	for _, detail := range st.GetDetails() {
+		failure := &gaerrors.GoogleAdsFailure{}
+		if err := detail.UnmarshalTo(failure); err != nil {
+			continue
+		}
+		for _, e := range failure.GetErrors() {
+			row := RowError{Index: -1, Message: e.GetMessage()}
+			if e.GetErrorCode() != nil {
+				row.Code = strings.TrimSpace(prototext.Format(e.GetErrorCode()))
+			}
+			for _, el := range e.GetLocation().GetFieldPathElements() {
+				if el.GetFieldName() == field {
+					row.Index = int(el.GetIndex())
+					break
+				}
+			}
+			res.Rows = append(res.Rows, row)
+		}
+	}
+
+	// the details couldn't be read, keep the summary
+	if len(res.Rows) == 0 {
+		res.Rows = append(res.Rows, RowError{Index: -1, Message: st.GetMessage()})
+	}
+	return res
+}
@@ -0,0 +1,194 @@
+package googleads
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/shenzhencenter/google-ads-pb/services"
+)
+
+// AdSpend sums the cost of the customer account between the two dates,
+// both inclusive, in the account currency.
+func (c *Client) AdSpend(startDate, endDate time.Time) (float64, error) {
+	query := fmt.Sprintf(`
+SELECT
+  metrics.cost_micros
+FROM customer
+WHERE segments.date BETWEEN '%s' AND '%s'`, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+
+	req := &services.SearchGoogleAdsRequest{
+		CustomerId: c.CustomerInfo.CustomerID,
+		Query:      query,
+	}
+
+	svc := services.NewGoogleAdsServiceClient(c.grpcConn)
+	resp, err := svc.Search(c.ctx, req)
+	if err != nil {
+		return 0, fmt.Errorf("search: %w", err)
+	}
+
+	var totalMicros int64
+	for _, row := range resp.Results {
+		if row.GetMetrics() != nil {
+			totalMicros += row.GetMetrics().GetCostMicros()
+		}
+	}
+
+	return float64(totalMicros) / 1e6, nil // micros → currency units
+}
+
+// AdStat is the daily performance of an ad. Campaigns without ads, like
+// Performance Max, and spend not attributed to an ad are reported on a row
+// of the campaign with empty ad group and ad ids.
+type AdStat struct {
+	Date         time.Time
+	CampaignID   string
+	CampaignName string
+	AdGroupID    string
+	AdGroupName  string
+	AdID         string
+	AdName       string
+	Spend        float64
+	Currency     string
+	Clicks       int64
+	Impressions  int64
+}
+
+// DailyAdStats returns the spend, clicks and impressions of every ad by day
+// between the two dates, both inclusive.
+func (c *Client) DailyAdStats(startDate, endDate time.Time) ([]AdStat, error) {
+	dateFilter := fmt.Sprintf("segments.date BETWEEN '%s' AND '%s'", startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+
+	adRows, err := c.searchAll(`
+SELECT
+  segments.date,
+  campaign.id,
+  campaign.name,
+  ad_group.id,
+  ad_group.name,
+  ad_group_ad.ad.id,
+  ad_group_ad.ad.name,
+  metrics.cost_micros,
+  metrics.clicks,
+  metrics.impressions,
+  customer.currency_code
+FROM ad_group_ad
+WHERE ` + dateFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	campaignRows, err := c.searchAll(`
+SELECT
+  segments.date,
+  campaign.id,
+  campaign.name,
+  metrics.cost_micros,
+  metrics.clicks,
+  metrics.impressions,
+  customer.currency_code
+FROM campaign
+WHERE ` + dateFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	campaigns := make([]AdStat, 0, len(campaignRows))
+	for _, row := range campaignRows {
+		stat, err := rowStat(row)
+		if err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, stat)
+	}
+
+	stats := make([]AdStat, 0, len(adRows)+len(campaignRows))
+	for _, row := range adRows {
+		stat, err := rowStat(row)
+		if err != nil {
+			return nil, err
+		}
+		stat.AdGroupID = fmt.Sprint(row.GetAdGroup().GetId())
+		stat.AdGroupName = row.GetAdGroup().GetName()
+		stat.AdID = fmt.Sprint(row.GetAdGroupAd().GetAd().GetId())
+		stat.AdName = row.GetAdGroupAd().GetAd().GetName()
+		stats = append(stats, stat)
+	}
+
+	return append(stats, CampaignRemainders(stats, campaigns)...), nil
+}
+
+// CampaignRemainders returns, for every campaign total by day, what the ad
+// stats of the campaign on that day don't add up to, as a campaign row with
+// empty ad group and ad ids. Totals the ads fully cover are left out.
+func CampaignRemainders(ads, campaigns []AdStat) []AdStat {
+	type campaignDay struct{ date, campaign string }
+	keyOf := func(s AdStat) campaignDay { return campaignDay{s.Date.Format("2006-01-02"), s.CampaignID} }
+
+	rests := slices.Clone(campaigns)
+	remainder := make(map[campaignDay]*AdStat, len(rests))
+	for i := range rests {
+		remainder[keyOf(rests[i])] = &rests[i]
+	}
+
+	for _, ad := range ads {
+		if rest, ok := remainder[keyOf(ad)]; ok {
+			rest.Spend -= ad.Spend
+			rest.Clicks -= ad.Clicks
+			rest.Impressions -= ad.Impressions
+		}
+	}
+
+	res := []AdStat{}
+	for _, rest := range rests {
+		if rest.Spend > 0.005 || rest.Clicks > 0 || rest.Impressions > 0 {
+			rest.Spend = max(rest.Spend, 0)
+			rest.Clicks = max(rest.Clicks, 0)
+			rest.Impressions = max(rest.Impressions, 0)
+			res = append(res, rest)
+		}
+	}
+	return res
+}
+
+// rowStat reads the date, campaign and metrics of a row
+func rowStat(row *services.GoogleAdsRow) (AdStat, error) {
+	date, err := time.Parse("2006-01-02", row.GetSegments().GetDate())
+	if err != nil {
+		return AdStat{}, fmt.Errorf("parse date %q: %w", row.GetSegments().GetDate(), err)
+	}
+	return AdStat{
+		Date:         date,
+		CampaignID:   fmt.Sprint(row.GetCampaign().GetId()),
+		CampaignName: row.GetCampaign().GetName(),
+		Spend:        float64(row.GetMetrics().GetCostMicros()) / 1e6,
+		Currency:     row.GetCustomer().GetCurrencyCode(),
+		Clicks:       row.GetMetrics().GetClicks(),
+		Impressions:  row.GetMetrics().GetImpressions(),
+	}, nil
+}
+
+// searchAll runs a GAQL query and reads every page of its results
+func (c *Client) searchAll(query string) ([]*services.GoogleAdsRow, error) {
+	svc := services.NewGoogleAdsServiceClient(c.grpcConn)
+
+	var rows []*services.GoogleAdsRow
+	pageToken := ""
+	for {
+		resp, err := svc.Search(c.ctx, &services.SearchGoogleAdsRequest{
+			CustomerId: c.CustomerInfo.CustomerID,
+			Query:      query,
+			PageToken:  pageToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("search: %w", err)
+		}
+		rows = append(rows, resp.GetResults()...)
+
+		pageToken = resp.GetNextPageToken()
+		if pageToken == "" {
+			return rows, nil
+		}
+	}
+}
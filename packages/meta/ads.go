@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // Response struct based on Meta Ads Insights API
@@ -46,3 +48,13 @@ func GetMetaAdSpend(accessToken, adAccountID, startDate, endDate string) (string
 
 	return result.Data[0].Spend, nil
 }
+
+// AdSpend sums the spend of the ad account between the two dates, both
+// inclusive, in the account currency
+func (c *Client) AdSpend(adAccountID string, startDate, endDate time.Time) (float64, error) {
+	spend, err := GetMetaAdSpend(c.cfg.access_token, adAccountID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(spend, 64)
+}
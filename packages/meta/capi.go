@@ -0,0 +1,210 @@
+package meta
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Standard events the Conversions API accepts for offline conversions
+const (
+	EventLead     = "Lead"
+	EventSchedule = "Schedule"
+	EventPurchase = "Purchase"
+
+	// ActionSourceSystem marks conversions that happened outside the
+	// website, e.g. a booking or a sale in Zenoti
+	ActionSourceSystem = "system_generated"
+)
+
+type (
+	// ConversionEvent is a server event. Email and Phone are sent hashed.
+	ConversionEvent struct {
+		EventName    string
+		EventTime    time.Time
+		EventID      string // deduplicates the event against the pixel's
+		ActionSource string // ActionSourceSystem by default
+
+		Email      string
+		Phone      string
+		Fbc        string // click id cookie, fb.1.<time>.<fbclid>
+		Fbp        string // browser id cookie
+		ExternalID string
+
+		Value    float64
+		Currency string // ISO 4217, e.g. "USD"
+	}
+
+	ConversionsResponse struct {
+		EventsReceived int      `json:"events_received"`
+		Messages       []string `json:"messages"`
+		FbtraceID      string   `json:"fbtrace_id"`
+	}
+
+	capiEvent struct {
+		EventName    string          `json:"event_name"`
+		EventTime    int64           `json:"event_time"`
+		EventID      string          `json:"event_id,omitempty"`
+		ActionSource string          `json:"action_source"`
+		UserData     capiUserData    `json:"user_data"`
+		CustomData   *capiCustomData `json:"custom_data,omitempty"`
+	}
+
+	capiUserData struct {
+		Em         []string `json:"em,omitempty"`
+		Ph         []string `json:"ph,omitempty"`
+		Fbc        string   `json:"fbc,omitempty"`
+		Fbp        string   `json:"fbp,omitempty"`
+		ExternalID []string `json:"external_id,omitempty"`
+	}
+
+	capiCustomData struct {
+		Value    float64 `json:"value"`
+		Currency string  `json:"currency"`
+	}
+)
+
+// SendConversions posts events to the dataset (pixel) of the Conversions
+// API. With a test event code the events only show up in the Test Events
+// tab of Events Manager.
+// Docs: POST https://graph.facebook.com/v21.0/{pixel_id}/events
+func (c *Client) SendConversions(pixelID string, events []ConversionEvent, testEventCode string) (ConversionsResponse, error) {
+	if pixelID == "" {
+		return ConversionsResponse{}, errors.New("meta capi: pixel id is required")
+	}
+	if len(events) == 0 {
+		return ConversionsResponse{}, errors.New("meta capi: no events to send")
+	}
+
+	data := make([]capiEvent, 0, len(events))
+	for _, e := range events {
+		ev, err := e.toCapi()
+		if err != nil {
+			return ConversionsResponse{}, err
+		}
+		data = append(data, ev)
+	}
+
+	body := map[string]any{"data": data}
+	if testEventCode != "" {
+		body["test_event_code"] = testEventCode
+	}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return ConversionsResponse{}, err
+	}
+
+	q := url.Values{}
+	q.Set("access_token", c.cfg.access_token)
+	endpoint := fmt.Sprintf("https://graph.facebook.com/v21.0/%s/events?%s", url.PathEscape(pixelID), q.Encode())
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(raw))
+	if err != nil {
+		return ConversionsResponse{}, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return ConversionsResponse{}, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	resBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ConversionsResponse{}, err
+	}
+	if resp.StatusCode > 299 {
+		return ConversionsResponse{}, fmt.Errorf("META>POST /%s/events: HTTP error: %v %s", pixelID, resp.StatusCode, string(resBody))
+	}
+
+	out := ConversionsResponse{}
+	if err := json.Unmarshal(resBody, &out); err != nil {
+		return out, fmt.Errorf("decode response: %w", err)
+	}
+	return out, nil
+}
+
+func (e ConversionEvent) toCapi() (capiEvent, error) {
+	if e.EventName == "" {
+		return capiEvent{}, errors.New("meta capi: event name is required")
+	}
+
+	ev := capiEvent{
+		EventName:    e.EventName,
+		EventTime:    e.EventTime.Unix(),
+		EventID:      e.EventID,
+		ActionSource: e.ActionSource,
+		UserData: capiUserData{
+			Fbc: strings.TrimSpace(e.Fbc),
+			Fbp: strings.TrimSpace(e.Fbp),
+		},
+	}
+	if e.EventTime.IsZero() {
+		ev.EventTime = time.Now().Unix()
+	}
+	if ev.ActionSource == "" {
+		ev.ActionSource = ActionSourceSystem
+	}
+	if em := HashEmail(e.Email); em != "" {
+		ev.UserData.Em = []string{em}
+	}
+	if ph := HashPhone(e.Phone); ph != "" {
+		ev.UserData.Ph = []string{ph}
+	}
+	if id := strings.TrimSpace(e.ExternalID); id != "" {
+		ev.UserData.ExternalID = []string{hash(id)}
+	}
+	if ev.UserData.Em == nil && ev.UserData.Ph == nil && ev.UserData.Fbc == "" && ev.UserData.Fbp == "" && ev.UserData.ExternalID == nil {
+		return capiEvent{}, errors.New("meta capi: an email, phone, fbc, fbp or external id is required to match the event")
+	}
+
+	if e.Value != 0 || e.EventName == EventPurchase {
+		currency := strings.ToUpper(strings.TrimSpace(e.Currency))
+		if currency == "" {
+			currency = "USD"
+		}
+		ev.CustomData = &capiCustomData{Value: e.Value, Currency: currency}
+	}
+	return ev, nil
+}
+
+// HashEmail normalizes an email the way Meta matches it and hashes it
+func HashEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return ""
+	}
+	return hash(email)
+}
+
+// HashPhone keeps the digits of a phone, with the country code, and hashes
+// them. 10 digit numbers are taken as US numbers.
+func HashPhone(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	if digits == "" {
+		return ""
+	}
+	if len(digits) == 10 {
+		digits = "1" + digits
+	}
+	return hash(digits)
+}
+
+func hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
@@ -13,9 +13,11 @@ type (
 	}
 
 	AdAccount struct {
-		ID    string `json:"id"`
-		Name  string `json:"name"`
-		Owner string `json:"owner"`
+		ID            string `json:"id"`
+		Name          string `json:"name"`
+		Owner         string `json:"owner"`
+		Currency      string `json:"currency"`
+		AccountStatus int    `json:"account_status"`
 	}
 
 	Paging struct {
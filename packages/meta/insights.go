@@ -12,6 +12,7 @@ type (
 		From          time.Time
 		To            time.Time
 		TimeIncrement int
+		Level         string // account (default), campaign, adset or ad
 		Paging        Paging
 		Fields        []string
 	}
@@ -24,6 +25,11 @@ func (c *Client) Insights(req InsightsReq) (res MetaList[Insight], err error) {
 	}
 
 	qParams := req.GetQParams()
+	next, err := req.Paging.getNextParams()
+	if err != nil {
+		return
+	}
+	qParams = append(qParams, next...)
 
 	resp, err := fetch[[]Insight](reqParams{
 		Method:   "GET",
@@ -36,9 +42,27 @@ func (c *Client) Insights(req InsightsReq) (res MetaList[Insight], err error) {
 	}
 
 	res.Data = resp.Data
+	res.Paging = resp.Paging
 	return
 }
 
+// InsightsAll reads every page of the insights
+func (c *Client) InsightsAll(req InsightsReq) ([]Insight, error) {
+	var res []Insight
+	for {
+		page, err := c.Insights(req)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, page.Data...)
+
+		if page.Paging.Next == "" {
+			return res, nil
+		}
+		req.Paging = page.Paging
+	}
+}
+
 func (r *InsightsReq) GetQParams() []queryParam {
 	res := []queryParam{
 		{
@@ -57,6 +81,13 @@ func (r *InsightsReq) GetQParams() []queryParam {
 		})
 	}
 
+	if r.Level != "" {
+		res = append(res, queryParam{
+			Key:   "level",
+			Value: r.Level,
+		})
+	}
+
 	if len(r.Fields) > 0 {
 		res = append(res, queryParam{
 			Key:   "fields",
@@ -0,0 +1,56 @@
+package meta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AdsScopes are the permissions needed to read ad accounts and their
+// insights, and to send conversions to their pixels
+var AdsScopes = []string{"ads_read", "ads_management", "business_management"}
+
+// AuthURL builds the Facebook login dialog URL. The caller supplies a state
+// that encodes profile/user context.
+// Docs: https://www.facebook.com/v21.0/dialog/oauth
+func AuthURL(appID, redirectURL, state string, scopes []string) (string, error) {
+	if appID == "" || redirectURL == "" {
+		return "", errors.New("meta oauth: app id and redirect url are required")
+	}
+
+	q := url.Values{}
+	q.Set("client_id", appID)
+	q.Set("redirect_uri", redirectURL)
+	q.Set("state", state)
+	q.Set("response_type", "code")
+	q.Set("scope", strings.Join(scopes, ","))
+
+	return "https://www.facebook.com/v21.0/dialog/oauth?" + q.Encode(), nil
+}
+
+// ExchangeCode trades an authorization code for a short-lived user token.
+// Exchange it with ExchangeForLongLivedToken before storing it.
+// Docs: GET https://graph.facebook.com/v21.0/oauth/access_token
+func ExchangeCode(ctx context.Context, appID, appSecret, redirectURL, code string) (ExchangeResponse, error) {
+	if appID == "" || appSecret == "" || redirectURL == "" {
+		return ExchangeResponse{}, errors.New("meta oauth: app id, app secret and redirect url are required")
+	}
+	if code == "" {
+		return ExchangeResponse{}, errors.New("meta oauth: code is required")
+	}
+
+	q := url.Values{}
+	q.Set("client_id", appID)
+	q.Set("client_secret", appSecret)
+	q.Set("redirect_uri", redirectURL)
+	q.Set("code", code)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://graph.facebook.com/v21.0/oauth/access_token?"+q.Encode(), nil)
+	if err != nil {
+		return ExchangeResponse{}, fmt.Errorf("build request: %w", err)
+	}
+	return doTokenRequest(req)
+}
@@ -11,6 +11,21 @@ import (
 	"time"
 )
 
+// Robust HTTP client with timeouts
+var httpClient = &http.Client{
+	Timeout: 20 * time.Second,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		IdleConnTimeout:     90 * time.Second,
+		MaxIdleConns:        100,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
 // ExchangeResponse is the JSON returned by Meta's OAuth token exchange endpoint.
 type ExchangeResponse struct {
 	AccessToken string `json:"access_token"`
@@ -36,26 +51,15 @@ func ExchangeForLongLivedToken(ctx context.Context, appID, appSecret, shortLived
 	u, _ := url.Parse(base)
 	u.RawQuery = q.Encode()
 
-	// Robust HTTP client with timeouts
-	httpClient := &http.Client{
-		Timeout: 20 * time.Second,
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			DialContext: (&net.Dialer{
-				Timeout:   10 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			IdleConnTimeout:     90 * time.Second,
-			MaxIdleConns:        100,
-			TLSHandshakeTimeout: 10 * time.Second,
-		},
-	}
-
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return ExchangeResponse{}, fmt.Errorf("build request: %w", err)
 	}
+	return doTokenRequest(req)
+}
 
+// doTokenRequest sends an OAuth token request and decodes its token
+func doTokenRequest(req *http.Request) (ExchangeResponse, error) {
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		return ExchangeResponse{}, fmt.Errorf("do request: %w", err)
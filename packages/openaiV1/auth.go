@@ -1,6 +1,8 @@
 package openaiv1
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -26,32 +28,7 @@ type (
 )
 
 func (c *Client) fetch(r reqParams, data interface{}) (http.Response, []byte, error) {
-	url := strings.TrimRight(c.cfg.baseURL, "/") + r.Endpoint
-
-	headers := http.Header{}
-	if r.Method != "GET" && r.Body != "" {
-		headers.Add("Content-Type", "application/json")
-	}
-	if c.cfg.apiKey != "" {
-		headers.Add("Authorization", "Bearer "+c.cfg.apiKey)
-	}
-	if c.cfg.organization != "" {
-		headers.Add("OpenAI-Organization", c.cfg.organization)
-	}
-	if c.cfg.project != "" {
-		headers.Add("OpenAI-Project", c.cfg.project)
-	}
-	for key, val := range r.Headers {
-		headers.Add(key, val)
-	}
-
-	if len(r.QParams) > 0 {
-		values := neturl.Values{}
-		for _, v := range r.QParams {
-			values.Add(v.Key, v.Value)
-		}
-		url = url + "?" + values.Encode()
-	}
+	url, headers := c.prepare(r)
 
 	client := &http.Client{}
 	if c.cfg.clientTimeout > 0 {
@@ -81,6 +58,106 @@ func (c *Client) fetch(r reqParams, data interface{}) (http.Response, []byte, er
 	return *res, body, nil
 }
 
+// stream sends the request and hands every server-sent event to onEvent as
+// it arrives. Streams aren't retried, part of the events may be consumed.
+func (c *Client) stream(r reqParams, onEvent func(event string, data []byte) error) error {
+	url, headers := c.prepare(r)
+	headers.Set("Accept", "text/event-stream")
+
+	var bodyReader io.Reader
+	if r.Body != "" {
+		bodyReader = strings.NewReader(r.Body)
+	}
+	req, err := http.NewRequest(r.Method, url, bodyReader)
+	if err != nil {
+		return err
+	}
+	req.Header = headers
+
+	client := &http.Client{}
+	if c.cfg.clientTimeout > 0 {
+		client.Timeout = c.cfg.clientTimeout
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode > 299 {
+		body, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("OPENAI>%s %s: HTTP error: %v %s", r.Method, r.Endpoint, res.StatusCode, string(body))
+	}
+
+	reader := bufio.NewReader(res.Body)
+	event := ""
+	data := []byte{}
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		trimmed := bytes.TrimRight(line, "\r\n")
+
+		switch {
+		case len(trimmed) == 0 && len(line) > 0:
+			// a blank line ends the event
+			if len(data) > 0 {
+				if cbErr := onEvent(event, data); cbErr != nil {
+					return cbErr
+				}
+			}
+			event, data = "", []byte{}
+		case bytes.HasPrefix(trimmed, []byte("event:")):
+			event = string(bytes.TrimSpace(trimmed[len("event:"):]))
+		case bytes.HasPrefix(trimmed, []byte("data:")):
+			if len(data) > 0 {
+				data = append(data, '\n')
+			}
+			data = append(data, bytes.TrimPrefix(trimmed[len("data:"):], []byte(" "))...)
+		}
+
+		if err == io.EOF {
+			if len(data) > 0 {
+				return onEvent(event, data)
+			}
+			return nil
+		}
+	}
+}
+
+func (c *Client) prepare(r reqParams) (string, http.Header) {
+	url := strings.TrimRight(c.cfg.baseURL, "/") + r.Endpoint
+
+	headers := http.Header{}
+	if r.Method != "GET" && r.Body != "" {
+		headers.Add("Content-Type", "application/json")
+	}
+	if c.cfg.apiKey != "" {
+		headers.Add("Authorization", "Bearer "+c.cfg.apiKey)
+	}
+	if c.cfg.organization != "" {
+		headers.Add("OpenAI-Organization", c.cfg.organization)
+	}
+	if c.cfg.project != "" {
+		headers.Add("OpenAI-Project", c.cfg.project)
+	}
+	for key, val := range r.Headers {
+		headers.Add(key, val)
+	}
+
+	if len(r.QParams) > 0 {
+		values := neturl.Values{}
+		for _, v := range r.QParams {
+			values.Add(v.Key, v.Value)
+		}
+		url = url + "?" + values.Encode()
+	}
+
+	return url, headers
+}
+
 func (c *Client) doRequestWithRetry(client *http.Client, method, url, body string, headers http.Header, retries int) (*http.Response, []byte, error) {
 	var lastErr error
 	for attempt := 0; attempt <= retries; attempt++ {
@@ -0,0 +1,167 @@
+package openaiv1
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type (
+	ChatCompletionRequest struct {
+		Model          string              `json:"model"`
+		Messages       []ChatMessage       `json:"messages"`
+		Tools          []Tool              `json:"tools,omitempty"`
+		ToolChoice     interface{}         `json:"tool_choice,omitempty"`
+		Temperature    *float64            `json:"temperature,omitempty"`
+		ResponseFormat *ChatResponseFormat `json:"response_format,omitempty"`
+		Stream         bool                `json:"stream,omitempty"`
+		StreamOptions  *ChatStreamOptions  `json:"stream_options,omitempty"`
+	}
+
+	ChatMessage struct {
+		Role       string         `json:"role"`
+		Content    string         `json:"content"`
+		ToolCalls  []ChatToolCall `json:"tool_calls,omitempty"`
+		ToolCallID string         `json:"tool_call_id,omitempty"`
+	}
+
+	ChatToolCall struct {
+		Index    *int                 `json:"index,omitempty"` // stream deltas only
+		ID       string               `json:"id,omitempty"`
+		Type     string               `json:"type,omitempty"`
+		Function ChatToolCallFunction `json:"function"`
+	}
+
+	ChatToolCallFunction struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	}
+
+	ChatResponseFormat struct {
+		Type       string          `json:"type"` // text, json_object or json_schema
+		JSONSchema *ChatJSONSchema `json:"json_schema,omitempty"`
+	}
+
+	ChatJSONSchema struct {
+		Name   string                 `json:"name"`
+		Schema map[string]interface{} `json:"schema"`
+		Strict *bool                  `json:"strict,omitempty"`
+	}
+
+	ChatStreamOptions struct {
+		IncludeUsage bool `json:"include_usage"`
+	}
+
+	ChatCompletion struct {
+		ID      string        `json:"id,omitempty"`
+		Object  string        `json:"object,omitempty"`
+		Model   string        `json:"model,omitempty"`
+		Created int64         `json:"created,omitempty"`
+		Choices []ChatChoice  `json:"choices,omitempty"`
+		Usage   ResponseUsage `json:"usage,omitempty"`
+	}
+
+	ChatChoice struct {
+		Index        int         `json:"index"`
+		Message      ChatMessage `json:"message,omitempty"`
+		Delta        ChatMessage `json:"delta,omitempty"`
+		FinishReason string      `json:"finish_reason,omitempty"`
+	}
+)
+
+func (c *Client) ChatCompletionsCreate(req ChatCompletionRequest) (ChatCompletion, error) {
+	res := ChatCompletion{}
+	req.Stream = false
+	req.StreamOptions = nil
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return res, err
+	}
+
+	_, _, err = c.fetch(reqParams{
+		Method:   "POST",
+		Endpoint: "/chat/completions",
+		Body:     string(payload),
+	}, &res)
+
+	return res, err
+}
+
+// ChatCompletionsCreateStream streams a completion, calls onDelta with the
+// content deltas and returns the completion assembled from the chunks.
+func (c *Client) ChatCompletionsCreateStream(req ChatCompletionRequest, onDelta func(string)) (ChatCompletion, error) {
+	res := ChatCompletion{}
+	req.Stream = true
+	req.StreamOptions = &ChatStreamOptions{IncludeUsage: true}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return res, err
+	}
+
+	message := ChatMessage{Role: "assistant"}
+	finishReason := ""
+	err = c.stream(reqParams{
+		Method:   "POST",
+		Endpoint: "/chat/completions",
+		Body:     string(payload),
+	}, func(_ string, data []byte) error {
+		if string(data) == "[DONE]" {
+			return nil
+		}
+
+		chunk := ChatCompletion{}
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("OPENAI>stream: invalid chunk: %w", err)
+		}
+		res.ID, res.Object, res.Model, res.Created = chunk.ID, chunk.Object, chunk.Model, chunk.Created
+		if chunk.Usage.PromptTokens > 0 || chunk.Usage.CompletionTokens > 0 {
+			res.Usage = chunk.Usage
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			if choice.Delta.Content != "" {
+				message.Content += choice.Delta.Content
+				if onDelta != nil {
+					onDelta(choice.Delta.Content)
+				}
+			}
+			for _, call := range choice.Delta.ToolCalls {
+				message.ToolCalls = mergeToolCallDelta(message.ToolCalls, call)
+			}
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return res, err
+	}
+
+	res.Choices = []ChatChoice{{Message: message, FinishReason: finishReason}}
+	return res, nil
+}
+
+// mergeToolCallDelta adds a streamed tool call fragment to the calls, the
+// fragments of one call share its index
+func mergeToolCallDelta(calls []ChatToolCall, delta ChatToolCall) []ChatToolCall {
+	index := len(calls)
+	if delta.Index != nil {
+		index = *delta.Index
+	}
+	for len(calls) <= index {
+		calls = append(calls, ChatToolCall{Type: "function"})
+	}
+
+	call := &calls[index]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Function.Name != "" {
+		call.Function.Name = delta.Function.Name
+	}
+	call.Function.Arguments += delta.Function.Arguments
+	return calls
+}
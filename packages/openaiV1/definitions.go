@@ -40,6 +40,11 @@ type (
 		Status  string                  `json:"status,omitempty"`
 		Role    string                  `json:"role,omitempty"`
 		Content []ResponseOutputContent `json:"content,omitempty"`
+
+		// function_call items
+		CallID    string `json:"call_id,omitempty"`
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments,omitempty"`
 	}
 
 	Assistant struct {
@@ -1,18 +1,71 @@
 package openaiv1
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
 
 type (
 	ResponseRequest struct {
-		Model           string         `json:"model"`
-		Input           interface{}    `json:"input,omitempty"`
-		Instructions    string         `json:"instructions,omitempty"`
-		Tools           []Tool         `json:"tools,omitempty"`
-		ToolChoice      interface{}    `json:"tool_choice,omitempty"`
-		Temperature     *float64       `json:"temperature,omitempty"`
-		MaxOutputTokens int            `json:"max_output_tokens,omitempty"`
-		Metadata        map[string]any `json:"metadata,omitempty"`
-		Stream          bool           `json:"stream,omitempty"`
+		Model              string         `json:"model"`
+		Input              interface{}    `json:"input,omitempty"`
+		Instructions       string         `json:"instructions,omitempty"`
+		Tools              []ResponseTool `json:"tools,omitempty"`
+		Text               *ResponseText  `json:"text,omitempty"`
+		ToolChoice         interface{}    `json:"tool_choice,omitempty"`
+		Temperature        *float64       `json:"temperature,omitempty"`
+		MaxOutputTokens    int            `json:"max_output_tokens,omitempty"`
+		Metadata           map[string]any `json:"metadata,omitempty"`
+		PreviousResponseID string         `json:"previous_response_id,omitempty"`
+		Store              *bool          `json:"store,omitempty"`
+		Stream             bool           `json:"stream,omitempty"`
+	}
+
+	// ResponseTool is a tool in the Responses API format. Function tools are
+	// flat, unlike Tool of the Assistants API.
+	ResponseTool struct {
+		Type           string                 `json:"type"`
+		Name           string                 `json:"name,omitempty"`
+		Description    string                 `json:"description,omitempty"`
+		Parameters     map[string]interface{} `json:"parameters,omitempty"`
+		Strict         *bool                  `json:"strict,omitempty"`
+		Container      interface{}            `json:"container,omitempty"`
+		VectorStoreIDs []string               `json:"vector_store_ids,omitempty"`
+	}
+
+	// ResponseText sets the output format, e.g. a JSON schema for structured output
+	ResponseText struct {
+		Format ResponseTextFormat `json:"format"`
+	}
+
+	ResponseTextFormat struct {
+		Type        string                 `json:"type"` // text, json_object or json_schema
+		Name        string                 `json:"name,omitempty"`
+		Description string                 `json:"description,omitempty"`
+		Schema      map[string]interface{} `json:"schema,omitempty"`
+		Strict      *bool                  `json:"strict,omitempty"`
+	}
+
+	// ResponseInputMessage is a message item of the request input
+	ResponseInputMessage struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+
+	// ResponseFunctionCall replays a function call of an earlier response
+	ResponseFunctionCall struct {
+		Type      string `json:"type"` // function_call
+		CallID    string `json:"call_id"`
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	}
+
+	// ResponseFunctionCallOutput answers a function_call output item
+	ResponseFunctionCallOutput struct {
+		Type   string `json:"type"` // function_call_output
+		CallID string `json:"call_id"`
+		Output string `json:"output"`
 	}
 
 	Response struct {
@@ -25,10 +78,23 @@ type (
 		Usage     ResponseUsage    `json:"usage,omitempty"`
 		Error     *ResponseError   `json:"error,omitempty"`
 	}
+
+	// ResponseStreamEvent is one server-sent event of a streamed response
+	ResponseStreamEvent struct {
+		Type        string          `json:"type"`
+		Delta       string          `json:"delta,omitempty"`
+		ItemID      string          `json:"item_id,omitempty"`
+		OutputIndex int             `json:"output_index,omitempty"`
+		Item        *ResponseOutput `json:"item,omitempty"`
+		Response    *Response       `json:"response,omitempty"`
+		Code        string          `json:"code,omitempty"`
+		Message     string          `json:"message,omitempty"`
+	}
 )
 
 func (c *Client) ResponsesCreate(req ResponseRequest) (Response, error) {
 	res := Response{}
+	req.Stream = false
 	payload, err := json.Marshal(req)
 	if err != nil {
 		return res, err
@@ -42,3 +108,81 @@ func (c *Client) ResponsesCreate(req ResponseRequest) (Response, error) {
 
 	return res, err
 }
+
+// ResponsesCreateStream creates a response with streaming on, calls onEvent
+// for every event and returns the final response once it is done.
+func (c *Client) ResponsesCreateStream(req ResponseRequest, onEvent func(ResponseStreamEvent)) (Response, error) {
+	res := Response{}
+	req.Stream = true
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return res, err
+	}
+
+	done := false
+	err = c.stream(reqParams{
+		Method:   "POST",
+		Endpoint: "/responses",
+		Body:     string(payload),
+	}, func(_ string, data []byte) error {
+		event := ResponseStreamEvent{}
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("OPENAI>stream: invalid event: %w", err)
+		}
+		if onEvent != nil {
+			onEvent(event)
+		}
+
+		switch event.Type {
+		case "response.completed", "response.incomplete", "response.failed":
+			if event.Response != nil {
+				res = *event.Response
+			}
+			done = true
+		case "error":
+			return fmt.Errorf("OPENAI>stream: %s %s", event.Code, event.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return res, err
+	}
+	if !done {
+		return res, fmt.Errorf("OPENAI>stream: ended before the response was done")
+	}
+	if res.Status == "failed" {
+		if res.Error != nil {
+			return res, fmt.Errorf("OPENAI>response failed: %s", res.Error.Message)
+		}
+		return res, fmt.Errorf("OPENAI>response failed")
+	}
+
+	return res, nil
+}
+
+// OutputText joins the text of the assistant messages in the output
+func (r Response) OutputText() string {
+	parts := []string{}
+	for _, item := range r.Output {
+		if item.Type != "message" {
+			continue
+		}
+		for _, content := range item.Content {
+			if content.Type == "output_text" && content.Text != "" {
+				parts = append(parts, content.Text)
+			}
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// FunctionCalls returns the function calls the model asked for
+func (r Response) FunctionCalls() []ResponseOutput {
+	calls := []ResponseOutput{}
+	for _, item := range r.Output {
+		if item.Type == "function_call" {
+			calls = append(calls, item)
+		}
+	}
+	return calls
+}
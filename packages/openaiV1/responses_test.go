@@ -16,3 +16,26 @@ func TestResponsesCreate(t *testing.T) {
 		t.Error("expected response id")
 	}
 }
+
+func TestResponsesCreateStream(t *testing.T) {
+	client := getClient(t)
+
+	deltas := 0
+	resp, err := client.ResponsesCreateStream(ResponseRequest{
+		Model: "gpt-4.1-mini",
+		Input: "Say hello in one sentence.",
+	}, func(event ResponseStreamEvent) {
+		if event.Type == "response.output_text.delta" {
+			deltas++
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deltas == 0 {
+		t.Error("expected text deltas")
+	}
+	if resp.OutputText() == "" {
+		t.Error("expected output text")
+	}
+}
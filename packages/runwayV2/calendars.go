@@ -3,6 +3,7 @@ package runwayv2
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 )
 
@@ -41,6 +42,15 @@ type (
 		EndTime    string `json:"endTime"`
 		Title      string `json:"title"`
 	}
+
+	CalendarEditAppointmentReq struct {
+		CalendarId        string     `json:"calendarId,omitempty"`
+		StartTime         *time.Time `json:"startTime,omitempty"`
+		EndTime           *time.Time `json:"endTime,omitempty"`
+		Title             string     `json:"title,omitempty"`
+		AppointmentStatus string     `json:"appointmentStatus,omitempty"`
+		IgnoreDateRange   bool       `json:"ignoreDateRange,omitempty"`
+	}
 )
 
 func (a *Client) CalendarsGet() ([]Calendar, error) {
@@ -150,25 +160,99 @@ func (a *Client) CalendarEditBlockSlot(id string, req BlockSlot) (BlockSlot, err
 	return res, err
 }
 
-func (a *Client) CalendarGetFreeSlots(calendarId string, start, end time.Time) (TimeSlots, error) {
+// CalendarGetFreeSlots returns the free slots of a calendar between start
+// and end. timezone is an IANA name the slots are rendered in; empty leaves
+// it to the calendar settings.
+func (a *Client) CalendarGetFreeSlots(calendarId string, start, end time.Time, timezone string) (TimeSlots, error) {
 	res := TimeSlots{}
 
-	_, bd, err := a.fetch(reqParams{
+	qParams := []queryParam{
+		{
+			Key:   "startDate",
+			Value: fmt.Sprintf("%d", start.UnixMilli()),
+		},
+		{
+			Key:   "endDate",
+			Value: fmt.Sprintf("%d", end.UnixMilli()),
+		},
+	}
+	if timezone != "" {
+		qParams = append(qParams, queryParam{Key: "timezone", Value: timezone})
+	}
+
+	_, _, err := a.fetch(reqParams{
 		Method:   "GET",
 		Endpoint: "/calendars/" + calendarId + "/free-slots",
-		QParams: []queryParam{
-			{
-				Key:   "startDate",
-				Value: fmt.Sprintf("%d", start.Unix()),
-			},
-			{
-				Key:   "endDate",
-				Value: fmt.Sprintf("%d", end.Unix()),
-			},
-		},
+		QParams:  qParams,
 	}, &res)
 
-	fmt.Println(string(bd))
+	return res, err
+}
+
+func (a *Client) CalendarGetAppointment(eventId string) (Appointment, error) {
+	res := struct {
+		Appointment Appointment `json:"appointment"`
+	}{}
+
+	_, _, err := a.fetch(reqParams{
+		Method:   "GET",
+		Endpoint: "/calendars/events/appointments/" + eventId,
+	}, &res)
+
+	return res.Appointment, err
+}
+
+func (a *Client) CalendarEditAppointment(eventId string, req CalendarEditAppointmentReq) (Appointment, error) {
+	res := Appointment{}
+	bd, err := json.Marshal(req)
+
+	if err != nil {
+		return res, err
+	}
+
+	_, _, err = a.fetch(reqParams{
+		Method:   "PUT",
+		Endpoint: "/calendars/events/appointments/" + eventId,
+		Body:     string(bd),
+	}, &res)
 
 	return res, err
 }
+
+// UnmarshalJSON skips the non-date keys (e.g. traceId) of the free-slots response
+func (t *TimeSlots) UnmarshalJSON(data []byte) error {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	res := TimeSlots{}
+	for k, v := range raw {
+		if _, err := time.Parse("2006-01-02", k); err != nil {
+			continue
+		}
+		day := TimeSlotsDay{}
+		if err := json.Unmarshal(v, &day); err != nil {
+			return err
+		}
+		res[k] = day
+	}
+	*t = res
+	return nil
+}
+
+// Times returns all free slot start times in chronological order
+func (t TimeSlots) Times() ([]time.Time, error) {
+	res := []time.Time{}
+	for _, day := range t {
+		for _, s := range day.Slots {
+			st, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, st)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Before(res[j]) })
+	return res, nil
+}
@@ -155,10 +155,11 @@ type (
 		AppointmentStatus string
 	}
 
-	TimeSlots struct {
-		Dates struct {
-			Slots []string `json:"slots,omitempty"`
-		} `json:"_dates_,omitempty"`
+	// TimeSlots is the free-slots response keyed by date (2006-01-02)
+	TimeSlots map[string]TimeSlotsDay
+
+	TimeSlotsDay struct {
+		Slots []string `json:"slots,omitempty"`
 	}
 
 	OpportunityStatus string
@@ -255,6 +256,37 @@ type (
 		LocationId string `json:"locationId,omitempty"`
 		Timestamp  string `json:"timestamp,omitempty"`
 	}
+
+	// ContactWebhook is the payload of the ContactCreate webhook
+	ContactWebhook struct {
+		Id                    string              `json:"id"`
+		LocationId            string              `json:"locationId"`
+		Email                 string              `json:"email"`
+		Phone                 string              `json:"phone"`
+		FirstName             string              `json:"firstName"`
+		LastName              string              `json:"lastName"`
+		Source                string              `json:"source"`
+		DateAdded             string              `json:"dateAdded"`
+		DateUpdated           string              `json:"dateUpdated,omitempty"`
+		AttributionSource     *ContactAttribution `json:"attributionSource,omitempty"`
+		LastAttributionSource *ContactAttribution `json:"lastAttributionSource,omitempty"`
+	}
+
+	// ContactAttribution is the session a contact was tracked from
+	ContactAttribution struct {
+		SessionSource string `json:"sessionSource,omitempty"`
+		Medium        string `json:"medium,omitempty"`
+		Url           string `json:"url,omitempty"`
+		Referrer      string `json:"referrer,omitempty"`
+		Campaign      string `json:"campaign,omitempty"`
+		UtmSource     string `json:"utmSource,omitempty"`
+		UtmMedium     string `json:"utmMedium,omitempty"`
+		UtmCampaign   string `json:"utmCampaign,omitempty"`
+		UtmContent    string `json:"utmContent,omitempty"`
+		UtmTerm       string `json:"utmTerm,omitempty"`
+		Gclid         string `json:"gclid,omitempty"`
+		Fbclid        string `json:"fbclid,omitempty"`
+	}
 )
 
 // MarshalJSON implements custom JSON marshaling for Filter so that the
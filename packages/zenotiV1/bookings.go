@@ -92,20 +92,27 @@ func (c *Client) BookingsConfirm(bookingId string) (Reservation, error) {
 }
 
 func (c *Client) BookWithConfirm(req BookingReq) (Booking, error) {
+	book, _, err := c.BookWithConfirmDetails(req)
+	return book, err
+}
+
+// BookWithConfirmDetails books, reserves and confirms the slot, returning the
+// confirmed reservation as well so callers can keep track of the invoice.
+func (c *Client) BookWithConfirmDetails(req BookingReq) (Booking, Reservation, error) {
 	book, err := c.BookingsCreate(req)
 	if err != nil {
-		return book, err
+		return book, Reservation{}, err
 	}
 
 	_, err = c.BookingsReserve(book.Id, req.Date.Time.Format("2006-01-02T15:04:05"))
 	if err != nil {
-		return book, err
+		return book, Reservation{}, err
 	}
 
-	_, err = c.BookingsConfirm(book.Id)
+	res, err := c.BookingsConfirm(book.Id)
 	if err != nil {
-		return book, err
+		return book, res, err
 	}
 
-	return book, nil
+	return book, res, nil
 }
@@ -1,6 +1,9 @@
 package zenotiv1
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 func (c *Client) InvoicesGetDetails(invoiceId string) (Invoice, error) {
 	res := Invoice{}
@@ -13,3 +16,32 @@ func (c *Client) InvoicesGetDetails(invoiceId string) (Invoice, error) {
 	fmt.Println(string(body))
 	return res, err
 }
+
+// Cancels all appointments of the invoice.
+func (c *Client) InvoicesCancel(invoiceId, comments string) error {
+	res := struct {
+		Error struct {
+			StatusCode int
+			Message    string
+		}
+	}{}
+
+	body, err := json.Marshal(map[string]string{
+		"comments": comments,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, _, err = c.fetch(reqParams{
+		Method:   "PUT",
+		Endpoint: "/invoices/" + invoiceId + "/cancel",
+		Body:     string(body),
+	}, &res)
+
+	if res.Error.StatusCode != 0 {
+		return fmt.Errorf("error: %s", res.Error.Message)
+	}
+
+	return err
+}
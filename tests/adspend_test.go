@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"client-runaway-zenoti/internal/db/models"
+	"client-runaway-zenoti/internal/reports"
+	"client-runaway-zenoti/internal/services/svc_adspend"
+	"client-runaway-zenoti/packages/googleads"
+	"testing"
+	"time"
+)
+
+func TestSplitShared(t *testing.T) {
+	rows := []models.AdSpendDaily{
+		{LocationId: "a", CampaignId: "1", Spend: 90, Clicks: 10, Impressions: 301},
+		{LocationId: "a", CampaignId: "2", Spend: 3, Clicks: 1, Impressions: 2},
+	}
+
+	shares := svc_adspend.SplitShared(rows, []string{"a", "b", "c"})
+	if len(shares) != 6 {
+		t.Fatalf("got %d shares, want 6", len(shares))
+	}
+
+	spend := map[string]float64{}
+	var clicks, impressions int64
+	for _, s := range shares {
+		spend[s.LocationId] += s.Spend
+		clicks += s.Clicks
+		impressions += s.Impressions
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if spend[id] != 31 {
+			t.Errorf("spend of %s = %v, want 31", id, spend[id])
+		}
+	}
+	if clicks != 11 || impressions != 303 {
+		t.Errorf("clicks = %d, impressions = %d, want 11 and 303", clicks, impressions)
+	}
+	if shares[0].Clicks != 4 || shares[1].Clicks != 3 || shares[2].Clicks != 3 {
+		t.Errorf("click shares = %d %d %d, want 4 3 3", shares[0].Clicks, shares[1].Clicks, shares[2].Clicks)
+	}
+
+	if got := svc_adspend.SplitShared(rows, []string{"a"}); len(got) != 2 || got[0].Spend != 90 {
+		t.Errorf("single location split = %+v", got)
+	}
+}
+
+func TestCampaignRemainders(t *testing.T) {
+	day1 := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+
+	campaigns := []googleads.AdStat{
+		{Date: day1, CampaignID: "search", Spend: 100, Clicks: 20, Impressions: 1000},
+		{Date: day2, CampaignID: "search", Spend: 50, Clicks: 10, Impressions: 500},
+		// performance max has no ads
+		{Date: day1, CampaignID: "pmax", Spend: 30, Clicks: 3, Impressions: 300},
+	}
+	ads := []googleads.AdStat{
+		{Date: day1, CampaignID: "search", AdID: "1", Spend: 60, Clicks: 12, Impressions: 600},
+		{Date: day1, CampaignID: "search", AdID: "2", Spend: 30, Clicks: 8, Impressions: 400},
+		// day 2 is fully covered, up to rounding
+		{Date: day2, CampaignID: "search", AdID: "1", Spend: 50.004, Clicks: 10, Impressions: 500},
+	}
+
+	rests := googleads.CampaignRemainders(ads, campaigns)
+	if len(rests) != 2 {
+		t.Fatalf("got %d remainders, want 2: %+v", len(rests), rests)
+	}
+
+	search := rests[0]
+	if search.CampaignID != "search" || !search.Date.Equal(day1) || search.AdID != "" {
+		t.Errorf("first remainder = %+v, want the search campaign on day 1", search)
+	}
+	if search.Spend != 10 || search.Clicks != 0 || search.Impressions != 0 {
+		t.Errorf("search remainder = %v spend, %d clicks, %d impressions, want 10, 0, 0", search.Spend, search.Clicks, search.Impressions)
+	}
+
+	pmax := rests[1]
+	if pmax.CampaignID != "pmax" || pmax.Spend != 30 || pmax.Clicks != 3 || pmax.Impressions != 300 {
+		t.Errorf("pmax remainder = %+v", pmax)
+	}
+
+	// ads reported above their campaign never go negative
+	over := googleads.CampaignRemainders(
+		[]googleads.AdStat{{Date: day1, CampaignID: "search", Spend: 90, Clicks: 25}},
+		[]googleads.AdStat{{Date: day1, CampaignID: "search", Spend: 100, Clicks: 20}},
+	)
+	if len(over) != 1 || over[0].Spend != 10 || over[0].Clicks != 0 {
+		t.Errorf("overreported remainder = %+v", over)
+	}
+}
+
+func TestMergeExpenses(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	manual := []reports.DailyExpense{
+		{LocationId: "synced", Date: day(1), Total: 10},
+		{LocationId: "synced", Date: day(2), Total: 10},
+		// replaced by the ingested spend
+		{LocationId: "synced", Date: day(3), Total: 10},
+		{LocationId: "manual", Date: day(3), Total: 7},
+		// manual days stored in another time zone still land on their day
+		{LocationId: "synced", Date: time.Date(2025, 3, 4, 0, 0, 0, 0, time.FixedZone("EST", -5*3600)), Total: 10},
+	}
+	ingested := []reports.DailyExpense{
+		// before the sync started, the account's history may be partial
+		{LocationId: "synced", Network: models.AdNetworkGoogle, Date: day(2), Total: 100},
+		{LocationId: "synced", Network: models.AdNetworkGoogle, Date: day(3), Total: 25},
+		{LocationId: "synced", Network: models.AdNetworkGoogle, Date: day(4), Total: 25},
+		// only manual expenses count for locations without a sync
+		{LocationId: "manual", Network: models.AdNetworkGoogle, Date: day(3), Total: 100},
+	}
+	synced := map[string]map[string]time.Time{"synced": {models.AdNetworkGoogle: day(3)}}
+
+	got := reports.MergeExpenses(manual, ingested, synced)
+	if want := 10.0 + 10 + 7 + 25 + 25; got != want {
+		t.Errorf("MergeExpenses = %v, want %v", got, want)
+	}
+
+	if got := reports.MergeExpenses(manual, ingested, nil); got != 47 {
+		t.Errorf("MergeExpenses without syncs = %v, want 47", got)
+	}
+}
+
+func TestMergeExpensesPerNetwork(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	manual := []reports.DailyExpense{
+		// replaced by the ingested Google spend
+		{LocationId: "a", Network: models.AdNetworkGoogle, Date: day(3), Total: 10},
+		// Meta isn't connected, its manual spend stays
+		{LocationId: "a", Network: models.AdNetworkMeta, Date: day(3), Total: 20},
+		// not ad spend
+		{LocationId: "a", Network: models.ExpenseOther, Date: day(3), Total: 5},
+	}
+	ingested := []reports.DailyExpense{
+		{LocationId: "a", Network: models.AdNetworkGoogle, Date: day(3), Total: 12},
+	}
+	synced := map[string]map[string]time.Time{"a": {models.AdNetworkGoogle: day(1)}}
+
+	if got, want := reports.MergeExpenses(manual, ingested, synced), 12.0+20+5; got != want {
+		t.Errorf("MergeExpenses = %v, want %v", got, want)
+	}
+
+	// legacy totals give way from the latest day a network starts
+	synced["a"][models.AdNetworkMeta] = day(3)
+	ingested = append(ingested, reports.DailyExpense{LocationId: "a", Network: models.AdNetworkMeta, Date: day(3), Total: 18})
+	legacy := []reports.DailyExpense{
+		{LocationId: "a", Date: day(2), Total: 30},
+		{LocationId: "a", Date: day(3), Total: 30},
+	}
+	if got, want := reports.MergeExpenses(legacy, ingested, synced), 30.0+12+18; got != want {
+		t.Errorf("MergeExpenses of legacy totals = %v, want %v", got, want)
+	}
+}
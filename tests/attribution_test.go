@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"client-runaway-zenoti/internal/services/svc_attribution"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAttributionNormalizeIdentity(t *testing.T) {
+	phones := map[string]string{
+		"(555) 123-4567":   "15551234567",
+		"+1 555 123 4567":  "15551234567",
+		"1-555-123-4567":   "15551234567",
+		"+44 20 7946 0958": "442079460958",
+		"":                 "",
+	}
+	for raw, want := range phones {
+		if got := svc_attribution.NormalizePhone(raw); got != want {
+			t.Errorf("NormalizePhone(%q) = %q, want %q", raw, got, want)
+		}
+	}
+
+	if got := svc_attribution.NormalizeEmail("  Jane.Doe@Example.COM "); got != "jane.doe@example.com" {
+		t.Errorf("NormalizeEmail = %q", got)
+	}
+}
+
+func TestAttributionCreditWeights(t *testing.T) {
+	conversion := time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)
+	touches := []time.Time{
+		conversion.AddDate(0, 0, -14),
+		conversion.AddDate(0, 0, -7),
+		conversion.AddDate(0, 0, -3),
+		conversion,
+	}
+
+	want := map[string][]float64{
+		svc_attribution.ModelFirstTouch:    {1, 0, 0, 0},
+		svc_attribution.ModelLastTouch:     {0, 0, 0, 1},
+		svc_attribution.ModelLinear:        {0.25, 0.25, 0.25, 0.25},
+		svc_attribution.ModelPositionBased: {0.4, 0.1, 0.1, 0.4},
+	}
+	for model, weights := range want {
+		got := svc_attribution.CreditWeights(model, touches, conversion)
+		for i := range weights {
+			if math.Abs(got[i]-weights[i]) > 1e-9 {
+				t.Errorf("%s: weights = %v, want %v", model, got, weights)
+				break
+			}
+		}
+	}
+
+	// a touch a week older gets half the credit
+	decay := svc_attribution.CreditWeights(svc_attribution.ModelTimeDecay, touches, conversion)
+	if math.Abs(decay[0]*2-decay[1]) > 1e-9 {
+		t.Errorf("time decay: weights = %v", decay)
+	}
+	var total float64
+	for _, w := range decay {
+		total += w
+	}
+	if math.Abs(total-1) > 1e-9 {
+		t.Errorf("time decay: weights add up to %v", total)
+	}
+}
@@ -95,7 +95,7 @@ func TestGetFreeSlots(t *testing.T) {
 	svc := runway.GetSvc()
 	client, _ := svc.NewClientFromId(l.Id)
 
-	res, err := client.CalendarGetFreeSlots(calendarId, start, end)
+	res, err := client.CalendarGetFreeSlots(calendarId, start, end, "")
 	if err != nil {
 		t.Error(err)
 	}
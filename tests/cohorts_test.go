@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"client-runaway-zenoti/internal/services/svc_cohorts"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCohortStart(t *testing.T) {
+	wednesday := time.Date(2025, 3, 12, 15, 30, 0, 0, time.UTC)
+	if got := svc_cohorts.CohortStart(wednesday, svc_cohorts.PeriodWeek); !got.Equal(time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("week cohort = %s", got)
+	}
+	if got := svc_cohorts.CohortStart(wednesday, svc_cohorts.PeriodMonth); !got.Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("month cohort = %s", got)
+	}
+}
+
+func TestBuildCohorts(t *testing.T) {
+	day := func(month time.Month, d int) *time.Time {
+		t := time.Date(2025, month, d, 10, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	leads := []svc_cohorts.CohortLead{
+		// booked, showed and bought within 30 days
+		{CreatedAt: *day(3, 1), Source: "google", BookedAt: day(3, 5), ShowedAt: day(3, 10), SoldAt: day(3, 10),
+			Payments: []svc_cohorts.CohortPayment{{Date: *day(3, 10), Total: 300}, {Date: *day(4, 15), Total: 200}}},
+		// booked in the second month, no show
+		{CreatedAt: *day(3, 20), Source: "google", BookedAt: day(5, 1)},
+		// never booked
+		{CreatedAt: *day(3, 25), Source: "facebook"},
+		// next cohort
+		{CreatedAt: *day(4, 2), Source: "facebook", BookedAt: day(4, 3), ShowedAt: day(4, 3)},
+	}
+
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	rows := svc_cohorts.BuildCohorts(leads, svc_cohorts.PeriodMonth, []int{30, 60}, now)
+
+	var order []string
+	for _, r := range rows {
+		order = append(order, r.Cohort+" "+r.Source)
+	}
+	want := "2025-03-01 All sources,2025-03-01 facebook,2025-03-01 google,2025-04-01 All sources,2025-04-01 facebook"
+	if got := strings.Join(order, ","); got != want {
+		t.Fatalf("rows = %s, want %s", got, want)
+	}
+
+	march := rows[0]
+	if march.Leads != 3 {
+		t.Errorf("march leads = %d", march.Leads)
+	}
+	d30, d60 := march.Offsets[0], march.Offsets[1]
+	if d30.Booked != 1 || d30.Showed != 1 || d30.Sold != 1 || d30.Revenue != 300 {
+		t.Errorf("march day 30 = %+v", d30)
+	}
+	if d60.Booked != 2 || d60.Revenue != 500 || d60.BookingRate != 66.7 || d60.ShowRate != 50 {
+		t.Errorf("march day 60 = %+v", d60)
+	}
+	if !d30.Complete || !d60.Complete {
+		t.Errorf("march offsets should be complete by june")
+	}
+
+	// april's day 60 runs past june 1st for leads created late in april
+	if rows[3].Offsets[1].Complete {
+		t.Errorf("april day 60 should not be complete")
+	}
+
+	csv, err := svc_cohorts.CohortsCSV(rows, []int{30, 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(csv), "\n"); lines != len(rows)+1 {
+		t.Errorf("csv lines = %d", lines)
+	}
+}
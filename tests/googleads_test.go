@@ -19,3 +19,19 @@ func TestGetAdSpend(t *testing.T) {
 	}
 	t.Logf("Ad Spend: %f", spend)
 }
+
+func TestGoogleAdsHashUserData(t *testing.T) {
+	wantEmail := "d6117306485ed0e50afab3ac871e98f81699151f30281527d63ff5f233656c69"
+	for _, raw := range []string{"janedoe@gmail.com", " Jane.Doe@Gmail.com "} {
+		if got := googleads.HashEmail(raw); got != wantEmail {
+			t.Errorf("HashEmail(%q) = %q, want %q", raw, got, wantEmail)
+		}
+	}
+
+	wantPhone := "8a59780bb8cd2ba022bfa5ba2ea3b6e07af17a7d8b30c1f9b3390e36f69019e4"
+	for _, raw := range []string{"(555) 123-4567", "+1 555 123 4567"} {
+		if got := googleads.HashPhone(raw); got != wantPhone {
+			t.Errorf("HashPhone(%q) = %q, want %q", raw, got, wantPhone)
+		}
+	}
+}
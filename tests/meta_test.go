@@ -41,3 +41,21 @@ func TestGetMetaAdSpend(t *testing.T) {
 
 	t.Logf("Ad spend: %s", spend)
 }
+
+func TestMetaHashUserData(t *testing.T) {
+	wantEmail := "86e0b9e56c17cc4d12387e1949b85053fbe73bc3ce5a1188713a9d300cc6133d"
+	if got := meta.HashEmail("  Jane.Doe@Example.COM "); got != wantEmail {
+		t.Errorf("HashEmail = %q, want %q", got, wantEmail)
+	}
+
+	wantPhone := "d6736136ea896c1bfdc553e0e86e702c70d060d805696ca3e4e9e0961353860a"
+	for _, raw := range []string{"(555) 123-4567", "+1 555 123 4567", "15551234567"} {
+		if got := meta.HashPhone(raw); got != wantPhone {
+			t.Errorf("HashPhone(%q) = %q, want %q", raw, got, wantPhone)
+		}
+	}
+
+	if meta.HashEmail(" ") != "" || meta.HashPhone("n/a") != "" {
+		t.Error("empty values must not be hashed")
+	}
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"client-runaway-zenoti/internal/services/svc_noshows"
+	"client-runaway-zenoti/internal/types"
+	"testing"
+	"time"
+)
+
+func TestBuildNoShowReport(t *testing.T) {
+	monday := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	booked := monday.AddDate(0, 0, -7)
+
+	appts := []svc_noshows.NoShowAppointment{
+		{AppointmentId: "1", GuestId: "a", Therapist: "Ann", Service: "Botox", Source: "google", StartTime: monday, BookedAt: booked, Status: types.NoShowed},
+		{AppointmentId: "2", GuestId: "b", Therapist: "Ann", Service: "Botox", Source: "facebook", StartTime: monday, BookedAt: booked, Status: types.Closed},
+		{AppointmentId: "3", GuestId: "c", Therapist: "Bob", Service: "Filler", StartTime: monday.AddDate(0, 0, 1), BookedAt: booked, Status: types.Canceled},
+		{AppointmentId: "4", GuestId: "d", Therapist: "Bob", Service: "Filler", Source: "google", StartTime: monday.AddDate(0, 0, 1), BookedAt: booked, Status: types.NoShowed},
+	}
+	guestAppts := append([]svc_noshows.NoShowAppointment{
+		// a rebooks three days after the no-show
+		{AppointmentId: "5", GuestId: "a", BookedAt: monday.AddDate(0, 0, 3), Status: types.Booked},
+		// c reschedules right when cancelling
+		{AppointmentId: "6", GuestId: "c", BookedAt: monday, Status: types.Confirmed},
+		// d rebooks too late, then cancels another
+		{AppointmentId: "7", GuestId: "d", BookedAt: monday.AddDate(0, 0, 30), Status: types.Booked},
+		{AppointmentId: "8", GuestId: "d", BookedAt: monday.AddDate(0, 0, 2), Status: types.Canceled},
+	}, appts...)
+
+	report := svc_noshows.BuildNoShowReport(appts, guestAppts, 14)
+
+	total := report.Total
+	if total.Appointments != 4 || total.NoShows != 2 || total.Cancellations != 1 || total.Recovered != 2 {
+		t.Fatalf("total = %+v", total)
+	}
+	if total.NoShowRate != 50 || total.CancellationRate != 25 || total.RecoveryRate != 66.7 {
+		t.Errorf("total rates = %+v", total)
+	}
+
+	if len(report.ByDayOfWeek) != 7 || report.ByDayOfWeek[0].Key != "Monday" || report.ByDayOfWeek[0].NoShows != 1 || report.ByDayOfWeek[1].Appointments != 2 {
+		t.Errorf("by day of week = %+v", report.ByDayOfWeek)
+	}
+
+	sources := map[string]svc_noshows.NoShowBucket{}
+	for _, b := range report.BySource {
+		sources[b.Key] = b
+	}
+	if sources["google"].NoShows != 2 || sources["google"].Recovered != 1 || sources["Unknown"].Cancellations != 1 {
+		t.Errorf("by source = %+v", report.BySource)
+	}
+
+	if report.ByTherapist[0].Key != "Ann" || report.ByTherapist[0].NoShowRate != 50 {
+		t.Errorf("by therapist = %+v", report.ByTherapist)
+	}
+}
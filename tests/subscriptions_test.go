@@ -0,0 +1,179 @@
+package tests
+
+import (
+	"bytes"
+	"client-runaway-zenoti/internal/db/models"
+	svc_jpmreport "client-runaway-zenoti/internal/services/svc_jpmReport"
+	"client-runaway-zenoti/internal/services/svc_subscriptions"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSubscriptionNextRun(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+
+	cases := []struct {
+		name    string
+		cadence string
+		tz      string
+		after   time.Time
+		want    time.Time
+	}{
+		{"daily later today", models.ReportCadenceDaily, "", time.Date(2025, 3, 5, 6, 0, 0, 0, time.UTC), time.Date(2025, 3, 5, 8, 0, 0, 0, time.UTC)},
+		{"daily at the hour", models.ReportCadenceDaily, "", time.Date(2025, 3, 5, 8, 0, 0, 0, time.UTC), time.Date(2025, 3, 6, 8, 0, 0, 0, time.UTC)},
+		// clocks go forward on March 9, the send stays at 8 local
+		{"daily over DST", models.ReportCadenceDaily, "America/New_York", time.Date(2025, 3, 8, 9, 0, 0, 0, ny), time.Date(2025, 3, 9, 8, 0, 0, 0, ny)},
+		{"daily back from DST", models.ReportCadenceDaily, "America/New_York", time.Date(2025, 11, 1, 9, 0, 0, 0, ny), time.Date(2025, 11, 2, 8, 0, 0, 0, ny)},
+		{"weekly from sunday", models.ReportCadenceWeekly, "", time.Date(2025, 3, 9, 12, 0, 0, 0, time.UTC), time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)},
+		{"weekly monday before the hour", models.ReportCadenceWeekly, "", time.Date(2025, 3, 10, 7, 59, 0, 0, time.UTC), time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)},
+		{"weekly monday after the hour", models.ReportCadenceWeekly, "", time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC), time.Date(2025, 3, 17, 8, 0, 0, 0, time.UTC)},
+		// 3:00 UTC on Monday is still Sunday in New York
+		{"weekly in the zone's day", models.ReportCadenceWeekly, "America/New_York", time.Date(2025, 3, 10, 3, 0, 0, 0, time.UTC), time.Date(2025, 3, 10, 8, 0, 0, 0, ny)},
+		{"monthly end of month", models.ReportCadenceMonthly, "", time.Date(2025, 1, 31, 10, 0, 0, 0, time.UTC), time.Date(2025, 2, 1, 8, 0, 0, 0, time.UTC)},
+		{"monthly first before the hour", models.ReportCadenceMonthly, "", time.Date(2025, 3, 1, 7, 0, 0, 0, time.UTC), time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)},
+		{"monthly first at the hour", models.ReportCadenceMonthly, "", time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC), time.Date(2025, 4, 1, 8, 0, 0, 0, time.UTC)},
+		{"monthly over the year", models.ReportCadenceMonthly, "", time.Date(2025, 12, 15, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)},
+		{"monthly over DST", models.ReportCadenceMonthly, "America/New_York", time.Date(2025, 3, 2, 0, 0, 0, 0, ny), time.Date(2025, 4, 1, 8, 0, 0, 0, ny)},
+	}
+	for _, tc := range cases {
+		sub := models.ReportSubscription{Cadence: tc.cadence, SendHour: 8, TimeZone: tc.tz}
+		got, err := svc_subscriptions.NextRun(sub, tc.after)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("%s: NextRun = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+
+	if _, err := svc_subscriptions.NextRun(models.ReportSubscription{Cadence: "hourly"}, time.Now()); err == nil {
+		t.Error("NextRun of an unknown cadence didn't fail")
+	}
+	if _, err := svc_subscriptions.NextRun(models.ReportSubscription{Cadence: models.ReportCadenceDaily, TimeZone: "Nowhere/City"}, time.Now()); err == nil {
+		t.Error("NextRun of an unknown time zone didn't fail")
+	}
+}
+
+func TestSubscriptionReportPeriod(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	day := func(y int, m time.Month, d int, loc *time.Location) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	}
+
+	cases := []struct {
+		name       string
+		cadence    string
+		tz         string
+		runAt      time.Time
+		start, end time.Time
+	}{
+		{"daily", models.ReportCadenceDaily, "", time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC), day(2025, 3, 9, time.UTC), day(2025, 3, 9, time.UTC)},
+		{"daily on the first", models.ReportCadenceDaily, "", time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC), day(2025, 2, 28, time.UTC), day(2025, 2, 28, time.UTC)},
+		// 3:00 UTC on the 10th is the evening of the 9th in New York
+		{"daily in the zone's day", models.ReportCadenceDaily, "America/New_York", time.Date(2025, 3, 10, 3, 0, 0, 0, time.UTC), day(2025, 3, 8, ny), day(2025, 3, 8, ny)},
+		{"weekly on monday", models.ReportCadenceWeekly, "", time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC), day(2025, 3, 3, time.UTC), day(2025, 3, 9, time.UTC)},
+		{"weekly late on sunday", models.ReportCadenceWeekly, "", time.Date(2025, 3, 16, 23, 0, 0, 0, time.UTC), day(2025, 3, 3, time.UTC), day(2025, 3, 9, time.UTC)},
+		{"weekly over DST", models.ReportCadenceWeekly, "America/New_York", time.Date(2025, 3, 10, 8, 0, 0, 0, ny), day(2025, 3, 3, ny), day(2025, 3, 9, ny)},
+		{"weekly over the year", models.ReportCadenceWeekly, "", time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC), day(2024, 12, 30, time.UTC), day(2025, 1, 5, time.UTC)},
+		{"monthly", models.ReportCadenceMonthly, "", time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC), day(2025, 2, 1, time.UTC), day(2025, 2, 28, time.UTC)},
+		{"monthly leap year", models.ReportCadenceMonthly, "", time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC), day(2024, 2, 1, time.UTC), day(2024, 2, 29, time.UTC)},
+		{"monthly over the year", models.ReportCadenceMonthly, "", time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC), day(2024, 12, 1, time.UTC), day(2024, 12, 31, time.UTC)},
+		{"monthly late send", models.ReportCadenceMonthly, "", time.Date(2025, 3, 20, 8, 0, 0, 0, time.UTC), day(2025, 2, 1, time.UTC), day(2025, 2, 28, time.UTC)},
+		{"monthly over DST", models.ReportCadenceMonthly, "America/New_York", time.Date(2025, 4, 1, 8, 0, 0, 0, ny), day(2025, 3, 1, ny), day(2025, 3, 31, ny)},
+	}
+	for _, tc := range cases {
+		sub := models.ReportSubscription{Cadence: tc.cadence, TimeZone: tc.tz}
+		start, end, err := svc_subscriptions.ReportPeriod(sub, tc.runAt)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if !start.Equal(tc.start) || !end.Equal(tc.end) {
+			t.Errorf("%s: ReportPeriod = %s to %s, want %s to %s", tc.name, start, end, tc.start, tc.end)
+		}
+	}
+}
+
+func TestSubscriptionMoney(t *testing.T) {
+	cases := map[float64]string{
+		0:          "$0.00",
+		5:          "$5.00",
+		999.99:     "$999.99",
+		999.999:    "$1,000.00",
+		1234.5:     "$1,234.50",
+		100000:     "$100,000.00",
+		1234567.89: "$1,234,567.89",
+		-42.1:      "-$42.10",
+		-1234567.8: "-$1,234,567.80",
+	}
+	for v, want := range cases {
+		if got := svc_subscriptions.Money(v); got != want {
+			t.Errorf("Money(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestSubscriptionFunnelPDF(t *testing.T) {
+	breakdown := map[string]svc_jpmreport.SourceBreakdown{}
+	for i := 0; i < 80; i++ {
+		source := fmt.Sprintf("Source %02d", i)
+		breakdown[source] = svc_jpmreport.SourceBreakdown{Leads: i, AdSpend: float64(i) * 10, Revenue: float64(i) * 100}
+	}
+	data := []svc_jpmreport.ReportData{
+		{Label: "Total", CoreMetrics: svc_jpmreport.CoreMetrics{Leads: 12, Booked: 6, Sales: 3, Revenue: 4500}},
+		{Label: `Spa (Main) \ Café ✓`, SourceBreakdown: breakdown},
+	}
+
+	pdf := svc_subscriptions.FunnelPDF("JPM", "Mar 1, 2025 - Mar 31, 2025", data)
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatalf("not a PDF: %q...", pdf[:min(len(pdf), 40)])
+	}
+	for _, want := range []string{
+		"(JPM) Tj",
+		"($4,500.00) Tj",
+		// parentheses and backslashes are escaped, characters outside
+		// WinAnsi replaced
+		`(Spa \(Main\) \\ Caf` + "\xe9" + ` ?) Tj`,
+		"/Count 2",
+	} {
+		if !bytes.Contains(pdf, []byte(want)) {
+			t.Errorf("PDF lacks %q", want)
+		}
+	}
+
+	// every xref entry points at its object
+	m := regexp.MustCompile(`(?s)xref\n0 (\d+)\n0000000000 65535 f \n(.*?)trailer`).FindSubmatch(pdf)
+	if m == nil {
+		t.Fatal("PDF has no xref table")
+	}
+	count, _ := strconv.Atoi(string(m[1]))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllSubmatch(m[2], -1)
+	if len(entries) != count-1 {
+		t.Fatalf("xref has %d entries, want %d", len(entries), count-1)
+	}
+	for i, e := range entries {
+		offset, _ := strconv.Atoi(string(e[1]))
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !bytes.HasPrefix(pdf[offset:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q", i+1, pdf[offset:min(len(pdf), offset+12)])
+		}
+	}
+
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	if startxref == nil {
+		t.Fatal("PDF has no startxref")
+	}
+	if offset, _ := strconv.Atoi(string(startxref[1])); !bytes.HasPrefix(pdf[offset:], []byte("xref\n")) {
+		t.Errorf("startxref points at %q", pdf[offset:min(len(pdf), offset+8)])
+	}
+}